@@ -6,6 +6,14 @@ if errorlevel 1 (
     exit /b 1
 )
 echo.
+rem The app is embedded in the binary; a brotli copy, when the brotli
+rem tool is installed, is embedded along with it for browsers that take it.
+where brotli >nul 2>nul
+if not errorlevel 1 (
+    echo Compressing the app...
+    brotli --best --force --keep frontend\index.html
+)
+echo.
 echo Building server...
 go build -o workout-tracker.exe ./cmd/server
 if errorlevel 1 (
@@ -1,96 +1,360 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	_ "time/tzdata" // user time zones work on hosts without a zoneinfo database
+	"workout-tracker/frontend"
+	"workout-tracker/internal/auth"
+	"workout-tracker/internal/config"
 	"workout-tracker/internal/database"
 	"workout-tracker/internal/handlers"
+	"workout-tracker/internal/live"
+	"workout-tracker/internal/logging"
+	"workout-tracker/internal/metrics"
 	"workout-tracker/internal/middleware"
 	"workout-tracker/internal/seeder"
+	"workout-tracker/internal/static"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
-	} else {
-		log.Println("Loaded config from .env file")
+	// Settings come from the defaults, the file named by CONFIG_FILE, .env
+	// and the environment, in increasing precedence; see internal/config.
+	cfg, sources, err := config.Load(os.LookupEnv, ".env")
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
 	}
+	// Logs are JSON lines on stdout.
+	slog.SetDefault(logging.New(os.Stdout, cfg.LogLevel))
+	slog.Info("loaded configuration", "sources", sources, "config", cfg)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "workout_tracker.db"
+	dsn := cfg.DatabaseURL
+	if dsn == "" {
+		dsn = cfg.DBPath
 	}
-
-	db, err := database.New(dbPath)
+	db, err := database.New(dsn)
 	if err != nil {
-		log.Fatal("Failed to open database:", err)
+		fatal("failed to open database", "error", err)
 	}
 	if err := db.Migrate(); err != nil {
-		log.Fatal("Migration failed:", err)
+		fatal("migration failed", "error", err)
 	}
 	if err := seeder.SeedExercises(db); err != nil {
-		log.Fatal("Seeding failed:", err)
+		fatal("seeding failed", "error", err)
 	}
+	// Every store call is timed for the metrics.
+	store := database.Instrument(db, metrics.ObserveQuery)
+	tokens := auth.NewTokens(cfg.Auth.JWTSecret, cfg.Auth.TokenTTL)
 
-	r := gin.Default()
+	// Background jobs run until shutdown, which waits for a running one to
+	// finish before closing the database.
+	jobs, stopJobs := context.WithCancel(context.Background())
+	var jobsDone sync.WaitGroup
+	background := func(interval time.Duration, what string, purge func() (int64, error)) {
+		jobsDone.Add(1)
+		go func() {
+			defer jobsDone.Done()
+			purgeEvery(jobs, interval, what, purge)
+		}()
+	}
+	background(time.Hour, "expired idempotency keys", func() (int64, error) {
+		return store.PurgeIdempotencyKeys(time.Now().Add(-cfg.IdempotencyTTL))
+	})
+	background(time.Hour, "workouts from the trash", func() (int64, error) {
+		return store.PurgeTrash(time.Now().AddDate(0, 0, -cfg.TrashRetentionDays))
+	})
+	background(time.Hour, "sync tombstones", func() (int64, error) {
+		return store.PurgeTombstones(time.Now().AddDate(0, 0, -cfg.TombstoneRetentionDays))
+	})
 
-	r.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+	// Exports and event streams lift the write timeout.
+	newServer := func(addr string, h http.Handler) *http.Server {
+		return &http.Server{
+			Addr:              addr,
+			Handler:           h,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
 		}
-		c.Next()
-	})
+	}
+
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("invalid TRUSTED_PROXIES", "error", err)
+	}
+	r.Use(middleware.RequestID(), middleware.Logger(), middleware.Recovery(), middleware.Errors())
 
-	r.Static("/app", "./frontend")
+	// Browsers may call the API from the configured origins, but only
+	// those listed by name may read the config, which holds the Groq key.
+	cors := middleware.CORSPolicy{
+		Origins:          cfg.CORS.Origins,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}
+	r.Use(
+		middleware.SecurityHeaders(cfg.CORS.HSTSMaxAge),
+		middleware.CORS(cors, middleware.CORSRoute{Path: "/api/config", Policy: cors.Explicit()}),
+	)
+
+	// The app is built into the binary; FRONTEND_DIR serves it from disk
+	// instead while working on it.
+	var site *static.Site
+	if cfg.FrontendDir != "" {
+		site, err = static.New(os.DirFS(cfg.FrontendDir), time.Now(), true)
+	} else {
+		site, err = static.New(frontend.Files, buildTime(), false)
+	}
+	if err != nil {
+		fatal("loading the app failed", "error", err)
+	}
+	r.GET("/app/*path", site.Serve)
+	r.HEAD("/app/*path", site.Serve)
 	r.GET("/", func(c *gin.Context) {
 		c.Redirect(http.StatusMovedPermanently, "/app")
 	})
 
-	authH := handlers.NewAuthHandler(db)
-	exerciseH := handlers.NewExerciseHandler(db)
-	workoutH := handlers.NewWorkoutHandler(db)
-
-	// Config endpoint - serves Groq key to authenticated users only
-	r.GET("/api/config", middleware.AuthRequired(), func(c *gin.Context) {
-		groqKey := os.Getenv("GROQ_API_KEY")
-		c.JSON(http.StatusOK, gin.H{
-			"groq_key_set": groqKey != "",
-			"groq_key":     groqKey,
-		})
-	})
+	authH := handlers.NewAuthHandler(store, tokens)
+	exerciseH := handlers.NewExerciseHandler(store)
+	workoutH := handlers.NewWorkoutHandler(store)
+	activityH := handlers.NewActivityHandler(store)
+	importH := handlers.NewImportHandler(store)
+	exportH := handlers.NewExportHandler(store)
+	hub := live.NewHub()
+	sessionH := handlers.NewSessionHandler(store, hub)
+	syncH := handlers.NewSyncHandler(store)
+	auditH := handlers.NewAuditHandler(store)
+	socialH := handlers.NewSocialHandler(store)
+	coachingH := handlers.NewCoachingHandler(store)
+	teamH := handlers.NewTeamHandler(store)
+	goalH := handlers.NewGoalHandler(store)
+	healthH := handlers.NewHealthHandler(store)
+	aiH := handlers.NewAIHandler(cfg.AI)
+	if err := sessionH.Resume(); err != nil {
+		fatal("resuming sessions failed", "error", err)
+	}
+
+	authed := middleware.AuthRequired(tokens)
+	idempotent := middleware.Idempotency(store, cfg.IdempotencyTTL)
+	limited := middleware.RateLimit(cfg.RateLimit.Requests, cfg.RateLimit.Burst)
+	authLimited := middleware.RateLimit(cfg.RateLimit.AuthRequests, cfg.RateLimit.AuthBurst)
+	// Probes and metrics are not rate limited; the rest of the API is.
+	api := r.Group("", limited)
+
+	// The app's AI settings, including the Groq key, for signed-in users
+	// only.
+	api.GET("/api/config", authed, aiH.Config)
+	api.POST("/api/ai/generations", authed, aiH.RecordGeneration)
+
+	// Prometheus metrics, on their own address when one is configured.
+	metricsH := []gin.HandlerFunc{middleware.MetricsToken(cfg.Metrics.Token), gin.WrapH(metrics.Default.Handler())}
+	servers := []*http.Server{newServer(":"+cfg.Port, r)}
+	if cfg.Metrics.Addr != "" {
+		mr := gin.New()
+		mr.Use(middleware.Recovery(), middleware.Errors())
+		mr.GET("/metrics", metricsH...)
+		servers = append(servers, newServer(cfg.Metrics.Addr, mr))
+	} else {
+		r.GET("/metrics", metricsH...)
+	}
 
-	auth := r.Group("/auth")
+	// Liveness and readiness probes.
+	r.GET("/healthz", healthH.Live)
+	r.GET("/readyz", healthH.Ready)
+
+	authG := api.Group("/auth")
 	{
-		auth.POST("/register", authH.Register)
-		auth.POST("/login", authH.Login)
-		auth.GET("/me", middleware.AuthRequired(), authH.Me)
+		authG.POST("/register", authLimited, authH.Register)
+		authG.POST("/login", authLimited, authH.Login)
+		authG.GET("/me", authed, authH.Me)
+		authG.PATCH("/me", authed, authH.UpdateMe)
 	}
 
-	r.GET("/exercises", middleware.AuthRequired(), exerciseH.List)
+	api.GET("/exercises", authed, exerciseH.List)
 
-	workouts := r.Group("/workouts", middleware.AuthRequired())
+	workouts := api.Group("/workouts", authed, idempotent)
 	{
 		workouts.POST("", workoutH.Create)
 		workouts.GET("", workoutH.List)
 		workouts.GET("/report", workoutH.Report)
+		workouts.GET("/trash", workoutH.Trash)
 		workouts.GET("/:id", workoutH.Get)
 		workouts.PUT("/:id", workoutH.Update)
+		workouts.PATCH("/:id", workoutH.Patch)
 		workouts.DELETE("/:id", workoutH.Delete)
+		workouts.POST("/:id/restore", workoutH.Restore)
+		workouts.POST("/:id/session", sessionH.Start)
+		workouts.GET("/:id/session", sessionH.Get)
+		workouts.POST("/:id/session/sets", sessionH.LogSet)
+		workouts.POST("/:id/session/finish", sessionH.Finish)
+		workouts.POST("/:id/share", socialH.Share)
+		workouts.DELETE("/:id/share", socialH.Unshare)
+	}
+	// The one route that takes the token from the query string.
+	api.GET("/workouts/:id/session/events", middleware.StreamAuthRequired(tokens), sessionH.Events)
+
+	api.POST("/users/:id/follow", authed, idempotent, socialH.Follow)
+	api.DELETE("/users/:id/follow", authed, idempotent, socialH.Unfollow)
+	api.GET("/following", authed, socialH.Following)
+	api.GET("/followers", authed, socialH.Followers)
+
+	feed := api.Group("/feed", authed, idempotent)
+	{
+		feed.GET("", socialH.Feed)
+		feed.GET("/:id", socialH.Item)
+		feed.POST("/:id/reactions", socialH.React)
+		feed.DELETE("/:id/reactions/:kind", socialH.Unreact)
+		feed.GET("/:id/comments", socialH.Comments)
+		feed.POST("/:id/comments", socialH.AddComment)
+		feed.DELETE("/:id/comments/:comment_id", socialH.DeleteComment)
 	}
 
-	log.Printf("Workout Tracker running on http://localhost:%s\n", port)
-	r.Run(":" + port)
+	// Coaches work on the accounts of athletes who accepted their invite,
+	// within the scopes granted; athletes manage that access.
+	athletes := api.Group("/athletes", authed, idempotent)
+	{
+		athletes.POST("", coachingH.Invite)
+		athletes.GET("", coachingH.Athletes)
+		athletes.DELETE("/:id", coachingH.Drop)
+		athletes.GET("/:id/workouts", coachingH.Workouts)
+		athletes.POST("/:id/workouts", coachingH.Assign)
+		athletes.GET("/:id/workouts/:workout_id", coachingH.Workout)
+		athletes.PUT("/:id/workouts/:workout_id", coachingH.Update)
+		athletes.GET("/:id/report", coachingH.Report)
+	}
+	coaches := api.Group("/coaches", authed, idempotent)
+	{
+		coaches.GET("", coachingH.Coaches)
+		coaches.POST("/:id/accept", coachingH.Accept)
+		coaches.PUT("/:id/scopes", coachingH.SetScopes)
+		coaches.DELETE("/:id", coachingH.Revoke)
+	}
+
+	// Members of a team share its leaderboard and challenges.
+	teams := api.Group("/teams", authed, idempotent)
+	{
+		teams.POST("", teamH.Create)
+		teams.GET("", teamH.List)
+		teams.POST("/join", teamH.Join)
+		teams.GET("/:id", teamH.Get)
+		teams.DELETE("/:id", teamH.Delete)
+		teams.DELETE("/:id/members/:user_id", teamH.RemoveMember)
+		teams.GET("/:id/leaderboard", teamH.Leaderboard)
+		teams.POST("/:id/challenges", teamH.CreateChallenge)
+		teams.GET("/:id/challenges", teamH.ListChallenges)
+		teams.GET("/:id/challenges/:challenge_id", teamH.GetChallenge)
+		teams.DELETE("/:id/challenges/:challenge_id", teamH.DeleteChallenge)
+	}
+
+	// Goals track progress on read; achievements are awarded as workouts
+	// complete.
+	goals := api.Group("/goals", authed, idempotent)
+	{
+		goals.POST("", goalH.Create)
+		goals.GET("", goalH.List)
+		goals.GET("/:id", goalH.Get)
+		goals.DELETE("/:id", goalH.Delete)
+	}
+	bodyWeight := api.Group("/bodyweight", authed, idempotent)
+	{
+		bodyWeight.POST("", goalH.LogBodyWeight)
+		bodyWeight.GET("", goalH.BodyWeights)
+		bodyWeight.DELETE("/:id", goalH.DeleteBodyWeight)
+	}
+	api.GET("/achievements", authed, goalH.Achievements)
+
+	// Share links are public: whoever has one may read the summary.
+	api.GET("/share/:token", socialH.Shared)
+
+	api.POST("/import", authed, idempotent, importH.Import)
+	api.GET("/export", authed, exportH.Export)
+	api.POST("/import/activity", authed, idempotent, activityH.Import)
+	api.GET("/sync", authed, syncH.Pull)
+	api.POST("/sync", authed, idempotent, syncH.Push)
+	api.GET("/audit", authed, auditH.List)
+
+	admin := api.Group("/admin", authed, middleware.AdminRequired(cfg.Auth.AdminEmails))
+	{
+		admin.GET("/audit/export", auditH.Export)
+	}
+
+	// Event streams never finish on their own, so shutting down ends them.
+	servers[0].RegisterOnShutdown(hub.Stop)
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				fatal("server failed", "addr", srv.Addr, "error", err)
+			}
+		}(srv)
+	}
+	slog.Info("workout tracker running", "url", "http://localhost:"+cfg.Port)
+
+	// SIGTERM, as sent on a deploy, or Ctrl-C stops taking requests and
+	// lets the ones in flight finish. A second signal exits at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	slog.Info("shutting down", "timeout", cfg.ShutdownTimeout.String())
+	healthH.Drain()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("requests still running at shutdown", "addr", srv.Addr, "error", err)
+		}
+	}
+	stopJobs()
+	jobsDone.Wait()
+	if err := db.Close(); err != nil {
+		slog.Error("closing database", "error", err)
+	}
+	slog.Info("stopped")
+}
+
+// fatal logs an error that keeps the server from running and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// buildTime is when the binary was built, as far as its file tells: the
+// Last-Modified of the embedded app. It falls back to now.
+func buildTime() time.Time {
+	if exe, err := os.Executable(); err == nil {
+		if info, err := os.Stat(exe); err == nil {
+			return info.ModTime()
+		}
+	}
+	return time.Now()
+}
+
+// purgeEvery runs a cleanup job at each interval until ctx is done, logging
+// what it removed.
+func purgeEvery(ctx context.Context, interval time.Duration, what string, purge func() (int64, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := purge()
+		if err != nil {
+			slog.Error("purge failed", "what", what, "error", err)
+		} else if n > 0 {
+			slog.Info("purged", "what", what, "count", n)
+		}
+	}
 }
@@ -0,0 +1,12 @@
+// Package frontend embeds the web app, so the server binary serves it from
+// wherever it is started. Precompressed siblings such as index.html.br,
+// when a build produced them, are embedded along with it.
+package frontend
+
+import "embed"
+
+// Files are the app's files. They include this source file, which the
+// server leaves out.
+//
+//go:embed *
+var Files embed.FS
@@ -0,0 +1,89 @@
+// Package achievements holds the rules that award badges and the streak
+// arithmetic they build on. It does no I/O: the store gathers Stats when a
+// workout completes and keeps the badges Earned returns.
+package achievements
+
+import "time"
+
+// Stats is what the rules look at: a user's completed workouts so far.
+type Stats struct {
+	Workouts         int
+	LongestStreak    int // days, as Streaks counts them
+	TotalVolumeKg    float64
+	CardioDistanceKm float64
+	BestLiftKg       map[string]float64 // heaviest weight by built-in exercise name
+}
+
+// Badge is an achievement and the rule that awards it.
+type Badge struct {
+	Code        string
+	Name        string
+	Description string
+	earned      func(Stats) bool
+}
+
+func workouts(n int) func(Stats) bool {
+	return func(s Stats) bool { return s.Workouts >= n }
+}
+
+func streak(days int) func(Stats) bool {
+	return func(s Stats) bool { return s.LongestStreak >= days }
+}
+
+func lift(exercise string, kg float64) func(Stats) bool {
+	return func(s Stats) bool { return s.BestLiftKg[exercise] >= kg }
+}
+
+// Badges lists every badge in the order they are shown. Codes are stored,
+// so a badge is never renamed, only added.
+var Badges = []Badge{
+	{"first_workout", "First workout", "Complete a workout", workouts(1)},
+	{"workouts_10", "Ten workouts", "Complete 10 workouts", workouts(10)},
+	{"workouts_100", "Century", "Complete 100 workouts", workouts(100)},
+	{"streak_7", "One-week streak", "Keep a streak going for 7 days", streak(7)},
+	{"streak_30", "30-day streak", "Keep a streak going for 30 days", streak(30)},
+	{"bench_100", "100 kg bench", "Bench press 100 kg", lift("Bench Press", 100)},
+	{"squat_140", "140 kg squat", "Squat 140 kg", lift("Squat", 140)},
+	{"deadlift_180", "180 kg deadlift", "Deadlift 180 kg", lift("Deadlift", 180)},
+	{"volume_100t", "100 tonnes", "Lift 100,000 kg in total", func(s Stats) bool { return s.TotalVolumeKg >= 100000 }},
+	{"distance_100k", "100 km", "Cover 100 km of cardio", func(s Stats) bool { return s.CardioDistanceKm >= 100 }},
+}
+
+// Earned returns the badges whose rules s meets, in the order of Badges.
+func Earned(s Stats) []Badge {
+	var earned []Badge
+	for _, b := range Badges {
+		if b.earned(s) {
+			earned = append(earned, b)
+		}
+	}
+	return earned
+}
+
+// Day numbers t's calendar day in loc from 1 January 1970, so that
+// consecutive days differ by one across daylight saving changes.
+func Day(t time.Time, loc *time.Location) int {
+	y, m, d := t.In(loc).Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// Streaks measures runs of training days. days are the Day numbers with a
+// completed workout, ascending and without repeats. A run survives up to
+// restDays days in a row without a workout and lasts from its first
+// workout day to its last. The current run is still alive today: its last
+// workout was at most restDays+1 days ago, so today can still extend it.
+func Streaks(days []int, today, restDays int) (current, longest int) {
+	start := 0
+	for i, d := range days {
+		if i > 0 && d-days[i-1] > restDays+1 {
+			start = i
+		}
+		if n := d - days[start] + 1; n > longest {
+			longest = n
+		}
+	}
+	if n := len(days); n > 0 && today-days[n-1] <= restDays+1 {
+		current = days[n-1] - days[start] + 1
+	}
+	return current, longest
+}
@@ -0,0 +1,68 @@
+package achievements
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreaks(t *testing.T) {
+	tests := []struct {
+		name             string
+		days             []int
+		today, restDays  int
+		current, longest int
+	}{
+		{name: "no workouts", today: 10},
+		{name: "every day up to today", days: []int{8, 9, 10}, today: 10, current: 3, longest: 3},
+		{name: "yesterday keeps it alive", days: []int{8, 9}, today: 10, current: 2, longest: 2},
+		{name: "a missed day ends it", days: []int{7, 8}, today: 10, longest: 2},
+		{name: "rest days count toward the run", days: []int{1, 3, 5, 6}, today: 7, restDays: 1, current: 6, longest: 6},
+		{name: "too much rest splits runs", days: []int{1, 2, 3, 4, 8, 10}, today: 11, restDays: 1, current: 3, longest: 4},
+		{name: "rest after the last workout", days: []int{5, 6}, today: 9, restDays: 2, current: 2, longest: 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			current, longest := Streaks(tc.days, tc.today, tc.restDays)
+			if current != tc.current || longest != tc.longest {
+				t.Errorf("Streaks = %d, %d; want %d, %d", current, longest, tc.current, tc.longest)
+			}
+		})
+	}
+}
+
+func TestDay(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	// 23:30 UTC is already the next day in Berlin.
+	late := time.Date(2026, 3, 28, 23, 30, 0, 0, time.UTC)
+	if Day(late, berlin) != Day(late, time.UTC)+1 {
+		t.Errorf("Day in Berlin = %d, in UTC %d", Day(late, berlin), Day(late, time.UTC))
+	}
+	// The night clocks go forward is still one day.
+	before, after := time.Date(2026, 3, 28, 12, 0, 0, 0, berlin), time.Date(2026, 3, 29, 12, 0, 0, 0, berlin)
+	if Day(after, berlin)-Day(before, berlin) != 1 {
+		t.Errorf("days across DST = %d, %d", Day(before, berlin), Day(after, berlin))
+	}
+}
+
+func TestEarned(t *testing.T) {
+	earned := Earned(Stats{Workouts: 12, LongestStreak: 7, BestLiftKg: map[string]float64{"Bench Press": 100, "Squat": 139.5}})
+	var codes []string
+	for _, b := range earned {
+		codes = append(codes, b.Code)
+	}
+	want := []string{"first_workout", "workouts_10", "streak_7", "bench_100"}
+	if len(codes) != len(want) {
+		t.Fatalf("earned %v, want %v", codes, want)
+	}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("earned %v, want %v", codes, want)
+		}
+	}
+	if len(Earned(Stats{})) != 0 {
+		t.Error("badges for no workouts")
+	}
+}
@@ -0,0 +1,163 @@
+// Package activity parses recorded cardio activities (GPX, TCX and FIT
+// files) into a track summary that can be stored as a completed workout.
+package activity
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Point is one recorded sample of a track. Zero values mean the device did
+// not record that field.
+type Point struct {
+	Time      time.Time
+	Lat, Lon  float64
+	HasPos    bool
+	Elevation float64
+	HasEle    bool
+	HeartRate int
+	DistanceM float64 // cumulative, when the device records it
+}
+
+// Summary is what gets stored on the imported workout.
+type Summary struct {
+	Name           string
+	Sport          string // running, cycling, or "" when unknown
+	StartTime      time.Time
+	EndTime        time.Time
+	DurationSec    int
+	DistanceM      float64
+	ElevationGainM float64
+	AvgHeartRate   int
+	MaxHeartRate   int
+	Calories       int
+	Points         int
+}
+
+var ErrUnknownFormat = errors.New("unrecognised activity file, expected GPX, TCX or FIT")
+
+// Parse detects the file format from its content and parses it.
+func Parse(r io.Reader) (*Summary, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch DetectFormat(data) {
+	case "fit":
+		return ParseFIT(bytes.NewReader(data))
+	case "gpx":
+		return ParseGPX(bytes.NewReader(data))
+	case "tcx":
+		return ParseTCX(bytes.NewReader(data))
+	}
+	return nil, ErrUnknownFormat
+}
+
+// DetectFormat returns "fit", "gpx", "tcx" or "".
+func DetectFormat(data []byte) string {
+	if len(data) >= 12 && string(data[8:12]) == ".FIT" {
+		return "fit"
+	}
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	s := string(head)
+	switch {
+	case strings.Contains(s, "<gpx"):
+		return "gpx"
+	case strings.Contains(s, "<TrainingCenterDatabase"):
+		return "tcx"
+	}
+	return ""
+}
+
+// summarize fills in the derived fields of a summary from its points.
+// Values the file states explicitly (e.g. a lap's total distance) win over
+// values computed from the points.
+func summarize(s *Summary, points []Point) {
+	s.Points = len(points)
+	if len(points) == 0 {
+		return
+	}
+
+	var computedDist, gain float64
+	var hrSum, hrCount int
+	var lastPos, lastEle *Point
+	var deviceDist float64
+	for i := range points {
+		p := &points[i]
+		if s.StartTime.IsZero() || (!p.Time.IsZero() && p.Time.Before(s.StartTime)) {
+			s.StartTime = p.Time
+		}
+		if p.Time.After(s.EndTime) {
+			s.EndTime = p.Time
+		}
+		if p.HasPos {
+			if lastPos != nil {
+				computedDist += haversine(lastPos.Lat, lastPos.Lon, p.Lat, p.Lon)
+			}
+			lastPos = p
+		}
+		if p.HasEle {
+			if lastEle != nil && p.Elevation > lastEle.Elevation {
+				gain += p.Elevation - lastEle.Elevation
+			}
+			lastEle = p
+		}
+		if p.HeartRate > 0 {
+			hrSum += p.HeartRate
+			hrCount++
+			if p.HeartRate > s.MaxHeartRate {
+				s.MaxHeartRate = p.HeartRate
+			}
+		}
+		if p.DistanceM > deviceDist {
+			deviceDist = p.DistanceM
+		}
+	}
+
+	if s.DistanceM == 0 {
+		s.DistanceM = deviceDist
+		if s.DistanceM == 0 {
+			s.DistanceM = computedDist
+		}
+	}
+	if s.ElevationGainM == 0 {
+		s.ElevationGainM = gain
+	}
+	if s.AvgHeartRate == 0 && hrCount > 0 {
+		s.AvgHeartRate = int(math.Round(float64(hrSum) / float64(hrCount)))
+	}
+	if s.DurationSec == 0 && !s.StartTime.IsZero() {
+		s.DurationSec = int(s.EndTime.Sub(s.StartTime).Seconds())
+	}
+}
+
+const earthRadiusM = 6371000
+
+// haversine returns the great-circle distance in metres.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(a))
+}
+
+// normalizeSport maps the sport names used by the different formats onto
+// the ones the exercise library knows about.
+func normalizeSport(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "running", "run", "trail_running", "treadmill":
+		return "running"
+	case "biking", "cycling", "bike", "ride", "road_biking", "mountain_biking":
+		return "cycling"
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package activity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+     xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+  <metadata><name>Morning Run</name></metadata>
+  <trk>
+    <type>running</type>
+    <trkseg>
+      <trkpt lat="52.0000" lon="13.0000"><ele>30</ele><time>2024-05-01T07:00:00Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>140</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+      <trkpt lat="52.0090" lon="13.0000"><ele>35</ele><time>2024-05-01T07:05:00Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>160</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+      <trkpt lat="52.0180" lon="13.0000"><ele>32</ele><time>2024-05-01T07:10:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestParseGPX(t *testing.T) {
+	s, err := Parse(strings.NewReader(sampleGPX))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "Morning Run" || s.Sport != "running" {
+		t.Errorf("name/sport = %q/%q", s.Name, s.Sport)
+	}
+	if s.DurationSec != 600 {
+		t.Errorf("duration = %d, want 600", s.DurationSec)
+	}
+	// 0.018 degrees of latitude is about 2001 m.
+	if math.Abs(s.DistanceM-2001) > 5 {
+		t.Errorf("distance = %.1f, want ~2001", s.DistanceM)
+	}
+	if s.ElevationGainM != 5 {
+		t.Errorf("elevation gain = %.1f, want 5", s.ElevationGainM)
+	}
+	if s.AvgHeartRate != 150 || s.MaxHeartRate != 160 {
+		t.Errorf("hr avg/max = %d/%d, want 150/160", s.AvgHeartRate, s.MaxHeartRate)
+	}
+}
+
+const sampleTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Activities>
+    <Activity Sport="Biking">
+      <Id>2024-05-02T18:00:00Z</Id>
+      <Lap StartTime="2024-05-02T18:00:00Z">
+        <TotalTimeSeconds>1800</TotalTimeSeconds>
+        <DistanceMeters>12000</DistanceMeters>
+        <Calories>420</Calories>
+        <Track>
+          <Trackpoint><Time>2024-05-02T18:00:00Z</Time><AltitudeMeters>100</AltitudeMeters><HeartRateBpm><Value>120</Value></HeartRateBpm></Trackpoint>
+          <Trackpoint><Time>2024-05-02T18:30:00Z</Time><AltitudeMeters>140</AltitudeMeters><HeartRateBpm><Value>150</Value></HeartRateBpm></Trackpoint>
+        </Track>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`
+
+func TestParseTCX(t *testing.T) {
+	s, err := Parse(strings.NewReader(sampleTCX))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Sport != "cycling" || s.DurationSec != 1800 || s.DistanceM != 12000 || s.Calories != 420 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if s.ElevationGainM != 40 || s.AvgHeartRate != 135 || s.MaxHeartRate != 150 {
+		t.Errorf("unexpected elevation/hr %+v", s)
+	}
+}
+
+func TestParseFIT(t *testing.T) {
+	var body bytes.Buffer
+	le := binary.LittleEndian
+
+	// Definition for local type 0: record with timestamp, heart rate, distance.
+	body.Write([]byte{0x40, 0, 0})
+	binary.Write(&body, le, uint16(fitMsgRecord))
+	body.Write([]byte{3, fitFieldTimestamp, 4, 0x86, fitRecordHeartRate, 1, 0x02, fitRecordDistance, 4, 0x86})
+
+	start := time.Date(2024, 5, 3, 6, 0, 0, 0, time.UTC)
+	base := uint32(start.Sub(fitEpoch).Seconds())
+	for i, hr := range []byte{130, 150, 170} {
+		body.WriteByte(0x00)
+		binary.Write(&body, le, base+uint32(i*300))
+		body.WriteByte(hr)
+		binary.Write(&body, le, uint32(i*100000)) // 1000 m per sample, in cm
+	}
+
+	// Definition for local type 1: session with sport and calories.
+	body.Write([]byte{0x41, 0, 0})
+	binary.Write(&body, le, uint16(fitMsgSession))
+	body.Write([]byte{2, fitSessionSport, 1, 0x00, fitSessionCalories, 2, 0x84})
+	body.Write([]byte{0x01, 1})
+	binary.Write(&body, le, uint16(310))
+
+	var file bytes.Buffer
+	file.Write([]byte{12, 0x20})
+	binary.Write(&file, le, uint16(2132))
+	binary.Write(&file, le, uint32(body.Len()))
+	file.WriteString(".FIT")
+	file.Write(body.Bytes())
+	file.Write([]byte{0, 0}) // CRC, not checked
+
+	s, err := Parse(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.StartTime.Equal(start) || s.DurationSec != 600 {
+		t.Errorf("start/duration = %v/%d", s.StartTime, s.DurationSec)
+	}
+	if s.Sport != "running" || s.Calories != 310 || s.DistanceM != 2000 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if s.AvgHeartRate != 150 || s.MaxHeartRate != 170 {
+		t.Errorf("hr avg/max = %d/%d", s.AvgHeartRate, s.MaxHeartRate)
+	}
+}
+
+func TestParseUnknown(t *testing.T) {
+	if _, err := Parse(strings.NewReader("date,reps\n")); err != ErrUnknownFormat {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+}
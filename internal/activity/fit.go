@@ -0,0 +1,245 @@
+package activity
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// FIT global message numbers and field numbers used by the importer. See
+// the FIT SDK profile for the full list.
+const (
+	fitMsgSession = 18
+	fitMsgRecord  = 20
+
+	fitFieldTimestamp = 253
+
+	fitRecordLat              = 0
+	fitRecordLon              = 1
+	fitRecordAltitude         = 2
+	fitRecordHeartRate        = 3
+	fitRecordDistance         = 5
+	fitRecordEnhancedAltitude = 78
+
+	fitSessionSport        = 5
+	fitSessionElapsedTime  = 7
+	fitSessionDistance     = 9
+	fitSessionCalories     = 11
+	fitSessionAvgHeartRate = 16
+	fitSessionMaxHeartRate = 17
+	fitSessionTotalAscent  = 22
+)
+
+// fitEpoch is the FIT timestamp origin, 1989-12-31T00:00:00Z.
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+type fitField struct {
+	num, size byte
+}
+
+type fitDefinition struct {
+	global    uint16
+	order     binary.ByteOrder
+	fields    []fitField
+	devFields int // total size in bytes of developer fields
+}
+
+// ParseFIT decodes the record and session messages of a FIT activity file.
+// Other messages are skipped. The trailing CRC is not verified.
+func ParseFIT(r io.Reader) (*Summary, error) {
+	br := bufio.NewReader(r)
+	hdr := make([]byte, 12)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr[8:12]) != ".FIT" {
+		return nil, ErrUnknownFormat
+	}
+	if extra := int(hdr[0]) - 12; extra > 0 {
+		if _, err := br.Discard(extra); err != nil {
+			return nil, err
+		}
+	}
+	remaining := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+	data := &io.LimitedReader{R: br, N: remaining}
+
+	s := &Summary{}
+	var points []Point
+	defs := map[byte]*fitDefinition{}
+	var lastTimestamp uint32
+
+	for data.N > 0 {
+		var b [1]byte
+		if _, err := io.ReadFull(data, b[:]); err != nil {
+			return nil, err
+		}
+		header := b[0]
+
+		var local byte
+		compressedOffset := -1
+		switch {
+		case header&0x80 != 0: // compressed timestamp data message
+			local = (header >> 5) & 0x03
+			compressedOffset = int(header & 0x1F)
+		case header&0x40 != 0: // definition message
+			def, err := readFITDefinition(data, header&0x20 != 0)
+			if err != nil {
+				return nil, err
+			}
+			defs[header&0x0F] = def
+			continue
+		default:
+			local = header & 0x0F
+		}
+
+		def, ok := defs[local]
+		if !ok {
+			return nil, fmt.Errorf("fit: data message for undefined local type %d", local)
+		}
+		values := map[byte]uint64{}
+		for _, f := range def.fields {
+			buf := make([]byte, f.size)
+			if _, err := io.ReadFull(data, buf); err != nil {
+				return nil, err
+			}
+			if v, ok := fitValue(buf, def.order); ok {
+				values[f.num] = v
+			}
+		}
+		if def.devFields > 0 {
+			if _, err := io.CopyN(io.Discard, data, int64(def.devFields)); err != nil {
+				return nil, err
+			}
+		}
+
+		if ts, ok := values[fitFieldTimestamp]; ok {
+			lastTimestamp = uint32(ts)
+		} else if compressedOffset >= 0 {
+			ts := lastTimestamp&^0x1F | uint32(compressedOffset)
+			if uint32(compressedOffset) < lastTimestamp&0x1F {
+				ts += 0x20
+			}
+			lastTimestamp = ts
+			values[fitFieldTimestamp] = uint64(ts)
+		}
+
+		switch def.global {
+		case fitMsgRecord:
+			points = append(points, fitRecordPoint(values))
+		case fitMsgSession:
+			applyFITSession(s, values)
+		}
+	}
+
+	summarize(s, points)
+	return s, nil
+}
+
+func readFITDefinition(r io.Reader, hasDevFields bool) (*fitDefinition, error) {
+	fixed := make([]byte, 5)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+	def := &fitDefinition{order: binary.LittleEndian}
+	if fixed[1] == 1 {
+		def.order = binary.BigEndian
+	}
+	def.global = def.order.Uint16(fixed[2:4])
+	fields := make([]byte, int(fixed[4])*3)
+	if _, err := io.ReadFull(r, fields); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(fields); i += 3 {
+		def.fields = append(def.fields, fitField{num: fields[i], size: fields[i+1]})
+	}
+	if hasDevFields {
+		var n [1]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return nil, err
+		}
+		dev := make([]byte, int(n[0])*3)
+		if _, err := io.ReadFull(r, dev); err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(dev); i += 3 {
+			def.devFields += int(dev[i+1])
+		}
+	}
+	return def, nil
+}
+
+// fitValue decodes an unsigned field of 1, 2 or 4 bytes. All-ones values
+// are FIT's "invalid" marker and are reported as missing.
+func fitValue(buf []byte, order binary.ByteOrder) (uint64, bool) {
+	switch len(buf) {
+	case 1:
+		return uint64(buf[0]), buf[0] != 0xFF
+	case 2:
+		v := order.Uint16(buf)
+		return uint64(v), v != 0xFFFF
+	case 4:
+		v := order.Uint32(buf)
+		return uint64(v), v != 0xFFFFFFFF && v != 0x7FFFFFFF
+	}
+	return 0, false
+}
+
+func semicircles(v uint64) float64 {
+	return float64(int32(uint32(v))) * (180 / math.Pow(2, 31))
+}
+
+func fitRecordPoint(values map[byte]uint64) Point {
+	var p Point
+	if ts, ok := values[fitFieldTimestamp]; ok {
+		p.Time = fitEpoch.Add(time.Duration(ts) * time.Second)
+	}
+	lat, okLat := values[fitRecordLat]
+	lon, okLon := values[fitRecordLon]
+	if okLat && okLon {
+		p.Lat, p.Lon, p.HasPos = semicircles(lat), semicircles(lon), true
+	}
+	if alt, ok := values[fitRecordEnhancedAltitude]; ok {
+		p.Elevation, p.HasEle = float64(alt)/5-500, true
+	} else if alt, ok := values[fitRecordAltitude]; ok {
+		p.Elevation, p.HasEle = float64(alt)/5-500, true
+	}
+	if hr, ok := values[fitRecordHeartRate]; ok {
+		p.HeartRate = int(hr)
+	}
+	if d, ok := values[fitRecordDistance]; ok {
+		p.DistanceM = float64(d) / 100
+	}
+	return p
+}
+
+func applyFITSession(s *Summary, values map[byte]uint64) {
+	if v, ok := values[fitSessionSport]; ok {
+		switch v {
+		case 1:
+			s.Sport = "running"
+		case 2:
+			s.Sport = "cycling"
+		}
+	}
+	if v, ok := values[fitSessionElapsedTime]; ok {
+		s.DurationSec = int(v / 1000)
+	}
+	if v, ok := values[fitSessionDistance]; ok {
+		s.DistanceM = float64(v) / 100
+	}
+	if v, ok := values[fitSessionCalories]; ok {
+		s.Calories = int(v)
+	}
+	if v, ok := values[fitSessionAvgHeartRate]; ok {
+		s.AvgHeartRate = int(v)
+	}
+	if v, ok := values[fitSessionMaxHeartRate]; ok {
+		s.MaxHeartRate = int(v)
+	}
+	if v, ok := values[fitSessionTotalAscent]; ok {
+		s.ElevationGainM = float64(v)
+	}
+}
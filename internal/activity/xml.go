@@ -0,0 +1,141 @@
+package activity
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type gpxFile struct {
+	Metadata struct {
+		Name string `xml:"name"`
+	} `xml:"metadata"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Lat        float64  `xml:"lat,attr"`
+				Lon        float64  `xml:"lon,attr"`
+				Ele        *float64 `xml:"ele"`
+				Time       string   `xml:"time"`
+				Extensions struct {
+					// Garmin TrackPointExtension; the namespace prefix is
+					// ignored by encoding/xml when matching local names.
+					TPX struct {
+						HR int `xml:"hr"`
+					} `xml:"TrackPointExtension"`
+				} `xml:"extensions"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// ParseGPX parses a GPX 1.1 track, including Garmin heart rate extensions.
+func ParseGPX(r io.Reader) (*Summary, error) {
+	var f gpxFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	s := &Summary{Name: f.Metadata.Name}
+	var points []Point
+	for _, trk := range f.Tracks {
+		if s.Name == "" {
+			s.Name = trk.Name
+		}
+		if s.Sport == "" {
+			s.Sport = normalizeSport(trk.Type)
+		}
+		for _, seg := range trk.Segments {
+			for _, tp := range seg.Points {
+				p := Point{Lat: tp.Lat, Lon: tp.Lon, HasPos: true, HeartRate: tp.Extensions.TPX.HR}
+				if tp.Ele != nil {
+					p.Elevation, p.HasEle = *tp.Ele, true
+				}
+				if tp.Time != "" {
+					t, err := time.Parse(time.RFC3339, tp.Time)
+					if err != nil {
+						return nil, err
+					}
+					p.Time = t
+				}
+				points = append(points, p)
+			}
+		}
+	}
+	summarize(s, points)
+	return s, nil
+}
+
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Laps  []struct {
+			TotalTimeSeconds float64 `xml:"TotalTimeSeconds"`
+			DistanceMeters   float64 `xml:"DistanceMeters"`
+			Calories         int     `xml:"Calories"`
+			Tracks           []struct {
+				Points []struct {
+					Time     string `xml:"Time"`
+					Position *struct {
+						Lat float64 `xml:"LatitudeDegrees"`
+						Lon float64 `xml:"LongitudeDegrees"`
+					} `xml:"Position"`
+					Altitude  *float64 `xml:"AltitudeMeters"`
+					Distance  float64  `xml:"DistanceMeters"`
+					HeartRate struct {
+						Value int `xml:"Value"`
+					} `xml:"HeartRateBpm"`
+				} `xml:"Trackpoint"`
+			} `xml:"Track"`
+		} `xml:"Lap"`
+		Notes string `xml:"Notes"`
+	} `xml:"Activities>Activity"`
+}
+
+// ParseTCX parses a Garmin Training Center file. Lap totals are used for
+// duration, distance and calories when present.
+func ParseTCX(r io.Reader) (*Summary, error) {
+	var f tcxFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	s := &Summary{}
+	var points []Point
+	var lapTime float64
+	for _, act := range f.Activities {
+		if s.Sport == "" {
+			s.Sport = normalizeSport(act.Sport)
+		}
+		if s.Name == "" {
+			s.Name = act.Notes
+		}
+		for _, lap := range act.Laps {
+			lapTime += lap.TotalTimeSeconds
+			s.DistanceM += lap.DistanceMeters
+			s.Calories += lap.Calories
+			for _, trk := range lap.Tracks {
+				for _, tp := range trk.Points {
+					p := Point{HeartRate: tp.HeartRate.Value, DistanceM: tp.Distance}
+					if tp.Position != nil {
+						p.Lat, p.Lon, p.HasPos = tp.Position.Lat, tp.Position.Lon, true
+					}
+					if tp.Altitude != nil {
+						p.Elevation, p.HasEle = *tp.Altitude, true
+					}
+					if tp.Time != "" {
+						t, err := time.Parse(time.RFC3339, tp.Time)
+						if err != nil {
+							return nil, err
+						}
+						p.Time = t
+					}
+					points = append(points, p)
+				}
+			}
+		}
+	}
+	s.DurationSec = int(lapTime)
+	summarize(s, points)
+	return s, nil
+}
@@ -0,0 +1,272 @@
+// Package config holds the server's settings. They are read once at
+// startup, in increasing order of precedence, from built-in defaults, an
+// optional YAML or TOML file, a .env file and the process environment, and
+// checked before anything else starts. The rest of the server gets them
+// passed in; nothing else reads the environment.
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is every setting of the server. Each field is named by its env
+// tag in the environment and .env, and by the same name in lower case in a
+// config file. Fields tagged secret are redacted when the config is
+// logged.
+type Config struct {
+	// Port is the TCP port the API listens on.
+	Port string `env:"PORT"`
+	// DatabaseURL names a PostgreSQL server; when empty, DBPath is the
+	// SQLite file.
+	DatabaseURL string `env:"DATABASE_URL" secret:"true"`
+	DBPath      string `env:"DB_PATH"`
+
+	LogLevel slog.Level `env:"LOG_LEVEL"`
+	// FrontendDir, when set, serves the app from this directory instead
+	// of the copy built into the binary, picking up edits without a
+	// restart.
+	FrontendDir string `env:"FRONTEND_DIR"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse
+	// proxies whose X-Forwarded-For is believed for the client IP. Empty
+	// means the server is reached directly.
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	// ReadTimeout and WriteTimeout bound reading a request and writing its
+	// response; IdleTimeout closes idle keep-alive connections.
+	ReadTimeout  time.Duration `env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout time.Duration `env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `env:"HTTP_IDLE_TIMEOUT"`
+	// ShutdownTimeout is how long a shutdown waits for requests in flight.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT"`
+
+	Auth      Auth
+	CORS      CORS
+	RateLimit RateLimits
+	AI        AI
+	Metrics   Metrics
+
+	// IdempotencyTTL is how long responses to requests sent with an
+	// Idempotency-Key are kept for retries.
+	IdempotencyTTL time.Duration `env:"IDEMPOTENCY_TTL"`
+	// TrashRetentionDays is how long deleted workouts can be restored
+	// before they are purged.
+	TrashRetentionDays int `env:"TRASH_RETENTION_DAYS"`
+	// TombstoneRetentionDays is how long deletes are kept for offline
+	// clients; one that has not synced for longer must sync from scratch.
+	TombstoneRetentionDays int `env:"TOMBSTONE_RETENTION_DAYS"`
+}
+
+// Auth configures sign-in tokens and admins.
+type Auth struct {
+	// JWTSecret signs the tokens. It is required.
+	JWTSecret string `env:"JWT_SECRET" secret:"true"`
+	// TokenTTL is how long a token is valid after sign-in.
+	TokenTTL time.Duration `env:"JWT_TTL"`
+	// AdminEmails are the users who may export the whole audit log.
+	AdminEmails []string `env:"ADMIN_EMAILS"`
+}
+
+// CORS configures which web origins may call the API from a browser, and
+// the security headers sent to browsers.
+type CORS struct {
+	// Origins are the allowed origins, such as https://app.example.com;
+	// "*" allows any.
+	Origins []string `env:"CORS_ORIGINS"`
+	// AllowCredentials lets browsers send cookies and HTTP auth to the
+	// listed origins; it cannot be combined with "*".
+	AllowCredentials bool `env:"CORS_ALLOW_CREDENTIALS"`
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration `env:"CORS_MAX_AGE"`
+	// HSTSMaxAge is how long browsers should only use HTTPS for the
+	// server; zero sends no Strict-Transport-Security.
+	HSTSMaxAge time.Duration `env:"HSTS_MAX_AGE"`
+}
+
+// RateLimits bound how many requests each client IP address may send.
+type RateLimits struct {
+	// Requests per minute a client may send to the API, with bursts of up
+	// to Burst. Zero turns the limit off.
+	Requests int `env:"RATE_LIMIT"`
+	Burst    int `env:"RATE_LIMIT_BURST"`
+	// AuthRequests per minute a client may send to sign in or register,
+	// with bursts of up to AuthBurst. Zero turns the limit off.
+	AuthRequests int `env:"AUTH_RATE_LIMIT"`
+	AuthBurst    int `env:"AUTH_RATE_LIMIT_BURST"`
+}
+
+// AI configures the workout plan generator the app runs with Groq.
+type AI struct {
+	// GroqKey is handed to signed-in users' apps; without it users enter
+	// their own.
+	GroqKey     string  `env:"GROQ_API_KEY" secret:"true"`
+	Model       string  `env:"GROQ_MODEL"`
+	MaxTokens   int     `env:"GROQ_MAX_TOKENS"`
+	Temperature float64 `env:"GROQ_TEMPERATURE"`
+}
+
+// Metrics configures the Prometheus endpoint.
+type Metrics struct {
+	// Token, when set, is the bearer token a scraper must send.
+	Token string `env:"METRICS_TOKEN" secret:"true"`
+	// Addr, when set, serves metrics on their own address, such as ":9090"
+	// on a port that is not public, instead of on the API's.
+	Addr string `env:"METRICS_ADDR"`
+}
+
+// Default returns the settings used where nothing overrides them. It has
+// no JWT secret, so it does not validate as is.
+func Default() *Config {
+	return &Config{
+		Port:            "8080",
+		DBPath:          "workout_tracker.db",
+		LogLevel:        slog.LevelInfo,
+		ReadTimeout:     time.Minute,
+		WriteTimeout:    time.Minute,
+		IdleTimeout:     2 * time.Minute,
+		ShutdownTimeout: 30 * time.Second,
+		Auth: Auth{
+			TokenTTL: 24 * time.Hour,
+		},
+		CORS: CORS{
+			Origins:    []string{"*"},
+			MaxAge:     10 * time.Minute,
+			HSTSMaxAge: 180 * 24 * time.Hour,
+		},
+		RateLimit: RateLimits{
+			Requests:     600,
+			Burst:        100,
+			AuthRequests: 10,
+			AuthBurst:    5,
+		},
+		AI: AI{
+			Model:       "llama-3.3-70b-versatile",
+			MaxTokens:   4000,
+			Temperature: 0.7,
+		},
+		IdempotencyTTL:         24 * time.Hour,
+		TrashRetentionDays:     30,
+		TombstoneRetentionDays: 90,
+	}
+}
+
+// minSecretLen is the shortest JWT secret accepted: HS256 wants a key of
+// at least 256 bits.
+const minSecretLen = 32
+
+// Validate checks that required settings are present and every setting
+// is in range, reporting all problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, msg string) {
+		errs = append(errs, &Error{Key: key, Message: msg})
+	}
+
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		fail("PORT", "must be a port number")
+	}
+	if c.FrontendDir != "" {
+		if info, err := os.Stat(c.FrontendDir); err != nil || !info.IsDir() {
+			fail("FRONTEND_DIR", "must be a directory")
+		}
+	}
+	if c.DatabaseURL == "" && c.DBPath == "" {
+		fail("DB_PATH", "is required when DATABASE_URL is not set")
+	}
+	for _, p := range c.TrustedProxies {
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				fail("TRUSTED_PROXIES", "must be IP addresses or CIDR ranges")
+				break
+			}
+		}
+	}
+	switch {
+	case c.Auth.JWTSecret == "":
+		fail("JWT_SECRET", "is required")
+	case len(c.Auth.JWTSecret) < minSecretLen:
+		fail("JWT_SECRET", "must be at least "+strconv.Itoa(minSecretLen)+" characters")
+	}
+	durations := []struct {
+		key string
+		d   time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", c.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"JWT_TTL", c.Auth.TokenTTL},
+		{"IDEMPOTENCY_TTL", c.IdempotencyTTL},
+	}
+	for _, d := range durations {
+		if d.d <= 0 {
+			fail(d.key, "must be a positive duration")
+		}
+	}
+	if c.TrashRetentionDays < 1 {
+		fail("TRASH_RETENTION_DAYS", "must be at least 1")
+	}
+	if c.TombstoneRetentionDays < 1 {
+		fail("TOMBSTONE_RETENTION_DAYS", "must be at least 1")
+	}
+	for _, origin := range c.CORS.Origins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			fail("CORS_ORIGINS", "must be * or origins such as https://app.example.com")
+			break
+		}
+	}
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.Origins, "*") {
+		fail("CORS_ALLOW_CREDENTIALS", "cannot be used when CORS_ORIGINS is *")
+	}
+	if c.CORS.MaxAge < 0 {
+		fail("CORS_MAX_AGE", "must not be negative")
+	}
+	if c.CORS.HSTSMaxAge < 0 {
+		fail("HSTS_MAX_AGE", "must not be negative")
+	}
+	limits := []struct {
+		key, burstKey string
+		rate, burst   int
+	}{
+		{"RATE_LIMIT", "RATE_LIMIT_BURST", c.RateLimit.Requests, c.RateLimit.Burst},
+		{"AUTH_RATE_LIMIT", "AUTH_RATE_LIMIT_BURST", c.RateLimit.AuthRequests, c.RateLimit.AuthBurst},
+	}
+	for _, l := range limits {
+		if l.rate < 0 {
+			fail(l.key, "must not be negative")
+		} else if l.rate > 0 && l.burst < 1 {
+			fail(l.burstKey, "must be at least 1 when "+l.key+" is set")
+		}
+	}
+	if c.AI.Model == "" {
+		fail("GROQ_MODEL", "is required")
+	}
+	if c.AI.MaxTokens < 1 {
+		fail("GROQ_MAX_TOKENS", "must be at least 1")
+	}
+	if c.AI.Temperature < 0 || c.AI.Temperature > 2 {
+		fail("GROQ_TEMPERATURE", "must be between 0 and 2")
+	}
+	if c.Metrics.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Addr); err != nil {
+			fail("METRICS_ADDR", "must be an address such as :9090")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Error is a setting that is missing or invalid.
+type Error struct {
+	Key     string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Key + " " + e.Message
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+// envOf looks settings up in m, as os.LookupEnv does in the environment.
+func envOf(m map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
+}
+
+func write(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, sources, err := Load(envOf(map[string]string{"JWT_SECRET": secret}), filepath.Join(t.TempDir(), ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.Auth.JWTSecret = secret
+	if cfg.Port != want.Port || cfg.Auth.TokenTTL != want.Auth.TokenTTL || cfg.AI.Model != want.AI.Model || cfg.CORS.Origins[0] != "*" {
+		t.Errorf("defaults: %+v", cfg)
+	}
+	if strings.Join(sources, ",") != "defaults,environment" {
+		t.Errorf("sources: %v", sources)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := write(t, dir, "config.yaml", `
+port: 9000
+db_path: from-file.db
+jwt_ttl: 2h
+cors_origins:
+  - https://a.example.com
+  - https://b.example.com
+rate_limit: 120
+groq_temperature: 0.2
+`)
+	dotenv := write(t, dir, ".env", "CONFIG_FILE="+file+"\nDB_PATH=from-dotenv.db\nJWT_SECRET="+secret+"\nLOG_LEVEL=debug\n")
+
+	cfg, sources, err := Load(envOf(map[string]string{"DB_PATH": "from-env.db", "JWT_TTL": ""}), dotenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9000" {
+		t.Errorf("port from file: %q", cfg.Port)
+	}
+	if cfg.DBPath != "from-env.db" {
+		t.Errorf("env should override .env and the file: %q", cfg.DBPath)
+	}
+	if cfg.Auth.JWTSecret != secret || cfg.LogLevel != slog.LevelDebug {
+		t.Errorf(".env should override the defaults: %+v", cfg)
+	}
+	if cfg.Auth.TokenTTL != 2*time.Hour {
+		t.Errorf("an empty env value should not override the file: %v", cfg.Auth.TokenTTL)
+	}
+	if strings.Join(cfg.CORS.Origins, " ") != "https://a.example.com https://b.example.com" {
+		t.Errorf("origins from a YAML list: %v", cfg.CORS.Origins)
+	}
+	if cfg.RateLimit.Requests != 120 || cfg.AI.Temperature != 0.2 {
+		t.Errorf("numbers from the file: %+v %+v", cfg.RateLimit, cfg.AI)
+	}
+	if strings.Join(sources, ",") != "defaults,"+file+","+dotenv+",environment" {
+		t.Errorf("sources: %v", sources)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	file := write(t, t.TempDir(), "config.toml", `
+jwt_secret = "`+secret+`"
+admin_emails = ["root@example.com", "ops@example.com"]
+groq_max_tokens = 2000
+idempotency_ttl = "12h"
+`)
+	cfg, _, err := Load(envOf(map[string]string{"CONFIG_FILE": file}), filepath.Join(t.TempDir(), ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Auth.AdminEmails) != 2 || cfg.AI.MaxTokens != 2000 || cfg.IdempotencyTTL != 12*time.Hour {
+		t.Errorf("TOML: %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	noDotenv := filepath.Join(dir, ".env")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{"missing secret", nil, []string{"JWT_SECRET is required"}},
+		{"short secret", map[string]string{"JWT_SECRET": "short"}, []string{"JWT_SECRET must be at least 32 characters"}},
+		{"malformed values", map[string]string{"JWT_TTL": "a day", "RATE_LIMIT": "lots", "LOG_LEVEL": "loud"},
+			[]string{"JWT_TTL must be a duration", "RATE_LIMIT must be a whole number", "LOG_LEVEL invalid value", "JWT_SECRET is required"}},
+		{"out of range", map[string]string{"JWT_SECRET": secret, "PORT": "70000", "TRASH_RETENTION_DAYS": "0", "TOMBSTONE_RETENTION_DAYS": "0", "CORS_ORIGINS": "example.com", "GROQ_TEMPERATURE": "3"},
+			[]string{"PORT must be a port number", "TRASH_RETENTION_DAYS must be at least 1", "TOMBSTONE_RETENTION_DAYS must be at least 1", "CORS_ORIGINS must be", "GROQ_TEMPERATURE must be between 0 and 2"}},
+		{"credentials with any origin", map[string]string{"JWT_SECRET": secret, "CORS_ALLOW_CREDENTIALS": "true"}, []string{"CORS_ALLOW_CREDENTIALS cannot be used"}},
+		{"burst without room", map[string]string{"JWT_SECRET": secret, "AUTH_RATE_LIMIT_BURST": "0"}, []string{"AUTH_RATE_LIMIT_BURST must be at least 1"}},
+		{"unknown file setting", map[string]string{"JWT_SECRET": secret, "CONFIG_FILE": write(t, dir, "typo.yaml", "prot: 80\n")}, []string{`unknown setting "prot"`}},
+		{"unknown file format", map[string]string{"JWT_SECRET": secret, "CONFIG_FILE": write(t, dir, "config.json", "{}")}, []string{"unknown format"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Load(envOf(tt.env), noDotenv)
+			if err == nil {
+				t.Fatal("want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+
+	_, _, err := Load(envOf(nil), noDotenv)
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) || cfgErr.Key != "JWT_SECRET" {
+		t.Errorf("want a *Error for JWT_SECRET, got %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.JWTSecret = secret
+	cfg.AI.GroqKey = "gsk_live_key"
+	cfg.Metrics.Addr = ":9090"
+
+	got := cfg.Redacted()
+	for _, key := range []string{"JWT_SECRET", "GROQ_API_KEY"} {
+		if got[key] != "[redacted]" {
+			t.Errorf("%s: %q", key, got[key])
+		}
+	}
+	if got["METRICS_TOKEN"] != "" || got["METRICS_ADDR"] != ":9090" || got["JWT_TTL"] != "24h0m0s" || got["LOG_LEVEL"] != "INFO" {
+		t.Errorf("redacted: %v", got)
+	}
+	if s := slog.AnyValue(cfg).Resolve().String(); strings.Contains(s, secret) || strings.Contains(s, "gsk_live_key") {
+		t.Errorf("logged config leaks a secret: %s", s)
+	}
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileKey is the setting naming the config file. It is read from the
+// environment or .env only.
+const FileKey = "CONFIG_FILE"
+
+// Load reads the configuration: the defaults, then the YAML or TOML file
+// named by CONFIG_FILE, then the dotenv file, if it exists, then the
+// environment as env looks it up, each overriding the ones before. Empty
+// values count as unset. It returns the sources used, in order, and every
+// unknown, malformed or invalid setting at once.
+func Load(env func(key string) (string, bool), dotenv string) (*Config, []string, error) {
+	sources := []string{"defaults"}
+	var layers []map[string]string
+
+	dotenvValues, err := godotenv.Read(dotenv)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		dotenvValues = nil
+	case err != nil:
+		return nil, nil, fmt.Errorf("reading %s: %w", dotenv, err)
+	}
+
+	file, ok := env(FileKey)
+	if !ok || file == "" {
+		file = dotenvValues[FileKey]
+	}
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, values)
+		sources = append(sources, file)
+	}
+	if dotenvValues != nil {
+		layers = append(layers, dotenvValues)
+		sources = append(sources, dotenv)
+	}
+
+	cfg := Default()
+	var errs []error
+	for _, f := range fields(cfg) {
+		value, set := "", false
+		for _, layer := range layers {
+			if v := layer[f.key]; v != "" {
+				value, set = v, true
+			}
+		}
+		if v, ok := env(f.key); ok && v != "" {
+			value, set = v, true
+		}
+		if !set {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			errs = append(errs, &Error{Key: f.key, Message: err.Error()})
+		}
+	}
+	sources = append(sources, "environment")
+	// A malformed setting keeps its default, so validating reports only
+	// the other problems.
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return cfg, sources, nil
+}
+
+// readFile reads a config file's settings by their env names. Its format
+// follows its extension: .yaml, .yml or .toml. Keys are the env names in
+// lower case, and lists may be written as lists.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unknown format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	known := map[string]bool{}
+	for _, f := range fields(Default()) {
+		known[f.key] = true
+	}
+	values := map[string]string{}
+	var errs []error
+	for name, v := range raw {
+		key := strings.ToUpper(name)
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, name))
+			continue
+		}
+		s, err := fileValue(v)
+		if err != nil {
+			errs = append(errs, &Error{Key: key, Message: err.Error()})
+			continue
+		}
+		values[key] = s
+	}
+	return values, errors.Join(errs...)
+}
+
+// fileValue renders a value from a config file as it would be written in
+// the environment.
+func fileValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("must be a string, number, boolean or list, not %T", v)
+}
+
+// field is a setting: a leaf of Config and its env name.
+type field struct {
+	key    string
+	secret bool
+	value  reflect.Value
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// fields lists the settings of cfg in declaration order.
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if key := sf.Tag.Get("env"); key != "" {
+				out = append(out, field{key: key, secret: sf.Tag.Get("secret") == "true", value: v.Field(i)})
+			} else if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+	return out
+}
+
+// set parses s into the field.
+func (f field) set(s string) error {
+	v := f.value
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("invalid value %q", s)
+		}
+		return nil
+	}
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 90s or 12h, not %q", s)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("must be a whole number, not %q", s)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, not %q", s)
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("must be true or false, not %q", s)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		panic("config: unsupported type " + v.Type().String())
+	}
+	return nil
+}
+
+// redacted stands in for a secret's value.
+const redacted = "[redacted]"
+
+// Redacted returns every setting by its env name, formatted as it would
+// be written in the environment, with secrets that are set replaced by
+// "[redacted]".
+func (c *Config) Redacted() map[string]string {
+	out := map[string]string{}
+	for _, f := range fields(c) {
+		s := format(f.value)
+		if f.secret && s != "" {
+			s = redacted
+		}
+		out[f.key] = s
+	}
+	return out
+}
+
+// LogValue logs the config redacted, sorted by setting.
+func (c *Config) LogValue() slog.Value {
+	settings := c.Redacted()
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, len(keys))
+	for i, k := range keys {
+		attrs[i] = slog.String(k, settings[k])
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func format(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, _ := m.MarshalText()
+		return string(b)
+	}
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
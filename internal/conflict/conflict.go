@@ -0,0 +1,104 @@
+// Package conflict merges changes a client made offline into the server's
+// copy of a record, field by field, using a three-way comparison with the
+// values the client started from.
+package conflict
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// Strategy decides a field that both sides changed.
+type Strategy string
+
+const (
+	// ServerWins keeps the server's value.
+	ServerWins Strategy = "server_wins"
+	// LastWriterWins keeps the value of whoever changed the record last.
+	LastWriterWins Strategy = "last_writer_wins"
+)
+
+// ParseStrategy accepts a strategy name; empty means ServerWins.
+func ParseStrategy(s string) (Strategy, bool) {
+	switch Strategy(s) {
+	case "", ServerWins:
+		return ServerWins, true
+	case LastWriterWins:
+		return LastWriterWins, true
+	}
+	return "", false
+}
+
+// ClientWins reports whether the client's side of a conflict is kept: with
+// last-writer-wins, when the client changed the record after the server
+// last did. Ties go to the server.
+func (s Strategy) ClientWins(clientAt, serverAt time.Time) bool {
+	return s == LastWriterWins && clientAt.After(serverAt)
+}
+
+// Merge applies changes to a copy of server, both maps of field names to
+// JSON values. A changed field conflicts when the server value matches
+// neither the client's base value nor its new one, that is when the server
+// changed it too; a field missing from base counts as changed on the
+// server. Conflicts are decided by clientWins and reported by field name.
+// Passing server as base, for a client that is up to date, gives no
+// conflicts.
+func Merge(server, base, changes map[string]json.RawMessage, clientWins bool) (map[string]json.RawMessage, []models.SyncConflict) {
+	merged := make(map[string]json.RawMessage, len(server))
+	for k, v := range server {
+		merged[k] = v
+	}
+	var conflicts []models.SyncConflict
+	for field, value := range changes {
+		current := server[field]
+		if b, ok := base[field]; (ok && Equal(current, b)) || Equal(current, value) {
+			merged[field] = value
+			continue
+		}
+		winner := "server"
+		if clientWins {
+			winner = "client"
+			merged[field] = value
+		}
+		conflicts = append(conflicts, models.SyncConflict{Field: field, Client: value, Server: orNull(current), Winner: winner})
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Field < conflicts[j].Field })
+	return merged, conflicts
+}
+
+// Equal compares two JSON values, ignoring formatting and key order. A
+// missing value equals null.
+func Equal(a, b json.RawMessage) bool {
+	a, b = orNull(a), orNull(b)
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func orNull(v json.RawMessage) json.RawMessage {
+	if len(v) == 0 {
+		return json.RawMessage("null")
+	}
+	return v
+}
+
+// Fields encodes a record as a map of its JSON fields, the form Merge
+// works on.
+func Fields(v interface{}) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(b, &fields)
+	return fields, err
+}
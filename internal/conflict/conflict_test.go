@@ -0,0 +1,95 @@
+package conflict
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func raw(s string) json.RawMessage { return json.RawMessage(s) }
+
+func TestMerge(t *testing.T) {
+	server := map[string]json.RawMessage{"title": raw(`"Legs"`), "comment": raw(`"felt good"`), "status": raw(`"pending"`)}
+
+	tests := []struct {
+		name          string
+		base, changes map[string]json.RawMessage
+		clientWins    bool
+		want          map[string]string
+		conflicts     []string
+	}{
+		{
+			name:    "server untouched",
+			base:    map[string]json.RawMessage{"title": raw(`"Legs"`)},
+			changes: map[string]json.RawMessage{"title": raw(`"Leg day"`)},
+			want:    map[string]string{"title": `"Leg day"`, "comment": `"felt good"`},
+		},
+		{
+			name:      "both changed, server wins",
+			base:      map[string]json.RawMessage{"comment": raw(`""`)},
+			changes:   map[string]json.RawMessage{"comment": raw(`"tired"`), "status": raw(`"completed"`)},
+			want:      map[string]string{"comment": `"felt good"`, "status": `"pending"`},
+			conflicts: []string{"comment", "status"},
+		},
+		{
+			name:       "both changed, client wins",
+			base:       map[string]json.RawMessage{"comment": raw(`""`), "status": raw(`"pending"`)},
+			changes:    map[string]json.RawMessage{"comment": raw(`"tired"`), "status": raw(`"completed"`)},
+			clientWins: true,
+			want:       map[string]string{"comment": `"tired"`, "status": `"completed"`},
+			conflicts:  []string{"comment"},
+		},
+		{
+			name:    "same change on both sides",
+			base:    map[string]json.RawMessage{"comment": raw(`""`)},
+			changes: map[string]json.RawMessage{"comment": raw(` "felt good" `)},
+			want:    map[string]string{"comment": `"felt good"`},
+		},
+		{
+			name:    "up to date client",
+			base:    server,
+			changes: map[string]json.RawMessage{"comment": raw(`null`)},
+			want:    map[string]string{"comment": `null`, "title": `"Legs"`},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, conflicts := Merge(server, tc.base, tc.changes, tc.clientWins)
+			for field, want := range tc.want {
+				if !Equal(merged[field], raw(want)) {
+					t.Errorf("%s = %s, want %s", field, merged[field], want)
+				}
+			}
+			if len(conflicts) != len(tc.conflicts) {
+				t.Fatalf("conflicts = %+v, want %v", conflicts, tc.conflicts)
+			}
+			for i, c := range conflicts {
+				if c.Field != tc.conflicts[i] {
+					t.Errorf("conflict %d on %s, want %s", i, c.Field, tc.conflicts[i])
+				}
+				if want := map[bool]string{true: "client", false: "server"}[tc.clientWins]; c.Winner != want {
+					t.Errorf("conflict on %s won by %s, want %s", c.Field, c.Winner, want)
+				}
+			}
+		})
+	}
+	if string(server["title"]) != `"Legs"` {
+		t.Error("Merge changed the server map")
+	}
+}
+
+func TestClientWins(t *testing.T) {
+	server := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if ServerWins.ClientWins(server.Add(time.Hour), server) {
+		t.Error("server_wins let the client win")
+	}
+	if !LastWriterWins.ClientWins(server.Add(time.Second), server) {
+		t.Error("last_writer_wins ignored a later client change")
+	}
+	if LastWriterWins.ClientWins(server, server) {
+		t.Error("last_writer_wins gave a tie to the client")
+	}
+	if _, ok := ParseStrategy("client_wins"); ok {
+		t.Error("unknown strategy accepted")
+	}
+}
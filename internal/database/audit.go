@@ -0,0 +1,263 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// ---- Audit log ----
+
+// Actor is who makes changes through a store, and the request they came
+// with, as recorded in the audit log.
+type Actor struct {
+	UserID    int64
+	RequestID string
+}
+
+// As returns a store that records actor in the audit log as the maker of
+// its changes. It shares the connections of db and must not be closed.
+func (db *DB) As(actor Actor) Store {
+	scoped := *db
+	scoped.actor = actor
+	return &scoped
+}
+
+// audit records a change to one of the user's records, in the transaction
+// that makes it. before and after are snapshots of the record, nil where it
+// does not exist, and only the fields that differ are kept. An update that
+// changed nothing is not recorded.
+func (db *DB) audit(tx *Tx, userID int64, action, entity string, entityID int64, before, after interface{}) error {
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 && action == "update" {
+		return nil
+	}
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	var actor interface{}
+	if db.actor.UserID != 0 {
+		actor = db.actor.UserID
+	}
+	_, err = tx.Exec(`INSERT INTO audit_events (user_id, actor_id, action, entity, entity_id, changes, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, actor, action, entity, entityID, string(b), nullString(db.actor.RequestID), formatTime(now()))
+	return err
+}
+
+// auditChanges compares two snapshots field by field.
+func auditChanges(before, after interface{}) (map[string]models.AuditChange, error) {
+	old, err := snapshotFields(before)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := snapshotFields(after)
+	if err != nil {
+		return nil, err
+	}
+	null := json.RawMessage("null")
+	changes := map[string]models.AuditChange{}
+	for field, v := range old {
+		if w, ok := cur[field]; !ok || !bytes.Equal(v, w) {
+			changes[field] = models.AuditChange{Before: v, After: null}
+		}
+	}
+	for field, w := range cur {
+		if c, ok := changes[field]; ok {
+			c.After = w
+			changes[field] = c
+		} else if _, ok := old[field]; !ok {
+			changes[field] = models.AuditChange{Before: null, After: w}
+		}
+	}
+	return changes, nil
+}
+
+func snapshotFields(v interface{}) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if v == nil {
+		return fields, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return fields, err
+	}
+	err = json.Unmarshal(b, &fields)
+	return fields, err
+}
+
+// workoutSnapshot is what the audit log compares of a workout.
+type workoutSnapshot struct {
+	models.WorkoutState
+	CompletedAt *time.Time `json:"completed_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+// loadWorkoutSnapshot reads a workout inside tx, for the audit log.
+func loadWorkoutSnapshot(tx *Tx, id int64) (*workoutSnapshot, error) {
+	s := &workoutSnapshot{}
+	var scheduledStr, completedStr, deletedStr sql.NullString
+	err := tx.QueryRow(`SELECT title, description, comment, status, visibility, scheduled_at, completed_at, deleted_at FROM workouts WHERE id = ?`, id).
+		Scan(&s.Title, &s.Description, &s.Comment, &s.Status, &s.Visibility, &scheduledStr, &completedStr, &deletedStr)
+	if err != nil {
+		return nil, err
+	}
+	if s.ScheduledAt, err = parseNullTime(scheduledStr); err != nil {
+		return nil, err
+	}
+	if s.CompletedAt, err = parseNullTime(completedStr); err != nil {
+		return nil, err
+	}
+	if s.DeletedAt, err = parseNullTime(deletedStr); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(`SELECT id, exercise_id, sets, reps, weight_kg, duration_sec, rest_sec, notes,
+		distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, calories
+		FROM workout_exercises WHERE workout_id = ? ORDER BY position, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	s.Exercises = []models.WorkoutStateExercise{}
+	for rows.Next() {
+		var e models.WorkoutStateExercise
+		if err := rows.Scan(&e.ID, &e.ExerciseID, &e.Sets, &e.Reps, &e.WeightKg, &e.DurationSec, &e.RestSec, &e.Notes,
+			&e.DistanceM, &e.ElevationGainM, &e.AvgHeartRate, &e.MaxHeartRate, &e.Calories); err != nil {
+			return nil, err
+		}
+		s.Exercises = append(s.Exercises, e)
+	}
+	return s, rows.Err()
+}
+
+// auditWorkout records a change to a workout, given its snapshot from
+// before the change, or nil for a new one.
+func (db *DB) auditWorkout(tx *Tx, userID int64, action string, id int64, before *workoutSnapshot) error {
+	after, err := loadWorkoutSnapshot(tx, id)
+	if err != nil {
+		return err
+	}
+	return db.audit(tx, userID, action, "workout", id, before, after)
+}
+
+const auditColumns = `id, user_id, actor_id, action, entity, entity_id, changes, request_id, created_at`
+
+func scanAuditEvent(row rowScanner) (*models.AuditEvent, error) {
+	e := &models.AuditEvent{}
+	var actor sql.NullInt64
+	var requestID sql.NullString
+	var changes, createdStr string
+	if err := row.Scan(&e.ID, &e.UserID, &actor, &e.Action, &e.Entity, &e.EntityID, &changes, &requestID, &createdStr); err != nil {
+		return nil, err
+	}
+	e.ActorID = actor.Int64
+	e.RequestID = requestID.String
+	if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+		return nil, fmt.Errorf("audit event %d: %w", e.ID, err)
+	}
+	var err error
+	if e.CreatedAt, err = parseTime(createdStr); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// auditFilterSQL builds the WHERE clause of an audit query. A user sees
+// the changes to their data and the changes they made to others' data.
+func auditFilterSQL(f models.AuditFilter) (string, []interface{}) {
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	if f.UserID != 0 {
+		where += ` AND (user_id = ? OR actor_id = ?)`
+		args = append(args, f.UserID, f.UserID)
+	}
+	if f.Entity != "" {
+		where += ` AND entity = ?`
+		args = append(args, f.Entity)
+	}
+	if f.EntityID != 0 {
+		where += ` AND entity_id = ?`
+		args = append(args, f.EntityID)
+	}
+	if f.Action != "" {
+		where += ` AND action = ?`
+		args = append(args, f.Action)
+	}
+	if f.From != nil {
+		where += ` AND created_at >= ?`
+		args = append(args, formatTime(*f.From))
+	}
+	if f.To != nil {
+		where += ` AND created_at < ?`
+		args = append(args, formatTime(f.To.AddDate(0, 0, 1)))
+	}
+	return where, args
+}
+
+// ListAuditEvents returns a page of audit events matching the filter, most
+// recent first.
+func (db *DB) ListAuditEvents(f models.AuditFilter) (*models.AuditPage, error) {
+	where, args := auditFilterSQL(f)
+	if f.Cursor != "" {
+		cur, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		where += ` AND id < ?`
+		args = append(args, cur.ID)
+	}
+	limit := f.Limit
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	rows, err := db.Query(`SELECT `+auditColumns+` FROM audit_events`+where+` ORDER BY id DESC LIMIT ?`, append(args, limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	page := &models.AuditPage{Events: []models.AuditEvent{}}
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Events = append(page.Events, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Events) > limit {
+		page.Events = page.Events[:limit]
+		page.NextCursor = encodeCursor(pageCursor{ID: page.Events[limit-1].ID})
+	}
+	return page, nil
+}
+
+// StreamAuditEvents calls fn for each audit event matching the filter, in
+// the order they were recorded; the filter's limit and cursor are ignored.
+// fn must not use the database: the query is still open while it runs.
+func (db *DB) StreamAuditEvents(f models.AuditFilter, fn func(*models.AuditEvent) error) error {
+	where, args := auditFilterSQL(f)
+	rows, err := db.Query(`SELECT `+auditColumns+` FROM audit_events`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,352 @@
+package database
+
+import (
+	"database/sql"
+	"workout-tracker/internal/metrics"
+	"workout-tracker/internal/models"
+)
+
+// ---- Coaching ----
+
+// scopeColumns are the coach_links columns that grant each scope, in the
+// order scopes are listed.
+var scopeColumns = []struct{ scope, column string }{
+	{"view", "can_view"},
+	{"assign", "can_assign"},
+	{"edit", "can_edit"},
+}
+
+func scopeColumn(scope string) string {
+	for _, s := range scopeColumns {
+		if s.scope == scope {
+			return s.column
+		}
+	}
+	panic("database: unknown coach scope " + scope)
+}
+
+// access is whose workouts a query may reach: the user's own, or an
+// athlete's on behalf of a coach. A coach's grant is part of the query, so
+// a revoked link or scope takes effect even for requests already running.
+type access struct {
+	userID int64         // who owns the workouts
+	grant  string        // further condition on workouts aliased as w
+	args   []interface{} // the grant's arguments
+}
+
+func ownedBy(userID int64) access {
+	return access{userID: userID}
+}
+
+// coachedBy reaches athleteID's workouts as long as coachID's accepted
+// link grants scope.
+func coachedBy(coachID, athleteID int64, scope string) access {
+	return access{
+		userID: athleteID,
+		grant: ` AND EXISTS (SELECT 1 FROM coach_links cl WHERE cl.coach_id = ? AND cl.athlete_id = w.user_id
+			AND cl.accepted_at IS NOT NULL AND cl.` + scopeColumn(scope) + `)`,
+		args: []interface{}{coachID},
+	}
+}
+
+// where is the condition on workouts aliased as w, with a leading space,
+// and its arguments.
+func (a access) where() (string, []interface{}) {
+	return ` w.user_id = ?` + a.grant, append([]interface{}{a.userID}, a.args...)
+}
+
+// checkCoach tells why coachID may not use scope on athleteID's account:
+// ErrAthleteNotFound without an accepted link, ErrScopeNotGranted when the
+// link lacks the scope.
+func checkCoach(q queryRower, coachID, athleteID int64, scope string) error {
+	var granted bool
+	err := q.QueryRow(`SELECT `+scopeColumn(scope)+` FROM coach_links WHERE coach_id = ? AND athlete_id = ? AND accepted_at IS NOT NULL`,
+		coachID, athleteID).Scan(&granted)
+	if err == sql.ErrNoRows {
+		return ErrAthleteNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return ErrScopeNotGranted
+	}
+	return nil
+}
+
+// CheckCoach reports whether coachID may use scope on athleteID's account,
+// as checkCoach, so that handlers can refuse before validating a request.
+// The write itself checks again.
+func (db *DB) CheckCoach(coachID, athleteID int64, scope string) error {
+	return checkCoach(db, coachID, athleteID, scope)
+}
+
+const coachLinkQuery = `SELECT cl.coach_id, c.name, cl.athlete_id, a.name, cl.can_view, cl.can_assign, cl.can_edit, cl.created_at, cl.accepted_at
+	FROM coach_links cl JOIN users c ON c.id = cl.coach_id JOIN users a ON a.id = cl.athlete_id`
+
+func scanCoachLink(row rowScanner) (*models.CoachLink, error) {
+	l := &models.CoachLink{Scopes: []string{}}
+	var granted [3]bool
+	var created string
+	var accepted sql.NullString
+	if err := row.Scan(&l.CoachID, &l.CoachName, &l.AthleteID, &l.AthleteName, &granted[0], &granted[1], &granted[2], &created, &accepted); err != nil {
+		return nil, err
+	}
+	for i, s := range scopeColumns {
+		if granted[i] {
+			l.Scopes = append(l.Scopes, s.scope)
+		}
+	}
+	var err error
+	if l.CreatedAt, err = parseTime(created); err != nil {
+		return nil, err
+	}
+	if l.AcceptedAt, err = parseNullTime(accepted); err != nil {
+		return nil, err
+	}
+	l.Status = "pending"
+	if l.AcceptedAt != nil {
+		l.Status = "active"
+	}
+	return l, nil
+}
+
+// grants reports which of the columns in scopeColumns scopes sets.
+func grants(scopes []string) [3]bool {
+	var g [3]bool
+	for _, scope := range scopes {
+		for i, s := range scopeColumns {
+			if s.scope == scope {
+				g[i] = true
+			}
+		}
+	}
+	return g
+}
+
+// coachLinkSnapshot is what the audit log records of a coach link, under
+// the athlete, who the access is to.
+type coachLinkSnapshot struct {
+	CoachID int64    `json:"coach_id"`
+	Scopes  []string `json:"scopes"`
+	Status  string   `json:"status"`
+}
+
+func snapshotCoachLink(l *models.CoachLink) *coachLinkSnapshot {
+	if l == nil {
+		return nil
+	}
+	return &coachLinkSnapshot{CoachID: l.CoachID, Scopes: l.Scopes, Status: l.Status}
+}
+
+// getCoachLink reads a link, or returns nil if there is none.
+func getCoachLink(q queryRower, coachID, athleteID int64) (*models.CoachLink, error) {
+	l, err := scanCoachLink(q.QueryRow(coachLinkQuery+` WHERE cl.coach_id = ? AND cl.athlete_id = ?`, coachID, athleteID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return l, err
+}
+
+// InviteAthlete invites the user with an email to be coached by coachID
+// with scopes. The link stays pending until the athlete accepts it.
+func (db *DB) InviteAthlete(coachID int64, email string, scopes []string) (*models.CoachLink, error) {
+	athlete, err := db.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if athlete == nil {
+		return nil, ErrUserNotFound
+	}
+	if athlete.ID == coachID {
+		return nil, ErrCannotCoachSelf
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	g := grants(scopes)
+	_, err = tx.Exec(`INSERT INTO coach_links (coach_id, athlete_id, can_view, can_assign, can_edit, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		coachID, athlete.ID, g[0], g[1], g[2], formatTime(now()))
+	if isUniqueViolation(err) {
+		return nil, ErrCoachLinkExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	link, err := getCoachLink(tx, coachID, athlete.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, athlete.ID, "create", "coach_link", coachID, nil, snapshotCoachLink(link)); err != nil {
+		return nil, err
+	}
+	return link, tx.Commit()
+}
+
+// ListAthletes returns coachID's links to athletes, pending and active,
+// by athlete name.
+func (db *DB) ListAthletes(coachID int64) ([]models.CoachLink, error) {
+	return db.listCoachLinks(coachLinkQuery+` WHERE cl.coach_id = ? ORDER BY a.name, a.id`, coachID)
+}
+
+// ListCoaches returns athleteID's links to coaches, pending and active, by
+// coach name.
+func (db *DB) ListCoaches(athleteID int64) ([]models.CoachLink, error) {
+	return db.listCoachLinks(coachLinkQuery+` WHERE cl.athlete_id = ? ORDER BY c.name, c.id`, athleteID)
+}
+
+func (db *DB) listCoachLinks(query string, userID int64) ([]models.CoachLink, error) {
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.CoachLink{}
+	for rows.Next() {
+		l, err := scanCoachLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *l)
+	}
+	return list, rows.Err()
+}
+
+// AcceptCoach accepts coachID's invite to athleteID. Accepting an active
+// link changes nothing.
+func (db *DB) AcceptCoach(athleteID, coachID int64) (*models.CoachLink, error) {
+	return db.changeCoachLink(athleteID, coachID, `UPDATE coach_links SET accepted_at = ?
+		WHERE coach_id = ? AND athlete_id = ? AND accepted_at IS NULL`, formatTime(now()))
+}
+
+// UpdateCoachScopes replaces the scopes athleteID grants coachID, pending
+// or not.
+func (db *DB) UpdateCoachScopes(athleteID, coachID int64, scopes []string) (*models.CoachLink, error) {
+	g := grants(scopes)
+	return db.changeCoachLink(athleteID, coachID, `UPDATE coach_links SET can_view = ?, can_assign = ?, can_edit = ?
+		WHERE coach_id = ? AND athlete_id = ?`, g[0], g[1], g[2])
+}
+
+// changeCoachLink runs an update of a link, whose arguments are followed
+// by the coach's and athlete's IDs, and audits it.
+func (db *DB) changeCoachLink(athleteID, coachID int64, update string, args ...interface{}) (*models.CoachLink, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := getCoachLink(tx, coachID, athleteID)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, ErrCoachNotFound
+	}
+	if _, err := tx.Exec(update, append(args, coachID, athleteID)...); err != nil {
+		return nil, err
+	}
+	after, err := getCoachLink(tx, coachID, athleteID)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, athleteID, "update", "coach_link", coachID, snapshotCoachLink(before), snapshotCoachLink(after)); err != nil {
+		return nil, err
+	}
+	return after, tx.Commit()
+}
+
+// RevokeCoach ends athleteID's link to coachID, or declines the invite.
+func (db *DB) RevokeCoach(athleteID, coachID int64) error {
+	return db.removeCoachLink(coachID, athleteID, ErrCoachNotFound)
+}
+
+// DropAthlete ends coachID's link to athleteID, or withdraws the invite.
+func (db *DB) DropAthlete(coachID, athleteID int64) error {
+	return db.removeCoachLink(coachID, athleteID, ErrAthleteNotFound)
+}
+
+func (db *DB) removeCoachLink(coachID, athleteID int64, notFound error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getCoachLink(tx, coachID, athleteID)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return notFound
+	}
+	if _, err := tx.Exec(`DELETE FROM coach_links WHERE coach_id = ? AND athlete_id = ?`, coachID, athleteID); err != nil {
+		return err
+	}
+	if err := db.audit(tx, athleteID, "delete", "coach_link", coachID, snapshotCoachLink(before), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListAthleteWorkouts lists athleteID's workouts for coachID, who needs
+// the view scope. The filter works as for ListWorkouts.
+func (db *DB) ListAthleteWorkouts(coachID, athleteID int64, f models.WorkoutFilter) (*models.WorkoutPage, error) {
+	if err := checkCoach(db, coachID, athleteID, "view"); err != nil {
+		return nil, err
+	}
+	return db.listWorkouts(coachedBy(coachID, athleteID, "view"), f)
+}
+
+// GetAthleteWorkout returns one of athleteID's workouts for coachID, who
+// needs the view scope.
+func (db *DB) GetAthleteWorkout(id, coachID, athleteID int64) (*models.Workout, error) {
+	if err := checkCoach(db, coachID, athleteID, "view"); err != nil {
+		return nil, err
+	}
+	return db.getWorkout(coachedBy(coachID, athleteID, "view"), id)
+}
+
+// GetAthleteReport summarises athleteID's workouts for coachID, who needs
+// the view scope. Today and this week are the athlete's.
+func (db *DB) GetAthleteReport(coachID, athleteID int64, f models.WorkoutFilter) (*models.WorkoutReport, error) {
+	if err := checkCoach(db, coachID, athleteID, "view"); err != nil {
+		return nil, err
+	}
+	return db.getReport(coachedBy(coachID, athleteID, "view"), f)
+}
+
+// AssignWorkout schedules a workout for athleteID on behalf of coachID,
+// who needs the assign scope. The workout records who assigned it.
+func (db *DB) AssignWorkout(coachID, athleteID int64, req models.CreateWorkoutRequest) (*models.Workout, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkCoach(tx, coachID, athleteID, "assign"); err != nil {
+		return nil, err
+	}
+	wid, err := db.insertWorkout(tx, athleteID, req, coachID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	metrics.WorkoutsCreated.Inc("coach")
+	return db.GetWorkoutByID(wid, athleteID)
+}
+
+// UpdateAthleteWorkout changes one of athleteID's workouts on behalf of
+// coachID, who needs the edit scope. It works as UpdateWorkout.
+func (db *DB) UpdateAthleteWorkout(id, coachID, athleteID int64, req models.UpdateWorkoutRequest, ifVersion int) (*models.Workout, error) {
+	if err := checkCoach(db, coachID, athleteID, "edit"); err != nil {
+		return nil, err
+	}
+	return db.updateWorkout(coachedBy(coachID, athleteID, "edit"), id, req, ifVersion)
+}
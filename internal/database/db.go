@@ -5,26 +5,114 @@ import (
 	"fmt"
 	"time"
 	"workout-tracker/internal/models"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type DB struct {
 	*sql.DB
+	dialect dialect
+	// writer runs every statement that changes data. SQLite allows one
+	// writer at a time, so there it is a single connection beside the
+	// reader pool; on PostgreSQL it is the same pool as DB.
+	writer *sql.DB
+	// actor is recorded in the audit log as the maker of every change
+	// made through this handle; see As.
+	actor Actor
+}
+
+// New opens the database named by dsn. A postgres:// or postgresql:// URL
+// selects PostgreSQL; anything else is a SQLite file path or ":memory:".
+func New(dsn string) (*DB, error) {
+	if isPostgresURL(dsn) {
+		return openPostgres(dsn)
+	}
+	return openSQLite(dsn)
 }
 
-func New(path string) (*DB, error) {
-	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on")
+func openSQLite(path string) (*DB, error) {
+	db, err := sql.Open(sqliteDriver, sqliteDSN(path))
 	if err != nil {
 		return nil, err
 	}
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
-	return &DB{db}, nil
+	// Every connection to ":memory:" opens its own empty database, so the
+	// pool must never grow past one, and it has to do the writing too.
+	if path == ":memory:" {
+		db.SetMaxOpenConns(1)
+		return &DB{DB: db, dialect: dialectSQLite, writer: db}, nil
+	}
+
+	writer, err := sql.Open(sqliteDriver, sqliteDSN(path))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	writer.SetMaxOpenConns(1)
+	if err := writer.Ping(); err != nil {
+		db.Close()
+		writer.Close()
+		return nil, err
+	}
+	return &DB{DB: db, dialect: dialectSQLite, writer: writer}, nil
+}
+
+// Close closes the reader pool and, when separate, the writer. On SQLite
+// it first checkpoints the write-ahead log into the database file, so the
+// file is complete on its own, e.g. for a backup taken after shutdown.
+func (db *DB) Close() error {
+	var err error
+	if db.dialect == dialectSQLite {
+		_, err = db.writer.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	}
+	if cerr := db.DB.Close(); err == nil {
+		err = cerr
+	}
+	if db.writer != db.DB {
+		if werr := db.writer.Close(); err == nil {
+			err = werr
+		}
+	}
+	return err
 }
 
+// indexes are shared by both backends.
+const indexes = `
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_external
+	ON workouts(user_id, source, external_id) WHERE external_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_workouts_user_status_scheduled ON workouts(user_id, status, scheduled_at);
+CREATE INDEX IF NOT EXISTS idx_workout_exercises_workout ON workout_exercises(workout_id);
+CREATE INDEX IF NOT EXISTS idx_workout_sessions_workout ON workout_sessions(workout_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workout_sessions_active ON workout_sessions(workout_id) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_session_sets_session ON session_sets(session_id);
+CREATE INDEX IF NOT EXISTS idx_exercises_user ON exercises(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_client ON workouts(user_id, client_id) WHERE client_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_client ON exercises(user_id, client_id) WHERE client_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_session_sets_client ON session_sets(session_id, client_id) WHERE client_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_workouts_user_updated ON workouts(user_id, updated_at);
+CREATE INDEX IF NOT EXISTS idx_tombstones_user_deleted ON tombstones(user_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_workouts_deleted ON workouts(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_events_user ON audit_events(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
+CREATE INDEX IF NOT EXISTS idx_sync_results_created ON sync_results(created_at);
+CREATE INDEX IF NOT EXISTS idx_workouts_user_completed ON workouts(user_id, completed_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_share_token ON workouts(share_token) WHERE share_token IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_follows_followee ON follows(followee_id);
+CREATE INDEX IF NOT EXISTS idx_reactions_workout ON reactions(workout_id);
+CREATE INDEX IF NOT EXISTS idx_comments_workout ON comments(workout_id, id);
+CREATE INDEX IF NOT EXISTS idx_coach_links_athlete ON coach_links(athlete_id);
+CREATE INDEX IF NOT EXISTS idx_team_members_user ON team_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_challenges_team ON challenges(team_id, ends_at);
+CREATE INDEX IF NOT EXISTS idx_goals_user ON goals(user_id);
+CREATE INDEX IF NOT EXISTS idx_body_weights_user ON body_weights(user_id, measured_at);
+`
+
 func (db *DB) Migrate() error {
+	if db.dialect == dialectPostgres {
+		return db.migratePostgres()
+	}
 	schema := `
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -65,26 +153,292 @@ func (db *DB) Migrate() error {
 		duration_sec INTEGER DEFAULT 0,
 		notes TEXT DEFAULT ''
 	);
+
+	CREATE TABLE IF NOT EXISTS workout_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		workout_id INTEGER NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		status TEXT DEFAULT 'active',
+		rest_until DATETIME,
+		started_at DATETIME,
+		finished_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS session_sets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL REFERENCES workout_sessions(id) ON DELETE CASCADE,
+		workout_exercise_id INTEGER NOT NULL REFERENCES workout_exercises(id) ON DELETE CASCADE,
+		exercise_id INTEGER NOT NULL REFERENCES exercises(id),
+		set_number INTEGER NOT NULL,
+		reps INTEGER DEFAULT 0,
+		weight_kg REAL DEFAULT 0,
+		duration_sec INTEGER DEFAULT 0,
+		rest_sec INTEGER DEFAULT 0,
+		is_pr INTEGER DEFAULT 0,
+		completed_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS tombstones (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		entity TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		client_id TEXT,
+		deleted_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS sync_results (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		idempotency_key TEXT NOT NULL,
+		result TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, idempotency_key)
+	);
+
+	-- audit_events is append-only. It has no foreign keys so that events
+	-- outlive the records they describe.
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		actor_id INTEGER,
+		action TEXT NOT NULL,
+		entity TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		changes TEXT NOT NULL,
+		request_id TEXT,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		idempotency_key TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		headers TEXT,
+		body TEXT,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, idempotency_key)
+	);
+
+	CREATE TABLE IF NOT EXISTS follows (
+		follower_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		followee_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (follower_id, followee_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS reactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		workout_id INTEGER NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE (workout_id, user_id, kind)
+	);
+
+	CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		workout_id INTEGER NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		body TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS coach_links (
+		coach_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		athlete_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		can_view INTEGER NOT NULL DEFAULT 0,
+		can_assign INTEGER NOT NULL DEFAULT 0,
+		can_edit INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		accepted_at DATETIME,
+		PRIMARY KEY (coach_id, athlete_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS teams (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		join_code TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS team_members (
+		team_id INTEGER NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		joined_at DATETIME NOT NULL,
+		PRIMARY KEY (team_id, user_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS challenges (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		team_id INTEGER NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+		created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		title TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		exercise_id INTEGER REFERENCES exercises(id),
+		target REAL NOT NULL,
+		starts_at DATETIME NOT NULL,
+		ends_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS goals (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		target REAL NOT NULL,
+		period TEXT,
+		exercise_id INTEGER REFERENCES exercises(id),
+		start_value REAL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS body_weights (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		weight_kg REAL NOT NULL,
+		measured_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS achievements (
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code TEXT NOT NULL,
+		workout_id INTEGER REFERENCES workouts(id) ON DELETE SET NULL,
+		earned_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, code)
+	);
+
+	CREATE TABLE IF NOT EXISTS schema_version (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL,
+		migrated_at DATETIME NOT NULL
+	);
 	`
-	_, err := db.Exec(schema)
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema. CREATE TABLE IF NOT EXISTS
+	// leaves existing databases untouched, so they are added here.
+	columns := []struct{ table, column, definition string }{
+		{"workout_exercises", "rest_sec", "INTEGER DEFAULT 0"},
+		{"workout_exercises", "distance_m", "REAL DEFAULT 0"},
+		{"workout_exercises", "elevation_gain_m", "REAL DEFAULT 0"},
+		{"workout_exercises", "avg_heart_rate", "INTEGER DEFAULT 0"},
+		{"workout_exercises", "max_heart_rate", "INTEGER DEFAULT 0"},
+		{"workout_exercises", "calories", "INTEGER DEFAULT 0"},
+		{"exercises", "user_id", "INTEGER REFERENCES users(id) ON DELETE CASCADE"},
+		{"workouts", "source", "TEXT DEFAULT ''"},
+		{"workouts", "external_id", "TEXT"},
+		{"users", "timezone", "TEXT DEFAULT 'UTC'"},
+		{"workouts", "version", "INTEGER NOT NULL DEFAULT 1"},
+		{"workout_exercises", "position", "INTEGER DEFAULT 0"},
+		{"workouts", "client_id", "TEXT"},
+		{"exercises", "client_id", "TEXT"},
+		{"exercises", "updated_at", "DATETIME"},
+		{"session_sets", "client_id", "TEXT"},
+		{"session_sets", "updated_at", "DATETIME"},
+		{"workouts", "deleted_at", "DATETIME"},
+		{"workouts", "visibility", "TEXT NOT NULL DEFAULT 'private'"},
+		{"workouts", "share_token", "TEXT"},
+		{"workouts", "assigned_by", "INTEGER REFERENCES users(id) ON DELETE SET NULL"},
+		{"users", "rest_days", "INTEGER NOT NULL DEFAULT 1"},
+	}
+	for _, c := range columns {
+		if err := db.addColumn(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	if err := db.normalizeTimes(); err != nil {
+		return err
+	}
+	if err := db.finishDuplicateSessions(); err != nil {
+		return err
+	}
+	if _, err := db.Exec(indexes); err != nil {
+		return err
+	}
+	return db.recordSchemaVersion()
+}
+
+// finishDuplicateSessions finishes all but the latest active session of
+// each workout, which databases from before a workout was limited to one
+// may have, so that the index enforcing the limit can be built.
+func (db *DB) finishDuplicateSessions() error {
+	_, err := db.Exec(`UPDATE workout_sessions SET status = 'finished', rest_until = NULL, finished_at = started_at
+		WHERE status = 'active' AND id < (SELECT MAX(a.id) FROM workout_sessions a
+			WHERE a.workout_id = workout_sessions.workout_id AND a.status = 'active')`)
+	return err
+}
+
+func (db *DB) addColumn(table, column, definition string) error {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
 	return err
 }
 
 // ---- Users ----
 
-func (db *DB) CreateUser(name, email, hash string) (*models.User, error) {
-	res, err := db.Exec(`INSERT INTO users (name, email, password_hash) VALUES (?, ?, ?)`, name, email, hash)
+// CreateUser stores a new user. timeZone is an IANA name; empty means UTC.
+func (db *DB) CreateUser(name, email, hash, timeZone string) (*models.User, error) {
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id, err := insertID(tx, `INSERT INTO users (name, email, password_hash, timezone, created_at) VALUES (?, ?, ?, ?, ?)`,
+		name, email, hash, timeZone, formatTime(now()))
+	if isUniqueViolation(err) {
+		return nil, ErrEmailTaken
+	}
 	if err != nil {
 		return nil, err
 	}
-	id, _ := res.LastInsertId()
+	after := map[string]string{"name": name, "email": email, "timezone": timeZone}
+	if err := db.audit(tx, id, "create", "user", id, nil, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return db.GetUserByID(id)
 }
 
-func (db *DB) GetUserByEmail(email string) (*models.User, error) {
+const userColumns = `id, name, email, password_hash, timezone, rest_days, created_at`
+
+func scanUser(row rowScanner) (*models.User, error) {
 	u := &models.User{}
-	err := db.QueryRow(`SELECT id, name, email, password_hash, created_at FROM users WHERE email = ?`, email).
-		Scan(&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.CreatedAt)
+	var tz sql.NullString
+	var createdStr string
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.PasswordHash, &tz, &u.RestDays, &createdStr); err != nil {
+		return nil, err
+	}
+	u.TimeZone = tz.String
+	if u.TimeZone == "" {
+		u.TimeZone = "UTC"
+	}
+	var err error
+	if u.CreatedAt, err = parseTime(createdStr); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (db *DB) GetUserByEmail(email string) (*models.User, error) {
+	u, err := scanUser(db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = ?`, email))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -92,279 +446,194 @@ func (db *DB) GetUserByEmail(email string) (*models.User, error) {
 }
 
 func (db *DB) GetUserByID(id int64) (*models.User, error) {
-	u := &models.User{}
-	err := db.QueryRow(`SELECT id, name, email, password_hash, created_at FROM users WHERE id = ?`, id).
-		Scan(&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.CreatedAt)
+	u, err := scanUser(db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return u, err
 }
 
-// ---- Exercises ----
-
-func (db *DB) GetExercises() ([]models.Exercise, error) {
-	rows, err := db.Query(`SELECT id, name, description, category, muscle_group FROM exercises ORDER BY category, name`)
+// UpdateUserTimeZone sets the IANA time zone used for the user's days and
+// weeks.
+func (db *DB) UpdateUserTimeZone(id int64, timeZone string) (*models.User, error) {
+	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	var list []models.Exercise
-	for rows.Next() {
-		var e models.Exercise
-		rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.MuscleGroup)
-		list = append(list, e)
-	}
-	return list, nil
-}
+	defer tx.Rollback()
 
-func (db *DB) GetExerciseByID(id int64) (*models.Exercise, error) {
-	e := &models.Exercise{}
-	err := db.QueryRow(`SELECT id, name, description, category, muscle_group FROM exercises WHERE id = ?`, id).
-		Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.MuscleGroup)
+	var old sql.NullString
+	err = tx.QueryRow(`SELECT timezone FROM users WHERE id = ?`, id).Scan(&old)
 	if err == sql.ErrNoRows {
-		return nil, nil
+		return nil, ErrUserNotFound
 	}
-	return e, err
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE users SET timezone = ? WHERE id = ?`, timeZone, id); err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, id, "update", "user", id, map[string]string{"timezone": old.String}, map[string]string{"timezone": timeZone}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetUserByID(id)
 }
 
-// ---- Workouts ----
-
-func (db *DB) CreateWorkout(userID int64, req models.CreateWorkoutRequest) (*models.Workout, error) {
+// UpdateUserRestDays sets how many days in a row without a workout keep
+// the user's streak going.
+func (db *DB) UpdateUserRestDays(id int64, restDays int) (*models.User, error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	var scheduledStr interface{}
-	if req.ScheduledAt != nil {
-		scheduledStr = req.ScheduledAt.Format(time.RFC3339)
+	var old int
+	err = tx.QueryRow(`SELECT rest_days FROM users WHERE id = ?`, id).Scan(&old)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
 	}
-
-	res, err := tx.Exec(`INSERT INTO workouts (user_id, title, description, scheduled_at, status) VALUES (?, ?, ?, ?, 'pending')`,
-		userID, req.Title, req.Description, scheduledStr)
 	if err != nil {
 		return nil, err
 	}
-	wid, _ := res.LastInsertId()
-
-	for _, e := range req.Exercises {
-		_, err := tx.Exec(`INSERT INTO workout_exercises (workout_id, exercise_id, sets, reps, weight_kg, duration_sec, notes) VALUES (?,?,?,?,?,?,?)`,
-			wid, e.ExerciseID, e.Sets, e.Reps, e.WeightKg, e.DurationSec, e.Notes)
-		if err != nil {
-			return nil, err
-		}
+	if _, err := tx.Exec(`UPDATE users SET rest_days = ? WHERE id = ?`, restDays, id); err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, id, "update", "user", id, map[string]int{"rest_days": old}, map[string]int{"rest_days": restDays}); err != nil {
+		return nil, err
 	}
-
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	return db.GetWorkoutByID(wid, userID)
+	return db.GetUserByID(id)
 }
 
-func (db *DB) GetWorkoutByID(id, userID int64) (*models.Workout, error) {
-	w := &models.Workout{}
-	var scheduledStr, completedStr sql.NullString
-	err := db.QueryRow(`SELECT id, user_id, title, description, comment, status, scheduled_at, completed_at, created_at, updated_at FROM workouts WHERE id = ? AND user_id = ?`, id, userID).
-		Scan(&w.ID, &w.UserID, &w.Title, &w.Description, &w.Comment, &w.Status, &scheduledStr, &completedStr, &w.CreatedAt, &w.UpdatedAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
+// userLocation loads the user's time zone, falling back to UTC.
+func (db *DB) userLocation(userID int64) (*time.Location, error) {
+	u, err := db.GetUserByID(userID)
+	if err != nil || u == nil {
+		return time.UTC, err
 	}
-	if err != nil {
+	return u.Location(), nil
+}
+
+// ---- Exercises ----
+
+const exerciseColumns = `id, name, description, category, muscle_group, user_id, client_id, updated_at`
+
+func scanExercise(row rowScanner) (*models.Exercise, error) {
+	e := &models.Exercise{}
+	var clientID, updatedStr sql.NullString
+	if err := row.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.MuscleGroup, &e.UserID, &clientID, &updatedStr); err != nil {
 		return nil, err
 	}
-	if scheduledStr.Valid {
-		t, _ := time.Parse(time.RFC3339, scheduledStr.String)
-		w.ScheduledAt = &t
-	}
-	if completedStr.Valid {
-		t, _ := time.Parse(time.RFC3339, completedStr.String)
-		w.CompletedAt = &t
+	e.ClientID = clientID.String
+	var err error
+	if e.UpdatedAt, err = parseNullTime(updatedStr); err != nil {
+		return nil, err
 	}
-	w.Exercises, _ = db.getWorkoutExercises(id)
-	return w, nil
+	return e, nil
 }
 
-func (db *DB) getWorkoutExercises(workoutID int64) ([]models.WorkoutExercise, error) {
-	rows, err := db.Query(`
-		SELECT we.id, we.workout_id, we.exercise_id, we.sets, we.reps, we.weight_kg, we.duration_sec, we.notes,
-		       e.id, e.name, e.description, e.category, e.muscle_group
-		FROM workout_exercises we
-		JOIN exercises e ON e.id = we.exercise_id
-		WHERE we.workout_id = ?`, workoutID)
+// GetExercises returns the shared library plus the user's custom exercises.
+func (db *DB) GetExercises(userID int64) ([]models.Exercise, error) {
+	rows, err := db.Query(`SELECT `+exerciseColumns+` FROM exercises
+		WHERE user_id IS NULL OR user_id = ? ORDER BY category, name`, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var list []models.WorkoutExercise
+	var list []models.Exercise
 	for rows.Next() {
-		var we models.WorkoutExercise
-		e := &models.Exercise{}
-		rows.Scan(&we.ID, &we.WorkoutID, &we.ExerciseID, &we.Sets, &we.Reps, &we.WeightKg, &we.DurationSec, &we.Notes,
-			&e.ID, &e.Name, &e.Description, &e.Category, &e.MuscleGroup)
-		we.Exercise = e
-		list = append(list, we)
-	}
-	return list, nil
-}
-
-func (db *DB) ListWorkouts(userID int64, status string) ([]models.Workout, error) {
-	query := `SELECT id, user_id, title, description, comment, status, scheduled_at, completed_at, created_at, updated_at FROM workouts WHERE user_id = ?`
-	args := []interface{}{userID}
-	if status != "" {
-		query += ` AND status = ?`
-		args = append(args, status)
+		e, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
 	}
-	query += ` ORDER BY COALESCE(scheduled_at, created_at) ASC`
+	return list, rows.Err()
+}
 
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		return nil, err
+func (db *DB) GetExerciseByID(id int64) (*models.Exercise, error) {
+	e, err := scanExercise(db.QueryRow(`SELECT `+exerciseColumns+` FROM exercises WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	defer rows.Close()
+	return e, err
+}
 
-	var list []models.Workout
-	for rows.Next() {
-		var w models.Workout
-		var scheduledStr, completedStr sql.NullString
-		rows.Scan(&w.ID, &w.UserID, &w.Title, &w.Description, &w.Comment, &w.Status, &scheduledStr, &completedStr, &w.CreatedAt, &w.UpdatedAt)
-		if scheduledStr.Valid {
-			t, _ := time.Parse(time.RFC3339, scheduledStr.String)
-			w.ScheduledAt = &t
-		}
-		if completedStr.Valid {
-			t, _ := time.Parse(time.RFC3339, completedStr.String)
-			w.CompletedAt = &t
-		}
-		w.Exercises, _ = db.getWorkoutExercises(w.ID)
-		list = append(list, w)
+// GetExerciseByName looks a name up in the shared library.
+func (db *DB) GetExerciseByName(name string) (*models.Exercise, error) {
+	e, err := scanExercise(db.QueryRow(`SELECT `+exerciseColumns+` FROM exercises
+		WHERE LOWER(name) = LOWER(?) AND user_id IS NULL`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	return list, nil
+	return e, err
 }
 
-func (db *DB) UpdateWorkout(id, userID int64, req models.UpdateWorkoutRequest) (*models.Workout, error) {
+// CreateCustomExercise adds an exercise that only its owner can see.
+// clientID, if not empty, is the ID a syncing client gave it.
+func (db *DB) CreateCustomExercise(userID int64, name, category, muscleGroup, clientID string) (*models.Exercise, error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	existing, err := db.GetWorkoutByID(id, userID)
-	if err != nil || existing == nil {
-		return nil, fmt.Errorf("workout not found")
-	}
-
-	title := existing.Title
-	desc := existing.Description
-	comment := existing.Comment
-	status := existing.Status
-	var scheduledStr interface{}
-	if existing.ScheduledAt != nil {
-		scheduledStr = existing.ScheduledAt.Format(time.RFC3339)
-	}
-
-	if req.Title != nil {
-		title = *req.Title
-	}
-	if req.Description != nil {
-		desc = *req.Description
-	}
-	if req.Comment != nil {
-		comment = *req.Comment
-	}
-	if req.Status != nil {
-		status = *req.Status
-	}
-	if req.ScheduledAt != nil {
-		scheduledStr = req.ScheduledAt.Format(time.RFC3339)
-	}
-
-	var completedStr interface{}
-	if status == "completed" {
-		completedStr = time.Now().Format(time.RFC3339)
+	id, err := insertID(tx, `INSERT INTO exercises (name, description, category, muscle_group, user_id, client_id, updated_at) VALUES (?, '', ?, ?, ?, ?, ?)`,
+		name, category, muscleGroup, userID, nullString(clientID), formatTime(now()))
+	if isUniqueViolation(err) {
+		return nil, ErrClientIDTaken
 	}
-
-	_, err = tx.Exec(`UPDATE workouts SET title=?, description=?, comment=?, status=?, scheduled_at=?, completed_at=?, updated_at=CURRENT_TIMESTAMP WHERE id=? AND user_id=?`,
-		title, desc, comment, status, scheduledStr, completedStr, id, userID)
 	if err != nil {
 		return nil, err
 	}
-
-	if req.Exercises != nil {
-		_, err = tx.Exec(`DELETE FROM workout_exercises WHERE workout_id = ?`, id)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range req.Exercises {
-			_, err := tx.Exec(`INSERT INTO workout_exercises (workout_id, exercise_id, sets, reps, weight_kg, duration_sec, notes) VALUES (?,?,?,?,?,?,?)`,
-				id, e.ExerciseID, e.Sets, e.Reps, e.WeightKg, e.DurationSec, e.Notes)
-			if err != nil {
-				return nil, err
-			}
-		}
+	if err := db.audit(tx, userID, "create", "exercise", id, nil, exerciseSnapshot(name, category, muscleGroup)); err != nil {
+		return nil, err
 	}
-
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	return db.GetWorkoutByID(id, userID)
+	return db.GetExerciseByID(id)
 }
 
-func (db *DB) DeleteWorkout(id, userID int64) error {
-	res, err := db.Exec(`DELETE FROM workouts WHERE id = ? AND user_id = ?`, id, userID)
+// UpdateCustomExercise changes one of the user's custom exercises.
+func (db *DB) UpdateCustomExercise(id, userID int64, name, category, muscleGroup string) (*models.Exercise, error) {
+	tx, err := db.Begin()
 	if err != nil {
-		return err
-	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return fmt.Errorf("workout not found")
+		return nil, err
 	}
-	return nil
-}
+	defer tx.Rollback()
 
-func (db *DB) GetReport(userID int64) (*models.WorkoutReport, error) {
-	report := &models.WorkoutReport{}
-
-	db.QueryRow(`SELECT COUNT(*) FROM workouts WHERE user_id = ?`, userID).Scan(&report.TotalWorkouts)
-	db.QueryRow(`SELECT COUNT(*) FROM workouts WHERE user_id = ? AND status = 'completed'`, userID).Scan(&report.CompletedWorkouts)
-
-	var totalVol sql.NullFloat64
-	db.QueryRow(`
-		SELECT SUM(we.sets * we.reps * we.weight_kg)
-		FROM workout_exercises we
-		JOIN workouts w ON w.id = we.workout_id
-		WHERE w.user_id = ? AND w.status = 'completed'`, userID).Scan(&totalVol)
-	if totalVol.Valid {
-		report.TotalVolumeKg = totalVol.Float64
-	}
-
-	// avg per week
-	var firstDate sql.NullString
-	db.QueryRow(`SELECT MIN(created_at) FROM workouts WHERE user_id = ?`, userID).Scan(&firstDate)
-	if firstDate.Valid && report.TotalWorkouts > 0 {
-		t, _ := time.Parse("2006-01-02 15:04:05", firstDate.String)
-		weeks := time.Since(t).Hours() / 168
-		if weeks < 1 {
-			weeks = 1
-		}
-		report.AvgWorkoutsPerWeek = float64(report.TotalWorkouts) / weeks
+	var oldName, oldCategory string
+	var oldMuscle sql.NullString
+	err = tx.QueryRow(`SELECT name, category, muscle_group FROM exercises WHERE id = ? AND user_id = ?`, id, userID).
+		Scan(&oldName, &oldCategory, &oldMuscle)
+	if err == sql.ErrNoRows {
+		return nil, ErrExerciseNotFound
 	}
-
-	// most used exercise
-	var exName sql.NullString
-	db.QueryRow(`
-		SELECT e.name FROM workout_exercises we
-		JOIN exercises e ON e.id = we.exercise_id
-		JOIN workouts w ON w.id = we.workout_id
-		WHERE w.user_id = ?
-		GROUP BY we.exercise_id ORDER BY COUNT(*) DESC LIMIT 1`, userID).Scan(&exName)
-	if exName.Valid {
-		report.MostUsedExercise = exName.String
+	if err != nil {
+		return nil, err
 	}
+	if _, err := tx.Exec(`UPDATE exercises SET name = ?, category = ?, muscle_group = ?, updated_at = ? WHERE id = ? AND user_id = ?`,
+		name, category, muscleGroup, formatTime(now()), id, userID); err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, userID, "update", "exercise", id,
+		exerciseSnapshot(oldName, oldCategory, oldMuscle.String), exerciseSnapshot(name, category, muscleGroup)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetExerciseByID(id)
+}
 
-	workouts, _ := db.ListWorkouts(userID, "completed")
-	report.Workouts = workouts
-
-	return report, nil
+// exerciseSnapshot is what the audit log compares of a custom exercise.
+func exerciseSnapshot(name, category, muscleGroup string) map[string]string {
+	return map[string]string{"name": name, "category": category, "muscle_group": muscleGroup}
 }
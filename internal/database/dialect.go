@@ -0,0 +1,128 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// dialect is the SQL flavour of the open database. Queries are written
+// once with ? placeholders and rewritten for PostgreSQL on the way out.
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// rebind turns ? placeholders into $1, $2, ... for PostgreSQL. Question
+// marks inside string literals are left alone.
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n, quoted := 0, false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			quoted = !quoted
+		case ch == '?' && !quoted:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+// Exec runs on the writer.
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.writer.Exec(db.dialect.rebind(query), args...)
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.dialect.rebind(query), args...)
+}
+
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.dialect.rebind(query), args...)
+}
+
+// Prepare prepares on the writer; it is used for bulk inserts.
+func (db *DB) Prepare(query string) (*sql.Stmt, error) {
+	return db.writer.Prepare(db.dialect.rebind(query))
+}
+
+// Begin starts a transaction on the writer that rewrites placeholders like
+// DB does.
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.writer.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: db.dialect}, nil
+}
+
+type Tx struct {
+	*sql.Tx
+	dialect dialect
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.rebind(query), args...)
+}
+
+// lockUser makes the transaction wait for any other that is writing the
+// user's data, for writes that depend on what the user logged before,
+// such as flagging personal records. SQLite transactions take the write
+// lock when they begin, so only PostgreSQL needs it.
+func (tx *Tx) lockUser(userID int64) error {
+	if tx.dialect != dialectPostgres {
+		return nil
+	}
+	_, err := tx.Exec(`SELECT id FROM users WHERE id = ? FOR UPDATE`, userID)
+	return err
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// querier is a DB or Tx, for helpers that read several rows either way.
+type querier interface {
+	queryRower
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// writes is a queryRower on the writer, for INSERT ... RETURNING outside a
+// transaction.
+func (db *DB) writes() queryRower {
+	return writeConn{db}
+}
+
+type writeConn struct{ db *DB }
+
+func (w writeConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return w.db.writer.QueryRow(w.db.dialect.rebind(query), args...)
+}
+
+// insertID runs an INSERT and returns the new row's id. LastInsertId is not
+// available on PostgreSQL, so both backends use RETURNING.
+func insertID(q queryRower, query string, args ...interface{}) (int64, error) {
+	var id int64
+	err := q.QueryRow(query+` RETURNING id`, args...).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,19 @@
+package database
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct{ in, sqlite, postgres string }{
+		{"SELECT 1", "SELECT 1", "SELECT 1"},
+		{"WHERE a = ? AND b = ?", "WHERE a = ? AND b = ?", "WHERE a = $1 AND b = $2"},
+		{`LIKE ? ESCAPE '\' OR c = '?' OR d = ?`, `LIKE ? ESCAPE '\' OR c = '?' OR d = ?`, `LIKE $1 ESCAPE '\' OR c = '?' OR d = $2`},
+	}
+	for _, tt := range tests {
+		if got := dialectSQLite.rebind(tt.in); got != tt.sqlite {
+			t.Errorf("sqlite rebind(%q) = %q", tt.in, got)
+		}
+		if got := dialectPostgres.rebind(tt.in); got != tt.postgres {
+			t.Errorf("postgres rebind(%q) = %q, want %q", tt.in, got, tt.postgres)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"workout-tracker/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Kinds of failure a caller can act on. Errors the store returns for them
+// wrap one of these, so errors.Is(err, ErrNotFound) works; any other error
+// is an internal failure whose message is not meant for clients.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrForbidden  = errors.New("forbidden")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
+// Error is a domain error: its kind, a stable machine-readable code such as
+// "workout_not_found", and a message that is safe to show users.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []models.FieldError // the offending fields of an ErrValidation
+}
+
+func (e *Error) Error() string { return e.Message }
+func (e *Error) Unwrap() error { return e.Kind }
+
+func NotFound(code, message string) error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+func Conflict(code, message string) error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func Invalid(code, message string, fields ...models.FieldError) error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message, Fields: fields}
+}
+
+var (
+	ErrUserNotFound       = NotFound("user_not_found", "user not found")
+	ErrWorkoutNotFound    = NotFound("workout_not_found", "workout not found")
+	ErrExerciseNotFound   = NotFound("exercise_not_found", "exercise not found")
+	ErrSessionNotFound    = NotFound("session_not_found", "no active session")
+	ErrSessionActive      = Conflict("session_active", "workout already has an active session")
+	ErrEmailTaken         = Conflict("email_taken", "email already registered")
+	ErrVersionMismatch    = Conflict("version_mismatch", "workout was changed by another request")
+	ErrClientIDTaken      = Conflict("client_id_taken", "client_id already used")
+	ErrAlreadyImported    = Conflict("already_imported", "workout already imported")
+	ErrSyncKeyInUse       = Conflict("idempotency_key_in_use", "a mutation with this idempotency key is still being applied")
+	ErrCommentNotFound    = NotFound("comment_not_found", "comment not found")
+	ErrAthleteNotFound    = NotFound("athlete_not_found", "you do not coach this athlete")
+	ErrCoachNotFound      = NotFound("coach_not_found", "no coach link with this user")
+	ErrScopeNotGranted    = Forbidden("scope_not_granted", "the athlete has not granted you this permission")
+	ErrCoachLinkExists    = Conflict("coach_link_exists", "you already coach or invited this athlete")
+	ErrTeamNotFound       = NotFound("team_not_found", "team not found")
+	ErrChallengeNotFound  = NotFound("challenge_not_found", "challenge not found")
+	ErrTeamOwnerRequired  = Forbidden("team_owner_required", "only the team's owner can do this")
+	ErrTeamOwnerLeaving   = Conflict("team_owner_cannot_leave", "the owner cannot leave the team; delete it instead")
+	ErrGoalNotFound       = NotFound("goal_not_found", "goal not found")
+	ErrBodyWeightNotFound = NotFound("body_weight_not_found", "body weight not found")
+
+	ErrCannotFollowSelf = Invalid("cannot_follow_self", "you cannot follow yourself", models.FieldError{
+		Parameter: "id", Code: "cannot_follow_self", Message: "is your own user id",
+	})
+	ErrCannotCoachSelf = Invalid("cannot_coach_self", "you cannot coach yourself", models.FieldError{
+		Pointer: "/email", Code: "cannot_coach_self", Message: "is your own email",
+	})
+)
+
+// isUniqueViolation and isForeignKeyViolation recognise constraint errors
+// from both SQLite drivers, which share SQLite's messages, and PostgreSQL.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23503"
+	}
+	return err != nil && strings.Contains(err.Error(), "FOREIGN KEY constraint failed")
+}
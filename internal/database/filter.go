@@ -0,0 +1,86 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"workout-tracker/internal/models"
+)
+
+// MaxPageSize caps the limit parameter of workout listings.
+const MaxPageSize = 200
+
+// sortColumns maps the public sort names onto SQL expressions over the
+// workouts table aliased as w.
+var sortColumns = map[string]string{
+	"date":    "COALESCE(w.scheduled_at, w.created_at)",
+	"created": "w.created_at",
+	"title":   "w.title",
+}
+
+// ValidSort reports whether s is a sort accepted by ListWorkouts: one of the
+// keys of sortColumns, optionally prefixed with "-" for descending order.
+func ValidSort(s string) bool {
+	_, ok := sortColumns[strings.TrimPrefix(s, "-")]
+	return s == "" || ok
+}
+
+// workoutFilterSQL builds the WHERE conditions shared by workout listings
+// and the report. Status is left to the caller because the report counts
+// every status. The returned clause starts with " AND" or is empty.
+func workoutFilterSQL(f models.WorkoutFilter) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	date := sortColumns["date"]
+	if f.From != nil {
+		b.WriteString(" AND " + date + " >= ?")
+		args = append(args, formatTime(*f.From))
+	}
+	if f.To != nil {
+		b.WriteString(" AND " + date + " < ?")
+		args = append(args, formatTime(f.To.AddDate(0, 0, 1)))
+	}
+	if f.ExerciseID != 0 {
+		b.WriteString(" AND EXISTS (SELECT 1 FROM workout_exercises fe WHERE fe.workout_id = w.id AND fe.exercise_id = ?)")
+		args = append(args, f.ExerciseID)
+	}
+	if q := strings.TrimSpace(f.Query); q != "" {
+		like := "%" + escapeLike(strings.ToLower(q)) + "%"
+		b.WriteString(` AND (LOWER(w.title) LIKE ? ESCAPE '\' OR LOWER(w.description) LIKE ? ESCAPE '\')`)
+		args = append(args, like, like)
+	}
+	return b.String(), args
+}
+
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// pageCursor is the position after the last workout of a page: its sort key
+// and ID, so that the next page starts strictly after it.
+type pageCursor struct {
+	Key string `json:"k"`
+	ID  int64  `json:"id"`
+}
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+var errInvalidCursor = Invalid("invalid_cursor", "invalid cursor", models.FieldError{
+	Parameter: "cursor", Code: "invalid_cursor", Message: "not a cursor returned by this API",
+})
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,484 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+	"workout-tracker/internal/achievements"
+	"workout-tracker/internal/models"
+)
+
+// ---- Goals and achievements ----
+
+const goalQuery = `SELECT id, kind, target, period, exercise_id, start_value, created_at FROM goals`
+
+func scanGoal(row rowScanner) (*models.Goal, error) {
+	g := &models.Goal{}
+	var created string
+	var period sql.NullString
+	var exerciseID sql.NullInt64
+	var start sql.NullFloat64
+	if err := row.Scan(&g.ID, &g.Kind, &g.Target, &period, &exerciseID, &start, &created); err != nil {
+		return nil, err
+	}
+	g.Period = period.String
+	if exerciseID.Valid {
+		g.ExerciseID = &exerciseID.Int64
+	}
+	if start.Valid {
+		g.StartValue = &start.Float64
+	}
+	var err error
+	g.CreatedAt, err = parseTime(created)
+	return g, err
+}
+
+// CreateGoal sets userID a goal. Sessions and cardio distance count per
+// week unless the request says month; a lift must be on an exercise the
+// user can log; a body weight goal starts from the latest weight logged.
+func (db *DB) CreateGoal(userID int64, req models.CreateGoalRequest) (*models.Goal, error) {
+	var period interface{}
+	var exerciseID *int64
+	switch req.Kind {
+	case "sessions", "cardio_distance":
+		period = "week"
+		if req.Period != "" {
+			period = req.Period
+		}
+	case "lift":
+		exerciseID = req.ExerciseID
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if exerciseID != nil {
+		var found int
+		err := tx.QueryRow(`SELECT 1 FROM exercises WHERE id = ? AND (user_id IS NULL OR user_id = ?)`, *exerciseID, userID).Scan(&found)
+		if err == sql.ErrNoRows {
+			return nil, Invalid("unknown_exercise", "exercise not found", models.FieldError{
+				Pointer: "/exercise_id",
+				Code:    "unknown_exercise",
+				Message: fmt.Sprintf("no exercise with id %d", *exerciseID),
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var start sql.NullFloat64
+	if req.Kind == "bodyweight" {
+		err := tx.QueryRow(`SELECT weight_kg FROM body_weights WHERE user_id = ? ORDER BY measured_at DESC, id DESC LIMIT 1`, userID).Scan(&start)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	id, err := insertID(tx, `INSERT INTO goals (user_id, kind, target, period, exercise_id, start_value, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, req.Kind, req.Target, period, exerciseID, start, formatTime(now()))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, userID, "create", "goal", id, nil, map[string]interface{}{"kind": req.Kind, "target": req.Target}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetGoal(id, userID)
+}
+
+// ListGoals returns userID's goals, oldest first, with their progress.
+func (db *DB) ListGoals(userID int64) ([]models.Goal, error) {
+	rows, err := db.Query(goalQuery+` WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.Goal{}
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, *g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	loc, err := db.userLocation(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if err := db.goalProgress(userID, &list[i], loc); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// GetGoal returns one of userID's goals with its progress, or nil if there
+// is no such goal.
+func (db *DB) GetGoal(id, userID int64) (*models.Goal, error) {
+	g, err := scanGoal(db.QueryRow(goalQuery+` WHERE id = ? AND user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	loc, err := db.userLocation(userID)
+	if err != nil {
+		return nil, err
+	}
+	return g, db.goalProgress(userID, g, loc)
+}
+
+func (db *DB) DeleteGoal(id, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	g, err := scanGoal(tx.QueryRow(goalQuery+` WHERE id = ? AND user_id = ?`, id, userID))
+	if err == sql.ErrNoRows {
+		return ErrGoalNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM goals WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, userID, "delete", "goal", id, map[string]interface{}{"kind": g.Kind, "target": g.Target}, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// goalProgress fills in how far userID is toward g. Periods are the
+// current week or month in loc.
+func (db *DB) goalProgress(userID int64, g *models.Goal, loc *time.Location) error {
+	p := &g.Progress
+	if g.Period != "" {
+		t := time.Now().In(loc)
+		from := models.StartOfWeek(t, loc)
+		to := from.AddDate(0, 0, 7)
+		if g.Period == "month" {
+			from = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+			to = from.AddDate(0, 1, 0)
+		}
+		p.From, p.To = &from, &to
+	}
+
+	var err error
+	switch g.Kind {
+	case "sessions":
+		err = db.QueryRow(`SELECT COUNT(*) FROM workouts w WHERE w.user_id = ? AND w.status = 'completed'
+			AND w.completed_at >= ? AND w.completed_at < ?`+notTrashed, userID, formatTime(*p.From), formatTime(*p.To)).Scan(&p.Current)
+	case "cardio_distance":
+		var m sql.NullFloat64
+		err = db.QueryRow(`SELECT SUM(we.distance_m) FROM workout_exercises we
+			JOIN exercises e ON e.id = we.exercise_id JOIN workouts w ON w.id = we.workout_id
+			WHERE w.user_id = ? AND w.status = 'completed' AND e.category = 'cardio'
+			AND w.completed_at >= ? AND w.completed_at < ?`+notTrashed, userID, formatTime(*p.From), formatTime(*p.To)).Scan(&m)
+		p.Current = math.Round(m.Float64/10) / 100
+	case "lift":
+		p.Current, err = bestWeight(db, userID, *g.ExerciseID)
+	case "bodyweight":
+		return db.bodyWeightProgress(userID, g)
+	}
+	if err != nil {
+		return err
+	}
+	p.Achieved = p.Current >= g.Target
+	p.Percent = percent(p.Current, g.Target)
+	return nil
+}
+
+// bodyWeightProgress measures a body weight goal from where it started,
+// or from the first weight logged if there was none then, toward the
+// target in whichever direction that lies.
+func (db *DB) bodyWeightProgress(userID int64, g *models.Goal) error {
+	p := &g.Progress
+	var latest, first sql.NullFloat64
+	err := db.QueryRow(`SELECT weight_kg FROM body_weights WHERE user_id = ? ORDER BY measured_at DESC, id DESC LIMIT 1`, userID).Scan(&latest)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p.Current = latest.Float64
+	start := g.StartValue
+	if start == nil {
+		if err := db.QueryRow(`SELECT weight_kg FROM body_weights WHERE user_id = ? ORDER BY measured_at, id LIMIT 1`, userID).Scan(&first); err != nil {
+			return err
+		}
+		start = &first.Float64
+	}
+	if *start == g.Target {
+		p.Achieved = p.Current == g.Target
+	} else if *start > g.Target {
+		p.Achieved = p.Current <= g.Target
+	} else {
+		p.Achieved = p.Current >= g.Target
+	}
+	if *start != g.Target {
+		p.Percent = percent(*start-p.Current, *start-g.Target)
+	}
+	if p.Achieved {
+		p.Percent = 100
+	}
+	return nil
+}
+
+// percent is how much of target done is, from 0 to 100, to one decimal.
+func percent(done, target float64) float64 {
+	return math.Round(math.Max(0, math.Min(done/target, 1))*1000) / 10
+}
+
+// LogBodyWeight records a body weight, measured now unless said otherwise.
+func (db *DB) LogBodyWeight(userID int64, req models.LogBodyWeightRequest) (*models.BodyWeight, error) {
+	b := &models.BodyWeight{WeightKg: req.WeightKg, MeasuredAt: now()}
+	if req.MeasuredAt != nil {
+		b.MeasuredAt = req.MeasuredAt.UTC()
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	b.ID, err = insertID(tx, `INSERT INTO body_weights (user_id, weight_kg, measured_at) VALUES (?, ?, ?)`, userID, b.WeightKg, formatTime(b.MeasuredAt))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, userID, "create", "body_weight", b.ID, nil, bodyWeightSnapshot(b)); err != nil {
+		return nil, err
+	}
+	return b, tx.Commit()
+}
+
+func bodyWeightSnapshot(b *models.BodyWeight) map[string]interface{} {
+	return map[string]interface{}{"weight_kg": b.WeightKg, "measured_at": formatTime(b.MeasuredAt)}
+}
+
+// ListBodyWeights returns userID's body weights, latest first.
+func (db *DB) ListBodyWeights(userID int64) ([]models.BodyWeight, error) {
+	rows, err := db.Query(`SELECT id, weight_kg, measured_at FROM body_weights WHERE user_id = ? ORDER BY measured_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.BodyWeight{}
+	for rows.Next() {
+		var b models.BodyWeight
+		var measured string
+		if err := rows.Scan(&b.ID, &b.WeightKg, &measured); err != nil {
+			return nil, err
+		}
+		if b.MeasuredAt, err = parseTime(measured); err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+func (db *DB) DeleteBodyWeight(id, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	b := &models.BodyWeight{ID: id}
+	var measured string
+	err = tx.QueryRow(`SELECT weight_kg, measured_at FROM body_weights WHERE id = ? AND user_id = ?`, id, userID).Scan(&b.WeightKg, &measured)
+	if err == sql.ErrNoRows {
+		return ErrBodyWeightNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if b.MeasuredAt, err = parseTime(measured); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM body_weights WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, userID, "delete", "body_weight", id, bodyWeightSnapshot(b), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadStreak measures userID's streak over the days, in their time zone,
+// on which they completed a workout.
+func loadStreak(q querier, userID int64) (*models.Streak, error) {
+	var user models.User
+	var tz sql.NullString
+	if err := q.QueryRow(`SELECT timezone, rest_days FROM users WHERE id = ?`, userID).Scan(&tz, &user.RestDays); err != nil {
+		return nil, err
+	}
+	user.TimeZone = tz.String
+	loc := user.Location()
+
+	rows, err := q.Query(`SELECT w.completed_at FROM workouts w WHERE w.user_id = ? AND w.status = 'completed'
+		AND w.completed_at IS NOT NULL`+notTrashed+` ORDER BY w.completed_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var completed []time.Time
+	for rows.Next() {
+		var at string
+		if err := rows.Scan(&at); err != nil {
+			return nil, err
+		}
+		t, err := parseTime(at)
+		if err != nil {
+			return nil, err
+		}
+		completed = append(completed, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return streakOver(completed, loc, user.RestDays), nil
+}
+
+// streakOver measures a streak over the times, in order, at which workouts
+// were completed, counting days in loc.
+func streakOver(completed []time.Time, loc *time.Location, restDays int) *models.Streak {
+	var days []int
+	for _, t := range completed {
+		if day := achievements.Day(t, loc); len(days) == 0 || day != days[len(days)-1] {
+			days = append(days, day)
+		}
+	}
+	s := &models.Streak{RestDays: restDays}
+	s.Current, s.Longest = achievements.Streaks(days, achievements.Day(time.Now(), loc), restDays)
+	if len(completed) > 0 {
+		s.LastWorkoutOn = completed[len(completed)-1].In(loc).Format("2006-01-02")
+	}
+	return s
+}
+
+// GetAchievements returns userID's streak and every badge, earned or not.
+func (db *DB) GetAchievements(userID int64) (*models.Achievements, error) {
+	streak, err := loadStreak(db, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	type earned struct {
+		at        time.Time
+		workoutID *int64
+	}
+	rows, err := db.Query(`SELECT code, workout_id, earned_at FROM achievements WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	got := map[string]earned{}
+	for rows.Next() {
+		var code, at string
+		var workoutID sql.NullInt64
+		if err := rows.Scan(&code, &workoutID, &at); err != nil {
+			return nil, err
+		}
+		e := earned{}
+		if e.at, err = parseTime(at); err != nil {
+			return nil, err
+		}
+		if workoutID.Valid {
+			e.workoutID = &workoutID.Int64
+		}
+		got[code] = e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	a := &models.Achievements{Streak: *streak, Badges: []models.Achievement{}}
+	for _, b := range achievements.Badges {
+		badge := models.Achievement{Code: b.Code, Name: b.Name, Description: b.Description}
+		if e, ok := got[b.Code]; ok {
+			badge.Earned, badge.EarnedAt, badge.WorkoutID = true, &e.at, e.workoutID
+		}
+		a.Badges = append(a.Badges, badge)
+	}
+	return a, nil
+}
+
+// awardAchievements runs the badge rules over userID's completed workouts
+// once workoutID has completed, and keeps the badges newly earned. It runs
+// in the transaction that completes the workout.
+func (db *DB) awardAchievements(tx *Tx, userID, workoutID int64) error {
+	var stats achievements.Stats
+	var volume, distance sql.NullFloat64
+	err := tx.QueryRow(`SELECT COUNT(DISTINCT w.id), SUM(we.sets * we.reps * we.weight_kg),
+		SUM(CASE WHEN e.category = 'cardio' THEN we.distance_m END)
+		FROM workouts w LEFT JOIN workout_exercises we ON we.workout_id = w.id LEFT JOIN exercises e ON e.id = we.exercise_id
+		WHERE w.user_id = ? AND w.status = 'completed'`+notTrashed, userID).Scan(&stats.Workouts, &volume, &distance)
+	if err != nil {
+		return err
+	}
+	stats.TotalVolumeKg, stats.CardioDistanceKm = volume.Float64, distance.Float64/1000
+
+	// Best lifts count live sets as well as completed workouts, as PRs do.
+	rows, err := tx.Query(`
+		SELECT e.name, MAX(lifts.weight) FROM (
+			SELECT ss.exercise_id, ss.weight_kg AS weight FROM session_sets ss
+			JOIN workout_sessions s ON s.id = ss.session_id
+			JOIN workouts w ON w.id = s.workout_id
+			WHERE s.user_id = ?`+notTrashed+`
+			UNION ALL
+			SELECT we.exercise_id, we.weight_kg FROM workout_exercises we
+			JOIN workouts w ON w.id = we.workout_id
+			WHERE w.user_id = ? AND w.status = 'completed'`+notTrashed+`
+		) AS lifts JOIN exercises e ON e.id = lifts.exercise_id AND e.user_id IS NULL
+		GROUP BY e.name`, userID, userID)
+	if err != nil {
+		return err
+	}
+	stats.BestLiftKg = map[string]float64{}
+	for rows.Next() {
+		var name string
+		var best sql.NullFloat64
+		if err := rows.Scan(&name, &best); err != nil {
+			rows.Close()
+			return err
+		}
+		stats.BestLiftKg[name] = best.Float64
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	streak, err := loadStreak(tx, userID)
+	if err != nil {
+		return err
+	}
+	stats.LongestStreak = streak.Longest
+
+	earnedAt := formatTime(now())
+	for _, b := range achievements.Earned(stats) {
+		if _, err := tx.Exec(`INSERT INTO achievements (user_id, code, workout_id, earned_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id, code) DO NOTHING`, userID, b.Code, workoutID, earnedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ---- Health ----
+
+// schemaVersion is the version of the schema this build migrates to. Bump
+// it with every change to the schema, so Ready holds back a server whose
+// database has not been migrated yet.
+const schemaVersion = 6
+
+// recordSchemaVersion notes that the schema is migrated to schemaVersion.
+// A database migrated by a newer build keeps its higher version.
+func (db *DB) recordSchemaVersion() error {
+	_, err := db.Exec(`INSERT INTO schema_version (id, version, migrated_at) VALUES (1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET version = excluded.version, migrated_at = excluded.migrated_at
+		WHERE schema_version.version < excluded.version`, schemaVersion, formatTime(now()))
+	return err
+}
+
+// migratedVersion is the schema version last recorded, or zero for a
+// database no migration has recorded one in yet.
+func (db *DB) migratedVersion() (int, error) {
+	var version int
+	err := db.QueryRow(`SELECT version FROM schema_version WHERE id = 1`).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+// Ready reports whether the database can serve requests: its connections
+// answer and its schema is migrated at least to the version this build
+// expects.
+func (db *DB) Ready(ctx context.Context) error {
+	if err := db.PingContext(ctx); err != nil {
+		return err
+	}
+	if db.writer != db.DB {
+		if err := db.writer.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT version FROM schema_version WHERE id = 1`).Scan(&version)
+	if err == sql.ErrNoRows || (err == nil && version < schemaVersion) {
+		return fmt.Errorf("schema is at version %d, want %d", version, schemaVersion)
+	}
+	return err
+}
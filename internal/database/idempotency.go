@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// ---- Idempotency keys ----
+
+// IdempotencyClaimTimeout is how long a claimed key waits for its response
+// without being renewed. A request still unanswered after that is taken to
+// have died with the process, and the key can be claimed again; one that is
+// still running renews its claim well within it.
+const IdempotencyClaimTimeout = time.Minute
+
+// ClaimIdempotencyKey reserves key for a request with the given
+// fingerprint, returning nil when the caller should go ahead and run it.
+// When the key is already taken it returns what is stored instead: the
+// response to replay, or one with Status 0 while the first attempt is still
+// running. Keys created before expiredBefore are free to reuse.
+func (db *DB) ClaimIdempotencyKey(userID int64, key, fingerprint string, expiredBefore time.Time) (*models.StoredResponse, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stored := &models.StoredResponse{}
+	var headers, body sql.NullString
+	var createdStr string
+	err = tx.QueryRow(`SELECT fingerprint, status, headers, body, created_at FROM idempotency_keys
+		WHERE user_id = ? AND idempotency_key = ?`, userID, key).Scan(&stored.Fingerprint, &stored.Status, &headers, &body, &createdStr)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec(`INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint, created_at) VALUES (?, ?, ?, ?)`,
+			userID, key, fingerprint, formatTime(now()))
+		if isUniqueViolation(err) {
+			// Claimed by a concurrent attempt a moment ago.
+			return &models.StoredResponse{Fingerprint: fingerprint}, nil
+		}
+	case err != nil:
+		return nil, err
+	default:
+		var created time.Time
+		if created, err = parseTime(createdStr); err != nil {
+			return nil, err
+		}
+		abandoned := stored.Status == 0 && created.Before(now().Add(-IdempotencyClaimTimeout))
+		if !created.Before(expiredBefore) && !abandoned {
+			stored.Body = []byte(body.String)
+			if headers.Valid {
+				if err := json.Unmarshal([]byte(headers.String), &stored.Header); err != nil {
+					return nil, err
+				}
+			}
+			return stored, nil
+		}
+		_, err = tx.Exec(`UPDATE idempotency_keys SET fingerprint = ?, status = 0, headers = NULL, body = NULL, created_at = ?
+			WHERE user_id = ? AND idempotency_key = ?`, fingerprint, formatTime(now()), userID, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, tx.Commit()
+}
+
+// SaveIdempotentResponse stores the response to the request that claimed
+// key, for retries to replay.
+func (db *DB) SaveIdempotentResponse(userID int64, key string, resp models.StoredResponse) error {
+	headers, err := json.Marshal(resp.Header)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE idempotency_keys SET status = ?, headers = ?, body = ? WHERE user_id = ? AND idempotency_key = ?`,
+		resp.Status, string(headers), string(resp.Body), userID, key)
+	return err
+}
+
+// RenewIdempotencyKey restarts the claim timeout of key while the request
+// that claimed it is still running. Keys with a stored response are left
+// alone.
+func (db *DB) RenewIdempotencyKey(userID int64, key string) error {
+	_, err := db.Exec(`UPDATE idempotency_keys SET created_at = ? WHERE user_id = ? AND idempotency_key = ? AND status = 0`,
+		formatTime(now()), userID, key)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets key, so a retry runs the request again.
+func (db *DB) ReleaseIdempotencyKey(userID int64, key string) error {
+	_, err := db.Exec(`DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ?`, userID, key)
+	return err
+}
+
+// PurgeIdempotencyKeys deletes the idempotency keys and stored sync results
+// created before the given time, and returns how many it deleted.
+func (db *DB) PurgeIdempotencyKeys(before time.Time) (int64, error) {
+	var total int64
+	for _, table := range []string{"idempotency_keys", "sync_results"} {
+		res, err := db.Exec(`DELETE FROM `+table+` WHERE created_at < ?`, formatTime(before))
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
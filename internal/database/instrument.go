@@ -0,0 +1,484 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// Observer is told how long each store call took, and whether it failed
+// with anything other than one of the store's own errors (not found,
+// conflict, validation), which are the client's doing.
+type Observer func(op string, d time.Duration, failed bool)
+
+// Instrument wraps s so that every call is reported to observe under the
+// name of its method. Streaming calls include the time spent in their
+// callbacks.
+func Instrument(s Store, observe Observer) Store {
+	return &instrumented{Store: s, observer: observe}
+}
+
+type instrumented struct {
+	Store
+	observer Observer
+}
+
+func (s *instrumented) observe(op string, start time.Time, err *error) {
+	var de *Error
+	s.observer(op, time.Since(start), *err != nil && !errors.As(*err, &de))
+}
+
+func (s *instrumented) As(actor Actor) Store {
+	return &instrumented{Store: s.Store.As(actor), observer: s.observer}
+}
+
+func (s *instrumented) Ready(ctx context.Context) (err error) {
+	defer s.observe("Ready", time.Now(), &err)
+	return s.Store.Ready(ctx)
+}
+
+func (s *instrumented) CreateUser(name, email, hash, timeZone string) (_ *models.User, err error) {
+	defer s.observe("CreateUser", time.Now(), &err)
+	return s.Store.CreateUser(name, email, hash, timeZone)
+}
+
+func (s *instrumented) GetUserByEmail(email string) (_ *models.User, err error) {
+	defer s.observe("GetUserByEmail", time.Now(), &err)
+	return s.Store.GetUserByEmail(email)
+}
+
+func (s *instrumented) GetUserByID(id int64) (_ *models.User, err error) {
+	defer s.observe("GetUserByID", time.Now(), &err)
+	return s.Store.GetUserByID(id)
+}
+
+func (s *instrumented) UpdateUserTimeZone(id int64, timeZone string) (_ *models.User, err error) {
+	defer s.observe("UpdateUserTimeZone", time.Now(), &err)
+	return s.Store.UpdateUserTimeZone(id, timeZone)
+}
+
+func (s *instrumented) UpdateUserRestDays(id int64, restDays int) (_ *models.User, err error) {
+	defer s.observe("UpdateUserRestDays", time.Now(), &err)
+	return s.Store.UpdateUserRestDays(id, restDays)
+}
+
+func (s *instrumented) GetExercises(userID int64) (_ []models.Exercise, err error) {
+	defer s.observe("GetExercises", time.Now(), &err)
+	return s.Store.GetExercises(userID)
+}
+
+func (s *instrumented) GetExerciseByID(id int64) (_ *models.Exercise, err error) {
+	defer s.observe("GetExerciseByID", time.Now(), &err)
+	return s.Store.GetExerciseByID(id)
+}
+
+func (s *instrumented) GetExerciseByName(name string) (_ *models.Exercise, err error) {
+	defer s.observe("GetExerciseByName", time.Now(), &err)
+	return s.Store.GetExerciseByName(name)
+}
+
+func (s *instrumented) CreateCustomExercise(userID int64, name, category, muscleGroup, clientID string) (_ *models.Exercise, err error) {
+	defer s.observe("CreateCustomExercise", time.Now(), &err)
+	return s.Store.CreateCustomExercise(userID, name, category, muscleGroup, clientID)
+}
+
+func (s *instrumented) UpdateCustomExercise(id, userID int64, name, category, muscleGroup string) (_ *models.Exercise, err error) {
+	defer s.observe("UpdateCustomExercise", time.Now(), &err)
+	return s.Store.UpdateCustomExercise(id, userID, name, category, muscleGroup)
+}
+
+func (s *instrumented) CreateWorkout(userID int64, req models.CreateWorkoutRequest) (_ *models.Workout, err error) {
+	defer s.observe("CreateWorkout", time.Now(), &err)
+	return s.Store.CreateWorkout(userID, req)
+}
+
+func (s *instrumented) CreateCompletedWorkout(userID int64, req models.CreateWorkoutRequest, completedAt time.Time) (_ *models.Workout, err error) {
+	defer s.observe("CreateCompletedWorkout", time.Now(), &err)
+	return s.Store.CreateCompletedWorkout(userID, req, completedAt)
+}
+
+func (s *instrumented) ImportedExternalIDs(userID int64, source string) (_ map[string]bool, err error) {
+	defer s.observe("ImportedExternalIDs", time.Now(), &err)
+	return s.Store.ImportedExternalIDs(userID, source)
+}
+
+func (s *instrumented) GetWorkoutByID(id, userID int64) (_ *models.Workout, err error) {
+	defer s.observe("GetWorkoutByID", time.Now(), &err)
+	return s.Store.GetWorkoutByID(id, userID)
+}
+
+func (s *instrumented) ListWorkouts(userID int64, f models.WorkoutFilter) (_ *models.WorkoutPage, err error) {
+	defer s.observe("ListWorkouts", time.Now(), &err)
+	return s.Store.ListWorkouts(userID, f)
+}
+
+func (s *instrumented) StreamWorkouts(userID int64, from, to *time.Time, fn func(*models.Workout) error) (err error) {
+	defer s.observe("StreamWorkouts", time.Now(), &err)
+	return s.Store.StreamWorkouts(userID, from, to, fn)
+}
+
+func (s *instrumented) UpdateWorkout(id, userID int64, req models.UpdateWorkoutRequest, ifVersion int) (_ *models.Workout, err error) {
+	defer s.observe("UpdateWorkout", time.Now(), &err)
+	return s.Store.UpdateWorkout(id, userID, req, ifVersion)
+}
+
+func (s *instrumented) SaveWorkout(id, userID int64, version int, state models.WorkoutState) (_ *models.Workout, err error) {
+	defer s.observe("SaveWorkout", time.Now(), &err)
+	return s.Store.SaveWorkout(id, userID, version, state)
+}
+
+func (s *instrumented) DeleteWorkout(id, userID int64, ifVersion int) (err error) {
+	defer s.observe("DeleteWorkout", time.Now(), &err)
+	return s.Store.DeleteWorkout(id, userID, ifVersion)
+}
+
+func (s *instrumented) ListTrash(userID int64) (_ []models.Workout, err error) {
+	defer s.observe("ListTrash", time.Now(), &err)
+	return s.Store.ListTrash(userID)
+}
+
+func (s *instrumented) RestoreWorkout(id, userID int64) (_ *models.Workout, err error) {
+	defer s.observe("RestoreWorkout", time.Now(), &err)
+	return s.Store.RestoreWorkout(id, userID)
+}
+
+func (s *instrumented) PurgeTrash(before time.Time) (_ int64, err error) {
+	defer s.observe("PurgeTrash", time.Now(), &err)
+	return s.Store.PurgeTrash(before)
+}
+
+func (s *instrumented) GetReport(userID int64, f models.WorkoutFilter) (_ *models.WorkoutReport, err error) {
+	defer s.observe("GetReport", time.Now(), &err)
+	return s.Store.GetReport(userID, f)
+}
+
+func (s *instrumented) StartSession(workoutID, userID int64) (_ *models.WorkoutSession, err error) {
+	defer s.observe("StartSession", time.Now(), &err)
+	return s.Store.StartSession(workoutID, userID)
+}
+
+func (s *instrumented) GetActiveSession(workoutID, userID int64) (_ *models.WorkoutSession, err error) {
+	defer s.observe("GetActiveSession", time.Now(), &err)
+	return s.Store.GetActiveSession(workoutID, userID)
+}
+
+func (s *instrumented) ListActiveSessions() (_ []models.WorkoutSession, err error) {
+	defer s.observe("ListActiveSessions", time.Now(), &err)
+	return s.Store.ListActiveSessions()
+}
+
+func (s *instrumented) LogSessionSet(workoutID, userID int64, req models.LogSetRequest) (_ *models.SessionSet, _ *models.WorkoutSession, err error) {
+	defer s.observe("LogSessionSet", time.Now(), &err)
+	return s.Store.LogSessionSet(workoutID, userID, req)
+}
+
+func (s *instrumented) FinishSession(workoutID, userID int64) (_ *models.WorkoutSession, err error) {
+	defer s.observe("FinishSession", time.Now(), &err)
+	return s.Store.FinishSession(workoutID, userID)
+}
+
+func (s *instrumented) Follow(followerID, followeeID int64) (_ *models.Follow, err error) {
+	defer s.observe("Follow", time.Now(), &err)
+	return s.Store.Follow(followerID, followeeID)
+}
+
+func (s *instrumented) Unfollow(followerID, followeeID int64) (err error) {
+	defer s.observe("Unfollow", time.Now(), &err)
+	return s.Store.Unfollow(followerID, followeeID)
+}
+
+func (s *instrumented) ListFollowing(userID int64) (_ []models.Follow, err error) {
+	defer s.observe("ListFollowing", time.Now(), &err)
+	return s.Store.ListFollowing(userID)
+}
+
+func (s *instrumented) ListFollowers(userID int64) (_ []models.Follow, err error) {
+	defer s.observe("ListFollowers", time.Now(), &err)
+	return s.Store.ListFollowers(userID)
+}
+
+func (s *instrumented) GetFeed(userID int64, limit int, cursor string) (_ *models.FeedPage, err error) {
+	defer s.observe("GetFeed", time.Now(), &err)
+	return s.Store.GetFeed(userID, limit, cursor)
+}
+
+func (s *instrumented) GetFeedItem(workoutID, viewerID int64) (_ *models.FeedItem, err error) {
+	defer s.observe("GetFeedItem", time.Now(), &err)
+	return s.Store.GetFeedItem(workoutID, viewerID)
+}
+
+func (s *instrumented) React(workoutID, userID int64, kind string) (err error) {
+	defer s.observe("React", time.Now(), &err)
+	return s.Store.React(workoutID, userID, kind)
+}
+
+func (s *instrumented) Unreact(workoutID, userID int64, kind string) (err error) {
+	defer s.observe("Unreact", time.Now(), &err)
+	return s.Store.Unreact(workoutID, userID, kind)
+}
+
+func (s *instrumented) ListComments(workoutID, viewerID int64) (_ []models.Comment, err error) {
+	defer s.observe("ListComments", time.Now(), &err)
+	return s.Store.ListComments(workoutID, viewerID)
+}
+
+func (s *instrumented) AddComment(workoutID, userID int64, body string) (_ *models.Comment, err error) {
+	defer s.observe("AddComment", time.Now(), &err)
+	return s.Store.AddComment(workoutID, userID, body)
+}
+
+func (s *instrumented) DeleteComment(id, workoutID, userID int64) (err error) {
+	defer s.observe("DeleteComment", time.Now(), &err)
+	return s.Store.DeleteComment(id, workoutID, userID)
+}
+
+func (s *instrumented) ShareWorkout(id, userID int64) (_ string, err error) {
+	defer s.observe("ShareWorkout", time.Now(), &err)
+	return s.Store.ShareWorkout(id, userID)
+}
+
+func (s *instrumented) UnshareWorkout(id, userID int64) (err error) {
+	defer s.observe("UnshareWorkout", time.Now(), &err)
+	return s.Store.UnshareWorkout(id, userID)
+}
+
+func (s *instrumented) GetSharedWorkout(token string) (_ *models.WorkoutSummary, err error) {
+	defer s.observe("GetSharedWorkout", time.Now(), &err)
+	return s.Store.GetSharedWorkout(token)
+}
+
+func (s *instrumented) InviteAthlete(coachID int64, email string, scopes []string) (_ *models.CoachLink, err error) {
+	defer s.observe("InviteAthlete", time.Now(), &err)
+	return s.Store.InviteAthlete(coachID, email, scopes)
+}
+
+func (s *instrumented) ListAthletes(coachID int64) (_ []models.CoachLink, err error) {
+	defer s.observe("ListAthletes", time.Now(), &err)
+	return s.Store.ListAthletes(coachID)
+}
+
+func (s *instrumented) ListCoaches(athleteID int64) (_ []models.CoachLink, err error) {
+	defer s.observe("ListCoaches", time.Now(), &err)
+	return s.Store.ListCoaches(athleteID)
+}
+
+func (s *instrumented) AcceptCoach(athleteID, coachID int64) (_ *models.CoachLink, err error) {
+	defer s.observe("AcceptCoach", time.Now(), &err)
+	return s.Store.AcceptCoach(athleteID, coachID)
+}
+
+func (s *instrumented) UpdateCoachScopes(athleteID, coachID int64, scopes []string) (_ *models.CoachLink, err error) {
+	defer s.observe("UpdateCoachScopes", time.Now(), &err)
+	return s.Store.UpdateCoachScopes(athleteID, coachID, scopes)
+}
+
+func (s *instrumented) RevokeCoach(athleteID, coachID int64) (err error) {
+	defer s.observe("RevokeCoach", time.Now(), &err)
+	return s.Store.RevokeCoach(athleteID, coachID)
+}
+
+func (s *instrumented) CheckCoach(coachID, athleteID int64, scope string) (err error) {
+	defer s.observe("CheckCoach", time.Now(), &err)
+	return s.Store.CheckCoach(coachID, athleteID, scope)
+}
+
+func (s *instrumented) DropAthlete(coachID, athleteID int64) (err error) {
+	defer s.observe("DropAthlete", time.Now(), &err)
+	return s.Store.DropAthlete(coachID, athleteID)
+}
+
+func (s *instrumented) ListAthleteWorkouts(coachID, athleteID int64, f models.WorkoutFilter) (_ *models.WorkoutPage, err error) {
+	defer s.observe("ListAthleteWorkouts", time.Now(), &err)
+	return s.Store.ListAthleteWorkouts(coachID, athleteID, f)
+}
+
+func (s *instrumented) GetAthleteWorkout(id, coachID, athleteID int64) (_ *models.Workout, err error) {
+	defer s.observe("GetAthleteWorkout", time.Now(), &err)
+	return s.Store.GetAthleteWorkout(id, coachID, athleteID)
+}
+
+func (s *instrumented) GetAthleteReport(coachID, athleteID int64, f models.WorkoutFilter) (_ *models.WorkoutReport, err error) {
+	defer s.observe("GetAthleteReport", time.Now(), &err)
+	return s.Store.GetAthleteReport(coachID, athleteID, f)
+}
+
+func (s *instrumented) AssignWorkout(coachID, athleteID int64, req models.CreateWorkoutRequest) (_ *models.Workout, err error) {
+	defer s.observe("AssignWorkout", time.Now(), &err)
+	return s.Store.AssignWorkout(coachID, athleteID, req)
+}
+
+func (s *instrumented) UpdateAthleteWorkout(id, coachID, athleteID int64, req models.UpdateWorkoutRequest, ifVersion int) (_ *models.Workout, err error) {
+	defer s.observe("UpdateAthleteWorkout", time.Now(), &err)
+	return s.Store.UpdateAthleteWorkout(id, coachID, athleteID, req, ifVersion)
+}
+
+func (s *instrumented) CreateTeam(userID int64, name string) (_ *models.Team, err error) {
+	defer s.observe("CreateTeam", time.Now(), &err)
+	return s.Store.CreateTeam(userID, name)
+}
+
+func (s *instrumented) ListTeams(userID int64) (_ []models.Team, err error) {
+	defer s.observe("ListTeams", time.Now(), &err)
+	return s.Store.ListTeams(userID)
+}
+
+func (s *instrumented) GetTeam(id, userID int64) (_ *models.Team, err error) {
+	defer s.observe("GetTeam", time.Now(), &err)
+	return s.Store.GetTeam(id, userID)
+}
+
+func (s *instrumented) JoinTeam(userID int64, code string) (_ *models.Team, err error) {
+	defer s.observe("JoinTeam", time.Now(), &err)
+	return s.Store.JoinTeam(userID, code)
+}
+
+func (s *instrumented) RemoveTeamMember(teamID, userID, memberID int64) (err error) {
+	defer s.observe("RemoveTeamMember", time.Now(), &err)
+	return s.Store.RemoveTeamMember(teamID, userID, memberID)
+}
+
+func (s *instrumented) DeleteTeam(id, userID int64) (err error) {
+	defer s.observe("DeleteTeam", time.Now(), &err)
+	return s.Store.DeleteTeam(id, userID)
+}
+
+func (s *instrumented) GetLeaderboard(teamID, userID int64, metric string, from, to *time.Time) (_ []models.LeaderboardEntry, err error) {
+	defer s.observe("GetLeaderboard", time.Now(), &err)
+	return s.Store.GetLeaderboard(teamID, userID, metric, from, to)
+}
+
+func (s *instrumented) CreateChallenge(teamID, userID int64, req models.CreateChallengeRequest) (_ *models.Challenge, err error) {
+	defer s.observe("CreateChallenge", time.Now(), &err)
+	return s.Store.CreateChallenge(teamID, userID, req)
+}
+
+func (s *instrumented) ListChallenges(teamID, userID int64) (_ []models.Challenge, err error) {
+	defer s.observe("ListChallenges", time.Now(), &err)
+	return s.Store.ListChallenges(teamID, userID)
+}
+
+func (s *instrumented) GetChallenge(id, teamID, userID int64) (_ *models.Challenge, err error) {
+	defer s.observe("GetChallenge", time.Now(), &err)
+	return s.Store.GetChallenge(id, teamID, userID)
+}
+
+func (s *instrumented) DeleteChallenge(id, teamID, userID int64) (err error) {
+	defer s.observe("DeleteChallenge", time.Now(), &err)
+	return s.Store.DeleteChallenge(id, teamID, userID)
+}
+
+func (s *instrumented) CreateGoal(userID int64, req models.CreateGoalRequest) (_ *models.Goal, err error) {
+	defer s.observe("CreateGoal", time.Now(), &err)
+	return s.Store.CreateGoal(userID, req)
+}
+
+func (s *instrumented) ListGoals(userID int64) (_ []models.Goal, err error) {
+	defer s.observe("ListGoals", time.Now(), &err)
+	return s.Store.ListGoals(userID)
+}
+
+func (s *instrumented) GetGoal(id, userID int64) (_ *models.Goal, err error) {
+	defer s.observe("GetGoal", time.Now(), &err)
+	return s.Store.GetGoal(id, userID)
+}
+
+func (s *instrumented) DeleteGoal(id, userID int64) (err error) {
+	defer s.observe("DeleteGoal", time.Now(), &err)
+	return s.Store.DeleteGoal(id, userID)
+}
+
+func (s *instrumented) LogBodyWeight(userID int64, req models.LogBodyWeightRequest) (_ *models.BodyWeight, err error) {
+	defer s.observe("LogBodyWeight", time.Now(), &err)
+	return s.Store.LogBodyWeight(userID, req)
+}
+
+func (s *instrumented) ListBodyWeights(userID int64) (_ []models.BodyWeight, err error) {
+	defer s.observe("ListBodyWeights", time.Now(), &err)
+	return s.Store.ListBodyWeights(userID)
+}
+
+func (s *instrumented) DeleteBodyWeight(id, userID int64) (err error) {
+	defer s.observe("DeleteBodyWeight", time.Now(), &err)
+	return s.Store.DeleteBodyWeight(id, userID)
+}
+
+func (s *instrumented) GetAchievements(userID int64) (_ *models.Achievements, err error) {
+	defer s.observe("GetAchievements", time.Now(), &err)
+	return s.Store.GetAchievements(userID)
+}
+
+func (s *instrumented) GetChanges(userID int64, cursor string) (_ *models.SyncChanges, err error) {
+	defer s.observe("GetChanges", time.Now(), &err)
+	return s.Store.GetChanges(userID, cursor)
+}
+
+func (s *instrumented) FindClientID(userID int64, entity, clientID string) (_ int64, err error) {
+	defer s.observe("FindClientID", time.Now(), &err)
+	return s.Store.FindClientID(userID, entity, clientID)
+}
+
+func (s *instrumented) AddSyncedSet(workoutID, userID int64, clientID string, req models.SyncSet) (_ *models.SessionSet, err error) {
+	defer s.observe("AddSyncedSet", time.Now(), &err)
+	return s.Store.AddSyncedSet(workoutID, userID, clientID, req)
+}
+
+func (s *instrumented) ClaimSyncResult(userID int64, key string) (_ []byte, err error) {
+	defer s.observe("ClaimSyncResult", time.Now(), &err)
+	return s.Store.ClaimSyncResult(userID, key)
+}
+
+func (s *instrumented) GetSyncResult(userID int64, key string) (_ []byte, err error) {
+	defer s.observe("GetSyncResult", time.Now(), &err)
+	return s.Store.GetSyncResult(userID, key)
+}
+
+func (s *instrumented) SaveSyncResult(userID int64, key string, result []byte) (err error) {
+	defer s.observe("SaveSyncResult", time.Now(), &err)
+	return s.Store.SaveSyncResult(userID, key, result)
+}
+
+func (s *instrumented) ReleaseSyncResult(userID int64, key string) (err error) {
+	defer s.observe("ReleaseSyncResult", time.Now(), &err)
+	return s.Store.ReleaseSyncResult(userID, key)
+}
+
+func (s *instrumented) PurgeTombstones(before time.Time) (_ int64, err error) {
+	defer s.observe("PurgeTombstones", time.Now(), &err)
+	return s.Store.PurgeTombstones(before)
+}
+
+func (s *instrumented) ListAuditEvents(f models.AuditFilter) (_ *models.AuditPage, err error) {
+	defer s.observe("ListAuditEvents", time.Now(), &err)
+	return s.Store.ListAuditEvents(f)
+}
+
+func (s *instrumented) StreamAuditEvents(f models.AuditFilter, fn func(*models.AuditEvent) error) (err error) {
+	defer s.observe("StreamAuditEvents", time.Now(), &err)
+	return s.Store.StreamAuditEvents(f, fn)
+}
+
+func (s *instrumented) ClaimIdempotencyKey(userID int64, key, fingerprint string, expiredBefore time.Time) (_ *models.StoredResponse, err error) {
+	defer s.observe("ClaimIdempotencyKey", time.Now(), &err)
+	return s.Store.ClaimIdempotencyKey(userID, key, fingerprint, expiredBefore)
+}
+
+func (s *instrumented) SaveIdempotentResponse(userID int64, key string, resp models.StoredResponse) (err error) {
+	defer s.observe("SaveIdempotentResponse", time.Now(), &err)
+	return s.Store.SaveIdempotentResponse(userID, key, resp)
+}
+
+func (s *instrumented) RenewIdempotencyKey(userID int64, key string) (err error) {
+	defer s.observe("RenewIdempotencyKey", time.Now(), &err)
+	return s.Store.RenewIdempotencyKey(userID, key)
+}
+
+func (s *instrumented) ReleaseIdempotencyKey(userID int64, key string) (err error) {
+	defer s.observe("ReleaseIdempotencyKey", time.Now(), &err)
+	return s.Store.ReleaseIdempotencyKey(userID, key)
+}
+
+func (s *instrumented) PurgeIdempotencyKeys(before time.Time) (_ int64, err error) {
+	defer s.observe("PurgeIdempotencyKeys", time.Now(), &err)
+	return s.Store.PurgeIdempotencyKeys(before)
+}
@@ -0,0 +1,278 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// isPostgresURL reports whether a DATABASE_URL names a PostgreSQL server.
+func isPostgresURL(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+func openPostgres(dsn string) (*DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{DB: db, dialect: dialectPostgres, writer: db}, nil
+}
+
+// postgresSchema mirrors the SQLite tables, including the columns SQLite
+// databases gain through addColumn. Times are timestamptz; the application
+// writes them as RFC 3339 strings on both backends.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	timezone TEXT DEFAULT 'UTC'
+);
+
+CREATE TABLE IF NOT EXISTS exercises (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT,
+	category TEXT NOT NULL,
+	muscle_group TEXT,
+	user_id BIGINT REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS workouts (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	title TEXT NOT NULL,
+	description TEXT DEFAULT '',
+	comment TEXT DEFAULT '',
+	status TEXT DEFAULT 'pending',
+	scheduled_at TIMESTAMPTZ,
+	completed_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+	source TEXT DEFAULT '',
+	external_id TEXT
+);
+
+CREATE TABLE IF NOT EXISTS workout_exercises (
+	id BIGSERIAL PRIMARY KEY,
+	workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	exercise_id BIGINT NOT NULL REFERENCES exercises(id),
+	sets INTEGER DEFAULT 0,
+	reps INTEGER DEFAULT 0,
+	weight_kg DOUBLE PRECISION DEFAULT 0,
+	duration_sec INTEGER DEFAULT 0,
+	notes TEXT DEFAULT '',
+	rest_sec INTEGER DEFAULT 0,
+	distance_m DOUBLE PRECISION DEFAULT 0,
+	elevation_gain_m DOUBLE PRECISION DEFAULT 0,
+	avg_heart_rate INTEGER DEFAULT 0,
+	max_heart_rate INTEGER DEFAULT 0,
+	calories INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS workout_sessions (
+	id BIGSERIAL PRIMARY KEY,
+	workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	status TEXT DEFAULT 'active',
+	rest_until TIMESTAMPTZ,
+	started_at TIMESTAMPTZ,
+	finished_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS session_sets (
+	id BIGSERIAL PRIMARY KEY,
+	session_id BIGINT NOT NULL REFERENCES workout_sessions(id) ON DELETE CASCADE,
+	workout_exercise_id BIGINT NOT NULL REFERENCES workout_exercises(id) ON DELETE CASCADE,
+	exercise_id BIGINT NOT NULL REFERENCES exercises(id),
+	set_number INTEGER NOT NULL,
+	reps INTEGER DEFAULT 0,
+	weight_kg DOUBLE PRECISION DEFAULT 0,
+	duration_sec INTEGER DEFAULT 0,
+	rest_sec INTEGER DEFAULT 0,
+	is_pr BOOLEAN DEFAULT FALSE,
+	completed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS tombstones (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	entity TEXT NOT NULL,
+	entity_id BIGINT NOT NULL,
+	client_id TEXT,
+	deleted_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sync_results (
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	idempotency_key TEXT NOT NULL,
+	result TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (user_id, idempotency_key)
+);
+
+-- audit_events is append-only. It has no foreign keys so that events
+-- outlive the records they describe.
+CREATE TABLE IF NOT EXISTS audit_events (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	actor_id BIGINT,
+	action TEXT NOT NULL,
+	entity TEXT NOT NULL,
+	entity_id BIGINT NOT NULL,
+	changes TEXT NOT NULL,
+	request_id TEXT,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	idempotency_key TEXT NOT NULL,
+	fingerprint TEXT NOT NULL,
+	status INTEGER NOT NULL DEFAULT 0,
+	headers TEXT,
+	body TEXT,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE TABLE IF NOT EXISTS follows (
+	follower_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	followee_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (follower_id, followee_id)
+);
+
+CREATE TABLE IF NOT EXISTS reactions (
+	id BIGSERIAL PRIMARY KEY,
+	workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (workout_id, user_id, kind)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+	id BIGSERIAL PRIMARY KEY,
+	workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	body TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS coach_links (
+	coach_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	athlete_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	can_view BOOLEAN NOT NULL DEFAULT FALSE,
+	can_assign BOOLEAN NOT NULL DEFAULT FALSE,
+	can_edit BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL,
+	accepted_at TIMESTAMPTZ,
+	PRIMARY KEY (coach_id, athlete_id)
+);
+
+CREATE TABLE IF NOT EXISTS teams (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	join_code TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+	team_id BIGINT NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	joined_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (team_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS challenges (
+	id BIGSERIAL PRIMARY KEY,
+	team_id BIGINT NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+	created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+	title TEXT NOT NULL,
+	metric TEXT NOT NULL,
+	exercise_id BIGINT REFERENCES exercises(id),
+	target DOUBLE PRECISION NOT NULL,
+	starts_at TIMESTAMPTZ NOT NULL,
+	ends_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS goals (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	kind TEXT NOT NULL,
+	target DOUBLE PRECISION NOT NULL,
+	period TEXT,
+	exercise_id BIGINT REFERENCES exercises(id),
+	start_value DOUBLE PRECISION,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS body_weights (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	weight_kg DOUBLE PRECISION NOT NULL,
+	measured_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS achievements (
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	code TEXT NOT NULL,
+	workout_id BIGINT REFERENCES workouts(id) ON DELETE SET NULL,
+	earned_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (user_id, code)
+);
+
+CREATE TABLE IF NOT EXISTS schema_version (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	version INTEGER NOT NULL,
+	migrated_at TIMESTAMPTZ NOT NULL
+);
+`
+
+// postgresColumns were added after the PostgreSQL schema was first
+// released.
+var postgresColumns = []struct{ table, column, definition string }{
+	{"users", "timezone", "TEXT DEFAULT 'UTC'"},
+	{"workouts", "version", "INTEGER NOT NULL DEFAULT 1"},
+	{"workout_exercises", "position", "INTEGER DEFAULT 0"},
+	{"workouts", "client_id", "TEXT"},
+	{"exercises", "client_id", "TEXT"},
+	{"exercises", "updated_at", "TIMESTAMPTZ"},
+	{"session_sets", "client_id", "TEXT"},
+	{"session_sets", "updated_at", "TIMESTAMPTZ"},
+	{"workouts", "deleted_at", "TIMESTAMPTZ"},
+	{"workouts", "visibility", "TEXT NOT NULL DEFAULT 'private'"},
+	{"workouts", "share_token", "TEXT"},
+	{"workouts", "assigned_by", "BIGINT REFERENCES users(id) ON DELETE SET NULL"},
+	{"users", "rest_days", "INTEGER NOT NULL DEFAULT 1"},
+}
+
+func (db *DB) migratePostgres() error {
+	if _, err := db.Exec(postgresSchema); err != nil {
+		return err
+	}
+	for _, c := range postgresColumns {
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s`, c.table, c.column, c.definition)); err != nil {
+			return err
+		}
+	}
+	if err := db.finishDuplicateSessions(); err != nil {
+		return err
+	}
+	if _, err := db.Exec(indexes); err != nil {
+		return err
+	}
+	return db.recordSchemaVersion()
+}
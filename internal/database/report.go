@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// GetReport summarises the user's workouts. The filter narrows every
+// figure to matching workouts; its status, sort and paging apply to the
+// embedded list of completed workouts only.
+func (db *DB) GetReport(userID int64, f models.WorkoutFilter) (*models.WorkoutReport, error) {
+	return db.getReport(ownedBy(userID), f)
+}
+
+func (db *DB) getReport(a access, f models.WorkoutFilter) (*models.WorkoutReport, error) {
+	report := &models.WorkoutReport{}
+	where, args := a.where()
+	cond, condArgs := workoutFilterSQL(f)
+	args = append(args, condArgs...)
+
+	loc, err := db.userLocation(a.userID)
+	if err != nil {
+		return nil, err
+	}
+	report.TimeZone = loc.String()
+	t := time.Now()
+	today, week := models.StartOfDay(t, loc), models.StartOfWeek(t, loc)
+
+	var firstDate sql.NullString
+	err = db.QueryRow(`
+		SELECT COUNT(*),
+		       COALESCE(SUM(CASE WHEN w.status = 'completed' THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN w.status = 'completed' AND w.completed_at >= ? THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN w.status = 'completed' AND w.completed_at >= ? THEN 1 ELSE 0 END), 0),
+		       MIN(w.created_at)
+		FROM workouts w WHERE`+where+notTrashed+cond, append([]interface{}{formatTime(today), formatTime(week)}, args...)...).
+		Scan(&report.TotalWorkouts, &report.CompletedWorkouts, &report.CompletedToday, &report.CompletedThisWeek, &firstDate)
+	if err != nil {
+		return nil, err
+	}
+	if firstDate.Valid && report.TotalWorkouts > 0 {
+		first, err := parseTime(firstDate.String)
+		if err != nil {
+			return nil, err
+		}
+		// Calendar weeks in the user's zone, counting the current one.
+		weeks := calendarDays(models.StartOfWeek(first, loc), week)/7 + 1
+		if weeks < 1 {
+			weeks = 1
+		}
+		report.AvgWorkoutsPerWeek = float64(report.TotalWorkouts) / float64(weeks)
+	}
+
+	// Volume and cardio totals over completed workouts, in one pass.
+	var totalVol, cardioDist, runDist sql.NullFloat64
+	var cardioDur sql.NullInt64
+	err = db.QueryRow(`
+		SELECT SUM(we.sets * we.reps * we.weight_kg),
+		       SUM(CASE WHEN e.category = 'cardio' THEN we.distance_m END),
+		       SUM(CASE WHEN e.category = 'cardio' THEN we.duration_sec END),
+		       SUM(CASE WHEN e.name = 'Running' THEN we.distance_m END),
+		       COUNT(DISTINCT CASE WHEN e.name = 'Running' THEN w.id END)
+		FROM workout_exercises we
+		JOIN exercises e ON e.id = we.exercise_id
+		JOIN workouts w ON w.id = we.workout_id
+		WHERE`+where+` AND w.status = 'completed'`+notTrashed+cond, args...).
+		Scan(&totalVol, &cardioDist, &cardioDur, &runDist, &report.RunningSessions)
+	if err != nil {
+		return nil, err
+	}
+	report.TotalVolumeKg = totalVol.Float64
+	report.CardioDistanceKm = cardioDist.Float64 / 1000
+	report.CardioDurationSec = int(cardioDur.Int64)
+	report.RunningDistanceKm = runDist.Float64 / 1000
+
+	// most used exercise
+	var exName string
+	err = db.QueryRow(`
+		SELECT e.name FROM workout_exercises we
+		JOIN exercises e ON e.id = we.exercise_id
+		JOIN workouts w ON w.id = we.workout_id
+		WHERE`+where+notTrashed+cond+`
+		GROUP BY we.exercise_id, e.name ORDER BY COUNT(*) DESC, we.exercise_id LIMIT 1`, args...).Scan(&exName)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	report.MostUsedExercise = exName
+
+	if f.Status == "" {
+		f.Status = "completed"
+	}
+	page, err := db.listWorkouts(a, f)
+	if err != nil {
+		return nil, err
+	}
+	report.Workouts = page.Workouts
+	report.NextCursor = page.NextCursor
+
+	return report, nil
+}
+
+// calendarDays counts the days from one midnight to another, ignoring
+// daylight-saving shifts.
+func calendarDays(from, to time.Time) int {
+	y1, m1, d1 := from.Date()
+	y2, m2, d2 := to.Date()
+	a := time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)
+	b := time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
@@ -0,0 +1,402 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+	"workout-tracker/internal/metrics"
+	"workout-tracker/internal/models"
+)
+
+// DefaultRestSec is used when a planned exercise has no rest target.
+const DefaultRestSec = 90
+
+var errExerciseNotInWorkout = Invalid("exercise_not_in_workout", "exercise not part of workout", models.FieldError{
+	Pointer: "/workout_exercise_id",
+	Code:    "exercise_not_in_workout",
+	Message: "not an exercise of this workout",
+})
+
+// ---- Live sessions ----
+
+// StartSession opens a live session for a workout. Starting a workout that
+// already has an active session returns that session instead of a new one;
+// one started concurrently by another request is reported as a conflict.
+func (db *DB) StartSession(workoutID, userID int64) (*models.WorkoutSession, error) {
+	existing, err := db.GetActiveSession(workoutID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM workouts WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, workoutID, userID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrWorkoutNotFound
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := loadWorkoutSnapshot(tx, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	started := formatTime(now())
+	sessionID, err := insertID(tx, `INSERT INTO workout_sessions (workout_id, user_id, status, started_at) VALUES (?, ?, 'active', ?)`,
+		workoutID, userID, started)
+	if isUniqueViolation(err) {
+		return nil, ErrSessionActive
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, userID, "create", "session", sessionID, nil, sessionSnapshot(workoutID, "active", started, "")); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE workouts SET status = 'active', updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ?`,
+		started, workoutID, userID); err != nil {
+		return nil, err
+	}
+	if err := db.auditWorkout(tx, userID, "update", workoutID, before); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetActiveSession(workoutID, userID)
+}
+
+// GetActiveSession returns the active session of a workout, or nil if none
+// is running.
+func (db *DB) GetActiveSession(workoutID, userID int64) (*models.WorkoutSession, error) {
+	s, err := scanSession(db.QueryRow(`SELECT s.id, s.workout_id, s.user_id, s.status, s.rest_until, s.started_at, s.finished_at
+		FROM workout_sessions s JOIN workouts w ON w.id = s.workout_id
+		WHERE s.workout_id = ? AND s.user_id = ? AND s.status = 'active'`+notTrashed, workoutID, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.loadSessionDetail(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListActiveSessions returns every active session of a workout outside the
+// trash, used to restore rest timers after a restart.
+func (db *DB) ListActiveSessions() ([]models.WorkoutSession, error) {
+	rows, err := db.Query(`SELECT s.id, s.workout_id, s.user_id, s.status, s.rest_until, s.started_at, s.finished_at
+		FROM workout_sessions s JOIN workouts w ON w.id = s.workout_id
+		WHERE s.status = 'active'` + notTrashed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.WorkoutSession
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range list {
+		if err := db.loadSessionDetail(&list[i]); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// LogSessionSet records one completed set and starts the rest timer using
+// the rest target of the planned exercise.
+func (db *DB) LogSessionSet(workoutID, userID int64, req models.LogSetRequest) (*models.SessionSet, *models.WorkoutSession, error) {
+	s, err := db.GetActiveSession(workoutID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s == nil {
+		return nil, nil, ErrSessionNotFound
+	}
+
+	var exerciseID int64
+	var restSec int
+	err = db.QueryRow(`SELECT exercise_id, rest_sec FROM workout_exercises WHERE id = ? AND workout_id = ?`,
+		req.WorkoutExerciseID, workoutID).Scan(&exerciseID, &restSec)
+	if err == sql.ErrNoRows {
+		return nil, nil, errExerciseNotInWorkout
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if restSec <= 0 {
+		restSec = DefaultRestSec
+	}
+
+	t := now()
+	restUntil := t.Add(time.Duration(restSec) * time.Second)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// The set number and the PR flag depend on the sets before this one,
+	// so they are read under the same lock as the insert.
+	if err := tx.lockUser(userID); err != nil {
+		return nil, nil, err
+	}
+	var setNumber int
+	if err := tx.QueryRow(`SELECT COUNT(*) + 1 FROM session_sets WHERE session_id = ? AND workout_exercise_id = ?`,
+		s.ID, req.WorkoutExerciseID).Scan(&setNumber); err != nil {
+		return nil, nil, err
+	}
+	best, err := bestWeight(tx, userID, exerciseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	isPR := req.WeightKg > 0 && req.WeightKg > best
+
+	setID, err := insertID(tx, `INSERT INTO session_sets (session_id, workout_exercise_id, exercise_id, set_number, reps, weight_kg, duration_sec, rest_sec, is_pr, completed_at, updated_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		s.ID, req.WorkoutExerciseID, exerciseID, setNumber, req.Reps, req.WeightKg, req.DurationSec, restSec, isPR, formatTime(t), formatTime(t))
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(`UPDATE workout_sessions SET rest_until = ? WHERE id = ?`, formatTime(restUntil), s.ID); err != nil {
+		return nil, nil, err
+	}
+	set := &models.SessionSet{
+		ID:                setID,
+		SessionID:         s.ID,
+		WorkoutExerciseID: req.WorkoutExerciseID,
+		ExerciseID:        exerciseID,
+		SetNumber:         setNumber,
+		Reps:              req.Reps,
+		WeightKg:          req.WeightKg,
+		DurationSec:       req.DurationSec,
+		RestSec:           restSec,
+		IsPR:              isPR,
+		CompletedAt:       t,
+	}
+	if err := db.audit(tx, userID, "create", "set", setID, nil, set); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	s, err = db.GetActiveSession(workoutID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return set, s, nil
+}
+
+// FinishSession closes the active session and marks the workout completed.
+func (db *DB) FinishSession(workoutID, userID int64) (*models.WorkoutSession, error) {
+	s, err := db.GetActiveSession(workoutID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := loadWorkoutSnapshot(tx, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	t := now()
+	finished := formatTime(t)
+	if _, err := tx.Exec(`UPDATE workout_sessions SET status = 'finished', rest_until = NULL, finished_at = ? WHERE id = ?`, finished, s.ID); err != nil {
+		return nil, err
+	}
+	started := formatTime(s.StartedAt)
+	if err := db.audit(tx, userID, "update", "session", s.ID,
+		sessionSnapshot(workoutID, "active", started, ""), sessionSnapshot(workoutID, "finished", started, finished)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE workouts SET status = 'completed', completed_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ?`,
+		finished, finished, workoutID, userID); err != nil {
+		return nil, err
+	}
+	if err := db.auditWorkout(tx, userID, "update", workoutID, before); err != nil {
+		return nil, err
+	}
+	if before.Status != "completed" {
+		if err := db.awardAchievements(tx, userID, workoutID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if before.Status != "completed" {
+		metrics.WorkoutsCompleted.Inc()
+	}
+
+	s.Status = "finished"
+	s.RestUntil = nil
+	s.FinishedAt = &t
+	s.NextSet = nil
+	return s, nil
+}
+
+// sessionSnapshot is what the audit log compares of a session.
+func sessionSnapshot(workoutID int64, status, startedAt, finishedAt string) map[string]interface{} {
+	snap := map[string]interface{}{"workout_id": workoutID, "status": status, "started_at": startedAt, "finished_at": nil}
+	if finishedAt != "" {
+		snap["finished_at"] = finishedAt
+	}
+	return snap
+}
+
+// bestWeight is the heaviest weight the user has logged for an exercise,
+// across live sets and completed workouts.
+func bestWeight(q queryRower, userID, exerciseID int64) (float64, error) {
+	var best sql.NullFloat64
+	err := q.QueryRow(`
+		SELECT MAX(weight) FROM (
+			SELECT ss.weight_kg AS weight FROM session_sets ss
+			JOIN workout_sessions s ON s.id = ss.session_id
+			JOIN workouts w ON w.id = s.workout_id
+			WHERE s.user_id = ? AND ss.exercise_id = ?`+notTrashed+`
+			UNION ALL
+			SELECT we.weight_kg FROM workout_exercises we
+			JOIN workouts w ON w.id = we.workout_id
+			WHERE w.user_id = ? AND w.status = 'completed' AND we.exercise_id = ?`+notTrashed+`
+		) AS weights`, userID, exerciseID, userID, exerciseID).Scan(&best)
+	if err != nil {
+		return 0, err
+	}
+	return best.Float64, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (*models.WorkoutSession, error) {
+	s := &models.WorkoutSession{}
+	var restStr, startedStr, finishedStr sql.NullString
+	if err := row.Scan(&s.ID, &s.WorkoutID, &s.UserID, &s.Status, &restStr, &startedStr, &finishedStr); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.RestUntil, err = parseNullTime(restStr); err != nil {
+		return nil, err
+	}
+	if s.FinishedAt, err = parseNullTime(finishedStr); err != nil {
+		return nil, err
+	}
+	if startedStr.Valid {
+		if s.StartedAt, err = parseTime(startedStr.String); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+const setColumns = `ss.id, ss.session_id, ss.workout_exercise_id, ss.exercise_id, ss.set_number, ss.reps, ss.weight_kg,
+	ss.duration_sec, ss.rest_sec, ss.is_pr, ss.completed_at, ss.client_id`
+
+// scanSessionSet reads the columns in setColumns followed by any extra
+// destinations.
+func scanSessionSet(row rowScanner, extra ...interface{}) (*models.SessionSet, error) {
+	ss := &models.SessionSet{}
+	var completedStr, clientID sql.NullString
+	dest := append([]interface{}{&ss.ID, &ss.SessionID, &ss.WorkoutExerciseID, &ss.ExerciseID, &ss.SetNumber, &ss.Reps, &ss.WeightKg,
+		&ss.DurationSec, &ss.RestSec, &ss.IsPR, &completedStr, &clientID}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	ss.ClientID = clientID.String
+	if completedStr.Valid {
+		t, err := parseTime(completedStr.String)
+		if err != nil {
+			return nil, err
+		}
+		ss.CompletedAt = t
+	}
+	return ss, nil
+}
+
+// loadSessionDetail fills in the logged sets and works out the next set
+// from the workout plan.
+func (db *DB) loadSessionDetail(s *models.WorkoutSession) error {
+	rows, err := db.Query(`SELECT `+setColumns+` FROM session_sets ss WHERE ss.session_id = ? ORDER BY ss.id`, s.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	s.Sets = []models.SessionSet{}
+	done := map[int64]int{}
+	for rows.Next() {
+		ss, err := scanSessionSet(rows)
+		if err != nil {
+			return err
+		}
+		done[ss.WorkoutExerciseID]++
+		s.Sets = append(s.Sets, *ss)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	plan, err := db.getWorkoutExercises(s.WorkoutID)
+	if err != nil {
+		return err
+	}
+	s.NextSet = nil
+	for _, we := range plan {
+		sets := we.Sets
+		if sets < 1 {
+			sets = 1
+		}
+		if done[we.ID] >= sets {
+			continue
+		}
+		rest := we.RestSec
+		if rest <= 0 {
+			rest = DefaultRestSec
+		}
+		next := &models.NextSet{
+			WorkoutExerciseID: we.ID,
+			ExerciseID:        we.ExerciseID,
+			SetNumber:         done[we.ID] + 1,
+			TargetReps:        we.Reps,
+			TargetWeightKg:    we.WeightKg,
+			TargetDurationSec: we.DurationSec,
+			RestSec:           rest,
+		}
+		if we.Exercise != nil {
+			next.ExerciseName = we.Exercise.Name
+		}
+		s.NextSet = next
+		break
+	}
+	return nil
+}
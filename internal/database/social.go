@@ -0,0 +1,571 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"workout-tracker/internal/models"
+)
+
+// ---- Social ----
+
+// visibleTo limits workouts aliased as w to those a viewer may see: their
+// own, public ones, and those their followees share with followers. It
+// takes the viewer's ID twice.
+const visibleTo = ` AND (w.user_id = ? OR w.visibility = 'public' OR (w.visibility = 'followers' AND EXISTS (
+	SELECT 1 FROM follows vf WHERE vf.follower_id = ? AND vf.followee_id = w.user_id)))`
+
+// feedItem limits workouts aliased as w to those that make feed items:
+// completed and not in the trash.
+const feedItem = ` AND w.status = 'completed' AND w.completed_at IS NOT NULL` + notTrashed
+
+// Follow makes followerID follow followeeID. Following someone already
+// followed changes nothing.
+func (db *DB) Follow(followerID, followeeID int64) (*models.Follow, error) {
+	if followerID == followeeID {
+		return nil, ErrCannotFollowSelf
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (follower_id, followee_id) DO NOTHING`, followerID, followeeID, formatTime(now()))
+	if isForeignKeyViolation(err) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		if err := db.audit(tx, followerID, "create", "follow", followeeID, nil, followSnapshot(followeeID)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	f, err := scanFollow(db.QueryRow(`SELECT u.id, u.name, f.created_at FROM follows f JOIN users u ON u.id = f.followee_id
+		WHERE f.follower_id = ? AND f.followee_id = ?`, followerID, followeeID))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	return f, err
+}
+
+// Unfollow stops followerID following followeeID, if they did.
+func (db *DB) Unfollow(followerID, followeeID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM follows WHERE follower_id = ? AND followee_id = ?`, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		if err := db.audit(tx, followerID, "delete", "follow", followeeID, followSnapshot(followeeID), nil); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func followSnapshot(followeeID int64) map[string]int64 {
+	return map[string]int64{"followee_id": followeeID}
+}
+
+// ListFollowing returns the users userID follows, by name.
+func (db *DB) ListFollowing(userID int64) ([]models.Follow, error) {
+	return db.listFollows(`SELECT u.id, u.name, f.created_at FROM follows f JOIN users u ON u.id = f.followee_id
+		WHERE f.follower_id = ? ORDER BY u.name, u.id`, userID)
+}
+
+// ListFollowers returns the users who follow userID, by name.
+func (db *DB) ListFollowers(userID int64) ([]models.Follow, error) {
+	return db.listFollows(`SELECT u.id, u.name, f.created_at FROM follows f JOIN users u ON u.id = f.follower_id
+		WHERE f.followee_id = ? ORDER BY u.name, u.id`, userID)
+}
+
+func (db *DB) listFollows(query string, userID int64) ([]models.Follow, error) {
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Follow{}
+	for rows.Next() {
+		f, err := scanFollow(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *f)
+	}
+	return list, rows.Err()
+}
+
+func scanFollow(row rowScanner) (*models.Follow, error) {
+	f := &models.Follow{}
+	var since string
+	if err := row.Scan(&f.UserID, &f.Name, &since); err != nil {
+		return nil, err
+	}
+	var err error
+	f.Since, err = parseTime(since)
+	return f, err
+}
+
+// GetFeed returns a page of the completed workouts of the people userID
+// follows that they share with followers or everyone, most recently
+// completed first. Pages are keyset-paginated on completed_at and ID.
+func (db *DB) GetFeed(userID int64, limit int, cursor string) (*models.FeedPage, error) {
+	query := `SELECT ` + workoutColumns + `, u.name FROM workouts w
+		JOIN follows f ON f.followee_id = w.user_id AND f.follower_id = ?
+		JOIN users u ON u.id = w.user_id
+		WHERE w.visibility IN ('followers', 'public')` + feedItem
+	args := []interface{}{userID}
+	if cursor != "" {
+		cur, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query += ` AND (w.completed_at < ? OR (w.completed_at = ? AND w.id < ?))`
+		args = append(args, cur.Key, cur.Key, cur.ID)
+	}
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	query += ` ORDER BY w.completed_at DESC, w.id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	items, err := db.queryFeedItems(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.FeedPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeCursor(pageCursor{Key: formatTime(*last.CompletedAt), ID: last.ID})
+	}
+	if err := db.fillFeedItems(page.Items, userID); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetFeedItem returns a completed workout as a feed item, if viewerID may
+// see it.
+func (db *DB) GetFeedItem(workoutID, viewerID int64) (*models.FeedItem, error) {
+	items, err := db.queryFeedItems(`SELECT `+workoutColumns+`, u.name FROM workouts w
+		JOIN users u ON u.id = w.user_id WHERE w.id = ?`+feedItem+visibleTo, workoutID, viewerID, viewerID)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	if err := db.fillFeedItems(items, viewerID); err != nil {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// queryFeedItems reads workouts selected with their author's name.
+func (db *DB) queryFeedItems(query string, args ...interface{}) ([]models.FeedItem, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []models.FeedItem{}
+	for rows.Next() {
+		var author string
+		w, err := scanWorkout(rows, &author)
+		if err != nil {
+			return nil, err
+		}
+		// Client IDs belong to the author's devices.
+		w.ClientID = ""
+		items = append(items, models.FeedItem{Workout: *w, Author: author})
+	}
+	return items, rows.Err()
+}
+
+// fillFeedItems loads the exercises, records, reactions and comment counts
+// of a page of feed items, once the rows that listed them are closed.
+func (db *DB) fillFeedItems(items []models.FeedItem, viewerID int64) error {
+	if len(items) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(items))
+	args := make([]interface{}, len(items))
+	for i, it := range items {
+		ids[i], args[i] = it.ID, it.ID
+	}
+	byWorkout, err := db.loadWorkoutExercises(ids)
+	if err != nil {
+		return err
+	}
+	prs, err := db.loadPersonalRecords(ids)
+	if err != nil {
+		return err
+	}
+	index := make(map[int64]*models.FeedItem, len(items))
+	for i := range items {
+		it := &items[i]
+		it.Exercises = byWorkout[it.ID]
+		it.PRs = prs[it.ID]
+		if it.PRs == nil {
+			it.PRs = []models.PersonalRecord{}
+		}
+		it.Reactions = map[string]int{}
+		it.MyReactions = []string{}
+		index[it.ID] = it
+	}
+
+	rows, err := db.Query(`SELECT workout_id, kind, COUNT(*), SUM(CASE WHEN user_id = ? THEN 1 ELSE 0 END) FROM reactions
+		WHERE workout_id IN (`+placeholders(len(ids))+`) GROUP BY workout_id, kind ORDER BY workout_id, kind`, append([]interface{}{viewerID}, args...)...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int64
+		var kind string
+		var count, mine int
+		if err := rows.Scan(&id, &kind, &count, &mine); err != nil {
+			rows.Close()
+			return err
+		}
+		index[id].Reactions[kind] = count
+		if mine > 0 {
+			index[id].MyReactions = append(index[id].MyReactions, kind)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	rows, err = db.Query(`SELECT workout_id, COUNT(*) FROM comments WHERE workout_id IN (`+placeholders(len(ids))+`) GROUP BY workout_id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return err
+		}
+		index[id].CommentCount = count
+	}
+	return rows.Err()
+}
+
+// loadPersonalRecords returns, by workout, the heaviest record-setting set
+// of each exercise logged in the workouts' sessions. Callers pass at most
+// a page of IDs.
+func (db *DB) loadPersonalRecords(workoutIDs []int64) (map[int64][]models.PersonalRecord, error) {
+	args := make([]interface{}, len(workoutIDs))
+	for i, id := range workoutIDs {
+		args[i] = id
+	}
+	rows, err := db.Query(`SELECT s.workout_id, ss.exercise_id, e.name, ss.weight_kg, ss.reps, ss.completed_at
+		FROM session_sets ss
+		JOIN workout_sessions s ON s.id = ss.session_id
+		JOIN exercises e ON e.id = ss.exercise_id
+		WHERE ss.is_pr AND s.workout_id IN (`+placeholders(len(workoutIDs))+`)
+		ORDER BY s.workout_id, e.name, ss.exercise_id, ss.weight_kg DESC, ss.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byWorkout := map[int64][]models.PersonalRecord{}
+	for rows.Next() {
+		var workoutID int64
+		var pr models.PersonalRecord
+		var completed sql.NullString
+		if err := rows.Scan(&workoutID, &pr.ExerciseID, &pr.ExerciseName, &pr.WeightKg, &pr.Reps, &completed); err != nil {
+			return nil, err
+		}
+		list := byWorkout[workoutID]
+		if n := len(list); n > 0 && list[n-1].ExerciseID == pr.ExerciseID {
+			continue
+		}
+		if completed.Valid {
+			if pr.CompletedAt, err = parseTime(completed.String); err != nil {
+				return nil, err
+			}
+		}
+		byWorkout[workoutID] = append(list, pr)
+	}
+	return byWorkout, rows.Err()
+}
+
+// visibleFeedItem checks inside tx that viewerID may see a workout as a
+// feed item, returning its owner.
+func visibleFeedItem(tx *Tx, workoutID, viewerID int64) (int64, error) {
+	var owner int64
+	err := tx.QueryRow(`SELECT w.user_id FROM workouts w WHERE w.id = ?`+feedItem+visibleTo, workoutID, viewerID, viewerID).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return 0, ErrWorkoutNotFound
+	}
+	return owner, err
+}
+
+// React adds userID's reaction of a kind to a feed item. Reacting twice
+// with the same kind changes nothing.
+func (db *DB) React(workoutID, userID int64, kind string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := visibleFeedItem(tx, workoutID, userID); err != nil {
+		return err
+	}
+	id, err := insertID(tx, `INSERT INTO reactions (workout_id, user_id, kind, created_at) VALUES (?, ?, ?, ?)`,
+		workoutID, userID, kind, formatTime(now()))
+	if isUniqueViolation(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := db.audit(tx, userID, "create", "reaction", id, nil, reactionSnapshot(workoutID, kind)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Unreact takes back userID's reaction of a kind, if there is one.
+func (db *DB) Unreact(workoutID, userID int64, kind string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := visibleFeedItem(tx, workoutID, userID); err != nil {
+		return err
+	}
+	var id int64
+	err = tx.QueryRow(`SELECT id FROM reactions WHERE workout_id = ? AND user_id = ? AND kind = ?`, workoutID, userID, kind).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM reactions WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, userID, "delete", "reaction", id, reactionSnapshot(workoutID, kind), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func reactionSnapshot(workoutID int64, kind string) map[string]interface{} {
+	return map[string]interface{}{"workout_id": workoutID, "kind": kind}
+}
+
+const commentColumns = `c.id, c.workout_id, c.user_id, u.name, c.body, c.created_at`
+
+func scanComment(row rowScanner) (*models.Comment, error) {
+	cm := &models.Comment{}
+	var created string
+	if err := row.Scan(&cm.ID, &cm.WorkoutID, &cm.UserID, &cm.Author, &cm.Body, &created); err != nil {
+		return nil, err
+	}
+	var err error
+	cm.CreatedAt, err = parseTime(created)
+	return cm, err
+}
+
+// ListComments returns the comments on a feed item viewerID may see,
+// oldest first.
+func (db *DB) ListComments(workoutID, viewerID int64) ([]models.Comment, error) {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM workouts w WHERE w.id = ?`+feedItem+visibleTo, workoutID, viewerID, viewerID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrWorkoutNotFound
+	}
+	rows, err := db.Query(`SELECT `+commentColumns+` FROM comments c JOIN users u ON u.id = c.user_id
+		WHERE c.workout_id = ? ORDER BY c.id`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Comment{}
+	for rows.Next() {
+		cm, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *cm)
+	}
+	return list, rows.Err()
+}
+
+// AddComment leaves userID's comment on a feed item they may see.
+func (db *DB) AddComment(workoutID, userID int64, body string) (*models.Comment, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := visibleFeedItem(tx, workoutID, userID); err != nil {
+		return nil, err
+	}
+	id, err := insertID(tx, `INSERT INTO comments (workout_id, user_id, body, created_at) VALUES (?, ?, ?, ?)`,
+		workoutID, userID, body, formatTime(now()))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, userID, "create", "comment", id, nil, commentSnapshot(workoutID, body)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return scanComment(db.QueryRow(`SELECT `+commentColumns+` FROM comments c JOIN users u ON u.id = c.user_id WHERE c.id = ?`, id))
+}
+
+// DeleteComment removes a comment. Its author and the owner of the workout
+// may delete it; to anyone else it does not exist.
+func (db *DB) DeleteComment(id, workoutID, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var author, owner int64
+	var body string
+	err = tx.QueryRow(`SELECT c.user_id, c.body, w.user_id FROM comments c JOIN workouts w ON w.id = c.workout_id
+		WHERE c.id = ? AND c.workout_id = ?`, id, workoutID).Scan(&author, &body, &owner)
+	if err == sql.ErrNoRows || (err == nil && author != userID && owner != userID) {
+		return ErrCommentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM comments WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, author, "delete", "comment", id, commentSnapshot(workoutID, body), nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func commentSnapshot(workoutID int64, body string) map[string]interface{} {
+	return map[string]interface{}{"workout_id": workoutID, "body": body}
+}
+
+// ShareWorkout returns the token of the workout's public share link,
+// creating one if it has none.
+func (db *DB) ShareWorkout(id, userID int64) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var token sql.NullString
+	err = tx.QueryRow(`SELECT share_token FROM workouts WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, id, userID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", ErrWorkoutNotFound
+	}
+	if err != nil || token.Valid {
+		return token.String, err
+	}
+	if token.String, err = newToken(18); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`UPDATE workouts SET share_token = ? WHERE id = ?`, token.String, id); err != nil {
+		return "", err
+	}
+	if err := db.audit(tx, userID, "create", "share", id, nil, map[string]int64{"workout_id": id}); err != nil {
+		return "", err
+	}
+	return token.String, tx.Commit()
+}
+
+// newToken returns n random bytes, base64url-encoded, for links and codes
+// that must not be guessable.
+func newToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// UnshareWorkout revokes the workout's share link, if it has one.
+func (db *DB) UnshareWorkout(id, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var token sql.NullString
+	err = tx.QueryRow(`SELECT share_token FROM workouts WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, id, userID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return ErrWorkoutNotFound
+	}
+	if err != nil || !token.Valid {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE workouts SET share_token = NULL WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, userID, "delete", "share", id, map[string]int64{"workout_id": id}, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSharedWorkout returns the summary behind a share link, or nil if the
+// link was revoked or its workout deleted.
+func (db *DB) GetSharedWorkout(token string) (*models.WorkoutSummary, error) {
+	var author string
+	w, err := scanWorkout(db.QueryRow(`SELECT `+workoutColumns+`, u.name FROM workouts w JOIN users u ON u.id = w.user_id
+		WHERE w.share_token = ?`+notTrashed, token), &author)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	exercises, err := db.getWorkoutExercises(w.ID)
+	if err != nil {
+		return nil, err
+	}
+	prs, err := db.loadPersonalRecords([]int64{w.ID})
+	if err != nil {
+		return nil, err
+	}
+	s := &models.WorkoutSummary{
+		Title:       w.Title,
+		Description: w.Description,
+		Author:      author,
+		Date:        w.Date(),
+		Status:      w.Status,
+		Exercises:   exercises,
+		PRs:         prs[w.ID],
+	}
+	if s.PRs == nil {
+		s.PRs = []models.PersonalRecord{}
+	}
+	return s, nil
+}
@@ -0,0 +1,24 @@
+//go:build cgo && !purego
+
+package database
+
+import (
+	"net/url"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is github.com/mattn/go-sqlite3, which needs CGO. Build with
+// -tags purego or CGO_ENABLED=0 to use modernc.org/sqlite instead.
+const sqliteDriver = "sqlite3"
+
+func sqliteDSN(path string) string {
+	q := url.Values{}
+	q.Set("_foreign_keys", "on")
+	q.Set("_busy_timeout", "5000")
+	q.Set("_txlock", "immediate")
+	if path != ":memory:" {
+		q.Set("_journal_mode", "WAL")
+	}
+	return path + "?" + q.Encode()
+}
@@ -0,0 +1,24 @@
+//go:build !cgo || purego
+
+package database
+
+import (
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDriver is modernc.org/sqlite, a translation of SQLite to Go that
+// builds without CGO, for static and cross-compiled binaries.
+const sqliteDriver = "sqlite"
+
+func sqliteDSN(path string) string {
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	if path != ":memory:" {
+		q.Add("_pragma", "journal_mode(WAL)")
+	}
+	q.Set("_txlock", "immediate")
+	return path + "?" + q.Encode()
+}
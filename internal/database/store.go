@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// Store is the data access the handlers depend on. *DB implements it for
+// both SQLite and PostgreSQL; getters return nil, nil when nothing matches.
+type Store interface {
+	// Health
+	Ready(ctx context.Context) error
+
+	// Users
+	CreateUser(name, email, hash, timeZone string) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	GetUserByID(id int64) (*models.User, error)
+	UpdateUserTimeZone(id int64, timeZone string) (*models.User, error)
+	UpdateUserRestDays(id int64, restDays int) (*models.User, error)
+
+	// Exercises
+	GetExercises(userID int64) ([]models.Exercise, error)
+	GetExerciseByID(id int64) (*models.Exercise, error)
+	GetExerciseByName(name string) (*models.Exercise, error)
+	CreateCustomExercise(userID int64, name, category, muscleGroup, clientID string) (*models.Exercise, error)
+	UpdateCustomExercise(id, userID int64, name, category, muscleGroup string) (*models.Exercise, error)
+
+	// Workouts
+	CreateWorkout(userID int64, req models.CreateWorkoutRequest) (*models.Workout, error)
+	CreateCompletedWorkout(userID int64, req models.CreateWorkoutRequest, completedAt time.Time) (*models.Workout, error)
+	ImportedExternalIDs(userID int64, source string) (map[string]bool, error)
+	GetWorkoutByID(id, userID int64) (*models.Workout, error)
+	ListWorkouts(userID int64, f models.WorkoutFilter) (*models.WorkoutPage, error)
+	StreamWorkouts(userID int64, from, to *time.Time, fn func(*models.Workout) error) error
+	UpdateWorkout(id, userID int64, req models.UpdateWorkoutRequest, ifVersion int) (*models.Workout, error)
+	SaveWorkout(id, userID int64, version int, s models.WorkoutState) (*models.Workout, error)
+	DeleteWorkout(id, userID int64, ifVersion int) error
+	ListTrash(userID int64) ([]models.Workout, error)
+	RestoreWorkout(id, userID int64) (*models.Workout, error)
+	PurgeTrash(before time.Time) (int64, error)
+	GetReport(userID int64, f models.WorkoutFilter) (*models.WorkoutReport, error)
+
+	// Live sessions
+	StartSession(workoutID, userID int64) (*models.WorkoutSession, error)
+	GetActiveSession(workoutID, userID int64) (*models.WorkoutSession, error)
+	ListActiveSessions() ([]models.WorkoutSession, error)
+	LogSessionSet(workoutID, userID int64, req models.LogSetRequest) (*models.SessionSet, *models.WorkoutSession, error)
+	FinishSession(workoutID, userID int64) (*models.WorkoutSession, error)
+
+	// Social
+	Follow(followerID, followeeID int64) (*models.Follow, error)
+	Unfollow(followerID, followeeID int64) error
+	ListFollowing(userID int64) ([]models.Follow, error)
+	ListFollowers(userID int64) ([]models.Follow, error)
+	GetFeed(userID int64, limit int, cursor string) (*models.FeedPage, error)
+	GetFeedItem(workoutID, viewerID int64) (*models.FeedItem, error)
+	React(workoutID, userID int64, kind string) error
+	Unreact(workoutID, userID int64, kind string) error
+	ListComments(workoutID, viewerID int64) ([]models.Comment, error)
+	AddComment(workoutID, userID int64, body string) (*models.Comment, error)
+	DeleteComment(id, workoutID, userID int64) error
+	ShareWorkout(id, userID int64) (string, error)
+	UnshareWorkout(id, userID int64) error
+	GetSharedWorkout(token string) (*models.WorkoutSummary, error)
+
+	// Coaching
+	InviteAthlete(coachID int64, email string, scopes []string) (*models.CoachLink, error)
+	ListAthletes(coachID int64) ([]models.CoachLink, error)
+	ListCoaches(athleteID int64) ([]models.CoachLink, error)
+	AcceptCoach(athleteID, coachID int64) (*models.CoachLink, error)
+	UpdateCoachScopes(athleteID, coachID int64, scopes []string) (*models.CoachLink, error)
+	RevokeCoach(athleteID, coachID int64) error
+	DropAthlete(coachID, athleteID int64) error
+	CheckCoach(coachID, athleteID int64, scope string) error
+	ListAthleteWorkouts(coachID, athleteID int64, f models.WorkoutFilter) (*models.WorkoutPage, error)
+	GetAthleteWorkout(id, coachID, athleteID int64) (*models.Workout, error)
+	GetAthleteReport(coachID, athleteID int64, f models.WorkoutFilter) (*models.WorkoutReport, error)
+	AssignWorkout(coachID, athleteID int64, req models.CreateWorkoutRequest) (*models.Workout, error)
+	UpdateAthleteWorkout(id, coachID, athleteID int64, req models.UpdateWorkoutRequest, ifVersion int) (*models.Workout, error)
+
+	// Teams
+	CreateTeam(userID int64, name string) (*models.Team, error)
+	ListTeams(userID int64) ([]models.Team, error)
+	GetTeam(id, userID int64) (*models.Team, error)
+	JoinTeam(userID int64, code string) (*models.Team, error)
+	RemoveTeamMember(teamID, userID, memberID int64) error
+	DeleteTeam(id, userID int64) error
+	GetLeaderboard(teamID, userID int64, metric string, from, to *time.Time) ([]models.LeaderboardEntry, error)
+	CreateChallenge(teamID, userID int64, req models.CreateChallengeRequest) (*models.Challenge, error)
+	ListChallenges(teamID, userID int64) ([]models.Challenge, error)
+	GetChallenge(id, teamID, userID int64) (*models.Challenge, error)
+	DeleteChallenge(id, teamID, userID int64) error
+
+	// Goals and achievements
+	CreateGoal(userID int64, req models.CreateGoalRequest) (*models.Goal, error)
+	ListGoals(userID int64) ([]models.Goal, error)
+	GetGoal(id, userID int64) (*models.Goal, error)
+	DeleteGoal(id, userID int64) error
+	LogBodyWeight(userID int64, req models.LogBodyWeightRequest) (*models.BodyWeight, error)
+	ListBodyWeights(userID int64) ([]models.BodyWeight, error)
+	DeleteBodyWeight(id, userID int64) error
+	GetAchievements(userID int64) (*models.Achievements, error)
+
+	// Offline sync
+	GetChanges(userID int64, cursor string) (*models.SyncChanges, error)
+	FindClientID(userID int64, entity, clientID string) (int64, error)
+	AddSyncedSet(workoutID, userID int64, clientID string, req models.SyncSet) (*models.SessionSet, error)
+	ClaimSyncResult(userID int64, key string) ([]byte, error)
+	GetSyncResult(userID int64, key string) ([]byte, error)
+	SaveSyncResult(userID int64, key string, result []byte) error
+	ReleaseSyncResult(userID int64, key string) error
+	PurgeTombstones(before time.Time) (int64, error)
+
+	// Audit log
+	As(actor Actor) Store
+	ListAuditEvents(f models.AuditFilter) (*models.AuditPage, error)
+	StreamAuditEvents(f models.AuditFilter, fn func(*models.AuditEvent) error) error
+
+	// Idempotency keys
+	ClaimIdempotencyKey(userID int64, key, fingerprint string, expiredBefore time.Time) (*models.StoredResponse, error)
+	SaveIdempotentResponse(userID int64, key string, resp models.StoredResponse) error
+	RenewIdempotencyKey(userID int64, key string) error
+	ReleaseIdempotencyKey(userID int64, key string) error
+	PurgeIdempotencyKeys(before time.Time) (int64, error)
+}
+
+var _ Store = (*DB)(nil)
@@ -0,0 +1,1392 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+	"workout-tracker/internal/seeder"
+)
+
+// Every backend runs the same conformance suite. SQLite runs in-process;
+// PostgreSQL runs when TEST_DATABASE_URL points at a server, with each test
+// in a throwaway schema.
+
+func TestSQLiteStore(t *testing.T) {
+	runStoreSuite(t, func(t *testing.T) *database.DB {
+		db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	})
+}
+
+func TestSQLiteMemoryStore(t *testing.T) {
+	runStoreSuite(t, func(t *testing.T) *database.DB {
+		db, err := database.New(":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	})
+}
+
+func TestPostgresStore(t *testing.T) {
+	base := os.Getenv("TEST_DATABASE_URL")
+	if base == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	runStoreSuite(t, func(t *testing.T) *database.DB {
+		admin, err := database.New(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { admin.Close() })
+
+		schema := fmt.Sprintf("store_test_%d", time.Now().UnixNano())
+		if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+		u, err := url.Parse(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		db, err := database.New(u.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	})
+}
+
+func runStoreSuite(t *testing.T, open func(t *testing.T) *database.DB) {
+	newStore := func(t *testing.T) database.Store {
+		db := open(t)
+		if err := db.Migrate(); err != nil {
+			t.Fatal("migrate:", err)
+		}
+		// Migrating twice must be harmless.
+		if err := db.Migrate(); err != nil {
+			t.Fatal("second migrate:", err)
+		}
+		if err := seeder.SeedExercises(db); err != nil {
+			t.Fatal("seed:", err)
+		}
+		return db
+	}
+
+	t.Run("Ready", func(t *testing.T) {
+		if err := open(t).Ready(context.Background()); err == nil {
+			t.Error("unmigrated database is ready")
+		}
+		if err := newStore(t).Ready(context.Background()); err != nil {
+			t.Errorf("migrated database is not ready: %v", err)
+		}
+	})
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStore(t)) })
+	t.Run("Exercises", func(t *testing.T) { testExercises(t, newStore(t)) })
+	t.Run("Workouts", func(t *testing.T) { testWorkouts(t, newStore(t)) })
+	t.Run("ListWorkouts", func(t *testing.T) { testListWorkouts(t, newStore(t)) })
+	t.Run("Imports", func(t *testing.T) { testImports(t, newStore(t)) })
+	t.Run("Report", func(t *testing.T) { testReport(t, newStore(t)) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, newStore(t)) })
+	t.Run("TimeZones", func(t *testing.T) { testTimeZones(t, newStore(t)) })
+	t.Run("Errors", func(t *testing.T) { testErrors(t, newStore(t)) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, newStore(t)) })
+	t.Run("Sync", func(t *testing.T) { testSync(t, newStore(t)) })
+	t.Run("Trash", func(t *testing.T) { testTrash(t, newStore(t)) })
+	t.Run("Audit", func(t *testing.T) { testAudit(t, newStore(t)) })
+	t.Run("IdempotencyKeys", func(t *testing.T) { testIdempotencyKeys(t, newStore(t)) })
+	t.Run("Social", func(t *testing.T) { testSocial(t, newStore(t)) })
+	t.Run("Coaching", func(t *testing.T) { testCoaching(t, newStore(t)) })
+	t.Run("Teams", func(t *testing.T) { testTeams(t, newStore(t)) })
+	t.Run("Goals", func(t *testing.T) { testGoals(t, newStore(t)) })
+}
+
+func mustUser(t *testing.T, s database.Store, email string) *models.User {
+	t.Helper()
+	u, err := s.CreateUser("Test", email, "hash", "")
+	if err != nil {
+		t.Fatal("create user:", err)
+	}
+	return u
+}
+
+func mustExercise(t *testing.T, s database.Store, name string) *models.Exercise {
+	t.Helper()
+	e, err := s.GetExerciseByName(name)
+	if err != nil || e == nil {
+		t.Fatalf("exercise %q: %v", name, err)
+	}
+	return e
+}
+
+func testUsers(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "a@example.com")
+	if u.ID == 0 || u.Email != "a@example.com" || u.CreatedAt.IsZero() {
+		t.Fatalf("created user = %+v", u)
+	}
+	if _, err := s.CreateUser("Other", "a@example.com", "hash", ""); err == nil {
+		t.Error("duplicate email accepted")
+	}
+
+	got, err := s.GetUserByEmail("a@example.com")
+	if err != nil || got == nil || got.ID != u.ID {
+		t.Fatalf("GetUserByEmail = %+v, %v", got, err)
+	}
+	if got, err := s.GetUserByEmail("nobody@example.com"); got != nil || err != nil {
+		t.Errorf("missing email = %+v, %v; want nil, nil", got, err)
+	}
+	if got, err := s.GetUserByID(u.ID + 1000); got != nil || err != nil {
+		t.Errorf("missing id = %+v, %v; want nil, nil", got, err)
+	}
+}
+
+func testExercises(t *testing.T, s database.Store) {
+	alice := mustUser(t, s, "alice@example.com")
+	bob := mustUser(t, s, "bob@example.com")
+
+	library, err := s.GetExercises(alice.ID)
+	if err != nil || len(library) == 0 {
+		t.Fatalf("GetExercises = %d, %v", len(library), err)
+	}
+
+	custom, err := s.CreateCustomExercise(alice.ID, "Zercher Squat", "strength", "legs", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.UserID == nil || *custom.UserID != alice.ID {
+		t.Errorf("custom exercise owner = %v", custom.UserID)
+	}
+
+	forAlice, _ := s.GetExercises(alice.ID)
+	forBob, _ := s.GetExercises(bob.ID)
+	if len(forAlice) != len(library)+1 || len(forBob) != len(library) {
+		t.Errorf("alice sees %d, bob sees %d, library has %d", len(forAlice), len(forBob), len(library))
+	}
+
+	if e, err := s.GetExerciseByName("zercher squat"); e != nil || err != nil {
+		t.Errorf("GetExerciseByName found custom exercise: %+v, %v", e, err)
+	}
+	if e, err := s.GetExerciseByName("bench press"); err != nil || e == nil || e.Name != "Bench Press" {
+		t.Errorf("GetExerciseByName(bench press) = %+v, %v", e, err)
+	}
+	if e, err := s.GetExerciseByID(custom.ID); err != nil || e == nil || e.Name != "Zercher Squat" {
+		t.Errorf("GetExerciseByID = %+v, %v", e, err)
+	}
+}
+
+func testWorkouts(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "w@example.com")
+	other := mustUser(t, s, "other@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+	squat := mustExercise(t, s, "Squat")
+
+	at := time.Date(2024, 3, 4, 18, 30, 0, 0, time.UTC)
+	w, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{
+		Title:       "Push",
+		Description: "heavy",
+		ScheduledAt: &at,
+		Exercises: []models.WorkoutExerciseRequest{
+			{ExerciseID: bench.ID, Sets: 3, Reps: 5, WeightKg: 100, RestSec: 180},
+			{ExerciseID: squat.ID, Sets: 5, Reps: 5, WeightKg: 140},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != "pending" || w.ScheduledAt == nil || !w.ScheduledAt.Equal(at) {
+		t.Errorf("created workout = %+v", w)
+	}
+	if len(w.Exercises) != 2 || w.Exercises[0].Exercise == nil || w.Exercises[0].Exercise.Name != "Bench Press" || w.Exercises[0].RestSec != 180 {
+		t.Fatalf("exercises = %+v", w.Exercises)
+	}
+
+	if got, err := s.GetWorkoutByID(w.ID, other.ID); got != nil || err != nil {
+		t.Errorf("other user's workout = %+v, %v; want nil, nil", got, err)
+	}
+
+	title, status := "Push day", "completed"
+	updated, err := s.UpdateWorkout(w.ID, u.ID, models.UpdateWorkoutRequest{
+		Title:     &title,
+		Status:    &status,
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: squat.ID, Sets: 3, Reps: 3, WeightKg: 150}},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != title || updated.Status != status || updated.CompletedAt == nil || len(updated.Exercises) != 1 {
+		t.Errorf("updated workout = %+v", updated)
+	}
+	if _, err := s.UpdateWorkout(w.ID, other.ID, models.UpdateWorkoutRequest{Title: &title}, 0); err == nil {
+		t.Error("updated another user's workout")
+	}
+
+	if err := s.DeleteWorkout(w.ID, other.ID, 0); err == nil {
+		t.Error("deleted another user's workout")
+	}
+	if err := s.DeleteWorkout(w.ID, u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetWorkoutByID(w.ID, u.ID); got != nil || err != nil {
+		t.Errorf("deleted workout = %+v, %v", got, err)
+	}
+}
+
+func testListWorkouts(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "l@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+	run := mustExercise(t, s, "Running")
+
+	start := time.Date(2024, 1, 1, 7, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		at := start.AddDate(0, 0, i)
+		ex := bench
+		if i%2 == 1 {
+			ex = run
+		}
+		req := models.CreateWorkoutRequest{
+			Title:       fmt.Sprintf("Day %d", i+1),
+			ScheduledAt: &at,
+			Exercises:   []models.WorkoutExerciseRequest{{ExerciseID: ex.ID, Sets: 3, Reps: 5}},
+		}
+		if _, err := s.CreateWorkout(u.ID, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := s.ListWorkouts(u.ID, models.WorkoutFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Total != 7 || len(all.Workouts) != 7 || all.Workouts[0].Title != "Day 1" {
+		t.Fatalf("ListWorkouts = total %d, %d workouts", all.Total, len(all.Workouts))
+	}
+	for _, w := range all.Workouts {
+		if len(w.Exercises) != 1 {
+			t.Errorf("%s has %d exercises", w.Title, len(w.Exercises))
+		}
+	}
+
+	var titles []string
+	f := models.WorkoutFilter{Sort: "-date", Limit: 3}
+	for pages := 0; pages < 5; pages++ {
+		page, err := s.ListWorkouts(u.ID, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range page.Workouts {
+			titles = append(titles, w.Title)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		f.Cursor = page.NextCursor
+	}
+	if len(titles) != 7 || titles[0] != "Day 7" || titles[6] != "Day 1" {
+		t.Errorf("paged titles = %v", titles)
+	}
+
+	from, to := start.AddDate(0, 0, 2), start.AddDate(0, 0, 4)
+	ranged, err := s.ListWorkouts(u.ID, models.WorkoutFilter{From: &from, To: &to, ExerciseID: run.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranged.Total != 1 || ranged.Workouts[0].Title != "Day 4" {
+		t.Errorf("filtered = %+v", ranged.Workouts)
+	}
+
+	found, err := s.ListWorkouts(u.ID, models.WorkoutFilter{Query: "DAY 5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Total != 1 {
+		t.Errorf("search found %d", found.Total)
+	}
+
+	var streamed []string
+	err = s.StreamWorkouts(u.ID, &from, &to, func(w *models.Workout) error {
+		streamed = append(streamed, w.Title)
+		if len(w.Exercises) != 1 {
+			t.Errorf("streamed %s with %d exercises", w.Title, len(w.Exercises))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(streamed) != "[Day 3 Day 4 Day 5]" {
+		t.Errorf("streamed = %v", streamed)
+	}
+}
+
+func testImports(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "i@example.com")
+	run := mustExercise(t, s, "Running")
+
+	at := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	w, err := s.CreateCompletedWorkout(u.ID, models.CreateWorkoutRequest{
+		Title:       "Morning run",
+		ScheduledAt: &at,
+		Source:      "import:strong",
+		ExternalID:  "abc",
+		Exercises:   []models.WorkoutExerciseRequest{{ExerciseID: run.ID, DistanceM: 5000, DurationSec: 1500}},
+	}, at.Add(25*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != "completed" || w.CompletedAt == nil || w.Exercises[0].PaceSecPerKm != 300 {
+		t.Errorf("completed workout = %+v", w)
+	}
+
+	ids, err := s.ImportedExternalIDs(u.ID, "import:strong")
+	if err != nil || !ids["abc"] || len(ids) != 1 {
+		t.Errorf("ImportedExternalIDs = %v, %v", ids, err)
+	}
+	if _, err := s.CreateCompletedWorkout(u.ID, models.CreateWorkoutRequest{
+		Title: "Again", Source: "import:strong", ExternalID: "abc",
+	}, at); !errors.Is(err, database.ErrAlreadyImported) {
+		t.Errorf("duplicate external id: %v", err)
+	}
+}
+
+func testReport(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "r@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+	run := mustExercise(t, s, "Running")
+
+	at := time.Date(2024, 2, 1, 7, 0, 0, 0, time.UTC)
+	if _, err := s.CreateCompletedWorkout(u.ID, models.CreateWorkoutRequest{
+		Title: "Lift", ScheduledAt: &at,
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 3, Reps: 10, WeightKg: 50}},
+	}, at); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateCompletedWorkout(u.ID, models.CreateWorkoutRequest{
+		Title: "Run", ScheduledAt: &at,
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: run.ID, DistanceM: 10000, DurationSec: 3000}},
+	}, at); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{
+		Title:     "Planned",
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 5, Reps: 5, WeightKg: 80}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.GetReport(u.ID, models.WorkoutFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.TotalWorkouts != 3 || r.CompletedWorkouts != 2 || r.TotalVolumeKg != 1500 {
+		t.Errorf("report totals = %d/%d, volume %v", r.TotalWorkouts, r.CompletedWorkouts, r.TotalVolumeKg)
+	}
+	if r.MostUsedExercise != "Bench Press" || r.RunningDistanceKm != 10 || r.RunningSessions != 1 || r.CardioDurationSec != 3000 {
+		t.Errorf("report = %+v", r)
+	}
+	if r.AvgWorkoutsPerWeek <= 0 || len(r.Workouts) != 2 {
+		t.Errorf("avg %v, %d workouts listed", r.AvgWorkoutsPerWeek, len(r.Workouts))
+	}
+}
+
+func testSessions(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "s@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+
+	w, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{
+		Title:     "Bench",
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 2, Reps: 5, WeightKg: 100, RestSec: 120}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := s.StartSession(w.ID, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.StartSession(w.ID, u.ID)
+	if err != nil || again.ID != session.ID {
+		t.Fatalf("second start = %+v, %v", again, err)
+	}
+	if session.NextSet == nil || session.NextSet.SetNumber != 1 || session.NextSet.RestSec != 120 {
+		t.Errorf("next set = %+v", session.NextSet)
+	}
+
+	weID := w.Exercises[0].ID
+	set, session, err := s.LogSessionSet(w.ID, u.ID, models.LogSetRequest{WorkoutExerciseID: weID, Reps: 5, WeightKg: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !set.IsPR || set.SetNumber != 1 || session.RestUntil == nil || session.NextSet.SetNumber != 2 {
+		t.Errorf("first set = %+v, session = %+v", set, session)
+	}
+	set, _, err = s.LogSessionSet(w.ID, u.ID, models.LogSetRequest{WorkoutExerciseID: weID, Reps: 5, WeightKg: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.IsPR || set.SetNumber != 2 {
+		t.Errorf("second set = %+v", set)
+	}
+	var logged []models.SessionSet
+	if err := s.StreamWorkouts(u.ID, nil, nil, func(w *models.Workout) error {
+		logged = w.Exercises[0].LoggedSets
+		return nil
+	}); err != nil || len(logged) != 2 || !logged[0].IsPR || logged[1].SetNumber != 2 {
+		t.Errorf("streamed sets = %+v, %v", logged, err)
+	}
+
+	active, err := s.ListActiveSessions()
+	if err != nil || len(active) != 1 || len(active[0].Sets) != 2 {
+		t.Fatalf("active sessions = %+v, %v", active, err)
+	}
+
+	// Concurrent starts of a workout leave it with one active session.
+	racing, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{Title: "Race"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.StartSession(racing.ID, u.ID); err != nil && !errors.Is(err, database.ErrSessionActive) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if active, err := s.ListActiveSessions(); err != nil || len(active) != 2 {
+		t.Fatalf("active sessions after racing starts = %+v, %v", active, err)
+	}
+
+	if _, err := s.FinishSession(w.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	done, err := s.GetWorkoutByID(w.ID, u.ID)
+	if err != nil || done.Status != "completed" || done.CompletedAt == nil {
+		t.Errorf("finished workout = %+v, %v", done, err)
+	}
+	if got, err := s.GetActiveSession(w.ID, u.ID); got != nil || err != nil {
+		t.Errorf("active session after finish = %+v, %v", got, err)
+	}
+}
+
+func testTimeZones(t *testing.T, s database.Store) {
+	u, err := s.CreateUser("Berlin", "tz@example.com", "hash", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.TimeZone != "Europe/Berlin" || u.Location().String() != "Europe/Berlin" {
+		t.Errorf("time zone = %q", u.TimeZone)
+	}
+
+	// 23:30 and 00:30 Berlin time, either side of midnight on 2 January.
+	for _, at := range []time.Time{
+		time.Date(2024, 1, 1, 22, 30, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC),
+	} {
+		at := at
+		if _, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{Title: at.Format("15:04"), ScheduledAt: &at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, u.Location())
+	page, err := s.ListWorkouts(u.ID, models.WorkoutFilter{From: &day, To: &day})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Workouts[0].Title != "23:30" {
+		t.Errorf("workouts on 2 January in Berlin = %+v", page.Workouts)
+	}
+	if got := page.Workouts[0].ScheduledAt; got.Location() != time.UTC {
+		t.Errorf("stored time read back in %v, want UTC", got.Location())
+	}
+
+	updated, err := s.UpdateUserTimeZone(u.ID, "America/New_York")
+	if err != nil || updated.TimeZone != "America/New_York" {
+		t.Fatalf("UpdateUserTimeZone = %+v, %v", updated, err)
+	}
+	report, err := s.GetReport(u.ID, models.WorkoutFilter{})
+	if err != nil || report.TimeZone != "America/New_York" {
+		t.Errorf("report time zone = %+v, %v", report, err)
+	}
+}
+
+func testErrors(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "e@example.com")
+	if _, err := s.CreateUser("Other", "e@example.com", "hash", ""); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("duplicate email: %v, want ErrConflict", err)
+	}
+
+	title := "Missing"
+	if _, err := s.UpdateWorkout(999, u.ID, models.UpdateWorkoutRequest{Title: &title}, 0); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("UpdateWorkout of missing workout: %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteWorkout(999, u.ID, 0); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("DeleteWorkout of missing workout: %v, want ErrNotFound", err)
+	}
+	if _, err := s.FinishSession(999, u.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("FinishSession without session: %v, want ErrNotFound", err)
+	}
+
+	_, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{
+		Title:     "Bad",
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: 123456, Sets: 1}},
+	})
+	var de *database.Error
+	if !errors.As(err, &de) || de.Kind != database.ErrValidation || len(de.Fields) != 1 ||
+		de.Fields[0].Pointer != "/exercises/0/exercise_id" {
+		t.Errorf("unknown exercise: %#v", err)
+	}
+	if page, _ := s.ListWorkouts(u.ID, models.WorkoutFilter{}); len(page.Workouts) != 0 {
+		t.Errorf("failed create left %d workouts behind", len(page.Workouts))
+	}
+
+	if _, err := s.ListWorkouts(u.ID, models.WorkoutFilter{Limit: 10, Cursor: "garbage"}); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("bad cursor: %v, want ErrValidation", err)
+	}
+}
+
+func testVersions(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "v@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+	squat := mustExercise(t, s, "Squat")
+	row := mustExercise(t, s, "Bent Over Row")
+
+	w, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{
+		Title: "Full body",
+		Exercises: []models.WorkoutExerciseRequest{
+			{ExerciseID: bench.ID, Sets: 3, Reps: 5},
+			{ExerciseID: squat.ID, Sets: 3, Reps: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Version != 1 {
+		t.Fatalf("new workout version = %d", w.Version)
+	}
+	benchEntry, squatEntry := w.Exercises[0].ID, w.Exercises[1].ID
+
+	// Sets logged against the bench entry must survive editing the squat.
+	if _, err := s.StartSession(w.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.LogSessionSet(w.ID, u.ID, models.LogSetRequest{WorkoutExerciseID: benchEntry, Reps: 5, WeightKg: 60}); err != nil {
+		t.Fatal(err)
+	}
+	w, _ = s.GetWorkoutByID(w.ID, u.ID)
+	if w.Version != 2 {
+		t.Errorf("starting a session left version at %d", w.Version)
+	}
+
+	state := w.State()
+	state.Exercises[1].Reps = 8
+	state.Exercises = append([]models.WorkoutStateExercise{{WorkoutExerciseRequest: models.WorkoutExerciseRequest{ExerciseID: row.ID, Sets: 3, Reps: 10}}},
+		state.Exercises...)
+	saved, err := s.SaveWorkout(w.ID, u.ID, w.Version, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != 3 || len(saved.Exercises) != 3 || saved.Exercises[0].ExerciseID != row.ID ||
+		saved.Exercises[1].ID != benchEntry || saved.Exercises[2].ID != squatEntry || saved.Exercises[2].Reps != 8 {
+		t.Errorf("saved workout = %+v", saved)
+	}
+	if session, _ := s.GetActiveSession(w.ID, u.ID); session == nil || len(session.Sets) != 1 {
+		t.Errorf("logged sets lost after edit: %+v", session)
+	}
+
+	if _, err := s.SaveWorkout(w.ID, u.ID, w.Version, state); !errors.Is(err, database.ErrVersionMismatch) {
+		t.Errorf("save at stale version: %v, want ErrVersionMismatch", err)
+	}
+	title := "Stale"
+	if _, err := s.UpdateWorkout(w.ID, u.ID, models.UpdateWorkoutRequest{Title: &title}, w.Version); !errors.Is(err, database.ErrVersionMismatch) {
+		t.Errorf("update at stale version: %v, want ErrVersionMismatch", err)
+	}
+	state = saved.State()
+	state.Exercises = append(state.Exercises, models.WorkoutStateExercise{ID: 987654})
+	if _, err := s.SaveWorkout(w.ID, u.ID, saved.Version, state); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("save with foreign entry id: %v, want ErrValidation", err)
+	}
+	if err := s.DeleteWorkout(w.ID, u.ID, w.Version); !errors.Is(err, database.ErrVersionMismatch) {
+		t.Errorf("delete at stale version: %v, want ErrVersionMismatch", err)
+	}
+	if err := s.DeleteWorkout(w.ID, u.ID, saved.Version); err != nil {
+		t.Errorf("delete at current version: %v", err)
+	}
+}
+
+func testSync(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "sync@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+	squat := mustExercise(t, s, "Squat")
+
+	full, err := s.GetChanges(u.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{
+		Title:     "Offline",
+		ClientID:  "w-1",
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 3, Reps: 5}, {ExerciseID: squat.ID, Sets: 3, Reps: 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{Title: "Again", ClientID: "w-1"}); !errors.Is(err, database.ErrClientIDTaken) {
+		t.Errorf("reused client_id: %v, want ErrClientIDTaken", err)
+	}
+	if id, err := s.FindClientID(u.ID, "workout", "w-1"); err != nil || id != w.ID {
+		t.Errorf("FindClientID = %d, %v", id, err)
+	}
+
+	// A set logged offline gets a finished session and leaves the workout
+	// pending.
+	done := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+	set, err := s.AddSyncedSet(w.ID, u.ID, "s-1", models.SyncSet{WorkoutExerciseID: w.Exercises[1].ID, Reps: 5, WeightKg: 120, CompletedAt: &done})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.SetNumber != 1 || !set.IsPR || !set.CompletedAt.Equal(done) {
+		t.Errorf("synced set = %+v", set)
+	}
+	if active, _ := s.GetActiveSession(w.ID, u.ID); active != nil {
+		t.Errorf("synced set started a session: %+v", active)
+	}
+	if got, _ := s.GetWorkoutByID(w.ID, u.ID); got.Status != "pending" {
+		t.Errorf("workout status = %q", got.Status)
+	}
+
+	// Removing the squat takes its set with it.
+	state := w.State()
+	state.Exercises = state.Exercises[:1]
+	if _, err := s.SaveWorkout(w.ID, u.ID, w.Version, state); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := s.GetChanges(u.ID, full.Cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.Workouts) != 1 || ch.Workouts[0].ClientID != "w-1" || len(ch.Deleted) != 1 ||
+		ch.Deleted[0].Type != "set" || ch.Deleted[0].ID != set.ID || ch.Deleted[0].ClientID != "s-1" {
+		t.Errorf("changes = %+v", ch)
+	}
+	if n, err := s.PurgeTombstones(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("purge of fresh tombstones = %d, %v", n, err)
+	}
+	if n, err := s.PurgeTombstones(time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Errorf("purge = %d, %v, want 1", n, err)
+	}
+	if ch, err := s.GetChanges(u.ID, full.Cursor); err != nil || len(ch.Deleted) != 0 {
+		t.Errorf("changes after purge = %+v, %v", ch, err)
+	}
+
+	if err := s.SaveSyncResult(u.ID, "k", []byte(`{"status":"applied"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveSyncResult(u.ID, "k", []byte(`{"status":"other"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetSyncResult(u.ID, "k"); err != nil || string(got) != `{"status":"applied"}` {
+		t.Errorf("stored result = %s, %v", got, err)
+	}
+	if got, _ := s.GetSyncResult(u.ID, "missing"); got != nil {
+		t.Errorf("unknown key returned %s", got)
+	}
+
+	// A claimed key holds off a second attempt until the result is saved,
+	// and a released one is free again.
+	if got, err := s.ClaimSyncResult(u.ID, "c"); got != nil || err != nil {
+		t.Fatalf("first claim = %s, %v", got, err)
+	}
+	if _, err := s.ClaimSyncResult(u.ID, "c"); !errors.Is(err, database.ErrSyncKeyInUse) {
+		t.Errorf("claim while applying: %v", err)
+	}
+	if err := s.SaveSyncResult(u.ID, "c", []byte(`{"status":"applied"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.ClaimSyncResult(u.ID, "c"); err != nil || string(got) != `{"status":"applied"}` {
+		t.Errorf("claim after save = %s, %v", got, err)
+	}
+	if err := s.ReleaseSyncResult(u.ID, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetSyncResult(u.ID, "c"); got == nil {
+		t.Error("release dropped a saved result")
+	}
+	s.ClaimSyncResult(u.ID, "r")
+	if err := s.ReleaseSyncResult(u.ID, "r"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.ClaimSyncResult(u.ID, "r"); got != nil || err != nil {
+		t.Errorf("claim after release = %s, %v", got, err)
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "idem@example.com")
+	other := mustUser(t, s, "other@example.com")
+	hourAgo := time.Now().Add(-time.Hour)
+
+	if stored, err := s.ClaimIdempotencyKey(u.ID, "k1", "fp", hourAgo); err != nil || stored != nil {
+		t.Fatalf("first claim: %+v, %v", stored, err)
+	}
+	if stored, err := s.ClaimIdempotencyKey(u.ID, "k1", "fp", hourAgo); err != nil || stored == nil || stored.Status != 0 {
+		t.Fatalf("claim while running: %+v, %v", stored, err)
+	}
+	if err := s.RenewIdempotencyKey(u.ID, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := s.ClaimIdempotencyKey(u.ID, "k1", "fp", hourAgo); err != nil || stored == nil || stored.Status != 0 {
+		t.Fatalf("claim after renewal: %+v, %v", stored, err)
+	}
+	if stored, err := s.ClaimIdempotencyKey(other.ID, "k1", "fp", hourAgo); err != nil || stored != nil {
+		t.Errorf("keys are per user: %+v, %v", stored, err)
+	}
+
+	resp := models.StoredResponse{Fingerprint: "fp", Status: 201, Header: map[string]string{"ETag": `"1"`}, Body: []byte(`{"id":1}`)}
+	if err := s.SaveIdempotentResponse(u.ID, "k1", resp); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.ClaimIdempotencyKey(u.ID, "k1", "other", hourAgo)
+	if err != nil || stored == nil || stored.Fingerprint != "fp" || stored.Status != 201 ||
+		stored.Header["ETag"] != `"1"` || string(stored.Body) != `{"id":1}` {
+		t.Fatalf("stored response: %+v, %v", stored, err)
+	}
+
+	// An expired key is free again, and a released one at once.
+	if stored, err := s.ClaimIdempotencyKey(u.ID, "k1", "new", time.Now().Add(time.Minute)); err != nil || stored != nil {
+		t.Errorf("claim after expiry: %+v, %v", stored, err)
+	}
+	if err := s.ReleaseIdempotencyKey(u.ID, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := s.ClaimIdempotencyKey(u.ID, "k1", "fp", hourAgo); err != nil || stored != nil {
+		t.Errorf("claim after release: %+v, %v", stored, err)
+	}
+
+	if err := s.SaveSyncResult(u.ID, "m1", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeIdempotencyKeys(hourAgo); err != nil || n != 0 {
+		t.Errorf("purge of fresh keys = %d, %v", n, err)
+	}
+	if n, err := s.PurgeIdempotencyKeys(time.Now().Add(time.Minute)); err != nil || n != 3 {
+		t.Errorf("purge = %d, %v, want 3", n, err)
+	}
+	if stored, _ := s.GetSyncResult(u.ID, "m1"); stored != nil {
+		t.Error("sync result survived the purge")
+	}
+}
+
+func testTrash(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "trash@example.com")
+	other := mustUser(t, s, "trash2@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+
+	keep, err := s.CreateWorkout(u.ID, models.CreateWorkoutRequest{Title: "Keep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+	gone, err := s.CreateCompletedWorkout(u.ID, models.CreateWorkoutRequest{
+		Title:     "Heavy day",
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 1, Reps: 1, WeightKg: 200}},
+	}, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteWorkout(gone.ID, u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteWorkout(gone.ID, u.ID, 0); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("deleting a trashed workout: %v, want ErrNotFound", err)
+	}
+
+	// Trashed workouts are left out of lists, exports, reports and sync.
+	page, err := s.ListWorkouts(u.ID, models.WorkoutFilter{})
+	if err != nil || page.Total != 1 || page.Workouts[0].ID != keep.ID {
+		t.Errorf("list with one trashed: %+v, %v", page, err)
+	}
+	var streamed int
+	s.StreamWorkouts(u.ID, nil, nil, func(*models.Workout) error { streamed++; return nil })
+	if streamed != 1 {
+		t.Errorf("streamed %d workouts, want 1", streamed)
+	}
+	report, err := s.GetReport(u.ID, models.WorkoutFilter{})
+	if err != nil || report.TotalWorkouts != 1 || report.CompletedWorkouts != 0 || report.TotalVolumeKg != 0 {
+		t.Errorf("report with one trashed: %+v, %v", report, err)
+	}
+	if ch, err := s.GetChanges(u.ID, ""); err != nil || len(ch.Workouts) != 1 {
+		t.Errorf("sync with one trashed: %+v, %v", ch, err)
+	}
+	if _, err := s.StartSession(gone.ID, u.ID); !errors.Is(err, database.ErrWorkoutNotFound) {
+		t.Errorf("starting a trashed workout: %v", err)
+	}
+
+	trash, err := s.ListTrash(u.ID)
+	if err != nil || len(trash) != 1 || trash[0].ID != gone.ID || trash[0].DeletedAt == nil || len(trash[0].Exercises) != 1 {
+		t.Fatalf("trash = %+v, %v", trash, err)
+	}
+	if _, err := s.RestoreWorkout(gone.ID, other.ID); !errors.Is(err, database.ErrWorkoutNotFound) {
+		t.Errorf("restoring another user's workout: %v", err)
+	}
+	restored, err := s.RestoreWorkout(gone.ID, u.ID)
+	if err != nil || restored.DeletedAt != nil || restored.Version != gone.Version+2 || restored.CompletedAt == nil {
+		t.Fatalf("restored = %+v, %v", restored, err)
+	}
+	if _, err := s.RestoreWorkout(gone.ID, u.ID); !errors.Is(err, database.ErrWorkoutNotFound) {
+		t.Errorf("restoring a workout outside the trash: %v", err)
+	}
+
+	if err := s.DeleteWorkout(gone.ID, u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeTrash(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("purge of a fresh trash = %d, %v", n, err)
+	}
+	if n, err := s.PurgeTrash(time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Errorf("purge = %d, %v, want 1", n, err)
+	}
+	if trash, _ := s.ListTrash(u.ID); len(trash) != 0 {
+		t.Errorf("trash after purge: %+v", trash)
+	}
+	if got, _ := s.GetWorkoutByID(keep.ID, u.ID); got == nil {
+		t.Error("purge removed a live workout")
+	}
+}
+
+func testAudit(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "audit@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+	as := s.As(database.Actor{UserID: u.ID, RequestID: "req-1"})
+
+	w, err := as.CreateWorkout(u.ID, models.CreateWorkoutRequest{
+		Title:     "Push",
+		Exercises: []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 3, Reps: 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	title := "Push day"
+	if w, err = as.UpdateWorkout(w.ID, u.ID, models.UpdateWorkoutRequest{Title: &title}, 0); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing changed, so nothing is recorded; a failed change neither.
+	if w, err = as.UpdateWorkout(w.ID, u.ID, models.UpdateWorkoutRequest{Title: &title}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := as.UpdateWorkout(w.ID, u.ID, models.UpdateWorkoutRequest{Title: &title}, 1); !errors.Is(err, database.ErrVersionMismatch) {
+		t.Fatalf("stale update: %v", err)
+	}
+	if err := as.DeleteWorkout(w.ID, u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PurgeTrash(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := s.ListAuditEvents(models.AuditFilter{UserID: u.ID, Entity: "workout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range page.Events {
+		actions = append(actions, e.Action)
+	}
+	if got := strings.Join(actions, ","); got != "purge,delete,update,create" {
+		t.Fatalf("workout events = %s", got)
+	}
+	purge, del, update, create := page.Events[0], page.Events[1], page.Events[2], page.Events[3]
+	if create.ActorID != u.ID || create.RequestID != "req-1" || create.EntityID != w.ID ||
+		string(create.Changes["title"].Before) != "null" || string(create.Changes["title"].After) != `"Push"` {
+		t.Errorf("create = %+v", create)
+	}
+	if len(update.Changes) != 1 || string(update.Changes["title"].Before) != `"Push"` || string(update.Changes["title"].After) != `"Push day"` {
+		t.Errorf("update changes = %+v", update.Changes)
+	}
+	if len(del.Changes) != 1 || string(del.Changes["deleted_at"].Before) != "null" {
+		t.Errorf("delete changes = %+v", del.Changes)
+	}
+	if purge.ActorID != 0 || purge.RequestID != "" || string(purge.Changes["title"].After) != "null" {
+		t.Errorf("purge by the background job = %+v", purge)
+	}
+
+	if page, err := s.ListAuditEvents(models.AuditFilter{UserID: u.ID, Entity: "workout", Limit: 3}); err != nil || len(page.Events) != 3 || page.NextCursor == "" {
+		t.Fatalf("first page: %+v, %v", page, err)
+	} else if next, err := s.ListAuditEvents(models.AuditFilter{UserID: u.ID, Entity: "workout", Limit: 3, Cursor: page.NextCursor}); err != nil ||
+		len(next.Events) != 1 || next.Events[0].Action != "create" || next.NextCursor != "" {
+		t.Errorf("second page: %+v, %v", next, err)
+	}
+	if page, _ := s.ListAuditEvents(models.AuditFilter{UserID: u.ID, Entity: "user"}); len(page.Events) != 1 || page.Events[0].Action != "create" {
+		t.Errorf("user events = %+v", page.Events)
+	}
+	other := mustUser(t, s, "other@example.com")
+	if page, _ := s.ListAuditEvents(models.AuditFilter{UserID: other.ID, Entity: "workout"}); len(page.Events) != 0 {
+		t.Errorf("another user sees %d workout events", len(page.Events))
+	}
+	var streamed int
+	if err := s.StreamAuditEvents(models.AuditFilter{}, func(*models.AuditEvent) error { streamed++; return nil }); err != nil || streamed != 6 {
+		t.Errorf("streamed %d events, %v; want 6", streamed, err)
+	}
+}
+
+func testSocial(t *testing.T, s database.Store) {
+	alice := mustUser(t, s, "alice@example.com")
+	bob := mustUser(t, s, "bob@example.com")
+	carol := mustUser(t, s, "carol@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+
+	if _, err := s.Follow(bob.ID, bob.ID); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("follow self: %v", err)
+	}
+	if _, err := s.Follow(bob.ID, alice.ID+1000); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("follow a missing user: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if f, err := s.Follow(bob.ID, alice.ID); err != nil || f.UserID != alice.ID {
+			t.Fatalf("follow #%d = %+v, %v", i+1, f, err)
+		}
+	}
+	if list, err := s.ListFollowers(alice.ID); err != nil || len(list) != 1 || list[0].UserID != bob.ID {
+		t.Errorf("followers = %+v, %v", list, err)
+	}
+
+	// A workout finished in a live session, with a record, for followers.
+	shared, err := s.CreateWorkout(alice.ID, models.CreateWorkoutRequest{
+		Title:      "Bench",
+		Visibility: "followers",
+		Exercises:  []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 2, Reps: 5, WeightKg: 100}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StartSession(shared.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, kg := range []float64{100, 105} {
+		if _, _, err := s.LogSessionSet(shared.ID, alice.ID, models.LogSetRequest{WorkoutExerciseID: shared.Exercises[0].ID, Reps: 5, WeightKg: kg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.FinishSession(shared.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	earlier := time.Now().Add(-time.Hour)
+	public, err := s.CreateCompletedWorkout(alice.ID, models.CreateWorkoutRequest{Title: "Run", Visibility: "public"}, earlier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := s.CreateCompletedWorkout(alice.ID, models.CreateWorkoutRequest{Title: "Secret"}, earlier)
+	if err != nil || private.Visibility != "private" {
+		t.Fatalf("default visibility = %+v, %v", private, err)
+	}
+	if _, err := s.CreateWorkout(alice.ID, models.CreateWorkoutRequest{Title: "Planned", Visibility: "followers"}); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := s.GetFeed(bob.ID, 1, "")
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != shared.ID || page.NextCursor == "" {
+		t.Fatalf("first feed page = %+v, %v", page, err)
+	}
+	item := page.Items[0]
+	if item.Author != "Test" || len(item.PRs) != 1 || item.PRs[0].WeightKg != 105 || item.PRs[0].ExerciseName != "Bench Press" {
+		t.Errorf("feed item = %+v", item)
+	}
+	if next, err := s.GetFeed(bob.ID, 1, page.NextCursor); err != nil || len(next.Items) != 1 || next.Items[0].ID != public.ID || next.NextCursor != "" {
+		t.Errorf("second feed page = %+v, %v", next, err)
+	}
+	if page, err := s.GetFeed(carol.ID, 0, ""); err != nil || len(page.Items) != 0 {
+		t.Errorf("feed of someone who follows nobody = %+v, %v", page, err)
+	}
+	if got, err := s.GetFeedItem(public.ID, carol.ID); err != nil || got == nil {
+		t.Errorf("public item for a stranger = %+v, %v", got, err)
+	}
+	for _, id := range []int64{shared.ID, private.ID} {
+		if got, err := s.GetFeedItem(id, carol.ID); got != nil || err != nil {
+			t.Errorf("item %d for a stranger = %+v, %v; want nil, nil", id, got, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.React(shared.ID, bob.ID, "fire"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.React(shared.ID, alice.ID, "fire"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.React(shared.ID, carol.ID, "fire"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("stranger reacts: %v", err)
+	}
+	if got, _ := s.GetFeedItem(shared.ID, bob.ID); got.Reactions["fire"] != 2 || len(got.MyReactions) != 1 {
+		t.Errorf("reactions = %v, mine %v", got.Reactions, got.MyReactions)
+	}
+	if err := s.Unreact(shared.ID, bob.ID, "fire"); err != nil {
+		t.Fatal(err)
+	}
+
+	comment, err := s.AddComment(shared.ID, bob.ID, "Strong!")
+	if err != nil || comment.Author != "Test" || comment.Body != "Strong!" {
+		t.Fatalf("comment = %+v, %v", comment, err)
+	}
+	if _, err := s.AddComment(private.ID, bob.ID, "?"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("comment on a private workout: %v", err)
+	}
+	if list, err := s.ListComments(shared.ID, alice.ID); err != nil || len(list) != 1 {
+		t.Errorf("comments = %+v, %v", list, err)
+	}
+	if got, _ := s.GetFeedItem(shared.ID, bob.ID); got.CommentCount != 1 || got.Reactions["fire"] != 1 {
+		t.Errorf("feed item after comment = %+v", got)
+	}
+	if err := s.DeleteComment(comment.ID, shared.ID, carol.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("stranger deletes a comment: %v", err)
+	}
+	if err := s.DeleteComment(comment.ID, shared.ID, alice.ID); err != nil {
+		t.Errorf("owner deletes a comment: %v", err)
+	}
+
+	token, err := s.ShareWorkout(private.ID, alice.ID)
+	if err != nil || token == "" {
+		t.Fatalf("share = %q, %v", token, err)
+	}
+	if again, _ := s.ShareWorkout(private.ID, alice.ID); again != token {
+		t.Errorf("second share = %q, want %q", again, token)
+	}
+	if _, err := s.ShareWorkout(private.ID, bob.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("share someone else's workout: %v", err)
+	}
+	if sum, err := s.GetSharedWorkout(token); err != nil || sum == nil || sum.Title != "Secret" || sum.Author != "Test" {
+		t.Errorf("shared summary = %+v, %v", sum, err)
+	}
+	if err := s.UnshareWorkout(private.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if sum, err := s.GetSharedWorkout(token); sum != nil || err != nil {
+		t.Errorf("revoked link = %+v, %v; want nil, nil", sum, err)
+	}
+
+	if err := s.Unfollow(bob.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if page, err := s.GetFeed(bob.ID, 0, ""); err != nil || len(page.Items) != 0 {
+		t.Errorf("feed after unfollowing = %+v, %v", page, err)
+	}
+	if got, _ := s.GetFeedItem(shared.ID, bob.ID); got != nil {
+		t.Errorf("followers-only item after unfollowing = %+v", got)
+	}
+}
+
+func testCoaching(t *testing.T, s database.Store) {
+	coach := mustUser(t, s, "coach@example.com")
+	athlete := mustUser(t, s, "athlete@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+
+	if _, err := s.InviteAthlete(coach.ID, "coach@example.com", []string{"view"}); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("invite self: %v", err)
+	}
+	link, err := s.InviteAthlete(coach.ID, "athlete@example.com", []string{"view", "assign"})
+	if err != nil || link.Status != "pending" || len(link.Scopes) != 2 {
+		t.Fatalf("invite = %+v, %v", link, err)
+	}
+	if _, err := s.InviteAthlete(coach.ID, "athlete@example.com", []string{"view"}); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("invite twice: %v", err)
+	}
+
+	own, err := s.CreateWorkout(athlete.ID, models.CreateWorkoutRequest{Title: "Own"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pending invites grant nothing.
+	if _, err := s.ListAthleteWorkouts(coach.ID, athlete.ID, models.WorkoutFilter{}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("list before accepting: %v", err)
+	}
+	if link, err = s.AcceptCoach(athlete.ID, coach.ID); err != nil || link.Status != "active" || link.AcceptedAt == nil {
+		t.Fatalf("accept = %+v, %v", link, err)
+	}
+
+	req := models.CreateWorkoutRequest{Title: "Heavy day", Exercises: []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 5, Reps: 5, WeightKg: 80}}}
+	assigned, err := s.AssignWorkout(coach.ID, athlete.ID, req)
+	if err != nil || assigned.UserID != athlete.ID || assigned.AssignedBy == nil || *assigned.AssignedBy != coach.ID || len(assigned.Exercises) != 1 {
+		t.Fatalf("assign = %+v, %v", assigned, err)
+	}
+	page, err := s.ListAthleteWorkouts(coach.ID, athlete.ID, models.WorkoutFilter{})
+	if err != nil || page.Total != 2 {
+		t.Errorf("athlete's workouts = %+v, %v", page, err)
+	}
+	if w, err := s.GetAthleteWorkout(own.ID, coach.ID, athlete.ID); err != nil || w == nil {
+		t.Errorf("athlete's workout = %+v, %v", w, err)
+	}
+	if r, err := s.GetAthleteReport(coach.ID, athlete.ID, models.WorkoutFilter{}); err != nil || r.TotalWorkouts != 2 {
+		t.Errorf("athlete's report = %+v, %v", r, err)
+	}
+
+	title := "Renamed"
+	if _, err := s.UpdateAthleteWorkout(own.ID, coach.ID, athlete.ID, models.UpdateWorkoutRequest{Title: &title}, 0); !errors.Is(err, database.ErrForbidden) {
+		t.Errorf("edit without the scope: %v", err)
+	}
+	if _, err := s.UpdateCoachScopes(athlete.ID, coach.ID, []string{"edit"}); err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.UpdateAthleteWorkout(own.ID, coach.ID, athlete.ID, models.UpdateWorkoutRequest{Title: &title}, 0)
+	if err != nil || w.Title != title {
+		t.Errorf("edit = %+v, %v", w, err)
+	}
+	if _, err := s.ListAthleteWorkouts(coach.ID, athlete.ID, models.WorkoutFilter{}); !errors.Is(err, database.ErrForbidden) {
+		t.Errorf("view after it was taken away: %v", err)
+	}
+
+	// Coaching another account never reaches the coach's own workouts.
+	if _, err := s.GetAthleteWorkout(assigned.ID, athlete.ID, coach.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("athlete coaching the coach: %v", err)
+	}
+
+	if err := s.RevokeCoach(athlete.ID, coach.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateAthleteWorkout(own.ID, coach.ID, athlete.ID, models.UpdateWorkoutRequest{Title: &title}, 0); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("edit after revoking: %v", err)
+	}
+	if list, err := s.ListAthletes(coach.ID); err != nil || len(list) != 0 {
+		t.Errorf("athletes after revoking = %+v, %v", list, err)
+	}
+	if err := s.DropAthlete(coach.ID, athlete.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("drop after revoking: %v", err)
+	}
+}
+
+func testTeams(t *testing.T, s database.Store) {
+	owner := mustUser(t, s, "owner@example.com")
+	member := mustUser(t, s, "member@example.com")
+	outsider := mustUser(t, s, "outsider@example.com")
+	pullUp := mustExercise(t, s, "Pull-Up")
+	bench := mustExercise(t, s, "Bench Press")
+
+	team, err := s.CreateTeam(owner.ID, "Lunch lifters")
+	if err != nil || team.OwnerID != owner.ID || team.JoinCode == "" || len(team.Members) != 1 {
+		t.Fatalf("create = %+v, %v", team, err)
+	}
+	if _, err := s.JoinTeam(member.ID, "not-a-code"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("join with a wrong code: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if team, err = s.JoinTeam(member.ID, team.JoinCode); err != nil || team.MemberCount != 2 {
+			t.Fatalf("join %d = %+v, %v", i, team, err)
+		}
+	}
+	if got, err := s.GetTeam(team.ID, outsider.ID); err != nil || got != nil {
+		t.Errorf("outsider's view = %+v, %v", got, err)
+	}
+	if _, err := s.GetLeaderboard(team.ID, outsider.ID, "volume", nil, nil); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("outsider's leaderboard: %v", err)
+	}
+
+	now := time.Now().UTC()
+	complete := func(userID int64, at time.Time, e models.WorkoutExerciseRequest) {
+		t.Helper()
+		if _, err := s.CreateCompletedWorkout(userID, models.CreateWorkoutRequest{Title: "Done", Exercises: []models.WorkoutExerciseRequest{e}}, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	complete(owner.ID, now.Add(-24*time.Hour), models.WorkoutExerciseRequest{ExerciseID: pullUp.ID, Sets: 5, Reps: 10})
+	complete(owner.ID, now, models.WorkoutExerciseRequest{ExerciseID: pullUp.ID, Sets: 5, Reps: 10})
+	complete(member.ID, now, models.WorkoutExerciseRequest{ExerciseID: pullUp.ID, Sets: 5, Reps: 12})
+	complete(member.ID, now, models.WorkoutExerciseRequest{ExerciseID: bench.ID, Sets: 3, Reps: 5, WeightKg: 100})
+	complete(outsider.ID, now, models.WorkoutExerciseRequest{ExerciseID: bench.ID, Sets: 10, Reps: 10, WeightKg: 200})
+
+	board := func(metric string, from *time.Time) []models.LeaderboardEntry {
+		t.Helper()
+		entries, err := s.GetLeaderboard(team.ID, owner.ID, metric, from, nil)
+		if err != nil || len(entries) != 2 {
+			t.Fatalf("%s leaderboard = %+v, %v", metric, entries, err)
+		}
+		return entries
+	}
+	if e := board("volume", nil); e[0].UserID != member.ID || e[0].Value != 1500 || e[0].Rank != 1 || e[1].Rank != 2 {
+		t.Errorf("volume = %+v", e)
+	}
+	if e := board("sessions", nil); e[0].UserID != owner.ID || e[0].Value != 2 || e[1].Value != 2 || e[1].Rank != 1 {
+		t.Errorf("sessions = %+v", e)
+	}
+	if e := board("streak", nil); e[0].UserID != owner.ID || e[0].Value != 2 || e[1].Value != 1 {
+		t.Errorf("streak = %+v", e)
+	}
+	// As on their achievements, a rest day keeps the member's streak going
+	// until they take rest days away.
+	complete(member.ID, now.Add(-48*time.Hour), models.WorkoutExerciseRequest{ExerciseID: bench.ID, Sets: 1, Reps: 1, WeightKg: 20})
+	if e := board("streak", nil); e[0].UserID != member.ID || e[0].Value != 3 || e[1].Rank != 2 {
+		t.Errorf("streak with a rest day = %+v", e)
+	}
+	if _, err := s.UpdateUserRestDays(member.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if e := board("streak", nil); e[0].UserID != owner.ID || e[1].Value != 1 {
+		t.Errorf("streak without rest days = %+v", e)
+	}
+	if _, err := s.GetLeaderboard(team.ID, owner.ID, "calories", nil, nil); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("unknown metric: %v", err)
+	}
+	future := now.Add(time.Hour)
+	if e := board("volume", &future); e[0].Rank != 1 || e[1].Rank != 1 || e[0].Value != 0 {
+		t.Errorf("volume of an empty period = %+v", e)
+	}
+
+	req := models.CreateChallengeRequest{
+		Title: "100 pull-ups", Metric: "reps", ExerciseID: &pullUp.ID, Target: 100,
+		StartsAt: now.Add(-48 * time.Hour), EndsAt: now.Add(120 * time.Hour),
+	}
+	if _, err := s.CreateChallenge(team.ID, outsider.ID, req); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("outsider's challenge: %v", err)
+	}
+	custom, err := s.CreateCustomExercise(member.ID, "Towel pull-up", "strength", "back", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := req
+	bad.ExerciseID = &custom.ID
+	if _, err := s.CreateChallenge(team.ID, member.ID, bad); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("challenge on a custom exercise: %v", err)
+	}
+	ch, err := s.CreateChallenge(team.ID, owner.ID, req)
+	if err != nil || len(ch.Standings) != 2 {
+		t.Fatalf("challenge = %+v, %v", ch, err)
+	}
+	if first := ch.Standings[0]; first.UserID != owner.ID || first.Progress != 100 || !first.Completed {
+		t.Errorf("leader = %+v", first)
+	}
+	if second := ch.Standings[1]; second.Rank != 2 || second.Progress != 60 || second.Completed {
+		t.Errorf("runner-up = %+v", second)
+	}
+	if list, err := s.ListChallenges(team.ID, member.ID); err != nil || len(list) != 1 || list[0].Standings != nil {
+		t.Errorf("challenges = %+v, %v", list, err)
+	}
+	if err := s.DeleteChallenge(ch.ID, team.ID, member.ID); !errors.Is(err, database.ErrForbidden) {
+		t.Errorf("member deleting the owner's challenge: %v", err)
+	}
+
+	if err := s.RemoveTeamMember(team.ID, owner.ID, owner.ID); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("owner leaving: %v", err)
+	}
+	if err := s.DeleteTeam(team.ID, member.ID); !errors.Is(err, database.ErrForbidden) {
+		t.Errorf("member deleting the team: %v", err)
+	}
+	if err := s.RemoveTeamMember(team.ID, member.ID, member.ID); err != nil {
+		t.Fatal("leave:", err)
+	}
+	if list, err := s.ListTeams(member.ID); err != nil || len(list) != 0 {
+		t.Errorf("teams after leaving = %+v, %v", list, err)
+	}
+	if err := s.DeleteTeam(team.ID, owner.ID); err != nil {
+		t.Fatal("delete:", err)
+	}
+	if _, err := s.GetChallenge(ch.ID, team.ID, owner.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("challenge of a deleted team: %v", err)
+	}
+}
+
+func testGoals(t *testing.T, s database.Store) {
+	u := mustUser(t, s, "goals@example.com")
+	other := mustUser(t, s, "other@example.com")
+	bench := mustExercise(t, s, "Bench Press")
+
+	now := time.Now().UTC()
+	for _, at := range []time.Time{now.Add(-48 * time.Hour), now} {
+		if _, err := s.CreateCompletedWorkout(u.ID, models.CreateWorkoutRequest{
+			Title:     "Bench",
+			Exercises: []models.WorkoutExerciseRequest{{ExerciseID: bench.ID, Sets: 1, Reps: 1, WeightKg: 100}},
+		}, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The day in between is a rest day, which the default allows.
+	a, err := s.GetAchievements(u.ID)
+	if err != nil || a.Streak.Current != 3 || a.Streak.Longest != 3 || a.Streak.RestDays != 1 {
+		t.Fatalf("achievements = %+v, %v", a, err)
+	}
+	earned := map[string]bool{}
+	for _, b := range a.Badges {
+		if b.Earned != (b.EarnedAt != nil) {
+			t.Errorf("badge %+v", b)
+		}
+		earned[b.Code] = b.Earned
+	}
+	if len(a.Badges) < 3 || !earned["first_workout"] || !earned["bench_100"] || earned["workouts_10"] {
+		t.Errorf("badges = %+v", a.Badges)
+	}
+	if _, err := s.UpdateUserRestDays(u.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := s.GetAchievements(u.ID); err != nil || a.Streak.Current != 1 || a.Streak.Longest != 1 {
+		t.Errorf("streak without rest days = %+v, %v", a.Streak, err)
+	}
+
+	sessions, err := s.CreateGoal(u.ID, models.CreateGoalRequest{Kind: "sessions", Target: 1})
+	if err != nil || sessions.Period != "week" || sessions.Progress.From == nil || !sessions.Progress.Achieved || sessions.Progress.Percent != 100 {
+		t.Errorf("sessions goal = %+v, %v", sessions, err)
+	}
+	lift, err := s.CreateGoal(u.ID, models.CreateGoalRequest{Kind: "lift", Target: 120, ExerciseID: &bench.ID})
+	if err != nil || lift.Progress.Current != 100 || lift.Progress.Percent != 83.3 || lift.Progress.Achieved {
+		t.Errorf("lift goal = %+v, %v", lift, err)
+	}
+	custom, err := s.CreateCustomExercise(other.ID, "Floor press", "strength", "chest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateGoal(u.ID, models.CreateGoalRequest{Kind: "lift", Target: 50, ExerciseID: &custom.ID}); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("lift on someone else's exercise: %v", err)
+	}
+
+	if _, err := s.LogBodyWeight(u.ID, models.LogBodyWeightRequest{WeightKg: 90}); err != nil {
+		t.Fatal(err)
+	}
+	weight, err := s.CreateGoal(u.ID, models.CreateGoalRequest{Kind: "bodyweight", Target: 85})
+	if err != nil || weight.StartValue == nil || *weight.StartValue != 90 || weight.Progress.Percent != 0 {
+		t.Fatalf("body weight goal = %+v, %v", weight, err)
+	}
+	later := now.Add(time.Hour)
+	if _, err := s.LogBodyWeight(u.ID, models.LogBodyWeightRequest{WeightKg: 87, MeasuredAt: &later}); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := s.GetGoal(weight.ID, u.ID); err != nil || g.Progress.Current != 87 || g.Progress.Percent != 60 || g.Progress.Achieved {
+		t.Errorf("body weight progress = %+v, %v", g, err)
+	}
+	if list, err := s.ListBodyWeights(u.ID); err != nil || len(list) != 2 || list[0].WeightKg != 87 {
+		t.Errorf("body weights = %+v, %v", list, err)
+	}
+	if err := s.DeleteBodyWeight(1, other.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("delete someone else's body weight: %v", err)
+	}
+
+	if list, err := s.ListGoals(u.ID); err != nil || len(list) != 3 {
+		t.Errorf("goals = %+v, %v", list, err)
+	}
+	if g, err := s.GetGoal(lift.ID, other.ID); err != nil || g != nil {
+		t.Errorf("someone else's goal = %+v, %v", g, err)
+	}
+	if err := s.DeleteGoal(lift.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteGoal(lift.ID, u.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("delete twice: %v", err)
+	}
+}
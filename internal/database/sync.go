@@ -0,0 +1,356 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// ---- Offline sync ----
+
+// syncOverlap is how far a sync cursor reaches back before the pull that
+// returned it. A change is stamped before its transaction commits, so a
+// pull can miss changes stamped just before it; the next pull returns them
+// again, and clients apply changes by ID, so seeing one twice is harmless.
+const syncOverlap = 5 * time.Second
+
+var errInvalidSince = Invalid("invalid_cursor", "invalid cursor", models.FieldError{
+	Parameter: "since", Code: "invalid_cursor", Message: "not a cursor returned by GET /sync",
+})
+
+func encodeSyncCursor(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(formatTime(t)))
+}
+
+func decodeSyncCursor(s string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", errInvalidSince
+	}
+	t, err := time.Parse(timeLayout, string(b))
+	if err != nil {
+		return "", errInvalidSince
+	}
+	return formatTime(t), nil
+}
+
+// GetChanges returns what changed for the user since cursor: workouts,
+// custom exercises and logged sets written at or after it, and tombstones
+// of what was deleted. An empty cursor returns everything the user has,
+// the shared exercise library included, and no tombstones.
+func (db *DB) GetChanges(userID int64, cursor string) (*models.SyncChanges, error) {
+	var since string
+	if cursor != "" {
+		var err error
+		if since, err = decodeSyncCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	ch := &models.SyncChanges{
+		Cursor:    encodeSyncCursor(now().Add(-syncOverlap)),
+		Workouts:  []models.Workout{},
+		Exercises: []models.Exercise{},
+		Sets:      []models.SessionSet{},
+		Deleted:   []models.Tombstone{},
+	}
+
+	query := `SELECT ` + workoutColumns + ` FROM workouts w WHERE w.user_id = ?` + notTrashed
+	args := []interface{}{userID}
+	if since != "" {
+		query += ` AND w.updated_at >= ?`
+		args = append(args, since)
+	}
+	rows, err := db.Query(query+` ORDER BY w.updated_at, w.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		w, err := scanWorkout(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ch.Workouts = append(ch.Workouts, *w)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(ch.Workouts))
+	for i, w := range ch.Workouts {
+		ids[i] = w.ID
+	}
+	byWorkout, err := db.loadWorkoutExercises(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ch.Workouts {
+		ch.Workouts[i].Exercises = byWorkout[ch.Workouts[i].ID]
+	}
+
+	query = `SELECT ` + exerciseColumns + ` FROM exercises WHERE user_id IS NULL OR user_id = ?`
+	if since != "" {
+		query = `SELECT ` + exerciseColumns + ` FROM exercises WHERE user_id = ? AND updated_at >= ?`
+	}
+	rows, err = db.Query(query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		e, err := scanExercise(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ch.Exercises = append(ch.Exercises, *e)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	query = `SELECT ` + setColumns + `, s.workout_id FROM session_sets ss
+		JOIN workout_sessions s ON s.id = ss.session_id
+		JOIN workouts w ON w.id = s.workout_id WHERE s.user_id = ?` + notTrashed
+	if since != "" {
+		query += ` AND ss.updated_at >= ?`
+	}
+	rows, err = db.Query(query+` ORDER BY ss.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var workoutID int64
+		ss, err := scanSessionSet(rows, &workoutID)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ss.WorkoutID = workoutID
+		ch.Sets = append(ch.Sets, *ss)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil || since == "" {
+		return ch, err
+	}
+
+	rows, err = db.Query(`SELECT entity, entity_id, client_id, deleted_at FROM tombstones
+		WHERE user_id = ? AND deleted_at >= ? ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.Tombstone
+		var clientID sql.NullString
+		var deletedStr string
+		if err := rows.Scan(&t.Type, &t.ID, &clientID, &deletedStr); err != nil {
+			return nil, err
+		}
+		t.ClientID = clientID.String
+		if t.DeletedAt, err = parseTime(deletedStr); err != nil {
+			return nil, err
+		}
+		ch.Deleted = append(ch.Deleted, t)
+	}
+	return ch, rows.Err()
+}
+
+// clientIDQueries find a user's record by the ID a syncing client gave it.
+var clientIDQueries = map[string]string{
+	"workout":  `SELECT id FROM workouts WHERE user_id = ? AND client_id = ? AND deleted_at IS NULL`,
+	"exercise": `SELECT id FROM exercises WHERE user_id = ? AND client_id = ?`,
+	"set": `SELECT ss.id FROM session_sets ss JOIN workout_sessions s ON s.id = ss.session_id
+		WHERE s.user_id = ? AND ss.client_id = ?`,
+}
+
+// FindClientID returns the ID of the user's workout, exercise or set that
+// a syncing client created as clientID, or 0 if there is none.
+func (db *DB) FindClientID(userID int64, entity, clientID string) (int64, error) {
+	query, ok := clientIDQueries[entity]
+	if !ok {
+		return 0, fmt.Errorf("no client IDs for %q", entity)
+	}
+	var id int64
+	err := db.QueryRow(query, userID, clientID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// AddSyncedSet records a set logged offline, at the client's completion
+// time. It joins the workout's active session or else its latest one; a
+// workout without sessions gets a finished one. Unlike LogSessionSet it
+// starts no rest timer and leaves the workout's status alone.
+func (db *DB) AddSyncedSet(workoutID, userID int64, clientID string, req models.SyncSet) (*models.SessionSet, error) {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM workouts WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, workoutID, userID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrWorkoutNotFound
+	}
+	set := &models.SessionSet{
+		WorkoutID:         workoutID,
+		WorkoutExerciseID: req.WorkoutExerciseID,
+		Reps:              req.Reps,
+		WeightKg:          req.WeightKg,
+		DurationSec:       req.DurationSec,
+		ClientID:          clientID,
+		CompletedAt:       now(),
+	}
+	if req.CompletedAt != nil {
+		set.CompletedAt = req.CompletedAt.UTC().Truncate(time.Second)
+	}
+	err := db.QueryRow(`SELECT exercise_id, rest_sec FROM workout_exercises WHERE id = ? AND workout_id = ?`,
+		req.WorkoutExerciseID, workoutID).Scan(&set.ExerciseID, &set.RestSec)
+	if err == sql.ErrNoRows {
+		return nil, errExerciseNotInWorkout
+	}
+	if err != nil {
+		return nil, err
+	}
+	if set.RestSec <= 0 {
+		set.RestSec = DefaultRestSec
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := tx.lockUser(userID); err != nil {
+		return nil, err
+	}
+
+	completed := formatTime(set.CompletedAt)
+	err = tx.QueryRow(`SELECT id FROM workout_sessions WHERE workout_id = ? AND user_id = ?
+		ORDER BY CASE WHEN status = 'active' THEN 0 ELSE 1 END, id DESC LIMIT 1`, workoutID, userID).Scan(&set.SessionID)
+	if err == sql.ErrNoRows {
+		set.SessionID, err = insertID(tx, `INSERT INTO workout_sessions (workout_id, user_id, status, started_at, finished_at) VALUES (?, ?, 'finished', ?, ?)`,
+			workoutID, userID, completed, completed)
+		if err == nil {
+			err = db.audit(tx, userID, "create", "session", set.SessionID, nil, sessionSnapshot(workoutID, "finished", completed, completed))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) + 1 FROM session_sets WHERE session_id = ? AND workout_exercise_id = ?`,
+		set.SessionID, set.WorkoutExerciseID).Scan(&set.SetNumber); err != nil {
+		return nil, err
+	}
+	best, err := bestWeight(tx, userID, set.ExerciseID)
+	if err != nil {
+		return nil, err
+	}
+	set.IsPR = req.WeightKg > 0 && req.WeightKg > best
+	set.ID, err = insertID(tx, `INSERT INTO session_sets (session_id, workout_exercise_id, exercise_id, set_number, reps, weight_kg, duration_sec, rest_sec, is_pr, completed_at, client_id, updated_at)
+		VALUES (?,?,?,?,?,?,?,?,?,?,?,?)`,
+		set.SessionID, set.WorkoutExerciseID, set.ExerciseID, set.SetNumber, set.Reps, set.WeightKg, set.DurationSec, set.RestSec, set.IsPR,
+		completed, nullString(clientID), formatTime(now()))
+	if isUniqueViolation(err) {
+		return nil, ErrClientIDTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, userID, "create", "set", set.ID, nil, set); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// ClaimSyncResult reserves key for a mutation the user is about to apply,
+// so that a batch resent while the first is still running does not apply
+// it twice. It returns nil when the caller should go ahead, apply the
+// mutation and then SaveSyncResult or ReleaseSyncResult; the stored result
+// when the mutation was already applied; or ErrSyncKeyInUse while another
+// attempt is applying it. A claim left unfinished for longer than
+// IdempotencyClaimTimeout is taken to have died with its process.
+func (db *DB) ClaimSyncResult(userID int64, key string) ([]byte, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var result, createdStr string
+	err = tx.QueryRow(`SELECT result, created_at FROM sync_results WHERE user_id = ? AND idempotency_key = ?`,
+		userID, key).Scan(&result, &createdStr)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec(`INSERT INTO sync_results (user_id, idempotency_key, result, created_at) VALUES (?, ?, '', ?)`,
+			userID, key, formatTime(now()))
+		if isUniqueViolation(err) {
+			return nil, ErrSyncKeyInUse
+		}
+	case err != nil:
+		return nil, err
+	case result != "":
+		return []byte(result), nil
+	default:
+		created, err := parseTime(createdStr)
+		if err != nil {
+			return nil, err
+		}
+		if !created.Before(now().Add(-IdempotencyClaimTimeout)) {
+			return nil, ErrSyncKeyInUse
+		}
+		_, err = tx.Exec(`UPDATE sync_results SET created_at = ? WHERE user_id = ? AND idempotency_key = ?`,
+			formatTime(now()), userID, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, tx.Commit()
+}
+
+// GetSyncResult returns the result stored for a mutation the user already
+// sent with the idempotency key, or nil, also while it is being applied.
+func (db *DB) GetSyncResult(userID int64, key string) ([]byte, error) {
+	var result string
+	err := db.QueryRow(`SELECT result FROM sync_results WHERE user_id = ? AND idempotency_key = ?`, userID, key).Scan(&result)
+	if err == sql.ErrNoRows || result == "" {
+		return nil, nil
+	}
+	return []byte(result), err
+}
+
+// SaveSyncResult stores the result of a mutation under its idempotency key.
+// A key that already has a result keeps its first one.
+func (db *DB) SaveSyncResult(userID int64, key string, result []byte) error {
+	_, err := db.Exec(`INSERT INTO sync_results (user_id, idempotency_key, result, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET result = excluded.result WHERE sync_results.result = ''`,
+		userID, key, string(result), formatTime(now()))
+	return err
+}
+
+// ReleaseSyncResult gives up the claim on key of a mutation that was not
+// applied, so that a retry applies it.
+func (db *DB) ReleaseSyncResult(userID int64, key string) error {
+	_, err := db.Exec(`DELETE FROM sync_results WHERE user_id = ? AND idempotency_key = ? AND result = ''`, userID, key)
+	return err
+}
+
+// PurgeTombstones deletes the tombstones left before the given time and
+// returns how many it deleted. A client whose cursor is older than that
+// misses those deletes and must sync again from scratch.
+func (db *DB) PurgeTombstones(before time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM tombstones WHERE deleted_at < ?`, formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,538 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// ---- Teams ----
+
+const teamQuery = `SELECT t.id, t.name, t.owner_id, t.join_code, t.created_at,
+	(SELECT COUNT(*) FROM team_members c WHERE c.team_id = t.id) FROM teams t`
+
+func scanTeam(row rowScanner) (*models.Team, error) {
+	t := &models.Team{}
+	var created string
+	if err := row.Scan(&t.ID, &t.Name, &t.OwnerID, &t.JoinCode, &created, &t.MemberCount); err != nil {
+		return nil, err
+	}
+	var err error
+	t.CreatedAt, err = parseTime(created)
+	return t, err
+}
+
+// teamOwner returns the owner of a team userID belongs to. Teams the user
+// is not in do not exist for them: ErrTeamNotFound.
+func teamOwner(q queryRower, teamID, userID int64) (int64, error) {
+	var owner int64
+	err := q.QueryRow(`SELECT t.owner_id FROM teams t JOIN team_members m ON m.team_id = t.id AND m.user_id = ?
+		WHERE t.id = ?`, userID, teamID).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return 0, ErrTeamNotFound
+	}
+	return owner, err
+}
+
+// CreateTeam creates a team owned by userID, its first member.
+func (db *DB) CreateTeam(userID int64, name string) (*models.Team, error) {
+	code, err := newToken(9)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created := formatTime(now())
+	id, err := insertID(tx, `INSERT INTO teams (name, owner_id, join_code, created_at) VALUES (?, ?, ?, ?)`, name, userID, code, created)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`INSERT INTO team_members (team_id, user_id, joined_at) VALUES (?, ?, ?)`, id, userID, created); err != nil {
+		return nil, err
+	}
+	if err := db.audit(tx, userID, "create", "team", id, nil, map[string]string{"name": name}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetTeam(id, userID)
+}
+
+// ListTeams returns the teams userID belongs to, by name.
+func (db *DB) ListTeams(userID int64) ([]models.Team, error) {
+	rows, err := db.Query(teamQuery+` JOIN team_members m ON m.team_id = t.id WHERE m.user_id = ? ORDER BY t.name, t.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Team{}
+	for rows.Next() {
+		t, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
+
+// GetTeam returns a team with its members, or nil if userID is not one of
+// them.
+func (db *DB) GetTeam(id, userID int64) (*models.Team, error) {
+	if _, err := teamOwner(db, id, userID); err == ErrTeamNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	t, err := scanTeam(db.QueryRow(teamQuery+` WHERE t.id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT u.id, u.name, m.joined_at FROM team_members m JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = ? ORDER BY u.name, u.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	t.Members = []models.TeamMember{}
+	for rows.Next() {
+		var m models.TeamMember
+		var joined string
+		if err := rows.Scan(&m.UserID, &m.Name, &joined); err != nil {
+			return nil, err
+		}
+		if m.JoinedAt, err = parseTime(joined); err != nil {
+			return nil, err
+		}
+		t.Members = append(t.Members, m)
+	}
+	return t, rows.Err()
+}
+
+// JoinTeam adds userID to the team with a join code. Joining a team twice
+// changes nothing.
+func (db *DB) JoinTeam(userID int64, code string) (*models.Team, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(`SELECT id FROM teams WHERE join_code = ?`, code).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrTeamNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(`INSERT INTO team_members (team_id, user_id, joined_at) VALUES (?, ?, ?)
+		ON CONFLICT (team_id, user_id) DO NOTHING`, id, userID, formatTime(now()))
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		if err := db.audit(tx, userID, "create", "team_member", id, nil, map[string]int64{"team_id": id}); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetTeam(id, userID)
+}
+
+// RemoveTeamMember takes memberID off a team on behalf of userID. Members
+// may leave; only the owner removes others, and the owner cannot leave.
+func (db *DB) RemoveTeamMember(teamID, userID, memberID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	owner, err := teamOwner(tx, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if memberID == owner {
+		return ErrTeamOwnerLeaving
+	}
+	if memberID != userID && userID != owner {
+		return ErrTeamOwnerRequired
+	}
+	res, err := tx.Exec(`DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, memberID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	if err := db.audit(tx, memberID, "delete", "team_member", teamID, map[string]int64{"team_id": teamID}, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTeam deletes a team, with its challenges, on behalf of its owner.
+func (db *DB) DeleteTeam(id, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	owner, err := teamOwner(tx, id, userID)
+	if err != nil {
+		return err
+	}
+	if owner != userID {
+		return ErrTeamOwnerRequired
+	}
+	var name string
+	if err := tx.QueryRow(`SELECT name FROM teams WHERE id = ?`, id).Scan(&name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM teams WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := db.audit(tx, userID, "delete", "team", id, map[string]string{"name": name}, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LeaderboardMetrics are the metrics a team leaderboard ranks members by.
+var LeaderboardMetrics = []string{"volume", "reps", "distance", "sessions", "streak", "prs"}
+
+// scoreValues are the aggregates over workout_exercises we of the metrics
+// scored per exercise.
+var scoreValues = map[string]string{
+	"volume":   `SUM(we.sets * we.reps * we.weight_kg)`,
+	"reps":     `SUM(we.sets * we.reps)`,
+	"distance": `SUM(we.distance_m)`,
+	"sessions": `COUNT(DISTINCT w.id)`,
+}
+
+// scores builds the CTEs, ending in scores(user_id, value), that score the
+// members of a team on a metric other than the streak. Completed workouts
+// count from from until to, and only their exercise exerciseID if it is
+// set.
+func (db *DB) scores(teamID int64, metric string, exerciseID *int64, from, to *time.Time) (string, []interface{}) {
+	member := ` AND w.user_id IN (SELECT user_id FROM team_members WHERE team_id = ?)`
+	args := []interface{}{teamID}
+	window := func(timeColumn, exerciseColumn string) string {
+		var cond string
+		if from != nil {
+			cond += ` AND ` + timeColumn + ` >= ?`
+			args = append(args, formatTime(*from))
+		}
+		if to != nil {
+			cond += ` AND ` + timeColumn + ` < ?`
+			args = append(args, formatTime(*to))
+		}
+		if exerciseID != nil {
+			cond += ` AND ` + exerciseColumn + ` = ?`
+			args = append(args, *exerciseID)
+		}
+		return cond
+	}
+
+	switch metric {
+	case "prs":
+		return `scores AS (
+			SELECT w.user_id, COUNT(*) AS value FROM session_sets ss
+			JOIN workout_sessions s ON s.id = ss.session_id JOIN workouts w ON w.id = s.workout_id
+			WHERE ss.is_pr` + notTrashed + member + window("ss.completed_at", "ss.exercise_id") + `
+			GROUP BY w.user_id
+		)`, args
+	}
+	return `scores AS (
+		SELECT w.user_id, ` + scoreValues[metric] + ` AS value FROM workouts w
+		LEFT JOIN workout_exercises we ON we.workout_id = w.id
+		WHERE w.status = 'completed'` + notTrashed + member + window("w.completed_at", "we.exercise_id") + `
+		GROUP BY w.user_id
+	)`, args
+}
+
+// ranked ranks every member of a team by score, highest first. Ties share
+// a rank, and the next rank skips past them.
+func (db *DB) ranked(teamID int64, metric string, exerciseID *int64, from, to *time.Time, row func(rank int, userID int64, name string, value float64)) error {
+	if metric == "streak" {
+		return db.rankedStreaks(teamID, row)
+	}
+	ctes, args := db.scores(teamID, metric, exerciseID, from, to)
+	rows, err := db.Query(`WITH `+ctes+`
+		SELECT u.id, u.name, COALESCE(sc.value, 0), RANK() OVER (ORDER BY COALESCE(sc.value, 0) DESC) AS place
+		FROM team_members m JOIN users u ON u.id = m.user_id LEFT JOIN scores sc ON sc.user_id = m.user_id
+		WHERE m.team_id = ? ORDER BY place, u.name, u.id`, append(args, teamID)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			userID int64
+			name   string
+			value  float64
+			rank   int
+		)
+		if err := rows.Scan(&userID, &name, &value, &rank); err != nil {
+			return err
+		}
+		row(rank, userID, name, value)
+	}
+	return rows.Err()
+}
+
+// rankedStreaks ranks every member of a team by their current streak,
+// measured in their own time zone and with their own rest days, so that it
+// matches the streak on their achievements. The completion times of all
+// members come in one query, grouped by member.
+func (db *DB) rankedStreaks(teamID int64, row func(rank int, userID int64, name string, value float64)) error {
+	type member struct {
+		id     int64
+		name   string
+		streak int
+	}
+	rows, err := db.Query(`SELECT u.id, u.name, u.timezone, u.rest_days, w.completed_at
+		FROM team_members m JOIN users u ON u.id = m.user_id
+		LEFT JOIN workouts w ON w.user_id = u.id AND w.status = 'completed' AND w.completed_at IS NOT NULL`+notTrashed+`
+		WHERE m.team_id = ? ORDER BY u.id, w.completed_at`, teamID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var (
+		members   []member
+		user      models.User
+		completed []time.Time
+	)
+	measure := func() {
+		if len(members) > 0 {
+			members[len(members)-1].streak = streakOver(completed, user.Location(), user.RestDays).Current
+		}
+	}
+	for rows.Next() {
+		var m member
+		var tz, at sql.NullString
+		var restDays int
+		if err := rows.Scan(&m.id, &m.name, &tz, &restDays, &at); err != nil {
+			return err
+		}
+		if len(members) == 0 || members[len(members)-1].id != m.id {
+			measure()
+			members = append(members, m)
+			user = models.User{TimeZone: tz.String, RestDays: restDays}
+			completed = completed[:0]
+		}
+		if at.Valid {
+			t, err := parseTime(at.String)
+			if err != nil {
+				return err
+			}
+			completed = append(completed, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	measure()
+
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if a.streak != b.streak {
+			return a.streak > b.streak
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return a.id < b.id
+	})
+	rank := 0
+	for i, m := range members {
+		if i == 0 || m.streak != members[i-1].streak {
+			rank = i + 1
+		}
+		row(rank, m.id, m.name, float64(m.streak))
+	}
+	return nil
+}
+
+// GetLeaderboard ranks the members of a team userID belongs to on one of
+// LeaderboardMetrics. Times bound the period; either may be nil, and the
+// streak ignores them.
+func (db *DB) GetLeaderboard(teamID, userID int64, metric string, from, to *time.Time) ([]models.LeaderboardEntry, error) {
+	if _, err := teamOwner(db, teamID, userID); err != nil {
+		return nil, err
+	}
+	known := false
+	for _, m := range LeaderboardMetrics {
+		known = known || m == metric
+	}
+	if !known {
+		return nil, Invalid("invalid_metric", "unknown leaderboard metric", models.FieldError{
+			Parameter: "metric", Code: "oneof", Message: "must be one of " + strings.Join(LeaderboardMetrics, ", "),
+		})
+	}
+	entries := []models.LeaderboardEntry{}
+	err := db.ranked(teamID, metric, nil, from, to, func(rank int, userID int64, name string, value float64) {
+		entries = append(entries, models.LeaderboardEntry{Rank: rank, UserID: userID, Name: name, Value: value})
+	})
+	return entries, err
+}
+
+const challengeQuery = `SELECT id, team_id, created_by, title, metric, exercise_id, target, starts_at, ends_at, created_at FROM challenges`
+
+func scanChallenge(row rowScanner) (*models.Challenge, error) {
+	ch := &models.Challenge{}
+	var createdBy, exerciseID sql.NullInt64
+	var starts, ends, created string
+	if err := row.Scan(&ch.ID, &ch.TeamID, &createdBy, &ch.Title, &ch.Metric, &exerciseID, &ch.Target, &starts, &ends, &created); err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		ch.CreatedBy = &createdBy.Int64
+	}
+	if exerciseID.Valid {
+		ch.ExerciseID = &exerciseID.Int64
+	}
+	var err error
+	if ch.StartsAt, err = parseTime(starts); err != nil {
+		return nil, err
+	}
+	if ch.EndsAt, err = parseTime(ends); err != nil {
+		return nil, err
+	}
+	ch.CreatedAt, err = parseTime(created)
+	return ch, err
+}
+
+// CreateChallenge sets a team a challenge on behalf of one of its members.
+// A challenge on one exercise must name a built-in one, which every member
+// can log.
+func (db *DB) CreateChallenge(teamID, userID int64, req models.CreateChallengeRequest) (*models.Challenge, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := teamOwner(tx, teamID, userID); err != nil {
+		return nil, err
+	}
+	if req.ExerciseID != nil {
+		var found int
+		err := tx.QueryRow(`SELECT 1 FROM exercises WHERE id = ? AND user_id IS NULL`, *req.ExerciseID).Scan(&found)
+		if err == sql.ErrNoRows {
+			return nil, Invalid("unknown_exercise", "exercise not found", models.FieldError{
+				Pointer: "/exercise_id",
+				Code:    "unknown_exercise",
+				Message: fmt.Sprintf("no built-in exercise with id %d", *req.ExerciseID),
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	id, err := insertID(tx, `INSERT INTO challenges (team_id, created_by, title, metric, exercise_id, target, starts_at, ends_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, teamID, userID, req.Title, req.Metric, req.ExerciseID, req.Target,
+		formatTime(req.StartsAt), formatTime(req.EndsAt), formatTime(now()))
+	if err != nil {
+		return nil, err
+	}
+	after := map[string]interface{}{"team_id": teamID, "title": req.Title, "metric": req.Metric, "target": req.Target}
+	if err := db.audit(tx, userID, "create", "challenge", id, nil, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetChallenge(id, teamID, userID)
+}
+
+// ListChallenges returns a team's challenges, latest ending first, without
+// standings.
+func (db *DB) ListChallenges(teamID, userID int64) ([]models.Challenge, error) {
+	if _, err := teamOwner(db, teamID, userID); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(challengeQuery+` WHERE team_id = ? ORDER BY ends_at DESC, id DESC`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Challenge{}
+	for rows.Next() {
+		ch, err := scanChallenge(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *ch)
+	}
+	return list, rows.Err()
+}
+
+// GetChallenge returns a challenge with every member's standing, computed
+// from the workouts they completed during it. It returns nil if the
+// challenge is not the team's.
+func (db *DB) GetChallenge(id, teamID, userID int64) (*models.Challenge, error) {
+	if _, err := teamOwner(db, teamID, userID); err != nil {
+		return nil, err
+	}
+	ch, err := scanChallenge(db.QueryRow(challengeQuery+` WHERE id = ? AND team_id = ?`, id, teamID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ch.Standings = []models.ChallengeStanding{}
+	err = db.ranked(teamID, ch.Metric, ch.ExerciseID, &ch.StartsAt, &ch.EndsAt, func(rank int, userID int64, name string, value float64) {
+		ch.Standings = append(ch.Standings, models.ChallengeStanding{
+			Rank: rank, UserID: userID, Name: name, Progress: value, Completed: value >= ch.Target,
+		})
+	})
+	return ch, err
+}
+
+// DeleteChallenge deletes a challenge on behalf of its creator or the
+// team's owner.
+func (db *DB) DeleteChallenge(id, teamID, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	owner, err := teamOwner(tx, teamID, userID)
+	if err != nil {
+		return err
+	}
+	ch, err := scanChallenge(tx.QueryRow(challengeQuery+` WHERE id = ? AND team_id = ?`, id, teamID))
+	if err == sql.ErrNoRows {
+		return ErrChallengeNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if userID != owner && (ch.CreatedBy == nil || *ch.CreatedBy != userID) {
+		return ErrTeamOwnerRequired
+	}
+	if _, err := tx.Exec(`DELETE FROM challenges WHERE id = ?`, id); err != nil {
+		return err
+	}
+	before := map[string]interface{}{"team_id": teamID, "title": ch.Title, "metric": ch.Metric, "target": ch.Target}
+	if err := db.audit(tx, userID, "delete", "challenge", id, before, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
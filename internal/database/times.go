@@ -0,0 +1,94 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Every time is stored in UTC as fixed-width RFC 3339 text, so SQLite can
+// compare and sort them as strings. PostgreSQL stores timestamptz and
+// accepts the same text.
+const timeLayout = "2006-01-02T15:04:05Z"
+
+// sqliteTimeExpr converts any value SQLite understands as a time into
+// timeLayout, for the migration that normalises older rows.
+func sqliteTimeExpr(col string) string {
+	return `strftime('%Y-%m-%dT%H:%M:%SZ', ` + col + `)`
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// formatTimePtr returns nil for a nil time, for nullable columns.
+func formatTimePtr(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+// now is the current time at the precision times are stored with.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// parseTime reads a stored time. Drivers hand back either the stored text
+// or, for DATETIME and timestamptz columns, a time.Time that database/sql
+// renders as RFC 3339 with nanoseconds; both parse here.
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing stored time %q: %w", s, err)
+	}
+	return t.UTC(), nil
+}
+
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := parseTime(s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// normalizeTimes rewrites times stored before every write went through
+// formatTime: CURRENT_TIMESTAMP defaults ("2006-01-02 15:04:05") and RFC
+// 3339 values with an offset. Every build that records a schema version
+// stores times this way, so it only runs on databases without one; running
+// it again changes nothing.
+func (db *DB) normalizeTimes() error {
+	version, err := db.migratedVersion()
+	if err != nil {
+		return err
+	}
+	if version > 0 {
+		return nil
+	}
+	columns := map[string][]string{
+		"users":            {"created_at"},
+		"workouts":         {"scheduled_at", "completed_at", "created_at", "updated_at"},
+		"workout_sessions": {"rest_until", "started_at", "finished_at"},
+		"session_sets":     {"completed_at"},
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for table, cols := range columns {
+		for _, col := range cols {
+			expr := sqliteTimeExpr(col)
+			_, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = %s WHERE %s IS NOT NULL AND %s IS NOT NULL AND %s != %s`,
+				table, col, expr, col, expr, col, expr))
+			if err != nil {
+				return fmt.Errorf("normalising %s.%s: %w", table, col, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTimes(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "old.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows as older versions wrote them: CURRENT_TIMESTAMP defaults and
+	// RFC 3339 with the client's offset.
+	if _, err := db.Exec(`INSERT INTO users (name, email, password_hash, created_at) VALUES ('Old', 'old@example.com', 'x', '2024-01-02 03:04:05')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO workouts (user_id, title, scheduled_at, completed_at, created_at, updated_at)
+		VALUES (1, 'Old', '2024-01-02T08:00:00+02:00', NULL, '2024-01-02 03:04:05', '2024-01-02 03:04:05')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`DELETE FROM schema_version`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var scheduled, created string
+	var completed *string
+	if err := db.QueryRow(`SELECT CAST(scheduled_at AS TEXT), CAST(completed_at AS TEXT), CAST(created_at AS TEXT) FROM workouts WHERE id = 1`).
+		Scan(&scheduled, &completed, &created); err != nil {
+		t.Fatal(err)
+	}
+	if scheduled != "2024-01-02T06:00:00Z" || created != "2024-01-02T03:04:05Z" || completed != nil {
+		t.Errorf("normalised workout times = %q, %v, %q", scheduled, completed, created)
+	}
+
+	u, err := db.GetUserByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !u.CreatedAt.Equal(want) {
+		t.Errorf("user created_at = %v, want %v", u.CreatedAt, want)
+	}
+
+	// Once schema_version records a migration, the rewrite does not run.
+	if _, err := db.Exec(`UPDATE workouts SET created_at = '2024-01-02 03:04:05' WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT CAST(created_at AS TEXT) FROM workouts WHERE id = 1`).Scan(&created); err != nil || created != "2024-01-02 03:04:05" {
+		t.Errorf("created_at after a recorded migration = %q, %v", created, err)
+	}
+}
+
+func TestParseTimeRejectsGarbage(t *testing.T) {
+	if _, err := parseTime("yesterday"); err == nil {
+		t.Error("parseTime accepted an invalid time")
+	}
+	got, err := parseTime("2024-06-01T10:00:00.5+02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Location() != time.UTC || got.Hour() != 8 {
+		t.Errorf("parseTime = %v, want 08:00 UTC", got)
+	}
+}
@@ -0,0 +1,954 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"workout-tracker/internal/metrics"
+	"workout-tracker/internal/models"
+)
+
+func (db *DB) CreateWorkout(userID int64, req models.CreateWorkoutRequest) (*models.Workout, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	wid, err := db.insertWorkout(tx, userID, req, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	metrics.WorkoutsCreated.Inc(sourceLabel(req.Source, "app"))
+	if req.Status == "completed" {
+		metrics.WorkoutsCompleted.Inc()
+	}
+	return db.GetWorkoutByID(wid, userID)
+}
+
+// insertWorkout adds a workout and its exercises for the user, pending
+// unless req says otherwise. assignedBy is the coach who scheduled it, or
+// zero.
+func (db *DB) insertWorkout(tx *Tx, userID int64, req models.CreateWorkoutRequest, assignedBy int64) (int64, error) {
+	var coach interface{}
+	if assignedBy != 0 {
+		coach = assignedBy
+	}
+	status := req.Status
+	if status == "" {
+		status = "pending"
+	}
+	t := now()
+	var completed *time.Time
+	if status == "completed" {
+		completed = &t
+	}
+	created := formatTime(t)
+	wid, err := insertID(tx, `INSERT INTO workouts (user_id, title, description, comment, scheduled_at, status, visibility, completed_at, source, external_id, client_id, assigned_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, req.Title, req.Description, req.Comment, formatTimePtr(req.ScheduledAt), status, visibilityOrDefault(req.Visibility), formatTimePtr(completed),
+		req.Source, nullString(req.ExternalID), nullString(req.ClientID), coach, created, created)
+	if isUniqueViolation(err) && req.ClientID != "" {
+		return 0, ErrClientIDTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range req.Exercises {
+		if err := insertWorkoutExercise(tx, wid, i, e); err != nil {
+			return 0, err
+		}
+	}
+	if err := db.auditWorkout(tx, userID, "create", wid, nil); err != nil {
+		return 0, err
+	}
+	if status == "completed" {
+		if err := db.awardAchievements(tx, userID, wid); err != nil {
+			return 0, err
+		}
+	}
+	return wid, nil
+}
+
+// sourceLabel names where a workout came from in the metrics: its import
+// source, or def for workouts made in the app or imported as activities.
+func sourceLabel(source, def string) string {
+	if source == "" {
+		return def
+	}
+	return source
+}
+
+// insertWorkoutExercise adds an exercise to a workout at position i.
+func insertWorkoutExercise(tx *Tx, workoutID int64, i int, e models.WorkoutExerciseRequest) error {
+	_, err := tx.Exec(`INSERT INTO workout_exercises (workout_id, position, exercise_id, sets, reps, weight_kg, duration_sec, rest_sec, notes,
+		distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, calories) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?)`,
+		workoutID, i, e.ExerciseID, e.Sets, e.Reps, e.WeightKg, e.DurationSec, e.RestSec, e.Notes,
+		e.DistanceM, e.ElevationGainM, e.AvgHeartRate, e.MaxHeartRate, e.Calories)
+	return exerciseError(err, i, e)
+}
+
+// exerciseError reports a write of the i-th exercise that names an
+// exercise that does not exist as a validation error.
+func exerciseError(err error, i int, e models.WorkoutExerciseRequest) error {
+	if isForeignKeyViolation(err) {
+		return Invalid("unknown_exercise", "exercise not found", models.FieldError{
+			Pointer: fmt.Sprintf("/exercises/%d/exercise_id", i),
+			Code:    "unknown_exercise",
+			Message: fmt.Sprintf("no exercise with id %d", e.ExerciseID),
+		})
+	}
+	return err
+}
+
+// CreateCompletedWorkout stores a workout that has already been done, such
+// as an imported activity.
+func (db *DB) CreateCompletedWorkout(userID int64, req models.CreateWorkoutRequest, completedAt time.Time) (*models.Workout, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created := formatTime(now())
+	wid, err := insertID(tx, `INSERT INTO workouts (user_id, title, description, scheduled_at, status, visibility, completed_at, source, external_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 'completed', ?, ?, ?, ?, ?, ?)`,
+		userID, req.Title, req.Description, formatTimePtr(req.ScheduledAt), visibilityOrDefault(req.Visibility), formatTime(completedAt), req.Source, nullString(req.ExternalID), created, created)
+	if isUniqueViolation(err) && req.ExternalID != "" {
+		return nil, ErrAlreadyImported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range req.Exercises {
+		if err := insertWorkoutExercise(tx, wid, i, e); err != nil {
+			return nil, err
+		}
+	}
+	if err := db.auditWorkout(tx, userID, "create", wid, nil); err != nil {
+		return nil, err
+	}
+	if err := db.awardAchievements(tx, userID, wid); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	metrics.WorkoutsCreated.Inc(sourceLabel(req.Source, "activity"))
+	metrics.WorkoutsCompleted.Inc()
+	return db.GetWorkoutByID(wid, userID)
+}
+
+// ImportedExternalIDs returns the external IDs already imported by a user
+// from a source. Workouts in the trash count, so deleting an import and
+// importing the file again does not bring it back.
+func (db *DB) ImportedExternalIDs(userID int64, source string) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT external_id FROM workouts WHERE user_id = ? AND source = ? AND external_id IS NOT NULL`, userID, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// visibilityOrDefault is the visibility a workout is saved with: new
+// workouts are private unless asked otherwise.
+func visibilityOrDefault(v string) string {
+	if v == "" {
+		return "private"
+	}
+	return v
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+const workoutColumns = `w.id, w.user_id, w.title, w.description, w.comment, w.status, w.visibility, w.scheduled_at, w.completed_at, w.created_at, w.updated_at, w.version, w.client_id, w.assigned_by, w.deleted_at`
+
+// notTrashed leaves out workouts in the trash. Every query that lists,
+// counts or analyses workouts aliased as w includes it.
+const notTrashed = ` AND w.deleted_at IS NULL`
+
+// scanWorkout reads the columns in workoutColumns followed by any extra
+// destinations.
+func scanWorkout(row rowScanner, extra ...interface{}) (*models.Workout, error) {
+	w := &models.Workout{}
+	var scheduledStr, completedStr, clientID, deletedStr sql.NullString
+	var createdStr, updatedStr string
+	var assignedBy sql.NullInt64
+	dest := append([]interface{}{&w.ID, &w.UserID, &w.Title, &w.Description, &w.Comment, &w.Status, &w.Visibility,
+		&scheduledStr, &completedStr, &createdStr, &updatedStr, &w.Version, &clientID, &assignedBy, &deletedStr}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	w.ClientID = clientID.String
+	if assignedBy.Valid {
+		w.AssignedBy = &assignedBy.Int64
+	}
+	var err error
+	if w.CreatedAt, err = parseTime(createdStr); err != nil {
+		return nil, err
+	}
+	if w.UpdatedAt, err = parseTime(updatedStr); err != nil {
+		return nil, err
+	}
+	if w.ScheduledAt, err = parseNullTime(scheduledStr); err != nil {
+		return nil, err
+	}
+	if w.CompletedAt, err = parseNullTime(completedStr); err != nil {
+		return nil, err
+	}
+	if w.DeletedAt, err = parseNullTime(deletedStr); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (db *DB) GetWorkoutByID(id, userID int64) (*models.Workout, error) {
+	return db.getWorkout(ownedBy(userID), id)
+}
+
+func (db *DB) getWorkout(a access, id int64) (*models.Workout, error) {
+	where, args := a.where()
+	w, err := scanWorkout(db.QueryRow(`SELECT `+workoutColumns+` FROM workouts w WHERE w.id = ? AND`+where+notTrashed, append([]interface{}{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if w.Exercises, err = db.getWorkoutExercises(id); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (db *DB) getWorkoutExercises(workoutID int64) ([]models.WorkoutExercise, error) {
+	byWorkout, err := db.loadWorkoutExercises([]int64{workoutID})
+	if err != nil {
+		return nil, err
+	}
+	return byWorkout[workoutID], nil
+}
+
+// exerciseBatchSize keeps IN lists well under SQLite's limit on bound
+// parameters.
+const exerciseBatchSize = 500
+
+// loadWorkoutExercises fetches the exercises of several workouts with one
+// query per batch of IDs, keyed by workout ID.
+func (db *DB) loadWorkoutExercises(workoutIDs []int64) (map[int64][]models.WorkoutExercise, error) {
+	byWorkout := make(map[int64][]models.WorkoutExercise, len(workoutIDs))
+	for start := 0; start < len(workoutIDs); start += exerciseBatchSize {
+		end := start + exerciseBatchSize
+		if end > len(workoutIDs) {
+			end = len(workoutIDs)
+		}
+		batch := workoutIDs[start:end]
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		rows, err := db.Query(`
+		SELECT we.id, we.workout_id, we.exercise_id, we.sets, we.reps, we.weight_kg, we.duration_sec, we.rest_sec, we.notes,
+		       we.distance_m, we.elevation_gain_m, we.avg_heart_rate, we.max_heart_rate, we.calories,
+		       e.id, e.name, e.description, e.category, e.muscle_group
+		FROM workout_exercises we
+		JOIN exercises e ON e.id = we.exercise_id
+		WHERE we.workout_id IN (`+placeholders(len(batch))+`)
+		ORDER BY we.workout_id, we.position, we.id`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var we models.WorkoutExercise
+			e := &models.Exercise{}
+			if err := rows.Scan(&we.ID, &we.WorkoutID, &we.ExerciseID, &we.Sets, &we.Reps, &we.WeightKg, &we.DurationSec, &we.RestSec, &we.Notes,
+				&we.DistanceM, &we.ElevationGainM, &we.AvgHeartRate, &we.MaxHeartRate, &we.Calories,
+				&e.ID, &e.Name, &e.Description, &e.Category, &e.MuscleGroup); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			we.Exercise = e
+			we.DeriveCardio()
+			byWorkout[we.WorkoutID] = append(byWorkout[we.WorkoutID], we)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return byWorkout, nil
+}
+
+func placeholders(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
+
+// ListWorkouts returns one page of the user's workouts matching the
+// filter, with the total number of matches. Pages are keyset-paginated on
+// the sort column and ID, so concurrent inserts never shift a page. A zero
+// limit returns every match.
+func (db *DB) ListWorkouts(userID int64, f models.WorkoutFilter) (*models.WorkoutPage, error) {
+	return db.listWorkouts(ownedBy(userID), f)
+}
+
+func (db *DB) listWorkouts(a access, f models.WorkoutFilter) (*models.WorkoutPage, error) {
+	sortKey, desc := strings.TrimPrefix(f.Sort, "-"), strings.HasPrefix(f.Sort, "-")
+	keyExpr, ok := sortColumns[sortKey]
+	if !ok {
+		keyExpr = sortColumns["date"]
+	}
+
+	where, args := a.where()
+	where = ` WHERE` + where + notTrashed
+	if f.Status != "" {
+		where += ` AND w.status = ?`
+		args = append(args, f.Status)
+	}
+	cond, condArgs := workoutFilterSQL(f)
+	where += cond
+	args = append(args, condArgs...)
+
+	page := &models.WorkoutPage{Workouts: []models.Workout{}}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM workouts w`+where, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if f.Cursor != "" {
+		cur, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		where += fmt.Sprintf(` AND (%s %s ? OR (%s = ? AND w.id %s ?))`, keyExpr, op, keyExpr, op)
+		args = append(args, cur.Key, cur.Key, cur.ID)
+	}
+
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	query := `SELECT ` + workoutColumns + `, ` + keyExpr + ` FROM workouts w` + where + fmt.Sprintf(` ORDER BY %s %s, w.id %s`, keyExpr, dir, dir)
+	limit := f.Limit
+	if limit > 0 {
+		// One extra row tells whether there is a next page.
+		query += ` LIMIT ?`
+		args = append(args, limit+1)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		w, err := scanWorkout(rows, &key)
+		if err != nil {
+			return nil, err
+		}
+		page.Workouts = append(page.Workouts, *w)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if limit > 0 && len(page.Workouts) > limit {
+		page.Workouts = page.Workouts[:limit]
+		last := page.Workouts[limit-1]
+		page.NextCursor = encodeCursor(pageCursor{Key: keys[limit-1], ID: last.ID})
+	}
+
+	// Exercises for the whole page come from a single query, run once the
+	// workout rows are closed so that the connection is free again.
+	ids := make([]int64, len(page.Workouts))
+	for i, w := range page.Workouts {
+		ids[i] = w.ID
+	}
+	byWorkout, err := db.loadWorkoutExercises(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Workouts {
+		page.Workouts[i].Exercises = byWorkout[page.Workouts[i].ID]
+	}
+	return page, nil
+}
+
+// StreamWorkouts calls fn for each of the user's workouts, with exercises
+// and the sets logged against them, in date order. Rows are read with a single query and handed over one
+// workout at a time so that large histories are never held in memory. fn
+// must not use the database: the query is still open while it runs.
+func (db *DB) StreamWorkouts(userID int64, from, to *time.Time, fn func(*models.Workout) error) error {
+	query := `
+		SELECT ` + workoutColumns + `,
+		       we.id, we.exercise_id, we.sets, we.reps, we.weight_kg, we.duration_sec, we.rest_sec, we.notes,
+		       we.distance_m, we.elevation_gain_m, we.avg_heart_rate, we.max_heart_rate, we.calories,
+		       e.name, e.category, e.muscle_group,
+		       ss.id, ss.session_id, ss.set_number, ss.reps, ss.weight_kg, ss.duration_sec, ss.rest_sec, ss.is_pr, ss.completed_at
+		FROM workouts w
+		LEFT JOIN workout_exercises we ON we.workout_id = w.id
+		LEFT JOIN exercises e ON e.id = we.exercise_id
+		LEFT JOIN session_sets ss ON ss.workout_exercise_id = we.id
+		WHERE w.user_id = ?` + notTrashed
+	args := []interface{}{userID}
+	dateExpr := `COALESCE(w.completed_at, w.scheduled_at, w.created_at)`
+	if from != nil {
+		query += ` AND ` + dateExpr + ` >= ?`
+		args = append(args, formatTime(*from))
+	}
+	if to != nil {
+		query += ` AND ` + dateExpr + ` < ?`
+		args = append(args, formatTime(to.AddDate(0, 0, 1)))
+	}
+	query += ` ORDER BY ` + dateExpr + `, w.id, we.position, we.id, ss.completed_at, ss.id`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var cur *models.Workout
+	for rows.Next() {
+		var weID, exID, sets, reps, dur, rest, avgHR, maxHR, cal sql.NullInt64
+		var weight, dist, gain sql.NullFloat64
+		var notes, exName, exCat, exMuscle sql.NullString
+		var setID, sessionID, setNumber, setReps, setDur, setRest sql.NullInt64
+		var setWeight sql.NullFloat64
+		var setPR sql.NullBool
+		var setCompleted sql.NullString
+		w, err := scanWorkout(rows, &weID, &exID, &sets, &reps, &weight, &dur, &rest, &notes, &dist, &gain, &avgHR, &maxHR, &cal,
+			&exName, &exCat, &exMuscle,
+			&setID, &sessionID, &setNumber, &setReps, &setWeight, &setDur, &setRest, &setPR, &setCompleted)
+		if err != nil {
+			return err
+		}
+
+		if cur == nil || cur.ID != w.ID {
+			if cur != nil {
+				if err := fn(cur); err != nil {
+					return err
+				}
+			}
+			cur = w
+		}
+		if !weID.Valid {
+			continue
+		}
+		// An entry spans one row per logged set.
+		if n := len(cur.Exercises); n > 0 && cur.Exercises[n-1].ID == weID.Int64 {
+			if err := appendLoggedSet(&cur.Exercises[n-1], setID, sessionID, setNumber, setReps, setWeight, setDur, setRest, setPR, setCompleted); err != nil {
+				return err
+			}
+			continue
+		}
+		we := models.WorkoutExercise{
+			ID:             weID.Int64,
+			WorkoutID:      cur.ID,
+			ExerciseID:     exID.Int64,
+			Sets:           int(sets.Int64),
+			Reps:           int(reps.Int64),
+			WeightKg:       weight.Float64,
+			DurationSec:    int(dur.Int64),
+			RestSec:        int(rest.Int64),
+			Notes:          notes.String,
+			DistanceM:      dist.Float64,
+			ElevationGainM: gain.Float64,
+			AvgHeartRate:   int(avgHR.Int64),
+			MaxHeartRate:   int(maxHR.Int64),
+			Calories:       int(cal.Int64),
+			Exercise:       &models.Exercise{ID: exID.Int64, Name: exName.String, Category: exCat.String, MuscleGroup: exMuscle.String},
+		}
+		we.DeriveCardio()
+		if err := appendLoggedSet(&we, setID, sessionID, setNumber, setReps, setWeight, setDur, setRest, setPR, setCompleted); err != nil {
+			return err
+		}
+		cur.Exercises = append(cur.Exercises, we)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if cur != nil {
+		return fn(cur)
+	}
+	return nil
+}
+
+// appendLoggedSet adds the set read by StreamWorkouts to its entry, unless
+// the entry has no logged sets and the columns are NULL.
+func appendLoggedSet(we *models.WorkoutExercise, id, sessionID, number, reps sql.NullInt64, weight sql.NullFloat64,
+	dur, rest sql.NullInt64, isPR sql.NullBool, completed sql.NullString) error {
+	if !id.Valid {
+		return nil
+	}
+	set := models.SessionSet{
+		ID:                id.Int64,
+		SessionID:         sessionID.Int64,
+		WorkoutExerciseID: we.ID,
+		ExerciseID:        we.ExerciseID,
+		SetNumber:         int(number.Int64),
+		Reps:              int(reps.Int64),
+		WeightKg:          weight.Float64,
+		DurationSec:       int(dur.Int64),
+		RestSec:           int(rest.Int64),
+		IsPR:              isPR.Bool,
+	}
+	if completed.Valid {
+		t, err := parseTime(completed.String)
+		if err != nil {
+			return err
+		}
+		set.CompletedAt = t
+	}
+	we.LoggedSets = append(we.LoggedSets, set)
+	return nil
+}
+
+// UpdateWorkout sets the fields present in req. Exercises, when given,
+// replace the workout's list. ifVersion, unless zero, is the version the
+// client last saw; the update fails with ErrVersionMismatch if the workout
+// has changed since.
+func (db *DB) UpdateWorkout(id, userID int64, req models.UpdateWorkoutRequest, ifVersion int) (*models.Workout, error) {
+	return db.updateWorkout(ownedBy(userID), id, req, ifVersion)
+}
+
+func (db *DB) updateWorkout(a access, id int64, req models.UpdateWorkoutRequest, ifVersion int) (*models.Workout, error) {
+	existing, err := db.getWorkout(a, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrWorkoutNotFound
+	}
+	if ifVersion != 0 && existing.Version != ifVersion {
+		return nil, ErrVersionMismatch
+	}
+
+	s := existing.State()
+	if req.Title != nil {
+		s.Title = *req.Title
+	}
+	if req.Description != nil {
+		s.Description = *req.Description
+	}
+	if req.Comment != nil {
+		s.Comment = *req.Comment
+	}
+	if req.Status != nil {
+		s.Status = *req.Status
+	}
+	if req.Visibility != nil {
+		s.Visibility = *req.Visibility
+	}
+	if req.ScheduledAt != nil {
+		s.ScheduledAt = req.ScheduledAt
+	}
+	if req.Exercises != nil {
+		s.Exercises = make([]models.WorkoutStateExercise, len(req.Exercises))
+		for i, e := range req.Exercises {
+			s.Exercises[i] = models.WorkoutStateExercise{WorkoutExerciseRequest: e}
+		}
+	}
+	return db.saveWorkout(a, id, existing.Version, s)
+}
+
+// SaveWorkout writes the state of a workout that was read at version, and
+// fails with ErrVersionMismatch if it has changed since. Exercises that
+// keep their id are updated only if they changed, new ones are inserted
+// and missing ones deleted, so sets logged against untouched exercises
+// survive.
+func (db *DB) SaveWorkout(id, userID int64, version int, s models.WorkoutState) (*models.Workout, error) {
+	return db.saveWorkout(ownedBy(userID), id, version, s)
+}
+
+func (db *DB) saveWorkout(a access, id int64, version int, s models.WorkoutState) (*models.Workout, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	userID := a.userID
+	where, args := a.where()
+	var status string
+	var current int
+	var completedStr sql.NullString
+	err = tx.QueryRow(`SELECT w.status, w.completed_at, w.version FROM workouts w WHERE w.id = ? AND`+where+notTrashed, append([]interface{}{id}, args...)...).
+		Scan(&status, &completedStr, &current)
+	if err == sql.ErrNoRows {
+		return nil, ErrWorkoutNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if current != version {
+		return nil, ErrVersionMismatch
+	}
+	before, err := loadWorkoutSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// completed_at is set when a workout becomes completed, kept while it
+	// stays so and cleared when it is reopened.
+	t := now()
+	completed, err := parseNullTime(completedStr)
+	if err != nil {
+		return nil, err
+	}
+	if s.Status != "completed" {
+		completed = nil
+	} else if status != "completed" || completed == nil {
+		completed = &t
+	}
+
+	res, err := tx.Exec(`UPDATE workouts SET title=?, description=?, comment=?, status=?, visibility=?, scheduled_at=?, completed_at=?, updated_at=?, version = version + 1
+		WHERE id=? AND user_id=? AND version=?`,
+		s.Title, s.Description, s.Comment, s.Status, visibilityOrDefault(s.Visibility), formatTimePtr(s.ScheduledAt), formatTimePtr(completed), formatTime(t), id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrVersionMismatch
+	}
+	if err := saveWorkoutExercises(tx, userID, id, s.Exercises); err != nil {
+		return nil, err
+	}
+	if err := db.auditWorkout(tx, userID, "update", id, before); err != nil {
+		return nil, err
+	}
+	if s.Status == "completed" && status != "completed" {
+		if err := db.awardAchievements(tx, userID, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if s.Status == "completed" && status != "completed" {
+		metrics.WorkoutsCompleted.Inc()
+	}
+	return db.GetWorkoutByID(id, userID)
+}
+
+// saveWorkoutExercises makes the exercise rows of a workout match list,
+// touching only the rows that differ. Sets logged against a removed row go
+// with it, and get tombstones.
+func saveWorkoutExercises(tx *Tx, userID, workoutID int64, list []models.WorkoutStateExercise) error {
+	type row struct {
+		position int
+		req      models.WorkoutExerciseRequest
+	}
+	rows, err := tx.Query(`SELECT id, position, exercise_id, sets, reps, weight_kg, duration_sec, rest_sec, notes,
+		distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, calories
+		FROM workout_exercises WHERE workout_id = ?`, workoutID)
+	if err != nil {
+		return err
+	}
+	existing := map[int64]row{}
+	for rows.Next() {
+		var id int64
+		var r row
+		e := &r.req
+		if err := rows.Scan(&id, &r.position, &e.ExerciseID, &e.Sets, &e.Reps, &e.WeightKg, &e.DurationSec, &e.RestSec, &e.Notes,
+			&e.DistanceM, &e.ElevationGainM, &e.AvgHeartRate, &e.MaxHeartRate, &e.Calories); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[id] = r
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	kept := map[int64]bool{}
+	for i, e := range list {
+		if e.ID == 0 {
+			if err := insertWorkoutExercise(tx, workoutID, i, e.WorkoutExerciseRequest); err != nil {
+				return err
+			}
+			continue
+		}
+		cur, ok := existing[e.ID]
+		if !ok || kept[e.ID] {
+			return Invalid("unknown_workout_exercise", "exercise entry not found", models.FieldError{
+				Pointer: fmt.Sprintf("/exercises/%d/id", i),
+				Code:    "unknown_workout_exercise",
+				Message: fmt.Sprintf("no exercise entry with id %d in this workout, or listed twice", e.ID),
+			})
+		}
+		kept[e.ID] = true
+		if cur.position == i && cur.req == e.WorkoutExerciseRequest {
+			continue
+		}
+		r := e.WorkoutExerciseRequest
+		_, err := tx.Exec(`UPDATE workout_exercises SET position=?, exercise_id=?, sets=?, reps=?, weight_kg=?, duration_sec=?, rest_sec=?, notes=?,
+			distance_m=?, elevation_gain_m=?, avg_heart_rate=?, max_heart_rate=?, calories=? WHERE id=?`,
+			i, r.ExerciseID, r.Sets, r.Reps, r.WeightKg, r.DurationSec, r.RestSec, r.Notes,
+			r.DistanceM, r.ElevationGainM, r.AvgHeartRate, r.MaxHeartRate, r.Calories, e.ID)
+		if err := exerciseError(err, i, r); err != nil {
+			return err
+		}
+	}
+	for id := range existing {
+		if kept[id] {
+			continue
+		}
+		if err := buryWorkoutExerciseSets(tx, userID, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM workout_exercises WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buryWorkoutExerciseSets leaves tombstones for the sets logged against an
+// exercise row that is about to be deleted.
+func buryWorkoutExerciseSets(tx *Tx, userID, workoutExerciseID int64) error {
+	rows, err := tx.Query(`SELECT id, client_id FROM session_sets WHERE workout_exercise_id = ?`, workoutExerciseID)
+	if err != nil {
+		return err
+	}
+	var sets []models.Tombstone
+	for rows.Next() {
+		var t models.Tombstone
+		var clientID sql.NullString
+		if err := rows.Scan(&t.ID, &clientID); err != nil {
+			rows.Close()
+			return err
+		}
+		t.ClientID = clientID.String
+		sets = append(sets, t)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	deleted := formatTime(now())
+	for _, t := range sets {
+		if _, err := tx.Exec(`INSERT INTO tombstones (user_id, entity, entity_id, client_id, deleted_at) VALUES (?, 'set', ?, ?, ?)`,
+			userID, t.ID, nullString(t.ClientID), deleted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteWorkout moves a workout to the trash and leaves a tombstone for
+// syncing clients. ifVersion works as for UpdateWorkout.
+func (db *DB) DeleteWorkout(id, userID int64, ifVersion int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	var clientID sql.NullString
+	err = tx.QueryRow(`SELECT version, client_id FROM workouts WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, id, userID).Scan(&version, &clientID)
+	if err == sql.ErrNoRows {
+		return ErrWorkoutNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if ifVersion != 0 && version != ifVersion {
+		return ErrVersionMismatch
+	}
+	before, err := loadWorkoutSnapshot(tx, id)
+	if err != nil {
+		return err
+	}
+	deleted := formatTime(now())
+	res, err := tx.Exec(`UPDATE workouts SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND version = ?`,
+		deleted, deleted, id, version)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrVersionMismatch
+	}
+	if _, err := tx.Exec(`INSERT INTO tombstones (user_id, entity, entity_id, client_id, deleted_at) VALUES (?, 'workout', ?, ?, ?)`,
+		userID, id, clientID, deleted); err != nil {
+		return err
+	}
+	if err := db.auditWorkout(tx, userID, "delete", id, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListTrash returns the user's workouts in the trash, most recently
+// deleted first.
+func (db *DB) ListTrash(userID int64) ([]models.Workout, error) {
+	rows, err := db.Query(`SELECT `+workoutColumns+` FROM workouts w
+		WHERE w.user_id = ? AND w.deleted_at IS NOT NULL ORDER BY w.deleted_at DESC, w.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Workout{}
+	for rows.Next() {
+		w, err := scanWorkout(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ids := make([]int64, len(list))
+	for i, w := range list {
+		ids[i] = w.ID
+	}
+	byWorkout, err := db.loadWorkoutExercises(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Exercises = byWorkout[list[i].ID]
+	}
+	return list, nil
+}
+
+// RestoreWorkout takes a workout out of the trash. Its tombstone goes, and
+// the bumped updated_at hands it back to syncing clients.
+func (db *DB) RestoreWorkout(id, userID int64) (*models.Workout, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM workouts WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`, id, userID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrWorkoutNotFound
+	}
+	before, err := loadWorkoutSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE workouts SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ?`, formatTime(now()), id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM tombstones WHERE user_id = ? AND entity = 'workout' AND entity_id = ?`, userID, id); err != nil {
+		return nil, err
+	}
+	if err := db.auditWorkout(tx, userID, "restore", id, before); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.GetWorkoutByID(id, userID)
+}
+
+// PurgeTrash deletes for good the workouts that went to the trash before
+// the given time, with their exercises and sessions, and returns how many
+// it deleted. Their tombstones stay for syncing clients until
+// PurgeTombstones.
+func (db *DB) PurgeTrash(before time.Time) (int64, error) {
+	rows, err := db.Query(`SELECT id, user_id FROM workouts WHERE deleted_at IS NOT NULL AND deleted_at < ?`, formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	var expired [][2]int64
+	for rows.Next() {
+		var w [2]int64
+		if err := rows.Scan(&w[0], &w[1]); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, w)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, w := range expired {
+		ok, err := db.purgeWorkout(w[0], w[1])
+		if err != nil {
+			return purged, err
+		}
+		if ok {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// purgeWorkout deletes one workout from the trash, reporting false if it
+// was restored in the meantime.
+func (db *DB) purgeWorkout(id, userID int64) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	before, err := loadWorkoutSnapshot(tx, id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`DELETE FROM workouts WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := db.audit(tx, userID, "purge", "workout", id, before, nil); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
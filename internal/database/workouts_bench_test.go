@@ -0,0 +1,124 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+const (
+	benchWorkouts            = 300
+	benchExercisesPerWorkout = 4
+)
+
+// newBenchDB returns a file-backed database holding one user with
+// benchWorkouts workouts of benchExercisesPerWorkout exercises each.
+func newBenchDB(b *testing.B) (*DB, int64) {
+	b.Helper()
+	db, err := New(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		b.Fatal(err)
+	}
+	u, err := db.CreateUser("Bench", "bench@example.com", "x", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var exerciseIDs []int64
+	for i := 0; i < benchExercisesPerWorkout; i++ {
+		e, err := db.CreateCustomExercise(u.ID, fmt.Sprintf("Exercise %d", i), "strength", "", "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		exerciseIDs = append(exerciseIDs, e.ID)
+	}
+	start := time.Date(2024, 1, 1, 7, 0, 0, 0, time.UTC)
+	for i := 0; i < benchWorkouts; i++ {
+		at := start.AddDate(0, 0, i)
+		req := models.CreateWorkoutRequest{Title: fmt.Sprintf("Workout %d", i), ScheduledAt: &at}
+		for _, id := range exerciseIDs {
+			req.Exercises = append(req.Exercises, models.WorkoutExerciseRequest{ExerciseID: id, Sets: 3, Reps: 8, WeightKg: 60})
+		}
+		if _, err := db.CreateCompletedWorkout(u.ID, req, at.Add(time.Hour)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return db, u.ID
+}
+
+func BenchmarkListWorkouts(b *testing.B) {
+	db, userID := newBenchDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		page, err := db.ListWorkouts(userID, models.WorkoutFilter{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(page.Workouts) != benchWorkouts || len(page.Workouts[0].Exercises) != benchExercisesPerWorkout {
+			b.Fatalf("got %d workouts", len(page.Workouts))
+		}
+	}
+}
+
+func benchWorkoutIDs(b *testing.B, db *DB, userID int64) []int64 {
+	page, err := db.ListWorkouts(userID, models.WorkoutFilter{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	ids := make([]int64, len(page.Workouts))
+	for i, w := range page.Workouts {
+		ids[i] = w.ID
+	}
+	return ids
+}
+
+func BenchmarkLoadExercisesBatched(b *testing.B) {
+	db, userID := newBenchDB(b)
+	ids := benchWorkoutIDs(b, db, userID)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.loadWorkoutExercises(ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLoadExercisesPerWorkout issues one query per workout, as
+// ListWorkouts used to, for comparison with the batched load.
+func BenchmarkLoadExercisesPerWorkout(b *testing.B) {
+	db, userID := newBenchDB(b)
+	ids := benchWorkoutIDs(b, db, userID)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			if _, err := db.getWorkoutExercises(id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkListWorkoutsPage(b *testing.B) {
+	db, userID := newBenchDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.ListWorkouts(userID, models.WorkoutFilter{Limit: 20, Sort: "-date"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetReport(b *testing.B) {
+	db, userID := newBenchDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.GetReport(userID, models.WorkoutFilter{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// AuditWriter receives audit events in the order they were recorded and
+// must be closed to finish the document.
+type AuditWriter interface {
+	Write(e *models.AuditEvent) error
+	Close() error
+}
+
+// AuditContentType returns the MIME type and file extension of an audit
+// export format, csv or json, or false for an unknown format.
+func AuditContentType(format string) (string, string, bool) {
+	if format != "csv" && format != "json" {
+		return "", "", false
+	}
+	return ContentType(format)
+}
+
+// NewAudit returns an audit writer for format ("csv" or "json"). The CSV
+// has one row per event, with the changed fields as a JSON object.
+func NewAudit(format string, out io.Writer) (AuditWriter, error) {
+	switch format {
+	case "csv":
+		w := csv.NewWriter(out)
+		if err := w.Write(auditHeader); err != nil {
+			return nil, err
+		}
+		return &auditCSV{w: w}, nil
+	case "json":
+		return &auditJSON{newJSON(out)}, nil
+	}
+	return nil, fmt.Errorf("unknown audit export format %q", format)
+}
+
+var auditHeader = []string{"id", "created_at", "user_id", "actor_id", "action", "entity", "entity_id", "request_id", "changes"}
+
+type auditCSV struct {
+	w *csv.Writer
+}
+
+func (a *auditCSV) Write(e *models.AuditEvent) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	actor := ""
+	if e.ActorID != 0 {
+		actor = strconv.FormatInt(e.ActorID, 10)
+	}
+	err = a.w.Write([]string{
+		strconv.FormatInt(e.ID, 10), e.CreatedAt.UTC().Format(time.RFC3339), strconv.FormatInt(e.UserID, 10), actor,
+		e.Action, e.Entity, strconv.FormatInt(e.EntityID, 10), e.RequestID, string(changes),
+	})
+	if err != nil {
+		return err
+	}
+	a.w.Flush()
+	return a.w.Error()
+}
+
+func (a *auditCSV) Close() error {
+	a.w.Flush()
+	return a.w.Error()
+}
+
+// auditJSON streams a JSON array of events.
+type auditJSON struct {
+	j *jsonWriter
+}
+
+func (a *auditJSON) Write(e *models.AuditEvent) error { return a.j.write(e) }
+func (a *auditJSON) Close() error                     { return a.j.Close() }
@@ -0,0 +1,182 @@
+// Package export writes a user's workouts as CSV, JSON or a printable PDF
+// training log. Every writer consumes workouts one at a time so a full
+// history can be streamed straight to the response.
+//
+// CSV layout, one row per set:
+//
+//	workout_id, workout_title, workout_status, workout_date (RFC 3339),
+//	exercise_id, exercise_name, category, set_number, reps, weight_kg,
+//	duration_sec, distance_m, rest_sec, avg_heart_rate, notes, is_pr,
+//	completed_at (RFC 3339)
+//
+// A workout exercise with logged sets gives one row per logged set, with
+// that set's number, reps, weight, duration, rest, PR flag and completion
+// time. One without gives its planned sets (3 sets give three identical
+// rows; no sets give one row with set_number 1), with is_pr and
+// completed_at empty. Workouts without exercises give a single row with
+// the exercise columns empty.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// Writer receives workouts in date order and must be closed to finish the
+// document.
+type Writer interface {
+	Write(w *models.Workout) error
+	Close() error
+}
+
+// ContentType returns the MIME type and file extension of a format, or
+// false for an unknown format.
+func ContentType(format string) (string, string, bool) {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8", "csv", true
+	case "json":
+		return "application/json", "json", true
+	case "pdf":
+		return "application/pdf", "pdf", true
+	}
+	return "", "", false
+}
+
+// New returns a writer for format ("csv", "json" or "pdf").
+func New(format string, out io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		return newCSV(out)
+	case "json":
+		return newJSON(out), nil
+	case "pdf":
+		return newPDF(out)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// ---- CSV ----
+
+var csvHeader = []string{
+	"workout_id", "workout_title", "workout_status", "workout_date",
+	"exercise_id", "exercise_name", "category", "set_number", "reps", "weight_kg",
+	"duration_sec", "distance_m", "rest_sec", "avg_heart_rate", "notes", "is_pr",
+	"completed_at",
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSV(out io.Writer) (*csvWriter, error) {
+	w := csv.NewWriter(out)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: w}, nil
+}
+
+func (c *csvWriter) Write(w *models.Workout) error {
+	base := []string{
+		strconv.FormatInt(w.ID, 10), w.Title, w.Status, w.Date().UTC().Format(time.RFC3339),
+	}
+	if len(w.Exercises) == 0 {
+		row := append(append([]string{}, base...), make([]string, len(csvHeader)-len(base))...)
+		return c.w.Write(row)
+	}
+	for _, we := range w.Exercises {
+		name, category := "", ""
+		if we.Exercise != nil {
+			name, category = we.Exercise.Name, we.Exercise.Category
+		}
+		exercise := append(append([]string{}, base...), strconv.FormatInt(we.ExerciseID, 10), name, category)
+		for _, set := range we.LoggedSets {
+			row := append(append([]string{}, exercise...),
+				strconv.Itoa(set.SetNumber), strconv.Itoa(set.Reps), formatFloat(set.WeightKg), strconv.Itoa(set.DurationSec),
+				formatFloat(we.DistanceM), strconv.Itoa(set.RestSec), strconv.Itoa(we.AvgHeartRate), we.Notes,
+				strconv.FormatBool(set.IsPR), set.CompletedAt.UTC().Format(time.RFC3339),
+			)
+			if err := c.w.Write(row); err != nil {
+				return err
+			}
+		}
+		if len(we.LoggedSets) > 0 {
+			continue
+		}
+		sets := we.Sets
+		if sets < 1 {
+			sets = 1
+		}
+		for n := 1; n <= sets; n++ {
+			row := append(append([]string{}, exercise...),
+				strconv.Itoa(n), strconv.Itoa(we.Reps), formatFloat(we.WeightKg), strconv.Itoa(we.DurationSec),
+				formatFloat(we.DistanceM), strconv.Itoa(we.RestSec), strconv.Itoa(we.AvgHeartRate), we.Notes, "", "",
+			)
+			if err := c.w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ---- JSON ----
+
+// jsonWriter writes a JSON array one element at a time.
+type jsonWriter struct {
+	out   *bufio.Writer
+	enc   *json.Encoder
+	count int
+}
+
+func newJSON(out io.Writer) *jsonWriter {
+	bw := bufio.NewWriter(out)
+	return &jsonWriter{out: bw, enc: json.NewEncoder(bw)}
+}
+
+func (j *jsonWriter) Write(w *models.Workout) error {
+	return j.write(w)
+}
+
+func (j *jsonWriter) write(v interface{}) error {
+	sep := ",\n"
+	if j.count == 0 {
+		sep = "[\n"
+	}
+	if _, err := j.out.WriteString(sep); err != nil {
+		return err
+	}
+	j.count++
+	if err := j.enc.Encode(v); err != nil {
+		return err
+	}
+	return j.out.Flush()
+}
+
+func (j *jsonWriter) Close() error {
+	end := "]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := j.out.WriteString(end); err != nil {
+		return err
+	}
+	return j.out.Flush()
+}
@@ -0,0 +1,129 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+func sampleWorkouts() []*models.Workout {
+	mon := time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC)
+	next := mon.AddDate(0, 0, 7)
+	return []*models.Workout{
+		{ID: 1, Title: "Push (heavy)", Status: "completed", CompletedAt: &mon, Exercises: []models.WorkoutExercise{
+			{ExerciseID: 1, Sets: 3, Reps: 5, WeightKg: 82.5, Exercise: &models.Exercise{Name: "Bench Press", Category: "strength"}},
+		}},
+		{ID: 2, Title: "Rest day walk", Status: "pending", ScheduledAt: &next},
+	}
+}
+
+func render(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	w, err := New(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wk := range sampleWorkouts() {
+		if err := w.Write(wk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVOneRowPerSet(t *testing.T) {
+	rows, err := csv.NewReader(bytes.NewReader(render(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header + 3 sets + 1 workout without exercises
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want 5", len(rows))
+	}
+	if strings.Join(rows[0], ",") != strings.Join(csvHeader, ",") {
+		t.Fatalf("unexpected header %v", rows[0])
+	}
+	if rows[3][7] != "3" || rows[3][9] != "82.5" || rows[4][5] != "" {
+		t.Fatalf("unexpected rows %v", rows[1:])
+	}
+}
+
+func TestCSVLoggedSets(t *testing.T) {
+	at := time.Date(2024, 3, 4, 18, 5, 0, 0, time.UTC)
+	w := &models.Workout{ID: 1, Title: "Push", Status: "completed", CompletedAt: &at, Exercises: []models.WorkoutExercise{
+		{ExerciseID: 1, Sets: 3, Reps: 5, WeightKg: 80, Exercise: &models.Exercise{Name: "Bench Press"}, LoggedSets: []models.SessionSet{
+			{SetNumber: 1, Reps: 5, WeightKg: 80, CompletedAt: at},
+			{SetNumber: 2, Reps: 3, WeightKg: 85, IsPR: true, CompletedAt: at.Add(3 * time.Minute)},
+		}},
+	}}
+	var buf bytes.Buffer
+	c, _ := New("csv", &buf)
+	if err := c.Write(w); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header + the 2 logged sets, not the 3 planned ones
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	if got := rows[2]; got[7] != "2" || got[8] != "3" || got[9] != "85" || got[15] != "true" || got[16] != "2024-03-04T18:08:00Z" {
+		t.Fatalf("unexpected logged set %v", got)
+	}
+}
+
+func TestJSONArray(t *testing.T) {
+	var out []models.Workout
+	if err := json.Unmarshal(render(t, "json"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Title != "Push (heavy)" {
+		t.Fatalf("unexpected export %+v", out)
+	}
+
+	var empty bytes.Buffer
+	w, _ := New("json", &empty)
+	w.Close()
+	if strings.TrimSpace(empty.String()) != "[]" {
+		t.Fatalf("empty export = %q", empty.String())
+	}
+}
+
+func TestPDFOnePagePerWeek(t *testing.T) {
+	doc := render(t, "pdf")
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF document")
+	}
+	if !bytes.Contains(doc, []byte("/Count 2")) {
+		t.Fatal("expected two pages, one per week")
+	}
+	if !bytes.Contains(doc, []byte(`Push \(heavy\)`)) {
+		t.Fatal("expected escaped workout title")
+	}
+
+	// Every xref entry must point at the start of its object.
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(doc)
+	start, _ := strconv.Atoi(string(m[1]))
+	lines := strings.Split(string(doc[start:]), "\n")
+	for i, line := range lines[3:] {
+		if !strings.HasSuffix(line, " n ") {
+			break
+		}
+		off, _ := strconv.Atoi(line[:10])
+		if want := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(doc[off:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q", i+1, doc[off:off+10])
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+// A4 in points, and the text layout used on every page.
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	marginLeft   = 50
+	marginTop    = 60
+	marginBottom = 50
+	lineHeight   = 14
+	maxLineChars = 95
+)
+
+// Fixed object numbers; pages and their content streams follow from 5.
+const (
+	objCatalog  = 1
+	objPages    = 2
+	objFont     = 3
+	objFontBold = 4
+)
+
+// pdfWriter writes a training log with one page per ISO week, continuing
+// onto extra pages when a week does not fit. Pages are written as soon as
+// the next week starts, and the page tree and cross-reference table are
+// written on Close, so only the current week is ever held in memory.
+type pdfWriter struct {
+	out     *countingWriter
+	offsets map[int]int64
+	nextObj int
+	pages   []int
+
+	week     string // ISO week of the lines being collected
+	lines    []pdfLine
+	workouts int
+}
+
+type pdfLine struct {
+	text string
+	bold bool
+	size int
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func newPDF(out io.Writer) (*pdfWriter, error) {
+	p := &pdfWriter{
+		out:     &countingWriter{w: out},
+		offsets: map[int]int64{},
+		nextObj: 5,
+	}
+	if _, err := io.WriteString(p.out, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"); err != nil {
+		return nil, err
+	}
+	if err := p.object(objCatalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", objPages)); err != nil {
+		return nil, err
+	}
+	if err := p.object(objFont, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"); err != nil {
+		return nil, err
+	}
+	if err := p.object(objFontBold, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pdfWriter) Write(w *models.Workout) error {
+	// Weeks follow the zone the workout's times are in.
+	date := w.Date()
+	year, wk := date.ISOWeek()
+	week := fmt.Sprintf("%d-W%02d", year, wk)
+	if week != p.week {
+		if err := p.flushWeek(); err != nil {
+			return err
+		}
+		p.week = week
+		monday := date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+		p.lines = append(p.lines,
+			pdfLine{text: fmt.Sprintf("Training log - week %s (from %s)", week, monday.Format("Mon 2 Jan 2006")), bold: true, size: 16},
+			pdfLine{},
+		)
+	}
+	p.workouts++
+
+	p.lines = append(p.lines, pdfLine{
+		text: fmt.Sprintf("%s  %s  [%s]", date.Format("Mon 2 Jan 15:04"), w.Title, w.Status),
+		bold: true, size: 11,
+	})
+	if w.Description != "" {
+		p.lines = append(p.lines, pdfLine{text: "    " + w.Description, size: 9})
+	}
+	for _, we := range w.Exercises {
+		p.lines = append(p.lines, pdfLine{text: "    " + describeExercise(we), size: 10})
+	}
+	if w.Comment != "" {
+		p.lines = append(p.lines, pdfLine{text: "    Comment: " + w.Comment, size: 9})
+	}
+	p.lines = append(p.lines, pdfLine{})
+	return nil
+}
+
+func describeExercise(we models.WorkoutExercise) string {
+	name := fmt.Sprintf("#%d", we.ExerciseID)
+	if we.Exercise != nil && we.Exercise.Name != "" {
+		name = we.Exercise.Name
+	}
+	var parts []string
+	if we.Sets > 0 || we.Reps > 0 {
+		parts = append(parts, fmt.Sprintf("%d x %d", we.Sets, we.Reps))
+	}
+	if we.WeightKg > 0 {
+		parts = append(parts, fmt.Sprintf("@ %s kg", formatFloat(we.WeightKg)))
+	}
+	if we.DistanceM > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f km", we.DistanceM/1000))
+	}
+	if we.DurationSec > 0 {
+		parts = append(parts, (time.Duration(we.DurationSec) * time.Second).String())
+	}
+	if we.PaceSecPerKm > 0 {
+		pace := int(we.PaceSecPerKm)
+		parts = append(parts, fmt.Sprintf("%d:%02d /km", pace/60, pace%60))
+	}
+	if we.Notes != "" {
+		parts = append(parts, "- "+we.Notes)
+	}
+	return name + "  " + strings.Join(parts, "  ")
+}
+
+// flushWeek writes the collected lines as one or more pages.
+func (p *pdfWriter) flushWeek() error {
+	perPage := (pageHeight - marginTop - marginBottom) / lineHeight
+	for len(p.lines) > 0 {
+		n := perPage
+		if n > len(p.lines) {
+			n = len(p.lines)
+		}
+		if err := p.page(p.lines[:n]); err != nil {
+			return err
+		}
+		p.lines = p.lines[n:]
+	}
+	p.lines = nil
+	return nil
+}
+
+func (p *pdfWriter) page(lines []pdfLine) error {
+	var content bytes.Buffer
+	content.WriteString("BT\n")
+	fmt.Fprintf(&content, "%d %d Td\n", marginLeft, pageHeight-marginTop)
+	for i, l := range lines {
+		if i > 0 {
+			fmt.Fprintf(&content, "0 -%d Td\n", lineHeight)
+		}
+		if l.text == "" {
+			continue
+		}
+		font, size := "F1", l.size
+		if l.bold {
+			font = "F2"
+		}
+		if size == 0 {
+			size = 10
+		}
+		fmt.Fprintf(&content, "/%s %d Tf (%s) Tj\n", font, size, pdfEscape(l.text))
+	}
+	content.WriteString("ET\n")
+
+	contentObj := p.nextObj
+	pageObj := p.nextObj + 1
+	p.nextObj += 2
+	if err := p.object(contentObj, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String())); err != nil {
+		return err
+	}
+	p.pages = append(p.pages, pageObj)
+	return p.object(pageObj, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> >>",
+		objPages, pageWidth, pageHeight, contentObj, objFont, objFontBold))
+}
+
+func (p *pdfWriter) object(num int, body string) error {
+	p.offsets[num] = p.out.n
+	_, err := fmt.Fprintf(p.out, "%d 0 obj\n%s\nendobj\n", num, body)
+	return err
+}
+
+func (p *pdfWriter) Close() error {
+	if p.workouts == 0 {
+		p.lines = []pdfLine{{text: "Training log", bold: true, size: 16}, {}, {text: "No workouts in this period.", size: 11}}
+	}
+	if err := p.flushWeek(); err != nil {
+		return err
+	}
+
+	kids := make([]string, len(p.pages))
+	for i, n := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", n)
+	}
+	if err := p.object(objPages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages))); err != nil {
+		return err
+	}
+
+	xref := p.out.n
+	var b strings.Builder
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", p.nextObj)
+	for n := 1; n < p.nextObj; n++ {
+		fmt.Fprintf(&b, "%010d 00000 n \n", p.offsets[n])
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", p.nextObj, objCatalog, xref)
+	_, err := io.WriteString(p.out, b.String())
+	return err
+}
+
+// pdfEscape escapes a PDF string literal. The standard fonts only cover
+// WinAnsi, so other characters are replaced.
+func pdfEscape(s string) string {
+	if len(s) > maxLineChars {
+		s = s[:maxLineChars-3] + "..."
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
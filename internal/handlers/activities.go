@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"workout-tracker/internal/activity"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/middleware"
+	"workout-tracker/internal/models"
+	"workout-tracker/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxActivityUpload caps GPX/TCX/FIT uploads; a multi-hour GPX track with
+// heart rate is a few megabytes.
+const maxActivityUpload = 20 << 20
+
+type ActivityHandler struct {
+	db database.Store
+}
+
+func NewActivityHandler(db database.Store) *ActivityHandler {
+	return &ActivityHandler{db: db}
+}
+
+// POST /import/activity
+//
+// Accepts a multipart upload in field "file". The format (GPX, TCX or FIT)
+// is detected from the content. "exercise_id" and "title" form fields
+// override what is inferred from the file.
+func (h *ActivityHandler) Import(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxActivityUpload)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.Error(errFileRequired)
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	format := activity.DetectFormat(data)
+	summary, err := activity.Parse(bytes.NewReader(data))
+	if err != nil {
+		c.Error(middleware.NewError(http.StatusUnprocessableEntity, "unreadable_file", err.Error()))
+		return
+	}
+	if summary.StartTime.IsZero() {
+		c.Error(middleware.NewError(http.StatusUnprocessableEntity, "no_timestamps", "activity has no timestamps"))
+		return
+	}
+
+	lib, err := exerciseLibrary(h.db, userID, true)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	exercise, err := h.exerciseFor(lib, c.PostForm("exercise_id"), summary.Sport)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	title := c.PostForm("title")
+	if title == "" {
+		title = summary.Name
+	}
+	if title == "" {
+		title = fmt.Sprintf("%s %s", exercise.Name, summary.StartTime.Format("2006-01-02"))
+	}
+
+	start := summary.StartTime
+	req := models.CreateWorkoutRequest{
+		Title:       title,
+		Description: fmt.Sprintf("Imported from %s", strings.ToUpper(format)),
+		ScheduledAt: &start,
+		Exercises: []models.WorkoutExerciseRequest{{
+			ExerciseID:     exercise.ID,
+			Sets:           1,
+			DurationSec:    summary.DurationSec,
+			DistanceM:      summary.DistanceM,
+			ElevationGainM: summary.ElevationGainM,
+			AvgHeartRate:   summary.AvgHeartRate,
+			MaxHeartRate:   summary.MaxHeartRate,
+			Calories:       summary.Calories,
+			Notes:          fmt.Sprintf("%d track points", summary.Points),
+		}},
+	}
+	if err := validation.Error(validation.CreateWorkout(&req, lib)); err != nil {
+		c.Error(err)
+		return
+	}
+	completedAt := summary.StartTime.Add(time.Duration(summary.DurationSec) * time.Second)
+	workout, err := audited(h.db, c).CreateCompletedWorkout(userID, req, completedAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.ActivityImport{Format: format, Workout: workout})
+}
+
+// exerciseFor picks the exercise to log the activity against: the one the
+// client asked for, which must be in the user's library, else the one
+// matching the file's sport, else Running.
+func (h *ActivityHandler) exerciseFor(lib validation.Library, idParam, sport string) (*models.Exercise, error) {
+	if idParam != "" {
+		id, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			return nil, invalidParam("exercise_id", "must be a number")
+		}
+		e, ok := lib[id]
+		if !ok {
+			return nil, database.Invalid("unknown_exercise", "exercise not found", models.FieldError{
+				Parameter: "exercise_id", Code: "unknown_exercise", Message: fmt.Sprintf("no exercise with id %d", id),
+			})
+		}
+		return &e, nil
+	}
+	name := "Running"
+	if sport == "cycling" {
+		name = "Cycling"
+	}
+	e, err := h.db.GetExerciseByName(name)
+	if err != nil || e == nil {
+		return nil, fmt.Errorf("exercise %q not found", name)
+	}
+	return e, nil
+}
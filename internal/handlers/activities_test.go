@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"workout-tracker/internal/database"
+)
+
+const runGPX = `<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><type>running</type><trkseg>
+    <trkpt lat="52.0000" lon="13.0000"><time>2024-05-01T07:00:00Z</time></trkpt>
+    <trkpt lat="52.0450" lon="13.0000"><time>2024-05-01T07:25:00Z</time></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+func importActivity(r http.Handler, token string, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "run.gpx")
+	fw.Write([]byte(runGPX))
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	mw.Close()
+
+	req, _ := http.NewRequest("POST", "/import/activity", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportActivity(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "runner@test.com")
+
+	w := importActivity(r, token, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Import expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var imported struct {
+		Format  string `json:"format"`
+		Workout struct {
+			Status    string `json:"status"`
+			Exercises []struct {
+				DistanceM    float64 `json:"distance_m"`
+				PaceSecPerKm float64 `json:"pace_sec_per_km"`
+			} `json:"exercises"`
+		} `json:"workout"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &imported)
+	if imported.Format != "gpx" || imported.Workout.Status != "completed" || len(imported.Workout.Exercises) != 1 {
+		t.Fatalf("Unexpected import: %s", w.Body.String())
+	}
+	if ex := imported.Workout.Exercises[0]; ex.DistanceM < 4990 || ex.PaceSecPerKm < 290 || ex.PaceSecPerKm > 310 {
+		t.Fatalf("Unexpected distance/pace: %+v", ex)
+	}
+
+	w = doJSON(r, "GET", "/workouts/report", token, nil)
+	var report struct {
+		RunningDistanceKm float64 `json:"running_distance_km"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.RunningDistanceKm < 4.99 {
+		t.Fatalf("Expected running volume in report, got %s", w.Body.String())
+	}
+}
+
+func TestImportActivityValidates(t *testing.T) {
+	r, db := setupTestRouter(t)
+	registerAndGetToken(t, r, "owner@test.com")
+	token := registerAndGetToken(t, r, "runner@test.com")
+	var store database.Store = db
+	private, err := store.CreateCustomExercise(1, "Secret trail", "cardio", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := importActivity(r, token, map[string]string{"exercise_id": fmt.Sprint(private.ID)})
+	if p := decodeProblem(t, w); w.Code != http.StatusBadRequest || p.Code != "unknown_exercise" || bytes.Contains(w.Body.Bytes(), []byte("Secret trail")) {
+		t.Errorf("another user's exercise: %d %s", w.Code, w.Body.String())
+	}
+	w = importActivity(r, token, map[string]string{"title": strings.Repeat("x", 201)})
+	if p := decodeProblem(t, w); w.Code != http.StatusBadRequest || p.Code != "invalid_workout" {
+		t.Errorf("long title: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", "/workouts", token, nil); w.Body.String() != "[]" {
+		t.Errorf("rejected imports were stored: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"workout-tracker/internal/config"
+	"workout-tracker/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AIHandler struct {
+	settings config.AI
+}
+
+func NewAIHandler(settings config.AI) *AIHandler {
+	return &AIHandler{settings: settings}
+}
+
+// GET /api/config
+//
+// The app generates plans by calling Groq itself, with the server's key
+// when one is configured, and the model and sampling settings given here.
+func (h *AIHandler) Config(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"groq_key_set":     h.settings.GroqKey != "",
+		"groq_key":         h.settings.GroqKey,
+		"groq_model":       h.settings.Model,
+		"groq_max_tokens":  h.settings.MaxTokens,
+		"groq_temperature": h.settings.Temperature,
+	})
+}
+
+// POST /api/ai/generations
+//
+// The app calls the AI provider itself, so it reports each plan generation
+// here to have it counted in the metrics. result is ok or error.
+func (h *AIHandler) RecordGeneration(c *gin.Context) {
+	var req struct {
+		Result string `json:"result" binding:"required,oneof=ok error"`
+	}
+	if !bindJSON(c, &req) {
+		return
+	}
+	metrics.AIGenerations.Inc(req.Result)
+	c.Status(http.StatusNoContent)
+}
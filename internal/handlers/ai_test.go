@@ -0,0 +1,25 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAIConfig(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	if w := doJSON(r, "GET", "/api/config", "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("signed out: %d", w.Code)
+	}
+	token := registerAndGetToken(t, r, "ai@test.com")
+	w := doJSON(r, "GET", "/api/config", token, nil)
+	var got struct {
+		KeySet bool   `json:"groq_key_set"`
+		Model  string `json:"groq_model"`
+		Tokens int    `json:"groq_max_tokens"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if got.KeySet || got.Model != "llama-3.3-70b-versatile" || got.Tokens != 4000 {
+		t.Errorf("config: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/export"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	db database.Store
+}
+
+func NewAuditHandler(db database.Store) *AuditHandler {
+	return &AuditHandler{db: db}
+}
+
+var (
+	auditEntities = map[string]bool{"user": true, "exercise": true, "workout": true, "session": true, "set": true,
+		"follow": true, "reaction": true, "comment": true, "share": true, "coach_link": true,
+		"team": true, "team_member": true, "challenge": true, "goal": true, "body_weight": true}
+	auditActions = map[string]bool{"create": true, "update": true, "delete": true, "restore": true, "purge": true}
+)
+
+// GET /audit?entity=&entity_id=&action=&from=&to=&limit=&cursor=
+//
+// Lists changes to the user's data, and changes the user made to others'
+// data, most recent first. from and to are dates in the user's time zone.
+func (h *AuditHandler) List(c *gin.Context) {
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	f, err := parseAuditFilter(c, loc)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	f.UserID = c.GetInt64("userID")
+	if f.Limit == 0 {
+		f.Limit = 50
+	}
+	page, err := h.db.ListAuditEvents(f)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range page.Events {
+		page.Events[i].CreatedAt = page.Events[i].CreatedAt.In(loc)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GET /admin/audit/export?format=csv|json&user_id=&entity=&entity_id=&action=&from=&to=
+//
+// Streams the audit log of every user, or of one, oldest first. Dates are
+// in UTC.
+func (h *AuditHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	contentType, ext, ok := export.AuditContentType(format)
+	if !ok {
+		c.Error(invalidParam("format", "must be csv or json"))
+		return
+	}
+	f, err := parseAuditFilter(c, time.UTC)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if v := c.Query("user_id"); v != "" {
+		if f.UserID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.Error(invalidParam("user_id", "must be a number"))
+			return
+		}
+	}
+
+	streaming(c)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.%s"`, time.Now().UTC().Format("2006-01-02"), ext))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewAudit(format, c.Writer)
+	if err != nil {
+		slog.ErrorContext(c, "audit export", "error", err)
+		return
+	}
+	err = h.db.StreamAuditEvents(f, func(e *models.AuditEvent) error {
+		if err := w.Write(e); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		slog.ErrorContext(c, "audit export failed", "error", err)
+	}
+}
+
+// parseAuditFilter reads the filter parameters shared by the audit listing
+// and export, with dates taken in loc.
+func parseAuditFilter(c *gin.Context, loc *time.Location) (models.AuditFilter, error) {
+	f := models.AuditFilter{Entity: c.Query("entity"), Action: c.Query("action"), Cursor: c.Query("cursor")}
+	if f.Entity != "" && !auditEntities[f.Entity] {
+		return f, invalidParam("entity", "must be one of user, exercise, workout, session, set, follow, reaction, comment, share, coach_link, team, team_member, challenge, goal, body_weight")
+	}
+	if f.Action != "" && !auditActions[f.Action] {
+		return f, invalidParam("action", "must be one of create, update, delete, restore, purge")
+	}
+	var err error
+	if v := c.Query("entity_id"); v != "" {
+		if f.EntityID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, invalidParam("entity_id", "must be a number")
+		}
+	}
+	if f.From, err = parseDateParam(c.Query("from"), loc); err != nil {
+		return f, invalidParam("from", dateFormats)
+	}
+	if f.To, err = parseDateParam(c.Query("to"), loc); err != nil {
+		return f, invalidParam("to", dateFormats)
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			return f, invalidParam("limit", "must be a positive number")
+		}
+	}
+	return f, nil
+}
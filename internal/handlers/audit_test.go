@@ -0,0 +1,68 @@
+package handlers_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func TestAuditLog(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "audited@test.com")
+
+	w := doRaw(r, "POST", "/workouts", token, "application/json", `{"title":"Legs"}`, map[string]string{"X-Request-ID": "req-create"})
+	var created models.Workout
+	json.Unmarshal(w.Body.Bytes(), &created)
+	path := "/workouts/" + itoa(int(created.ID))
+	doJSON(r, "PUT", path, token, map[string]interface{}{"title": "Leg day"})
+	doJSON(r, "DELETE", path, token, nil)
+
+	var page models.AuditPage
+	w = doJSON(r, "GET", "/audit?entity=workout&entity_id="+itoa(int(created.ID)), token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /audit: %d %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Events) != 3 || page.Events[0].Action != "delete" || page.Events[2].Action != "create" {
+		t.Fatalf("events: %+v", page.Events)
+	}
+	if e := page.Events[2]; e.RequestID != "req-create" || e.ActorID != created.UserID {
+		t.Errorf("create event: %+v", e)
+	}
+	if c := page.Events[1].Changes["title"]; string(c.Before) != `"Legs"` || string(c.After) != `"Leg day"` {
+		t.Errorf("update changes: %+v", page.Events[1].Changes)
+	}
+
+	json.Unmarshal(doJSON(r, "GET", "/audit?action=update&limit=1", token, nil).Body.Bytes(), &page)
+	if len(page.Events) != 1 || page.Events[0].Action != "update" || page.NextCursor != "" {
+		t.Errorf("filtered by action: %+v", page)
+	}
+	if w := doJSON(r, "GET", "/audit?entity=plan", token, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown entity: %d", w.Code)
+	}
+
+	if w := doJSON(r, "GET", "/admin/audit/export", token, nil); w.Code != http.StatusForbidden || decodeProblem(t, w).Code != "admin_required" {
+		t.Errorf("export by a non-admin: %d %s", w.Code, w.Body.String())
+	}
+	admin := registerAndGetToken(t, r, "admin@test.com")
+	w = doJSON(r, "GET", "/admin/audit/export?entity=workout", admin, nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("CSV export: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0][0] != "id" || records[1][4] != "create" || records[1][7] != "req-create" {
+		t.Errorf("CSV export: %v", records)
+	}
+
+	w = doJSON(r, "GET", "/admin/audit/export?format=json&user_id="+itoa(int(created.UserID))+"&entity=user", admin, nil)
+	var events []models.AuditEvent
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil || len(events) != 1 || events[0].EntityID != created.UserID {
+		t.Errorf("JSON export: %v %s", err, w.Body.String())
+	}
+}
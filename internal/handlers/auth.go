@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 	"workout-tracker/internal/auth"
 	"workout-tracker/internal/database"
+	"workout-tracker/internal/middleware"
 	"workout-tracker/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -11,63 +13,65 @@ import (
 )
 
 type AuthHandler struct {
-	db *database.DB
+	db     database.Store
+	tokens *auth.Tokens
 }
 
-func NewAuthHandler(db *database.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+func NewAuthHandler(db database.Store, tokens *auth.Tokens) *AuthHandler {
+	return &AuthHandler{db: db, tokens: tokens}
 }
 
 // POST /auth/register
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	existing, _ := h.db.GetUserByEmail(req.Email)
-	if existing != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "email already registered"})
+	if req.TimeZone != "" && !validTimeZone(req.TimeZone) {
+		c.Error(errUnknownTimeZone)
 		return
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		c.Error(err)
 		return
 	}
 
-	user, err := h.db.CreateUser(req.Name, req.Email, string(hash))
+	user, err := audited(h.db, c).CreateUser(req.Name, req.Email, string(hash), req.TimeZone)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	token, _ := auth.GenerateToken(user.ID, user.Email)
+	token, _ := h.tokens.Issue(user.ID, user.Email)
 	c.JSON(http.StatusCreated, models.AuthResponse{Token: token, User: *user})
 }
 
 // POST /auth/login
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	user, err := h.db.GetUserByEmail(req.Email)
-	if err != nil || user == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(errInvalidCredentials)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		c.Error(errInvalidCredentials)
 		return
 	}
 
-	token, _ := auth.GenerateToken(user.ID, user.Email)
+	token, _ := h.tokens.Issue(user.ID, user.Email)
 	c.JSON(http.StatusOK, models.AuthResponse{Token: token, User: *user})
 }
 
@@ -75,9 +79,85 @@ func (h *AuthHandler) Login(c *gin.Context) {
 func (h *AuthHandler) Me(c *gin.Context) {
 	userID := c.GetInt64("userID")
 	user, err := h.db.GetUserByID(userID)
-	if err != nil || user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(database.ErrUserNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// PATCH /auth/me
+//
+// Body: {"timezone": "Europe/Berlin", "rest_days": 1}, either or both.
+// Sets the user's IANA time zone, used for date filters, reports, streaks
+// and the times in responses, and the rest days (0 to 6) a streak allows.
+func (h *AuthHandler) UpdateMe(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	var req models.UpdateUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.TimeZone == "" && req.RestDays == nil {
+		c.Error(errNoSettings)
+		return
+	}
+	if req.TimeZone != "" && !validTimeZone(req.TimeZone) {
+		c.Error(errUnknownTimeZone)
+		return
+	}
+	var user *models.User
+	var err error
+	if req.TimeZone != "" {
+		user, err = audited(h.db, c).UpdateUserTimeZone(userID, req.TimeZone)
+	}
+	if err == nil && req.RestDays != nil {
+		user, err = audited(h.db, c).UpdateUserRestDays(userID, *req.RestDays)
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if user == nil {
+		c.Error(database.ErrUserNotFound)
 		return
 	}
 	c.JSON(http.StatusOK, user)
 }
+
+var (
+	errInvalidCredentials = middleware.NewError(http.StatusUnauthorized, "invalid_credentials", "invalid credentials")
+	errNoSettings         = database.Invalid("invalid_request", "nothing to update", models.FieldError{
+		Pointer: "/timezone", Code: "required", Message: "is required unless rest_days is set",
+	})
+	errUnknownTimeZone = database.Invalid("unknown_time_zone", "unknown time zone", models.FieldError{
+		Pointer: "/timezone", Code: "unknown_time_zone", Message: "must be an IANA time zone such as Europe/Berlin",
+	})
+)
+
+// validTimeZone accepts IANA zone names such as "Europe/Berlin" or "UTC".
+func validTimeZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// audited returns the store to make changes through on behalf of c, which
+// records its user and request ID in the audit log.
+func audited(db database.Store, c *gin.Context) database.Store {
+	return db.As(database.Actor{UserID: c.GetInt64("userID"), RequestID: c.GetString("requestID")})
+}
+
+// userLocation is the time zone of the signed-in user.
+func userLocation(db database.Store, c *gin.Context) (*time.Location, error) {
+	user, err := db.GetUserByID(c.GetInt64("userID"))
+	if err != nil {
+		return nil, err
+	}
+	return user.Location(), nil
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+	"workout-tracker/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errTemplateNotFound = database.NotFound("template_not_found", "template not found")
+
+// CoachingHandler serves both sides of coach links: coaches invite
+// athletes and work on their accounts under /athletes, and athletes answer
+// invites and control access under /coaches.
+type CoachingHandler struct {
+	db database.Store
+}
+
+func NewCoachingHandler(db database.Store) *CoachingHandler {
+	return &CoachingHandler{db: db}
+}
+
+// POST /athletes
+//
+// Body: {"email": "...", "scopes": ["view", "assign", "edit"]}. Invites the
+// user with that email to be coached; the link is pending until they
+// accept.
+func (h *CoachingHandler) Invite(c *gin.Context) {
+	var req models.CoachInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	link, err := audited(h.db, c).InviteAthlete(c.GetInt64("userID"), req.Email, req.Scopes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondLink(c, http.StatusCreated, link)
+}
+
+// GET /athletes
+func (h *CoachingHandler) Athletes(c *gin.Context) {
+	list, err := h.db.ListAthletes(c.GetInt64("userID"))
+	h.respondLinks(c, list, err)
+}
+
+// DELETE /athletes/:id
+//
+// Stops coaching the athlete, or withdraws the invite.
+func (h *CoachingHandler) Drop(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	if err := audited(h.db, c).DropAthlete(c.GetInt64("userID"), id); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// GET /coaches
+func (h *CoachingHandler) Coaches(c *gin.Context) {
+	list, err := h.db.ListCoaches(c.GetInt64("userID"))
+	h.respondLinks(c, list, err)
+}
+
+// POST /coaches/:id/accept
+//
+// Accepts the coach's invite, granting the scopes it asked for.
+func (h *CoachingHandler) Accept(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	link, err := audited(h.db, c).AcceptCoach(c.GetInt64("userID"), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondLink(c, http.StatusOK, link)
+}
+
+// PUT /coaches/:id/scopes
+//
+// Body: {"scopes": [...]}. Replaces what the coach may do.
+func (h *CoachingHandler) SetScopes(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	var req models.CoachScopesRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	link, err := audited(h.db, c).UpdateCoachScopes(c.GetInt64("userID"), id, req.Scopes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondLink(c, http.StatusOK, link)
+}
+
+// DELETE /coaches/:id
+//
+// Revokes the coach's access at once, or declines the invite.
+func (h *CoachingHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	if err := audited(h.db, c).RevokeCoach(c.GetInt64("userID"), id); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+func (h *CoachingHandler) respondLink(c *gin.Context, status int, link *models.CoachLink) {
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	link.In(loc)
+	c.JSON(status, link)
+}
+
+func (h *CoachingHandler) respondLinks(c *gin.Context, list []models.CoachLink, err error) {
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range list {
+		list[i].In(loc)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// athlete reads the athlete ID from the path and loads their time zone,
+// in which a coach sees their workouts.
+func (h *CoachingHandler) athlete(c *gin.Context) (int64, *time.Location, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return 0, nil, false
+	}
+	user, err := h.db.GetUserByID(id)
+	if err != nil {
+		c.Error(err)
+		return 0, nil, false
+	}
+	if user == nil {
+		c.Error(database.ErrAthleteNotFound)
+		return 0, nil, false
+	}
+	return id, user.Location(), true
+}
+
+// GET /athletes/:id/workouts
+//
+// Needs the view scope. Takes the parameters of GET /workouts and always
+// responds with a page; dates and times are the athlete's.
+func (h *CoachingHandler) Workouts(c *gin.Context) {
+	athleteID, loc, ok := h.athlete(c)
+	if !ok {
+		return
+	}
+	filter, err := parseWorkoutFilter(c, loc)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	page, err := h.db.ListAthleteWorkouts(c.GetInt64("userID"), athleteID, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range page.Workouts {
+		page.Workouts[i].In(loc)
+	}
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GET /athletes/:id/workouts/:workout_id
+//
+// Needs the view scope.
+func (h *CoachingHandler) Workout(c *gin.Context) {
+	athleteID, loc, ok := h.athlete(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("workout_id"), 10, 64)
+	if err != nil {
+		c.Error(invalidParam("workout_id", "must be a number"))
+		return
+	}
+	workout, err := h.db.GetAthleteWorkout(id, c.GetInt64("userID"), athleteID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if workout == nil {
+		c.Error(database.ErrWorkoutNotFound)
+		return
+	}
+	workout.In(loc)
+	c.Header("ETag", etag(workout.Version))
+	c.JSON(http.StatusOK, workout)
+}
+
+// POST /athletes/:id/workouts
+//
+// Needs the assign scope. Body: {"template_id": 12, "scheduled_at": "...",
+// "title": "...", "description": "..."}. Schedules a pending copy of one
+// of the coach's own workouts, with its exercises, for the athlete; title
+// and description default to the template's.
+func (h *CoachingHandler) Assign(c *gin.Context) {
+	coachID := c.GetInt64("userID")
+	athleteID, loc, ok := h.athlete(c)
+	if !ok {
+		return
+	}
+	// Coaches without the scope learn nothing from validating the body.
+	if err := h.db.CheckCoach(coachID, athleteID, "assign"); err != nil {
+		c.Error(err)
+		return
+	}
+	var req models.AssignWorkoutRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	template, err := h.db.GetWorkoutByID(req.TemplateID, coachID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if template == nil {
+		c.Error(errTemplateNotFound)
+		return
+	}
+	create := models.CreateWorkoutRequest{
+		Title:       template.Title,
+		Description: template.Description,
+		ScheduledAt: req.ScheduledAt,
+	}
+	if req.Title != "" {
+		create.Title = req.Title
+	}
+	if req.Description != "" {
+		create.Description = req.Description
+	}
+	for _, e := range template.Exercises {
+		create.Exercises = append(create.Exercises, e.Request())
+	}
+	// The athlete must be able to log every exercise, which rules out the
+	// coach's custom ones.
+	lib, err := exerciseLibrary(h.db, athleteID, len(create.Exercises) > 0)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := validation.Error(validation.CreateWorkout(&create, lib)); err != nil {
+		c.Error(err)
+		return
+	}
+	workout, err := audited(h.db, c).AssignWorkout(coachID, athleteID, create)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	workout.In(loc)
+	c.Header("ETag", etag(workout.Version))
+	c.JSON(http.StatusCreated, workout)
+}
+
+// PUT /athletes/:id/workouts/:workout_id
+//
+// Needs the edit scope. Works as PUT /workouts/:id, If-Match included.
+func (h *CoachingHandler) Update(c *gin.Context) {
+	coachID := c.GetInt64("userID")
+	athleteID, loc, ok := h.athlete(c)
+	if !ok {
+		return
+	}
+	if err := h.db.CheckCoach(coachID, athleteID, "edit"); err != nil {
+		c.Error(err)
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("workout_id"), 10, 64)
+	if err != nil {
+		c.Error(invalidParam("workout_id", "must be a number"))
+		return
+	}
+	var req models.UpdateWorkoutRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	lib, err := exerciseLibrary(h.db, athleteID, len(req.Exercises) > 0)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := validation.Error(validation.UpdateWorkout(&req, lib)); err != nil {
+		c.Error(err)
+		return
+	}
+	workout, err := audited(h.db, c).UpdateAthleteWorkout(id, coachID, athleteID, req, ifMatch(c))
+	if err != nil {
+		c.Error(conditionalError(c, err))
+		return
+	}
+	workout.In(loc)
+	c.Header("ETag", etag(workout.Version))
+	c.JSON(http.StatusOK, workout)
+}
+
+// GET /athletes/:id/report
+//
+// Needs the view scope. Takes the parameters of GET /workouts/report, in
+// the athlete's time zone.
+func (h *CoachingHandler) Report(c *gin.Context) {
+	athleteID, loc, ok := h.athlete(c)
+	if !ok {
+		return
+	}
+	filter, err := parseWorkoutFilter(c, loc)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	report, err := h.db.GetAthleteReport(c.GetInt64("userID"), athleteID, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range report.Workouts {
+		report.Workouts[i].In(loc)
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func TestCoaching(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	coach := registerAndGetToken(t, r, "coach@test.com")
+	athlete := registerAndGetToken(t, r, "athlete@test.com")
+
+	if w := doJSON(r, "POST", "/athletes", coach, map[string]interface{}{"email": "athlete@test.com", "scopes": []string{"view", "own"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown scope: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/athletes", coach, map[string]interface{}{"email": "athlete@test.com", "scopes": []string{"assign"}}); w.Code != http.StatusCreated {
+		t.Fatalf("invite: %d %s", w.Code, w.Body.String())
+	}
+	var template models.Workout
+	json.Unmarshal(doJSON(r, "POST", "/workouts", coach, map[string]interface{}{
+		"title":     "Squat day",
+		"exercises": []map[string]interface{}{{"exercise_id": 1, "sets": 5, "reps": 5, "weight_kg": 100}},
+	}).Body.Bytes(), &template)
+	assign := map[string]interface{}{"template_id": template.ID, "scheduled_at": "2026-03-02T07:00:00Z"}
+
+	// Until the athlete accepts, the coach has no athlete.
+	w := doJSON(r, "POST", "/athletes/2/workouts", coach, assign)
+	if p := decodeProblem(t, w); w.Code != http.StatusNotFound || p.Code != "athlete_not_found" {
+		t.Errorf("assign before accepting: %d %+v", w.Code, p)
+	}
+	var link models.CoachLink
+	json.Unmarshal(doJSON(r, "POST", "/coaches/1/accept", athlete, nil).Body.Bytes(), &link)
+	if link.Status != "active" || len(link.Scopes) != 1 || link.Scopes[0] != "assign" {
+		t.Fatalf("accept = %+v", link)
+	}
+
+	w = doJSON(r, "POST", "/athletes/2/workouts", coach, assign)
+	var assigned models.Workout
+	json.Unmarshal(w.Body.Bytes(), &assigned)
+	if w.Code != http.StatusCreated || assigned.Title != "Squat day" || assigned.Status != "pending" || len(assigned.Exercises) != 1 ||
+		assigned.AssignedBy == nil || *assigned.AssignedBy != 1 {
+		t.Fatalf("assign: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", fmt.Sprintf("/workouts/%d", assigned.ID), athlete, nil); w.Code != http.StatusOK {
+		t.Errorf("athlete's copy: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, "POST", "/athletes/2/workouts", coach, map[string]interface{}{"template_id": assigned.ID})
+	if p := decodeProblem(t, w); w.Code != http.StatusNotFound || p.Code != "template_not_found" {
+		t.Errorf("athlete's workout as a template: %d %+v", w.Code, p)
+	}
+
+	w = doJSON(r, "GET", "/athletes/2/report", coach, nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusForbidden || p.Code != "scope_not_granted" {
+		t.Errorf("report without view: %d %+v", w.Code, p)
+	}
+	if w := doJSON(r, "PUT", "/coaches/1/scopes", athlete, map[string]interface{}{"scopes": []string{"view"}}); w.Code != http.StatusOK {
+		t.Fatalf("grant view: %d %s", w.Code, w.Body.String())
+	}
+	// Without the assign scope the coach is refused before the template is
+	// checked, even one the athlete could not log.
+	var custom models.Exercise
+	json.Unmarshal(doJSON(r, "POST", "/exercises", coach, map[string]interface{}{"name": "Coach's sled", "category": "strength"}).Body.Bytes(), &custom)
+	var private models.Workout
+	json.Unmarshal(doJSON(r, "POST", "/workouts", coach, map[string]interface{}{
+		"title":     "Sled day",
+		"exercises": []map[string]interface{}{{"exercise_id": custom.ID, "sets": 3, "reps": 10}},
+	}).Body.Bytes(), &private)
+	w = doJSON(r, "POST", "/athletes/2/workouts", coach, map[string]interface{}{"template_id": private.ID})
+	if p := decodeProblem(t, w); w.Code != http.StatusForbidden || p.Code != "scope_not_granted" {
+		t.Errorf("assign without the scope: %d %+v", w.Code, p)
+	}
+	var report models.WorkoutReport
+	if w := doJSON(r, "GET", "/athletes/2/report", coach, nil); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &report) != nil || report.TotalWorkouts != 1 {
+		t.Errorf("report: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := doJSON(r, "DELETE", "/coaches/1", athlete, nil); w.Code != http.StatusOK {
+		t.Fatalf("revoke: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", "/athletes/2/workouts", coach, nil); w.Code != http.StatusNotFound {
+		t.Errorf("list after revoking: %d", w.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"workout-tracker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func corsRouter(policy middleware.CORSPolicy) *gin.Engine {
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Errors(), middleware.SecurityHeaders(time.Hour),
+		middleware.CORS(policy, middleware.CORSRoute{Path: "/api/config", Policy: policy.Explicit()}))
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	r.GET("/workouts", ok)
+	r.GET("/api/config", ok)
+	return r
+}
+
+func corsRequest(r *gin.Engine, method, path, origin string, preflight bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if preflight {
+		req.Header.Set("Access-Control-Request-Method", "POST")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSAllowList(t *testing.T) {
+	r := corsRouter(middleware.CORSPolicy{
+		Origins:          []string{"https://app.example.com"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	w := corsRequest(r, "GET", "/workouts", "https://app.example.com", false)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("allowed origin: Allow-Origin = %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Errorf("allowed origin headers: %v", w.Header())
+	}
+	if w.Header().Get("Vary") != "Origin" {
+		t.Errorf("Vary = %q", w.Header().Get("Vary"))
+	}
+
+	w = corsRequest(r, "OPTIONS", "/workouts", "https://app.example.com", true)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Max-Age") != "600" || w.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("preflight: %d %v", w.Code, w.Header())
+	}
+
+	w = corsRequest(r, "GET", "/workouts", "https://evil.example.com", false)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin: %d %v", w.Code, w.Header())
+	}
+	w = corsRequest(r, "OPTIONS", "/workouts", "https://evil.example.com", true)
+	if w.Code != http.StatusForbidden || decodeProblem(t, w).Code != "origin_not_allowed" {
+		t.Errorf("preflight from another origin: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := corsRequest(r, "GET", "/workouts", "", false); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("same-origin request got CORS headers: %v", w.Header())
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	r := corsRouter(middleware.CORSPolicy{Origins: []string{"*"}})
+
+	w := corsRequest(r, "GET", "/workouts", "https://anywhere.example.com", false)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin = %q, want *", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("credentials allowed: %v", w.Header())
+	}
+
+	// The config holds a secret, so the wildcard does not apply to it.
+	w = corsRequest(r, "GET", "/api/config", "https://anywhere.example.com", false)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("/api/config: Allow-Origin = %q", got)
+	}
+	if w := corsRequest(r, "OPTIONS", "/api/config", "https://anywhere.example.com", true); w.Code != http.StatusForbidden {
+		t.Errorf("/api/config preflight: %d", w.Code)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	r := corsRouter(middleware.CORSPolicy{})
+	w := corsRequest(r, "GET", "/workouts", "", false)
+	want := map[string]string{
+		"Content-Security-Policy":   middleware.FrontendCSP,
+		"Strict-Transport-Security": "max-age=3600; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if w := corsRequest(r, "GET", "/missing", "", false); w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("404 without security headers: %v", w.Header())
+	}
+
+	r = gin.New()
+	r.Use(middleware.SecurityHeaders(0))
+	r.GET("/", func(c *gin.Context) {})
+	if w := corsRequest(r, "GET", "/", "", false); w.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("HSTS sent with a zero max age")
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	errInvalidID = database.Invalid("invalid_id", "invalid id", models.FieldError{
+		Parameter: "id", Code: "invalid_id", Message: "must be a number",
+	})
+	errFileRequired = database.Invalid("file_required", "file is required", models.FieldError{
+		Parameter: "file", Code: "required", Message: "is required",
+	})
+)
+
+// invalidParam reports a malformed query or form parameter.
+func invalidParam(name, message string) error {
+	return database.Invalid("invalid_parameter", "invalid "+name, models.FieldError{
+		Parameter: name, Code: "invalid", Message: message,
+	})
+}
+
+func init() {
+	// Report validation failures by JSON field name rather than Go name.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// bindJSON decodes and validates the request body into obj. On failure it
+// records a validation error listing the offending fields and returns
+// false.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		c.Error(bindError(err))
+		return false
+	}
+	return true
+}
+
+func bindError(err error) error {
+	var verrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &verrs):
+		fields := make([]models.FieldError, len(verrs))
+		for i, fe := range verrs {
+			fields[i] = fieldError(fe)
+		}
+		return database.Invalid("invalid_request", "request body is invalid", fields...)
+	case errors.As(err, &typeErr):
+		return database.Invalid("invalid_request", "request body is invalid", models.FieldError{
+			Pointer: "/" + strings.ReplaceAll(typeErr.Field, ".", "/"),
+			Code:    "invalid_type",
+			Message: "must be a " + typeErr.Type.String(),
+		})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return database.Invalid("malformed_json", "request body is not valid JSON")
+	}
+	return database.Invalid("invalid_request", err.Error())
+}
+
+// fieldError describes one failed binding rule, pointing at the field
+// with a JSON pointer built from the validator's namespace, so that
+// "Request.exercises[0].exercise_id" becomes "/exercises/0/exercise_id".
+func fieldError(fe validator.FieldError) models.FieldError {
+	field := fe.Namespace()
+	if _, rest, ok := strings.Cut(field, "."); ok {
+		field = rest
+	}
+	pointer := "/" + strings.NewReplacer(".", "/", "[", "/", "]", "").Replace(field)
+	var msg string
+	switch fe.Tag() {
+	case "required":
+		msg = "is required"
+	case "email":
+		msg = "must be an email address"
+	case "min":
+		msg = "must be at least " + fe.Param() + units(fe)
+	case "max":
+		msg = "must be at most " + fe.Param() + units(fe)
+	case "gt":
+		msg = "must be greater than " + fe.Param()
+	case "gte":
+		msg = "must be at least " + fe.Param()
+	case "oneof":
+		msg = "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		msg = fmt.Sprintf("fails the %q rule", fe.Tag())
+	}
+	return models.FieldError{Pointer: pointer, Code: fe.Tag(), Message: msg}
+}
+
+// units names what min and max count for strings and lists.
+func units(fe validator.FieldError) string {
+	switch fe.Kind() {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Map:
+		return " items"
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) models.Problem {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json (body %s)", ct, w.Body.String())
+	}
+	var p models.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != w.Code || p.RequestID == "" || p.RequestID != w.Header().Get("X-Request-ID") {
+		t.Errorf("problem = %+v, status %d, X-Request-ID %q", p, w.Code, w.Header().Get("X-Request-ID"))
+	}
+	return p
+}
+
+func TestProblemResponses(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "problems@test.com")
+
+	w := doJSON(r, "PUT", "/workouts/9999", token, map[string]string{"title": "x"})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("update of missing workout: expected 404, got %d", w.Code)
+	}
+	if p := decodeProblem(t, w); p.Code != "workout_not_found" || p.Instance != "/workouts/9999" {
+		t.Errorf("problem = %+v", p)
+	}
+
+	w = doJSON(r, "POST", "/auth/register", "", map[string]string{"email": "not-an-email", "password": "123"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid registration: expected 400, got %d", w.Code)
+	}
+	p := decodeProblem(t, w)
+	fields := map[string]string{}
+	for _, f := range p.Errors {
+		fields[f.Pointer] = f.Code
+	}
+	if p.Code != "invalid_request" || fields["/name"] != "required" || fields["/email"] != "email" || fields["/password"] != "min" {
+		t.Errorf("problem = %+v", p)
+	}
+
+	w = doJSON(r, "POST", "/auth/register", "", map[string]string{"name": "Dup", "email": "problems@test.com", "password": "pass123"})
+	if p := decodeProblem(t, w); w.Code != http.StatusConflict || p.Code != "email_taken" {
+		t.Errorf("duplicate email: %d %+v", w.Code, p)
+	}
+
+	w = doJSON(r, "POST", "/workouts", token, map[string]interface{}{
+		"title": "Ghost", "exercises": []map[string]int{{"exercise_id": 424242, "sets": 1}},
+	})
+	if p := decodeProblem(t, w); w.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Pointer != "/exercises/0/exercise_id" {
+		t.Errorf("unknown exercise: %d %+v", w.Code, p)
+	}
+
+	w = doJSON(r, "GET", "/workouts", "", nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusUnauthorized || p.Code != "missing_token" {
+		t.Errorf("no token: %d %+v", w.Code, p)
+	}
+}
+
+func TestProblemKeepsRequestID(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	req := httptest.NewRequest("GET", "/auth/me", nil)
+	req.Header.Set("X-Request-ID", "trace-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if p := decodeProblem(t, w); p.RequestID != "trace-123" {
+		t.Errorf("request_id = %q, want the one the client sent", p.RequestID)
+	}
+}
+
+func TestInternalErrorsAreNotLeaked(t *testing.T) {
+	r, db := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "leak@test.com")
+	db.Close()
+
+	w := doJSON(r, "GET", "/workouts", token, nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+	if p := decodeProblem(t, w); p.Code != "internal_error" || p.Detail != "internal server error" {
+		t.Errorf("problem = %+v", p)
+	}
+}
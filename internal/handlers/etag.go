@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errPreconditionFailed = middleware.NewError(http.StatusPreconditionFailed, "precondition_failed",
+	"the workout was changed since it was read; fetch it again")
+
+// etag is the entity tag of a workout version.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch returns the workout version named by the If-Match header, or 0
+// when any version will do: no header, or "*". A tag that is not a version
+// of ours gives -1, which matches nothing.
+func ifMatch(c *gin.Context) int {
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	if h == "" || h == "*" {
+		return 0
+	}
+	if v, err := strconv.Atoi(strings.Trim(h, `"`)); err == nil && v > 0 && h == etag(v) {
+		return v
+	}
+	return -1
+}
+
+// conditionalError turns a version mismatch into 412 Precondition Failed
+// when the client made the request conditional. Without If-Match the
+// workout changed between reading and writing it, which stays a 409.
+func conditionalError(c *gin.Context, err error) error {
+	if errors.Is(err, database.ErrVersionMismatch) && c.GetHeader("If-Match") != "" {
+		return errPreconditionFailed
+	}
+	return err
+}
+
+// notModified reports whether the client's If-None-Match already names
+// this version, answering 304 if so.
+func notModified(c *gin.Context, version int) bool {
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			c.Header("ETag", etag(version))
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -8,18 +8,18 @@ import (
 )
 
 type ExerciseHandler struct {
-	db *database.DB
+	db database.Store
 }
 
-func NewExerciseHandler(db *database.DB) *ExerciseHandler {
+func NewExerciseHandler(db database.Store) *ExerciseHandler {
 	return &ExerciseHandler{db: db}
 }
 
 // GET /exercises
 func (h *ExerciseHandler) List(c *gin.Context) {
-	exercises, err := h.db.GetExercises()
+	exercises, err := h.db.GetExercises(c.GetInt64("userID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, exercises)
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/export"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	db database.Store
+}
+
+func NewExportHandler(db database.Store) *ExportHandler {
+	return &ExportHandler{db: db}
+}
+
+// GET /export?format=csv|json|pdf&from=&to=
+//
+// from and to are inclusive dates (YYYY-MM-DD or RFC 3339) in the user's
+// time zone, which is also used for the PDF's weeks and the JSON times. The
+// response is streamed, so an error after the first workout can only be
+// logged.
+func (h *ExportHandler) Export(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	format := c.DefaultQuery("format", "csv")
+	contentType, ext, ok := export.ContentType(format)
+	if !ok {
+		c.Error(invalidParam("format", "must be csv, json or pdf"))
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	from, err := parseDateParam(c.Query("from"), loc)
+	if err != nil {
+		c.Error(invalidParam("from", dateFormats))
+		return
+	}
+	to, err := parseDateParam(c.Query("to"), loc)
+	if err != nil {
+		c.Error(invalidParam("to", dateFormats))
+		return
+	}
+
+	streaming(c)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="workouts-%s.%s"`, time.Now().In(loc).Format("2006-01-02"), ext))
+	c.Status(http.StatusOK)
+
+	w, err := export.New(format, c.Writer)
+	if err != nil {
+		slog.ErrorContext(c, "export", "error", err)
+		return
+	}
+	err = h.db.StreamWorkouts(userID, from, to, func(workout *models.Workout) error {
+		workout.In(loc)
+		if err := w.Write(workout); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		slog.ErrorContext(c, "export failed", "error", err)
+	}
+}
+
+// streaming lifts the server's write timeout for a response that may take
+// longer than a request normally does, such as an export or an event
+// stream.
+func streaming(c *gin.Context) {
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+}
+
+const dateFormats = "must be a date (YYYY-MM-DD) or RFC 3339 time"
+
+// parseDateParam accepts YYYY-MM-DD or RFC 3339 and returns midnight of
+// that date in loc. An empty value means no bound.
+func parseDateParam(s string, loc *time.Location) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			y, m, d := t.Date()
+			day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+			return &day, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid date %q", s)
+}
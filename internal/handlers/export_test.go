@@ -0,0 +1,36 @@
+package handlers_test
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportCSVWithDateRange(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "export@test.com")
+
+	for _, w := range []map[string]interface{}{
+		{"title": "March", "scheduled_at": "2024-03-04T08:00:00Z", "exercises": []map[string]interface{}{{"exercise_id": 1, "sets": 2, "reps": 5, "weight_kg": 60}}},
+		{"title": "April", "scheduled_at": "2024-04-01T08:00:00Z"},
+	} {
+		doJSON(r, "POST", "/workouts", token, w)
+	}
+
+	w := doJSON(r, "GET", "/export?format=csv&from=2024-03-01&to=2024-03-31", token, nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Expected CSV, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][1] != "March" {
+		t.Fatalf("Expected header and two March sets, got %v", rows)
+	}
+
+	if w := doJSON(r, "GET", "/export?format=xml", token, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for unknown format, got %d", w.Code)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GoalHandler serves the user's goals, the body weights that body weight
+// goals track, and the streak and badges the achievements engine awards.
+type GoalHandler struct {
+	db database.Store
+}
+
+func NewGoalHandler(db database.Store) *GoalHandler {
+	return &GoalHandler{db: db}
+}
+
+// POST /goals
+//
+// Body: {"kind": "sessions", "target": 3, "period": "week"}. Kind is
+// sessions or cardio_distance (km), per week or month; lift (kg), with an
+// exercise_id; or bodyweight (kg).
+func (h *GoalHandler) Create(c *gin.Context) {
+	var req models.CreateGoalRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	goal, err := audited(h.db, c).CreateGoal(c.GetInt64("userID"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondGoal(c, http.StatusCreated, goal)
+}
+
+// GET /goals
+//
+// Every goal with its progress; weeks and months are the user's.
+func (h *GoalHandler) List(c *gin.Context) {
+	list, err := h.db.ListGoals(c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range list {
+		list[i].In(loc)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GET /goals/:id
+func (h *GoalHandler) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	goal, err := h.db.GetGoal(id, c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if goal == nil {
+		c.Error(database.ErrGoalNotFound)
+		return
+	}
+	h.respondGoal(c, http.StatusOK, goal)
+}
+
+// DELETE /goals/:id
+func (h *GoalHandler) Delete(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	if err := audited(h.db, c).DeleteGoal(id, c.GetInt64("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+func (h *GoalHandler) respondGoal(c *gin.Context, status int, goal *models.Goal) {
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	goal.In(loc)
+	c.JSON(status, goal)
+}
+
+// POST /bodyweight
+//
+// Body: {"weight_kg": 82.5, "measured_at": "..."}; measured_at defaults
+// to now.
+func (h *GoalHandler) LogBodyWeight(c *gin.Context) {
+	var req models.LogBodyWeightRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	b, err := audited(h.db, c).LogBodyWeight(c.GetInt64("userID"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	b.MeasuredAt = b.MeasuredAt.In(loc)
+	c.JSON(http.StatusCreated, b)
+}
+
+// GET /bodyweight
+//
+// Every body weight logged, latest first.
+func (h *GoalHandler) BodyWeights(c *gin.Context) {
+	list, err := h.db.ListBodyWeights(c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range list {
+		list[i].MeasuredAt = list[i].MeasuredAt.In(loc)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// DELETE /bodyweight/:id
+func (h *GoalHandler) DeleteBodyWeight(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	if err := audited(h.db, c).DeleteBodyWeight(id, c.GetInt64("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// GET /achievements
+//
+// The user's streak, in their time zone and allowing their rest days, and
+// every badge, earned or not. Badges are awarded as workouts complete.
+func (h *GoalHandler) Achievements(c *gin.Context) {
+	a, err := h.db.GetAchievements(c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	a.In(loc)
+	c.JSON(http.StatusOK, a)
+}
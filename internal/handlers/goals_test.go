@@ -0,0 +1,67 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func TestGoals(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "goals@test.com")
+
+	doJSON(r, "POST", "/workouts", token, map[string]interface{}{
+		"title":     "Heavy bench",
+		"exercises": []map[string]interface{}{{"exercise_id": 1, "sets": 1, "reps": 1, "weight_kg": 100}},
+	})
+	if w := doJSON(r, "PUT", "/workouts/1", token, map[string]interface{}{"status": "completed"}); w.Code != http.StatusOK {
+		t.Fatalf("complete: %d %s", w.Code, w.Body.String())
+	}
+	var a models.Achievements
+	w := doJSON(r, "GET", "/achievements", token, nil)
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &a) != nil || a.Streak.Current != 1 {
+		t.Fatalf("achievements: %d %s", w.Code, w.Body.String())
+	}
+	for _, b := range a.Badges {
+		want := b.Code == "first_workout" || b.Code == "bench_100"
+		if b.Earned != want || (want && (b.WorkoutID == nil || *b.WorkoutID != 1)) {
+			t.Errorf("badge %+v", b)
+		}
+	}
+
+	if w := doJSON(r, "PATCH", "/auth/me", token, map[string]interface{}{}); w.Code != http.StatusBadRequest {
+		t.Errorf("no settings: %d", w.Code)
+	}
+	if w := doJSON(r, "PATCH", "/auth/me", token, map[string]interface{}{"rest_days": 9}); w.Code != http.StatusBadRequest {
+		t.Errorf("rest_days out of range: %d", w.Code)
+	}
+	var user models.User
+	w = doJSON(r, "PATCH", "/auth/me", token, map[string]interface{}{"rest_days": 0})
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &user) != nil || user.RestDays != 0 || user.TimeZone != "UTC" {
+		t.Errorf("rest_days: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := doJSON(r, "POST", "/goals", token, map[string]interface{}{"kind": "lift", "target": 120}); w.Code != http.StatusBadRequest {
+		t.Errorf("lift without an exercise: %d", w.Code)
+	}
+	var goal models.Goal
+	w = doJSON(r, "POST", "/goals", token, map[string]interface{}{"kind": "lift", "target": 120, "exercise_id": 1})
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &goal) != nil || goal.Progress.Current != 100 {
+		t.Fatalf("lift goal: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/bodyweight", token, map[string]interface{}{"weight_kg": 80}); w.Code != http.StatusCreated {
+		t.Errorf("body weight: %d %s", w.Code, w.Body.String())
+	}
+	var goals []models.Goal
+	if w := doJSON(r, "GET", "/goals", token, nil); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &goals) != nil || len(goals) != 1 {
+		t.Errorf("goals: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "DELETE", "/goals/1", token, nil); w.Code != http.StatusOK {
+		t.Errorf("delete: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, "GET", "/goals/1", token, nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusNotFound || p.Code != "goal_not_found" {
+		t.Errorf("deleted goal: %d %+v", w.Code, p)
+	}
+}
@@ -7,8 +7,13 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
+	"workout-tracker/internal/auth"
+	"workout-tracker/internal/config"
 	"workout-tracker/internal/database"
 	"workout-tracker/internal/handlers"
+	"workout-tracker/internal/live"
+	"workout-tracker/internal/metrics"
 	"workout-tracker/internal/middleware"
 	"workout-tracker/internal/seeder"
 
@@ -27,23 +32,116 @@ func setupTestRouter(t *testing.T) (*gin.Engine, *database.DB) {
 	}
 	seeder.SeedExercises(db)
 
+	store := database.Instrument(db, metrics.ObserveQuery)
 	r := gin.New()
-	authH := handlers.NewAuthHandler(db)
-	exH := handlers.NewExerciseHandler(db)
-	workH := handlers.NewWorkoutHandler(db)
+	r.Use(middleware.RequestID(), middleware.Logger(), middleware.Recovery(), middleware.Errors())
+	tokens := auth.NewTokens("test-secret-that-is-at-least-32-bytes", time.Hour)
+	authed := middleware.AuthRequired(tokens)
+	authH := handlers.NewAuthHandler(store, tokens)
+	exH := handlers.NewExerciseHandler(store)
+	workH := handlers.NewWorkoutHandler(store)
 
 	r.POST("/auth/register", authH.Register)
 	r.POST("/auth/login", authH.Login)
-	r.GET("/auth/me", middleware.AuthRequired(), authH.Me)
+	r.GET("/auth/me", authed, authH.Me)
+	r.PATCH("/auth/me", authed, authH.UpdateMe)
 	r.GET("/exercises", exH.List)
 
-	protected := r.Group("/workouts", middleware.AuthRequired())
+	idempotent := middleware.Idempotency(store, time.Hour)
+	protected := r.Group("/workouts", authed, idempotent)
 	protected.POST("", workH.Create)
 	protected.GET("", workH.List)
 	protected.GET("/report", workH.Report)
+	protected.GET("/trash", workH.Trash)
 	protected.GET("/:id", workH.Get)
 	protected.PUT("/:id", workH.Update)
+	protected.PATCH("/:id", workH.Patch)
 	protected.DELETE("/:id", workH.Delete)
+	protected.POST("/:id/restore", workH.Restore)
+
+	sessH := handlers.NewSessionHandler(store, live.NewHub())
+	protected.POST("/:id/session", sessH.Start)
+	protected.GET("/:id/session", sessH.Get)
+	protected.POST("/:id/session/sets", sessH.LogSet)
+	protected.POST("/:id/session/finish", sessH.Finish)
+	r.GET("/workouts/:id/session/events", middleware.StreamAuthRequired(tokens), sessH.Events)
+
+	socialH := handlers.NewSocialHandler(store)
+	protected.POST("/:id/share", socialH.Share)
+	protected.DELETE("/:id/share", socialH.Unshare)
+	r.POST("/users/:id/follow", authed, idempotent, socialH.Follow)
+	r.DELETE("/users/:id/follow", authed, idempotent, socialH.Unfollow)
+	r.GET("/following", authed, socialH.Following)
+	r.GET("/followers", authed, socialH.Followers)
+	feed := r.Group("/feed", authed, idempotent)
+	feed.GET("", socialH.Feed)
+	feed.GET("/:id", socialH.Item)
+	feed.POST("/:id/reactions", socialH.React)
+	feed.DELETE("/:id/reactions/:kind", socialH.Unreact)
+	feed.GET("/:id/comments", socialH.Comments)
+	feed.POST("/:id/comments", socialH.AddComment)
+	feed.DELETE("/:id/comments/:comment_id", socialH.DeleteComment)
+	r.GET("/share/:token", socialH.Shared)
+
+	coachingH := handlers.NewCoachingHandler(store)
+	athletes := r.Group("/athletes", authed, idempotent)
+	athletes.POST("", coachingH.Invite)
+	athletes.GET("", coachingH.Athletes)
+	athletes.DELETE("/:id", coachingH.Drop)
+	athletes.GET("/:id/workouts", coachingH.Workouts)
+	athletes.POST("/:id/workouts", coachingH.Assign)
+	athletes.GET("/:id/workouts/:workout_id", coachingH.Workout)
+	athletes.PUT("/:id/workouts/:workout_id", coachingH.Update)
+	athletes.GET("/:id/report", coachingH.Report)
+	coaches := r.Group("/coaches", authed, idempotent)
+	coaches.GET("", coachingH.Coaches)
+	coaches.POST("/:id/accept", coachingH.Accept)
+	coaches.PUT("/:id/scopes", coachingH.SetScopes)
+	coaches.DELETE("/:id", coachingH.Revoke)
+	teamH := handlers.NewTeamHandler(store)
+	teams := r.Group("/teams", authed, idempotent)
+	teams.POST("", teamH.Create)
+	teams.GET("", teamH.List)
+	teams.POST("/join", teamH.Join)
+	teams.GET("/:id", teamH.Get)
+	teams.DELETE("/:id", teamH.Delete)
+	teams.DELETE("/:id/members/:user_id", teamH.RemoveMember)
+	teams.GET("/:id/leaderboard", teamH.Leaderboard)
+	teams.POST("/:id/challenges", teamH.CreateChallenge)
+	teams.GET("/:id/challenges", teamH.ListChallenges)
+	teams.GET("/:id/challenges/:challenge_id", teamH.GetChallenge)
+	teams.DELETE("/:id/challenges/:challenge_id", teamH.DeleteChallenge)
+	goalH := handlers.NewGoalHandler(store)
+	goals := r.Group("/goals", authed, idempotent)
+	goals.POST("", goalH.Create)
+	goals.GET("", goalH.List)
+	goals.GET("/:id", goalH.Get)
+	goals.DELETE("/:id", goalH.Delete)
+	bodyWeight := r.Group("/bodyweight", authed, idempotent)
+	bodyWeight.POST("", goalH.LogBodyWeight)
+	bodyWeight.GET("", goalH.BodyWeights)
+	bodyWeight.DELETE("/:id", goalH.DeleteBodyWeight)
+	r.GET("/achievements", authed, goalH.Achievements)
+
+	r.POST("/import", authed, idempotent, handlers.NewImportHandler(store).Import)
+	r.GET("/export", authed, handlers.NewExportHandler(store).Export)
+	r.POST("/import/activity", authed, idempotent, handlers.NewActivityHandler(store).Import)
+
+	syncH := handlers.NewSyncHandler(store)
+	r.GET("/sync", authed, syncH.Pull)
+	r.POST("/sync", authed, idempotent, syncH.Push)
+
+	healthH := handlers.NewHealthHandler(store)
+	r.GET("/healthz", healthH.Live)
+	r.GET("/readyz", healthH.Ready)
+	aiH := handlers.NewAIHandler(config.Default().AI)
+	r.GET("/api/config", authed, aiH.Config)
+	r.POST("/api/ai/generations", authed, aiH.RecordGeneration)
+	r.GET("/metrics", middleware.MetricsToken("metrics-token"), gin.WrapH(metrics.Default.Handler()))
+
+	auditH := handlers.NewAuditHandler(store)
+	r.GET("/audit", authed, auditH.List)
+	r.GET("/admin/audit/export", authed, middleware.AdminRequired([]string{"admin@test.com"}), auditH.Export)
 
 	return r, db
 }
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout bounds the database checks of a readiness probe.
+const readyTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	db       database.Store
+	draining atomic.Bool
+}
+
+func NewHealthHandler(db database.Store) *HealthHandler {
+	return &HealthHandler{db: db}
+}
+
+// Drain makes the server report itself not ready from now on, so load
+// balancers stop sending it requests while it shuts down.
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// GET /healthz
+//
+// Liveness: the process is up and serving requests.
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GET /readyz
+//
+// Readiness: the database answers and is migrated, and the server is not
+// shutting down.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	if h.draining.Load() {
+		c.Error(middleware.NewError(http.StatusServiceUnavailable, "shutting_down", "server is shutting down"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+	if err := h.db.Ready(ctx); err != nil {
+		slog.WarnContext(c, "not ready", "error", err)
+		c.Error(middleware.NewError(http.StatusServiceUnavailable, "database_unavailable", "database is not ready"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
@@ -0,0 +1,43 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"workout-tracker/internal/handlers"
+	"workout-tracker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealth(t *testing.T) {
+	_, db := setupTestRouter(t)
+	h := handlers.NewHealthHandler(db)
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Errors())
+	r.GET("/healthz", h.Live)
+	r.GET("/readyz", h.Ready)
+
+	if w := doJSON(r, "GET", "/healthz", "", nil); w.Code != http.StatusOK {
+		t.Errorf("healthz: %d", w.Code)
+	}
+	if w := doJSON(r, "GET", "/readyz", "", nil); w.Code != http.StatusOK {
+		t.Errorf("readyz: %d %s", w.Code, w.Body.String())
+	}
+
+	h.Drain()
+	if w := doJSON(r, "GET", "/readyz", "", nil); w.Code != http.StatusServiceUnavailable || decodeProblem(t, w).Code != "shutting_down" {
+		t.Errorf("readyz while draining: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", "/healthz", "", nil); w.Code != http.StatusOK {
+		t.Errorf("healthz while draining: %d", w.Code)
+	}
+
+	db.Close()
+	h = handlers.NewHealthHandler(db)
+	r = gin.New()
+	r.Use(middleware.RequestID(), middleware.Errors())
+	r.GET("/readyz", h.Ready)
+	if w := doJSON(r, "GET", "/readyz", "", nil); w.Code != http.StatusServiceUnavailable || decodeProblem(t, w).Code != "database_unavailable" {
+		t.Errorf("readyz with the database closed: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,52 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "idem@test.com")
+	key := map[string]string{"Idempotency-Key": "save-plan-day-1"}
+	body := `{"title":"Day 1: Push","exercises":[{"exercise_id":1,"sets":3,"reps":8}]}`
+
+	first := doRaw(r, "POST", "/workouts", token, "application/json", body, key)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", first.Code, first.Body.String())
+	}
+	retry := doRaw(r, "POST", "/workouts", token, "application/json", body, key)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() ||
+		retry.Header().Get("Idempotent-Replayed") != "true" || retry.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Errorf("retry: %d %v %s", retry.Code, retry.Header(), retry.Body.String())
+	}
+	var list []models.Workout
+	json.Unmarshal(doJSON(r, "GET", "/workouts", token, nil).Body.Bytes(), &list)
+	if len(list) != 1 {
+		t.Fatalf("%d workouts after a retried create, want 1", len(list))
+	}
+
+	w := doRaw(r, "POST", "/workouts", token, "application/json", `{"title":"Day 2: Pull"}`, key)
+	if p := decodeProblem(t, w); w.Code != http.StatusUnprocessableEntity || p.Code != "idempotency_key_reused" {
+		t.Errorf("reused key: %d %+v", w.Code, p)
+	}
+
+	// Keys belong to users, and failed requests are stored too.
+	other := registerAndGetToken(t, r, "idem2@test.com")
+	if w := doRaw(r, "POST", "/workouts", other, "application/json", body, key); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("same key, other user: %d %v", w.Code, w.Header())
+	}
+	bad := map[string]string{"Idempotency-Key": "bad"}
+	doRaw(r, "POST", "/workouts", token, "application/json", `{"title":""}`, bad)
+	if w := doRaw(r, "POST", "/workouts", token, "application/json", `{"title":""}`, bad); w.Code != http.StatusBadRequest ||
+		w.Header().Get("Idempotent-Replayed") != "true" || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("replayed error: %d %v", w.Code, w.Header())
+	}
+
+	// Reads ignore the header.
+	if w := doRaw(r, "GET", "/workouts", token, "", "", key); w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("GET with a key: %d %v", w.Code, w.Header())
+	}
+}
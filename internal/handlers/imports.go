@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/importer"
+	"workout-tracker/internal/middleware"
+	"workout-tracker/internal/models"
+	"workout-tracker/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxImportUpload = 50 << 20
+
+type ImportHandler struct {
+	db database.Store
+}
+
+func NewImportHandler(db database.Store) *ImportHandler {
+	return &ImportHandler{db: db}
+}
+
+// POST /import?dry_run=true
+//
+// Accepts a Strong, Hevy or FitNotes CSV export in multipart field "file".
+// Times in the file are read in the user's time zone. Workouts already
+// imported from the same export are skipped, so uploading a file twice
+// creates nothing the second time. Workouts that break the validation
+// rules are left out and their violations listed. A dry run reports what
+// would be created without writing anything.
+func (h *ImportHandler) Import(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	dryRun := c.Query("dry_run") == "true" || c.Query("dry_run") == "1"
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportUpload)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.Error(errFileRequired)
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer f.Close()
+
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	parsed, err := importer.Parse(f, loc)
+	if err != nil {
+		c.Error(middleware.NewError(http.StatusUnprocessableEntity, "unreadable_file", err.Error()))
+		return
+	}
+
+	report := &models.ImportReport{
+		Format:        string(parsed.Format),
+		DryRun:        dryRun,
+		WorkoutsFound: len(parsed.Workouts),
+		Exercises:     []models.ExerciseMapping{},
+		Warnings:      parsed.Warnings,
+		Errors:        []models.FieldError{},
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+
+	db := audited(h.db, c)
+	exerciseIDs, lib, err := mapExercises(db, userID, parsed, report)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	source := "import:" + string(parsed.Format)
+	imported, err := h.db.ImportedExternalIDs(userID, source)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	for i, w := range parsed.Workouts {
+		report.SetsFound += len(w.Sets)
+		if imported[w.ExternalID] {
+			report.WorkoutsSkipped++
+			continue
+		}
+
+		started := w.StartedAt
+		req := models.CreateWorkoutRequest{
+			Title:       w.Title,
+			Description: w.Notes,
+			ScheduledAt: &started,
+			Source:      source,
+			ExternalID:  w.ExternalID,
+		}
+		if req.Title == "" {
+			req.Title = "Imported workout"
+		}
+		for _, b := range importer.Collapse(w.Sets) {
+			req.Exercises = append(req.Exercises, models.WorkoutExerciseRequest{
+				ExerciseID:  exerciseIDs[b.Exercise],
+				Sets:        b.Sets,
+				Reps:        b.Reps,
+				WeightKg:    b.WeightKg,
+				DurationSec: b.DurationSec,
+				DistanceM:   b.DistanceM,
+				Notes:       b.Notes,
+			})
+		}
+		if errs := validation.CreateWorkout(&req, lib); len(errs) > 0 {
+			for _, e := range errs {
+				e.Pointer = fmt.Sprintf("/workouts/%d%s", i, e.Pointer)
+				report.Errors = append(report.Errors, e)
+			}
+			report.WorkoutsInvalid++
+			continue
+		}
+		if dryRun {
+			report.WorkoutsCreated++
+			continue
+		}
+
+		if err := createExercises(db, userID, &req, lib, report); err != nil {
+			c.Error(err)
+			return
+		}
+		completedAt := w.StartedAt.Add(time.Duration(w.DurationSec) * time.Second)
+		_, err := db.CreateCompletedWorkout(userID, req, completedAt)
+		if errors.Is(err, database.ErrAlreadyImported) {
+			// A concurrent or retried upload of the same file got there first.
+			report.WorkoutsSkipped++
+			continue
+		}
+		if err != nil {
+			c.Error(fmt.Errorf("importing %q: %w", w.Title, err))
+			return
+		}
+		imported[w.ExternalID] = true
+		report.WorkoutsCreated++
+	}
+
+	status := http.StatusOK
+	if report.WorkoutsCreated > 0 && !dryRun {
+		status = http.StatusCreated
+	}
+	c.JSON(status, report)
+}
+
+// mapExercises resolves every exercise name in the export. A name with no
+// match gets a custom exercise guessed from its sets, which stands in with
+// a negative ID until createExercises stores it. It also returns the
+// library to validate the workouts against.
+func mapExercises(db database.Store, userID int64, parsed *importer.Result, report *models.ImportReport) (map[string]int64, validation.Library, error) {
+	library, err := db.GetExercises(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	setsByName := map[string][]importer.Set{}
+	var names []string
+	for _, w := range parsed.Workouts {
+		for _, s := range w.Sets {
+			if _, ok := setsByName[s.Exercise]; !ok {
+				names = append(names, s.Exercise)
+			}
+			setsByName[s.Exercise] = append(setsByName[s.Exercise], s)
+		}
+	}
+
+	ids := map[string]int64{}
+	for _, name := range names {
+		if e := importer.MatchExercise(name, library); e != nil {
+			ids[name] = e.ID
+			report.Exercises = append(report.Exercises, models.ExerciseMapping{SourceName: name, ExerciseID: e.ID, ExerciseName: e.Name})
+			continue
+		}
+		sets := setsByName[name]
+		// The stand-in ID locates the mapping: -1 is the first entry.
+		e := models.Exercise{ID: -int64(len(report.Exercises) + 1), Name: name,
+			Category: importer.GuessCategory(sets), MuscleGroup: importer.GuessMuscleGroup(sets)}
+		library = append(library, e)
+		ids[name] = e.ID
+		report.Exercises = append(report.Exercises, models.ExerciseMapping{SourceName: name, ExerciseName: name, Created: report.DryRun})
+	}
+	return ids, validation.NewLibrary(library), nil
+}
+
+// createExercises stores the new exercises req is the first imported
+// workout to use, and points req at them, so that an exercise is only
+// created for a workout that is imported.
+func createExercises(db database.Store, userID int64, req *models.CreateWorkoutRequest, lib validation.Library, report *models.ImportReport) error {
+	for i := range req.Exercises {
+		id := req.Exercises[i].ExerciseID
+		if id >= 0 {
+			continue
+		}
+		m := &report.Exercises[-id-1]
+		if !m.Created {
+			e := lib[id]
+			created, err := db.CreateCustomExercise(userID, e.Name, e.Category, e.MuscleGroup, "")
+			if err != nil {
+				return err
+			}
+			m.ExerciseID, m.Created = created.ID, true
+		}
+		req.Exercises[i].ExerciseID = m.ExerciseID
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const strongExport = `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE
+2024-03-01 18:00:00,Push,1h,Bench Press (Barbell),1,80,5,0,0,,,
+2024-03-01 18:00:00,Push,1h,Bench Press (Barbell),2,80,5,0,0,,,
+2024-03-01 18:00:00,Push,1h,Landmine Press,1,30,10,0,0,,,
+`
+
+func uploadCSV(r *gin.Engine, path, token, content string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "export.csv")
+	fw.Write([]byte(content))
+	mw.Close()
+	req, _ := http.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportCSVIdempotent(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "importer@test.com")
+
+	type report struct {
+		Format          string `json:"format"`
+		WorkoutsCreated int    `json:"workouts_created"`
+		WorkoutsSkipped int    `json:"workouts_skipped"`
+		Exercises       []struct {
+			SourceName string `json:"source_name"`
+			Created    bool   `json:"created"`
+		} `json:"exercises"`
+	}
+
+	w := uploadCSV(r, "/import?dry_run=true", token, strongExport)
+	var dry report
+	json.Unmarshal(w.Body.Bytes(), &dry)
+	if w.Code != http.StatusOK || dry.Format != "strong" || dry.WorkoutsCreated != 1 || len(dry.Exercises) != 2 || !dry.Exercises[1].Created {
+		t.Fatalf("Unexpected dry run: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", "/workouts", token, nil); w.Body.String() != "[]" {
+		t.Fatalf("Dry run must not write, got %s", w.Body.String())
+	}
+
+	w = uploadCSV(r, "/import", token, strongExport)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Import expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = uploadCSV(r, "/import", token, strongExport)
+	var second report
+	json.Unmarshal(w.Body.Bytes(), &second)
+	if second.WorkoutsCreated != 0 || second.WorkoutsSkipped != 1 || second.Exercises[1].Created {
+		t.Fatalf("Re-import must be a no-op, got %s", w.Body.String())
+	}
+
+	var workouts []struct {
+		Status    string `json:"status"`
+		Exercises []struct {
+			Sets int `json:"sets"`
+		} `json:"exercises"`
+	}
+	json.Unmarshal(doJSON(r, "GET", "/workouts", token, nil).Body.Bytes(), &workouts)
+	if len(workouts) != 1 || workouts[0].Status != "completed" || len(workouts[0].Exercises) != 2 || workouts[0].Exercises[0].Sets != 2 {
+		t.Fatalf("Unexpected imported workouts: %+v", workouts)
+	}
+}
+
+func TestImportCreatesExercisesOnlyForImportedWorkouts(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "invalid-import@test.com")
+
+	const export = `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE
+2024-03-01 18:00:00,Push,1h,Bench Press (Barbell),1,80,5,0,0,,,
+2024-03-02 18:00:00,Odd,1h,Landmine Press,1,30,5000,0,0,,,
+`
+	w := uploadCSV(r, "/import", token, export)
+	var report models.ImportReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if w.Code != http.StatusCreated || report.WorkoutsCreated != 1 || report.WorkoutsInvalid != 1 ||
+		len(report.Exercises) != 2 || report.Exercises[1].Created || report.Exercises[1].ExerciseID != 0 {
+		t.Fatalf("import: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", "/exercises", token, nil); strings.Contains(w.Body.String(), "Landmine Press") {
+		t.Errorf("exercise of an invalid workout was created: %s", w.Body.String())
+	}
+}
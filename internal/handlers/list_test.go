@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type workoutPage struct {
+	Workouts []struct {
+		Title string `json:"title"`
+	} `json:"workouts"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor"`
+}
+
+func TestListWorkoutsPagination(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "pages@test.com")
+
+	for day := 1; day <= 5; day++ {
+		body := map[string]interface{}{
+			"title":        fmt.Sprintf("Day %d", day),
+			"scheduled_at": fmt.Sprintf("2024-03-0%dT08:00:00Z", day),
+		}
+		if day%2 == 0 {
+			body["description"] = "legs and core"
+			body["exercises"] = []map[string]interface{}{{"exercise_id": 9, "sets": 3, "reps": 5}}
+		}
+		doJSON(r, "POST", "/workouts", token, body)
+	}
+
+	var titles []string
+	path := "/workouts?limit=2&sort=-date"
+	for pages := 0; path != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("Pagination did not terminate")
+		}
+		w := doJSON(r, "GET", path, token, nil)
+		if w.Code != http.StatusOK || w.Header().Get("X-Total-Count") != "5" {
+			t.Fatalf("Expected 200 with total 5, got %d %q", w.Code, w.Header().Get("X-Total-Count"))
+		}
+		var page workoutPage
+		json.Unmarshal(w.Body.Bytes(), &page)
+		for _, wk := range page.Workouts {
+			titles = append(titles, wk.Title)
+		}
+		path = ""
+		if page.NextCursor != "" {
+			path = "/workouts?limit=2&sort=-date&cursor=" + url.QueryEscape(page.NextCursor)
+		}
+	}
+	if fmt.Sprint(titles) != "[Day 5 Day 4 Day 3 Day 2 Day 1]" {
+		t.Fatalf("Unexpected order across pages: %v", titles)
+	}
+
+	cases := map[string]int{
+		"/workouts?from=2024-03-02&to=2024-03-04": 3,
+		"/workouts?q=CORE":                        2,
+		"/workouts?exercise_id=9&from=2024-03-03": 1,
+	}
+	for path, want := range cases {
+		var list []json.RawMessage
+		json.Unmarshal(doJSON(r, "GET", path, token, nil).Body.Bytes(), &list)
+		if len(list) != want {
+			t.Errorf("%s: got %d workouts, want %d", path, len(list), want)
+		}
+	}
+
+	if w := doJSON(r, "GET", "/workouts?sort=weight", token, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for unknown sort, got %d", w.Code)
+	}
+	if w := doJSON(r, "GET", "/workouts/report?from=2024-03-04", token, nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected filtered report, got %d", w.Code)
+	} else {
+		var report struct {
+			TotalWorkouts int `json:"total_workouts"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &report)
+		if report.TotalWorkouts != 2 {
+			t.Fatalf("Expected 2 workouts in filtered report, got %d", report.TotalWorkouts)
+		}
+	}
+}
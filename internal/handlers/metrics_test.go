@@ -0,0 +1,69 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"workout-tracker/internal/metrics"
+	"workout-tracker/internal/models"
+)
+
+func TestMetrics(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "metrics@test.com")
+
+	created := metrics.WorkoutsCreated.Value("app")
+	completed := metrics.WorkoutsCompleted.Value()
+	requests := metrics.HTTPRequests.Value("POST", "/workouts", "201")
+	queries := metrics.DBDuration.Count("CreateWorkout")
+
+	w := doJSON(r, "POST", "/workouts", token, map[string]interface{}{"title": "Legs"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	var workout models.Workout
+	json.Unmarshal(w.Body.Bytes(), &workout)
+	doJSON(r, "PUT", "/workouts/"+itoa(int(workout.ID)), token, map[string]interface{}{"status": "completed"})
+	// Imported activities are created completed and count too.
+	if w := importActivity(r, token, nil); w.Code != http.StatusCreated {
+		t.Fatalf("import: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/api/ai/generations", token, map[string]string{"result": "ok"}); w.Code != http.StatusNoContent {
+		t.Errorf("AI generation: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/api/ai/generations", token, map[string]string{"result": "maybe"}); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown AI result: %d", w.Code)
+	}
+
+	if got := metrics.WorkoutsCreated.Value("app") - created; got != 1 {
+		t.Errorf("workouts created: %v", got)
+	}
+	if got := metrics.WorkoutsCompleted.Value() - completed; got != 2 {
+		t.Errorf("workouts completed: %v", got)
+	}
+	if got := metrics.HTTPRequests.Value("POST", "/workouts", "201") - requests; got != 1 {
+		t.Errorf("requests counted: %v", got)
+	}
+	if got := metrics.DBDuration.Count("CreateWorkout") - queries; got != 1 {
+		t.Errorf("CreateWorkout timed %d times", got)
+	}
+
+	if w := doJSON(r, "GET", "/metrics", token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("metrics with a user token: %d", w.Code)
+	}
+	w = doJSON(r, "GET", "/metrics", "metrics-token", nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("metrics: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`http_requests_total{method="POST",route="/workouts",status="201"}`,
+		`db_query_duration_seconds_bucket{op="GetUserByID",le="+Inf"}`,
+		`workouts_created_total{source="app"}`,
+		`ai_generations_total{result="ok"}`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
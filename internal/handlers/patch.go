@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+	"workout-tracker/internal/patch"
+)
+
+// applyWorkoutPatch applies a JSON Merge Patch or, for contentType
+// application/json-patch+json, a JSON Patch to the editable state of a
+// workout. The result must again be a valid workout document: read-only
+// fields such as id or version cannot be added.
+func applyWorkoutPatch(contentType string, body []byte, s models.WorkoutState) (models.WorkoutState, error) {
+	var doc interface{}
+	b, _ := json.Marshal(s)
+	json.Unmarshal(b, &doc)
+
+	switch contentType {
+	case patch.JSONPatchType:
+		var ops []patch.Operation
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return s, database.Invalid("malformed_json", "request body is not a JSON Patch array")
+		}
+		var err error
+		if doc, err = patch.Apply(doc, ops); err != nil {
+			var perr *patch.Error
+			if errors.As(err, &perr) {
+				return s, database.Invalid("invalid_patch", "patch cannot be applied", models.FieldError{
+					Pointer: perr.Pointer, Code: "invalid_patch", Message: perr.Message,
+				})
+			}
+			return s, err
+		}
+	default:
+		var p interface{}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return s, database.Invalid("malformed_json", "request body is not valid JSON")
+		}
+		if _, ok := p.(map[string]interface{}); !ok {
+			return s, database.Invalid("invalid_patch", "a merge patch must be a JSON object")
+		}
+		doc = patch.Merge(doc, p)
+	}
+
+	b, _ = json.Marshal(doc)
+	var patched models.WorkoutState
+	if err := decodeFields(b, &patched, "invalid_patch", "patch sets a field that cannot be changed"); err != nil {
+		return s, err
+	}
+	return patched, nil
+}
+
+// decodeFields decodes a patched or merged document into v. Fields v does
+// not have, such as id or version, cannot be written and are reported as
+// read_only under code.
+func decodeFields(b []byte, v interface{}, code, message string) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return database.Invalid(code, message, models.FieldError{
+				Pointer: "/" + strings.Trim(name, `"`), Code: "read_only", Message: "cannot be changed",
+			})
+		}
+		return bindError(err)
+	}
+	return nil
+}
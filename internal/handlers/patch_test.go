@@ -0,0 +1,116 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func doRaw(r *gin.Engine, method, path, token, contentType, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestWorkoutETags(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "etag@test.com")
+
+	w := doJSON(r, "POST", "/workouts", token, map[string]interface{}{"title": "Push"})
+	var created models.Workout
+	json.Unmarshal(w.Body.Bytes(), &created)
+	path := "/workouts/" + itoa(int(created.ID))
+	if created.Version != 1 || w.Header().Get("ETag") != `"1"` {
+		t.Fatalf("create: version %d, ETag %q", created.Version, w.Header().Get("ETag"))
+	}
+
+	if w := doRaw(r, "GET", path, token, "", "", map[string]string{"If-None-Match": `"1"`}); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match with current ETag: %d", w.Code)
+	}
+
+	w = doRaw(r, "PUT", path, token, "application/json", `{"title":"Push A"}`, map[string]string{"If-Match": `"1"`})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("update with current ETag: %d %q %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+	w = doRaw(r, "PUT", path, token, "application/json", `{"title":"Lost update"}`, map[string]string{"If-Match": `"1"`})
+	if p := decodeProblem(t, w); w.Code != http.StatusPreconditionFailed || p.Code != "precondition_failed" {
+		t.Errorf("update with stale ETag: %d %+v", w.Code, p)
+	}
+	if w := doRaw(r, "DELETE", path, token, "", "", map[string]string{"If-Match": `"1"`}); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("delete with stale ETag: %d", w.Code)
+	}
+	if w := doRaw(r, "DELETE", path, token, "", "", map[string]string{"If-Match": `"2"`}); w.Code != http.StatusOK {
+		t.Errorf("delete with current ETag: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPatchWorkout(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "patch@test.com")
+
+	w := doJSON(r, "POST", "/workouts", token, map[string]interface{}{
+		"title":       "Upper",
+		"description": "heavy day",
+		"exercises": []map[string]interface{}{
+			{"exercise_id": 1, "sets": 3, "reps": 5},
+			{"exercise_id": 2, "sets": 3, "reps": 12},
+		},
+	})
+	var created models.Workout
+	json.Unmarshal(w.Body.Bytes(), &created)
+	path := "/workouts/" + itoa(int(created.ID))
+	first := created.Exercises[0].ID
+
+	w = doRaw(r, "PATCH", path, token, "application/merge-patch+json", `{"title":"Upper A","description":null}`, nil)
+	var patched models.Workout
+	json.Unmarshal(w.Body.Bytes(), &patched)
+	if w.Code != http.StatusOK || patched.Title != "Upper A" || patched.Description != "" || len(patched.Exercises) != 2 || patched.Version != 2 {
+		t.Fatalf("merge patch: %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRaw(r, "PATCH", path, token, "application/json-patch+json", `[
+		{"op":"test","path":"/exercises/0/reps","value":5},
+		{"op":"replace","path":"/exercises/0/reps","value":6},
+		{"op":"remove","path":"/exercises/1"},
+		{"op":"add","path":"/exercises/-","value":{"exercise_id":3,"sets":4,"reps":10}}
+	]`, map[string]string{"If-Match": `"2"`})
+	patched = models.Workout{}
+	json.Unmarshal(w.Body.Bytes(), &patched)
+	if w.Code != http.StatusOK || len(patched.Exercises) != 2 || patched.Exercises[0].ID != first ||
+		patched.Exercises[0].Reps != 6 || patched.Exercises[1].ExerciseID != 3 {
+		t.Fatalf("json patch: %d %s", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		name, contentType, body string
+		status                  int
+		pointer                 string
+	}{
+		{"read-only field", "application/merge-patch+json", `{"version":9}`, http.StatusBadRequest, "/version"},
+		{"failed test", "application/json-patch+json", `[{"op":"test","path":"/title","value":"Lower"}]`, http.StatusBadRequest, "/0"},
+		{"invalid result", "application/json-patch+json", `[{"op":"replace","path":"/exercises/1/sets","value":500}]`, http.StatusBadRequest, "/exercises/1/sets"},
+		{"not an object", "application/merge-patch+json", `[1]`, http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		w := doRaw(r, "PATCH", path, token, tc.contentType, tc.body, nil)
+		p := decodeProblem(t, w)
+		if w.Code != tc.status || (tc.pointer != "" && (len(p.Errors) == 0 || p.Errors[0].Pointer != tc.pointer)) {
+			t.Errorf("%s: %d %+v", tc.name, w.Code, p)
+		}
+	}
+
+	if w := doRaw(r, "PATCH", path, token, "application/merge-patch+json", `{"title":"x"}`, map[string]string{"If-Match": `"1"`}); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("patch with stale ETag: %d", w.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"workout-tracker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Errors())
+	r.GET("/limited", middleware.RateLimit(1, 2), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	from := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/limited", nil)
+		req.RemoteAddr = ip + ":40000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := from("203.0.113.1"); w.Code != http.StatusNoContent {
+			t.Fatalf("request %d within the burst: %d", i+1, w.Code)
+		}
+	}
+	w := from("203.0.113.1")
+	if w.Code != http.StatusTooManyRequests || decodeProblem(t, w).Code != "rate_limited" {
+		t.Fatalf("over the limit: %d %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	if w := from("203.0.113.2"); w.Code != http.StatusNoContent {
+		t.Errorf("another client: %d", w.Code)
+	}
+
+	r = gin.New()
+	r.GET("/open", middleware.RateLimit(0, 0), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	for i := 0; i < 5; i++ {
+		if w := doRaw(r, "GET", "/open", "", "", "", nil); w.Code != http.StatusNoContent {
+			t.Fatalf("without a limit: %d", w.Code)
+		}
+	}
+}
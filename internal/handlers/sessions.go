@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/live"
+	"workout-tracker/internal/models"
+	"workout-tracker/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+const keepAliveInterval = 15 * time.Second
+
+type SessionHandler struct {
+	db  database.Store
+	hub *live.Hub
+}
+
+func NewSessionHandler(db database.Store, hub *live.Hub) *SessionHandler {
+	return &SessionHandler{db: db, hub: hub}
+}
+
+// Resume reschedules the rest timers of sessions that were active when the
+// server stopped.
+func (h *SessionHandler) Resume() error {
+	sessions, err := h.db.ListActiveSessions()
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		h.hub.ResumeRest(s)
+	}
+	if len(sessions) > 0 {
+		slog.Info("resumed active workout sessions", "count", len(sessions))
+	}
+	return nil
+}
+
+// POST /workouts/:id/session
+func (h *SessionHandler) Start(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	session, err := audited(h.db, c).StartSession(id, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.hub.Publish(userID, models.SessionEvent{Type: "session", WorkoutID: id, Data: session})
+	c.JSON(http.StatusCreated, session)
+}
+
+// GET /workouts/:id/session
+func (h *SessionHandler) Get(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	session, err := h.db.GetActiveSession(id, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if session == nil {
+		c.Error(database.ErrSessionNotFound)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// POST /workouts/:id/session/sets
+func (h *SessionHandler) LogSet(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	var req models.LogSetRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := validation.SetError(validation.Set(req.Reps, req.WeightKg, req.DurationSec)); err != nil {
+		c.Error(err)
+		return
+	}
+	set, session, err := audited(h.db, c).LogSessionSet(id, userID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if set.IsPR {
+		h.hub.Publish(userID, models.SessionEvent{Type: "pr", WorkoutID: id, Data: set})
+	}
+	if session.NextSet != nil {
+		h.hub.StartRest(*session)
+		h.hub.Publish(userID, models.SessionEvent{Type: "next_set", WorkoutID: id, Data: session.NextSet})
+	} else {
+		h.hub.StopRest(session.ID)
+	}
+	c.JSON(http.StatusCreated, gin.H{"set": set, "session": session})
+}
+
+// POST /workouts/:id/session/finish
+func (h *SessionHandler) Finish(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	session, err := audited(h.db, c).FinishSession(id, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.hub.StopRest(session.ID)
+	h.hub.Publish(userID, models.SessionEvent{Type: "finished", WorkoutID: id, Data: session})
+	c.JSON(http.StatusOK, session)
+}
+
+// GET /workouts/:id/session/events
+//
+// Server-Sent Events stream of the session. The current state is sent
+// first so a device that connects mid-session, or reconnects after a
+// restart, can render the running rest timer straight away.
+func (h *SessionHandler) Events(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	session, err := h.db.GetActiveSession(id, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if session == nil {
+		c.Error(database.ErrSessionNotFound)
+		return
+	}
+
+	streaming(c)
+	events, unsubscribe := h.hub.Subscribe(userID)
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("session", session)
+	if session.RestUntil != nil && session.RestUntil.After(time.Now()) {
+		c.SSEvent("timer", gin.H{
+			"state":         "started",
+			"session_id":    session.ID,
+			"ends_at":       session.RestUntil,
+			"remaining_sec": int(time.Until(*session.RestUntil).Round(time.Second).Seconds()),
+		})
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			if ev.WorkoutID != id {
+				return true
+			}
+			c.SSEvent(ev.Type, ev.Data)
+			return ev.Type != "finished"
+		case <-keepAlive.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func doJSON(r *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLiveSession(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "session@test.com")
+
+	w := doJSON(r, "POST", "/workouts", token, map[string]interface{}{
+		"title": "Bench",
+		"exercises": []map[string]interface{}{
+			{"exercise_id": 1, "sets": 2, "reps": 5, "weight_kg": 80, "rest_sec": 120},
+		},
+	})
+	var workout struct {
+		ID        int64 `json:"id"`
+		Exercises []struct {
+			ID int64 `json:"id"`
+		} `json:"exercises"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &workout)
+	base := fmt.Sprintf("/workouts/%d/session", workout.ID)
+
+	if w := doJSON(r, "POST", base, token, nil); w.Code != http.StatusCreated {
+		t.Fatalf("Start expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doJSON(r, "POST", base+"/sets", token, map[string]interface{}{
+		"workout_exercise_id": workout.Exercises[0].ID, "reps": -5, "weight_kg": 10000,
+	})
+	if p := decodeProblem(t, w); w.Code != http.StatusBadRequest || p.Code != "invalid_set" || len(p.Errors) != 2 {
+		t.Fatalf("Out-of-range set: %d %s", w.Code, w.Body.String())
+	}
+
+	w = doJSON(r, "POST", base+"/sets", token, map[string]interface{}{
+		"workout_exercise_id": workout.Exercises[0].ID, "reps": 5, "weight_kg": 82.5,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Log set expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var logged struct {
+		Set struct {
+			SetNumber int  `json:"set_number"`
+			RestSec   int  `json:"rest_sec"`
+			IsPR      bool `json:"is_pr"`
+		} `json:"set"`
+		Session struct {
+			RestUntil *string `json:"rest_until"`
+			NextSet   *struct {
+				SetNumber int `json:"set_number"`
+			} `json:"next_set"`
+		} `json:"session"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &logged)
+	if logged.Set.SetNumber != 1 || logged.Set.RestSec != 120 || !logged.Set.IsPR {
+		t.Fatalf("Unexpected set: %s", w.Body.String())
+	}
+	if logged.Session.RestUntil == nil || logged.Session.NextSet == nil || logged.Session.NextSet.SetNumber != 2 {
+		t.Fatalf("Expected rest timer and next set 2: %s", w.Body.String())
+	}
+
+	if w := doJSON(r, "GET", base, token, nil); w.Code != http.StatusOK {
+		t.Fatalf("Get session expected 200, got %d", w.Code)
+	}
+	if w := doJSON(r, "POST", base+"/finish", token, nil); w.Code != http.StatusOK {
+		t.Fatalf("Finish expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", base, token, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 after finish, got %d", w.Code)
+	}
+}
+
+func TestQueryTokenOnlyForEventStreams(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "stream@test.com")
+	doJSON(r, "POST", "/workouts", token, map[string]interface{}{"title": "Row"})
+
+	w := doJSON(r, "GET", "/workouts/1?access_token="+token, "", nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusUnauthorized || p.Code != "missing_token" {
+		t.Errorf("query token on a plain route: %d %+v", w.Code, p)
+	}
+	// Authenticated, the stream reports that no session is running.
+	w = doJSON(r, "GET", "/workouts/1/session/events?access_token="+token, "", nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusNotFound || p.Code != "session_not_found" {
+		t.Errorf("query token on the event stream: %d %+v", w.Code, p)
+	}
+	w = doJSON(r, "GET", "/workouts/1/session/events?access_token=nope", "", nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusUnauthorized || p.Code != "invalid_token" {
+		t.Errorf("bad query token: %d %+v", w.Code, p)
+	}
+}
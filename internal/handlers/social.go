@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errShareNotFound = database.NotFound("share_not_found", "share link not found")
+
+type SocialHandler struct {
+	db database.Store
+}
+
+func NewSocialHandler(db database.Store) *SocialHandler {
+	return &SocialHandler{db: db}
+}
+
+// POST /users/:id/follow
+//
+// Following someone already followed succeeds and changes nothing.
+func (h *SocialHandler) Follow(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	f, err := audited(h.db, c).Follow(c.GetInt64("userID"), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+// DELETE /users/:id/follow
+func (h *SocialHandler) Unfollow(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	if err := audited(h.db, c).Unfollow(c.GetInt64("userID"), id); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "unfollowed"})
+}
+
+// GET /following
+func (h *SocialHandler) Following(c *gin.Context) {
+	list, err := h.db.ListFollowing(c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GET /followers
+func (h *SocialHandler) Followers(c *gin.Context) {
+	list, err := h.db.ListFollowers(c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GET /feed?limit=&cursor=
+//
+// Lists the completed workouts of the people the user follows, newest
+// first, with their personal records, reactions and comment counts.
+// Workouts their owners keep private never appear.
+func (h *SocialHandler) Feed(c *gin.Context) {
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			c.Error(invalidParam("limit", "must be a positive number"))
+			return
+		}
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	page, err := h.db.GetFeed(c.GetInt64("userID"), limit, c.Query("cursor"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range page.Items {
+		page.Items[i].In(loc)
+	}
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GET /feed/:id
+//
+// Any completed workout the user may see: their own, public ones, and
+// those of people they follow shared with followers.
+func (h *SocialHandler) Item(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	h.respondItem(c, id, http.StatusOK)
+}
+
+// respondItem sends the feed item for a workout in the user's time zone.
+func (h *SocialHandler) respondItem(c *gin.Context, id int64, status int) {
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	item, err := h.db.GetFeedItem(id, c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if item == nil {
+		c.Error(database.ErrWorkoutNotFound)
+		return
+	}
+	item.In(loc)
+	c.JSON(status, item)
+}
+
+// POST /feed/:id/reactions
+//
+// Body: {"kind": "like" | "fire" | "strong" | "clap"}. Responds with the
+// feed item.
+func (h *SocialHandler) React(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	var req models.ReactionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if err := audited(h.db, c).React(id, c.GetInt64("userID"), req.Kind); err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondItem(c, id, http.StatusOK)
+}
+
+// DELETE /feed/:id/reactions/:kind
+//
+// Responds with the feed item.
+func (h *SocialHandler) Unreact(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	if err := audited(h.db, c).Unreact(id, c.GetInt64("userID"), c.Param("kind")); err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondItem(c, id, http.StatusOK)
+}
+
+// GET /feed/:id/comments
+//
+// Oldest first.
+func (h *SocialHandler) Comments(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	list, err := h.db.ListComments(id, c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range list {
+		list[i].CreatedAt = list[i].CreatedAt.In(loc)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// POST /feed/:id/comments
+func (h *SocialHandler) AddComment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	var req models.CommentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	comment, err := audited(h.db, c).AddComment(id, c.GetInt64("userID"), req.Body)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	comment.CreatedAt = comment.CreatedAt.In(loc)
+	c.JSON(http.StatusCreated, comment)
+}
+
+// DELETE /feed/:id/comments/:comment_id
+//
+// The comment's author and the workout's owner may delete it.
+func (h *SocialHandler) DeleteComment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	commentID, err := strconv.ParseInt(c.Param("comment_id"), 10, 64)
+	if err != nil {
+		c.Error(invalidParam("comment_id", "must be a number"))
+		return
+	}
+	if err := audited(h.db, c).DeleteComment(commentID, id, c.GetInt64("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// POST /workouts/:id/share
+//
+// Returns the workout's public share link, creating it on first use. The
+// link works whatever the workout's visibility, until it is revoked.
+func (h *SocialHandler) Share(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	token, err := audited(h.db, c).ShareWorkout(id, c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token, "url": "/share/" + token})
+}
+
+// DELETE /workouts/:id/share
+//
+// Revokes the share link; a later share gets a new one.
+func (h *SocialHandler) Unshare(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	if err := audited(h.db, c).UnshareWorkout(id, c.GetInt64("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "unshared"})
+}
+
+// GET /share/:token
+//
+// Needs no sign-in. Renders a read-only summary of the workout as an HTML
+// page, or as JSON for clients that ask for application/json.
+func (h *SocialHandler) Shared(c *gin.Context) {
+	summary, err := h.db.GetSharedWorkout(c.Param("token"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if summary == nil {
+		c.Error(errShareNotFound)
+		return
+	}
+	// Links get passed around; revoking one must take effect at once.
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, summary)
+		return
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := sharePage.Execute(c.Writer, summary); err != nil {
+		c.Error(err)
+	}
+}
+
+var sharePage = template.Must(template.New("share").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.UTC().Format("Mon 2 Jan 2006") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · {{.Author}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .4rem .5rem; border-bottom: 1px solid #ddd; }
+.meta { color: #666; }
+.pr { color: #b35c00; font-weight: 600; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Author}} · {{date .Date}} · {{.Status}}</p>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Exercises}}
+<table>
+<tr><th>Exercise</th><th>Sets</th><th>Reps</th><th>Weight</th><th>Time</th><th>Distance</th></tr>
+{{range .Exercises}}<tr>
+<td>{{if .Exercise}}{{.Exercise.Name}}{{end}}</td>
+<td>{{if .Sets}}{{.Sets}}{{end}}</td>
+<td>{{if .Reps}}{{.Reps}}{{end}}</td>
+<td>{{if .WeightKg}}{{.WeightKg}} kg{{end}}</td>
+<td>{{if .DurationSec}}{{.DurationSec}} s{{end}}</td>
+<td>{{if .DistanceM}}{{.DistanceM}} m{{end}}</td>
+</tr>{{end}}
+</table>
+{{end}}
+{{if .PRs}}
+<h2>Personal records</h2>
+<ul>
+{{range .PRs}}<li class="pr">{{.ExerciseName}}: {{.WeightKg}} kg × {{.Reps}}</li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+`))
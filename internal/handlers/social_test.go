@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func TestFeed(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	alice := registerAndGetToken(t, r, "alice@test.com")
+	bob := registerAndGetToken(t, r, "bob@test.com")
+
+	if w := doJSON(r, "POST", "/users/2/follow", bob, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("follow self: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/users/1/follow", bob, nil); w.Code != http.StatusOK {
+		t.Fatalf("follow: %d %s", w.Code, w.Body.String())
+	}
+	var following []models.Follow
+	json.Unmarshal(doJSON(r, "GET", "/following", bob, nil).Body.Bytes(), &following)
+	if len(following) != 1 || following[0].UserID != 1 {
+		t.Errorf("following = %+v", following)
+	}
+
+	if w := doJSON(r, "POST", "/workouts", alice, map[string]interface{}{"title": "Legs", "visibility": "friends"}); w.Code != http.StatusBadRequest ||
+		!strings.Contains(w.Body.String(), "/visibility") {
+		t.Errorf("unknown visibility: %d %s", w.Code, w.Body.String())
+	}
+	var workout models.Workout
+	json.Unmarshal(doJSON(r, "POST", "/workouts", alice, map[string]interface{}{"title": "Legs", "visibility": "followers"}).Body.Bytes(), &workout)
+	path := fmt.Sprintf("/workouts/%d", workout.ID)
+	if w := doJSON(r, "PUT", path, alice, map[string]interface{}{"status": "completed"}); w.Code != http.StatusOK {
+		t.Fatalf("complete: %d %s", w.Code, w.Body.String())
+	}
+
+	var page models.FeedPage
+	json.Unmarshal(doJSON(r, "GET", "/feed", bob, nil).Body.Bytes(), &page)
+	if len(page.Items) != 1 || page.Items[0].ID != workout.ID || page.Items[0].Visibility != "followers" {
+		t.Fatalf("feed = %+v", page)
+	}
+
+	item := fmt.Sprintf("/feed/%d", workout.ID)
+	if w := doJSON(r, "POST", item+"/reactions", bob, map[string]string{"kind": "meh"}); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown reaction: %d %s", w.Code, w.Body.String())
+	}
+	w := doJSON(r, "POST", item+"/reactions", bob, map[string]string{"kind": "strong"})
+	var reacted models.FeedItem
+	json.Unmarshal(w.Body.Bytes(), &reacted)
+	if w.Code != http.StatusOK || reacted.Reactions["strong"] != 1 {
+		t.Errorf("react: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", item+"/comments", bob, map[string]string{"body": "Nice one"}); w.Code != http.StatusCreated {
+		t.Errorf("comment: %d %s", w.Code, w.Body.String())
+	}
+	var comments []models.Comment
+	json.Unmarshal(doJSON(r, "GET", item+"/comments", alice, nil).Body.Bytes(), &comments)
+	if len(comments) != 1 || comments[0].Body != "Nice one" {
+		t.Errorf("comments = %+v", comments)
+	}
+
+	// Made private, the workout leaves the feed.
+	if w := doRaw(r, "PATCH", path, alice, "application/merge-patch+json", `{"visibility": "private"}`, nil); w.Code != http.StatusOK {
+		t.Fatalf("patch visibility: %d %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(doJSON(r, "GET", "/feed", bob, nil).Body.Bytes(), &page)
+	if len(page.Items) != 0 {
+		t.Errorf("feed after making the workout private = %+v", page)
+	}
+	if w := doJSON(r, "GET", item+"/comments", bob, nil); w.Code != http.StatusNotFound {
+		t.Errorf("comments on a private workout: %d", w.Code)
+	}
+}
+
+func TestShareLink(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "share@test.com")
+
+	var workout models.Workout
+	json.Unmarshal(doJSON(r, "POST", "/workouts", token, map[string]interface{}{
+		"title":     "Push <day>",
+		"exercises": []map[string]interface{}{{"exercise_id": 1, "sets": 3, "reps": 8, "weight_kg": 70}},
+	}).Body.Bytes(), &workout)
+	path := fmt.Sprintf("/workouts/%d/share", workout.ID)
+
+	var link struct{ Token, URL string }
+	json.Unmarshal(doJSON(r, "POST", path, token, nil).Body.Bytes(), &link)
+	if link.Token == "" || link.URL != "/share/"+link.Token {
+		t.Fatalf("share link = %+v", link)
+	}
+
+	// No sign-in needed.
+	req := httptest.NewRequest("GET", link.URL, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(body, "Push &lt;day&gt;") || !strings.Contains(body, "Bench Press") {
+		t.Fatalf("share page: %d %s", w.Code, body)
+	}
+
+	req = httptest.NewRequest("GET", link.URL, nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var summary models.WorkoutSummary
+	json.Unmarshal(w.Body.Bytes(), &summary)
+	if w.Code != http.StatusOK || summary.Title != "Push <day>" || len(summary.Exercises) != 1 {
+		t.Errorf("share JSON: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := doJSON(r, "DELETE", path, token, nil); w.Code != http.StatusOK {
+		t.Fatalf("unshare: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, "GET", link.URL, "", nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusNotFound || p.Code != "share_not_found" {
+		t.Errorf("revoked link: %d %+v", w.Code, p)
+	}
+}
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"workout-tracker/internal/conflict"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/middleware"
+	"workout-tracker/internal/models"
+	"workout-tracker/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSyncMutations caps one POST /sync batch.
+const maxSyncMutations = 500
+
+type SyncHandler struct {
+	db database.Store
+}
+
+func NewSyncHandler(db database.Store) *SyncHandler {
+	return &SyncHandler{db: db}
+}
+
+// GET /sync?since=<cursor>
+//
+// Returns the workouts, custom exercises and logged sets that changed
+// since the cursor, tombstones for what was deleted, and the cursor to
+// pass next time. Without since it returns everything, for a first sync.
+// Changes near the cursor can be returned twice. Tombstones are purged
+// after a while, so a client whose cursor is older must sync from scratch.
+func (h *SyncHandler) Pull(c *gin.Context) {
+	changes, err := h.db.GetChanges(c.GetInt64("userID"), c.Query("since"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range changes.Workouts {
+		changes.Workouts[i].In(loc)
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// POST /sync
+//
+// Applies a batch of offline mutations in order and reports each one's
+// outcome. A mutation that fails is rejected on its own; the rest still
+// apply. Results are stored under the mutation's idempotency key, so a
+// batch resent after a lost response is not applied twice.
+func (h *SyncHandler) Push(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	var req models.SyncRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	strategy, ok := conflict.ParseStrategy(req.Strategy)
+	if !ok {
+		c.Error(database.Invalid("invalid_sync", "invalid sync request", models.FieldError{
+			Pointer: "/strategy", Code: "oneof", Message: "must be server_wins or last_writer_wins",
+		}))
+		return
+	}
+	if len(req.Mutations) > maxSyncMutations {
+		c.Error(database.Invalid("invalid_sync", "invalid sync request", models.FieldError{
+			Pointer: "/mutations", Code: "max", Message: fmt.Sprintf("must have at most %d items", maxSyncMutations),
+		}))
+		return
+	}
+
+	s := &syncBatch{db: audited(h.db, c), userID: userID, strategy: strategy}
+	resp := models.SyncResponse{Results: make([]models.SyncResult, len(req.Mutations))}
+	for i := range req.Mutations {
+		r, err := s.apply(&req.Mutations[i])
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if r.Error != nil {
+			for j := range r.Error.Errors {
+				if p := r.Error.Errors[j].Pointer; p != "" {
+					r.Error.Errors[j].Pointer = fmt.Sprintf("/mutations/%d%s", i, p)
+				}
+			}
+		}
+		resp.Results[i] = *r
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// syncBatch applies the mutations of one POST /sync.
+type syncBatch struct {
+	db       database.Store
+	userID   int64
+	strategy conflict.Strategy
+	lib      validation.Library // loaded on first use, dropped when exercises change
+}
+
+var (
+	errUnknownMutation = database.Invalid("invalid_mutation", "unknown mutation", models.FieldError{
+		Pointer: "/type", Code: "oneof", Message: "must be workout, exercise or set with op upsert, or workout with op delete",
+	})
+	errNoWorkout = database.Invalid("invalid_mutation", "set names no workout", models.FieldError{
+		Pointer: "/workout_id", Code: "required", Message: "workout_id or workout_client_id is required",
+	})
+)
+
+// apply runs one mutation, or replays its stored result. Its idempotency
+// key is claimed before it runs, so the same mutation in a batch resent
+// while this one is still running is rejected rather than applied again.
+// Failures the client can act on are reported in the result; other errors
+// abort the batch.
+func (s *syncBatch) apply(m *models.SyncMutation) (*models.SyncResult, error) {
+	if m.IdempotencyKey != "" {
+		stored, err := s.db.ClaimSyncResult(s.userID, m.IdempotencyKey)
+		if err != nil {
+			return rejected(m, 0, err)
+		}
+		if stored != nil {
+			var r models.SyncResult
+			if err := json.Unmarshal(stored, &r); err != nil {
+				return nil, err
+			}
+			r.Replayed = true
+			return &r, nil
+		}
+	}
+
+	r := &models.SyncResult{IdempotencyKey: m.IdempotencyKey, Type: m.Type, ClientID: m.ClientID, Status: "applied"}
+	var err error
+	switch {
+	case m.Type == "workout" && m.Op == "upsert":
+		err = s.upsertWorkout(m, r)
+	case m.Type == "workout" && m.Op == "delete":
+		err = s.deleteWorkout(m, r)
+	case m.Type == "exercise" && m.Op == "upsert":
+		err = s.upsertExercise(m, r)
+	case m.Type == "set" && m.Op == "upsert":
+		err = s.addSet(m, r)
+	default:
+		err = errUnknownMutation
+	}
+	if err != nil {
+		if m.IdempotencyKey != "" {
+			if err := s.db.ReleaseSyncResult(s.userID, m.IdempotencyKey); err != nil {
+				return nil, err
+			}
+		}
+		return rejected(m, r.ID, err)
+	}
+
+	if m.IdempotencyKey != "" {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.db.SaveSyncResult(s.userID, m.IdempotencyKey, b); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// rejected reports a mutation that failed with err, or returns err itself
+// when the failure is the server's rather than one the client can act on.
+func rejected(m *models.SyncMutation, id int64, err error) (*models.SyncResult, error) {
+	p := middleware.ProblemFor(err)
+	if p.Status == http.StatusInternalServerError {
+		return nil, err
+	}
+	return &models.SyncResult{IdempotencyKey: m.IdempotencyKey, Type: m.Type, ID: id, ClientID: m.ClientID,
+		Status: "rejected", Error: &p}, nil
+}
+
+// target is the ID of the record a mutation names, or 0 if it names one
+// the server has not seen.
+func (s *syncBatch) target(entity string, id int64, clientID string) (int64, error) {
+	if id != 0 || clientID == "" {
+		return id, nil
+	}
+	return s.db.FindClientID(s.userID, entity, clientID)
+}
+
+func (s *syncBatch) library() (validation.Library, error) {
+	if s.lib == nil {
+		var err error
+		if s.lib, err = exerciseLibrary(s.db, s.userID, true); err != nil {
+			return nil, err
+		}
+	}
+	return s.lib, nil
+}
+
+// merge applies a mutation's changes to a record's fields, resolving
+// conflicts with the batch's strategy, and decodes the result into v.
+// upToDate means the client saw the record's current version, so nothing
+// can conflict; updatedAt is when the server last changed it.
+func (s *syncBatch) merge(m *models.SyncMutation, current interface{}, upToDate bool, updatedAt time.Time, v interface{}) ([]models.SyncConflict, error) {
+	server, err := conflict.Fields(current)
+	if err != nil {
+		return nil, err
+	}
+	base := m.Base
+	if upToDate {
+		base = server
+	}
+	merged, conflicts := conflict.Merge(server, base, m.Changes, s.strategy.ClientWins(m.ChangedAt, updatedAt))
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeFields(b, v, "invalid_mutation", "mutation sets a field that cannot be changed"); err != nil {
+		return nil, underChanges(err)
+	}
+	return conflicts, nil
+}
+
+// upsertWorkout creates a workout the server has not seen, or merges the
+// changes into the one it has. A workout changed concurrently is merged
+// again against the newer copy.
+func (s *syncBatch) upsertWorkout(m *models.SyncMutation, r *models.SyncResult) error {
+	id, err := s.target("workout", m.ID, m.ClientID)
+	if err != nil {
+		return err
+	}
+	lib, err := s.library()
+	if err != nil {
+		return err
+	}
+	if id == 0 {
+		return s.createWorkout(m, r, lib)
+	}
+
+	for attempt := 0; ; attempt++ {
+		w, err := s.db.GetWorkoutByID(id, s.userID)
+		if err != nil {
+			return err
+		}
+		if w == nil {
+			return database.ErrWorkoutNotFound
+		}
+		old := w.State()
+		var state models.WorkoutState
+		conflicts, err := s.merge(m, old, m.BaseVersion == w.Version, w.UpdatedAt, &state)
+		if err != nil {
+			return err
+		}
+		if err := validation.Error(validation.PatchWorkout(&old, &state, lib)); err != nil {
+			return underChanges(err)
+		}
+		saved, err := s.db.SaveWorkout(id, s.userID, w.Version, state)
+		if errors.Is(err, database.ErrVersionMismatch) && attempt < 2 {
+			continue
+		}
+		if err != nil {
+			return underChanges(err)
+		}
+		r.ID, r.Version, r.Conflicts = saved.ID, saved.Version, conflicts
+		return nil
+	}
+}
+
+func (s *syncBatch) createWorkout(m *models.SyncMutation, r *models.SyncResult, lib validation.Library) error {
+	var state models.WorkoutState
+	if _, err := s.merge(m, models.WorkoutState{Status: "pending", Visibility: "private"}, true, time.Time{}, &state); err != nil {
+		return err
+	}
+	req := models.CreateWorkoutRequest{
+		ClientID:    m.ClientID,
+		Title:       state.Title,
+		Description: state.Description,
+		Visibility:  state.Visibility,
+		ScheduledAt: state.ScheduledAt,
+		Status:      state.Status,
+		Comment:     state.Comment,
+		Exercises:   make([]models.WorkoutExerciseRequest, len(state.Exercises)),
+	}
+	for i, e := range state.Exercises {
+		req.Exercises[i] = e.WorkoutExerciseRequest
+	}
+	errs := validation.CreateWorkout(&req, lib)
+	errs = append(errs, validation.UpdateWorkout(&models.UpdateWorkoutRequest{Comment: &state.Comment, Status: &state.Status}, lib)...)
+	if err := validation.Error(errs); err != nil {
+		return underChanges(err)
+	}
+
+	w, err := s.db.CreateWorkout(s.userID, req)
+	if err != nil {
+		return underChanges(err)
+	}
+	r.ID, r.Version = w.ID, w.Version
+	return nil
+}
+
+// deleteWorkout deletes a workout. Deleting one that is already gone
+// succeeds. With server_wins, a workout changed since the client's
+// base_version is kept and the delete reported as a conflict.
+func (s *syncBatch) deleteWorkout(m *models.SyncMutation, r *models.SyncResult) error {
+	id, err := s.target("workout", m.ID, m.ClientID)
+	if err != nil || id == 0 {
+		return err
+	}
+	r.ID = id
+	w, err := s.db.GetWorkoutByID(id, s.userID)
+	if err != nil || w == nil {
+		return err
+	}
+	if m.BaseVersion != 0 && m.BaseVersion != w.Version && !s.strategy.ClientWins(m.ChangedAt, w.UpdatedAt) {
+		r.Version = w.Version
+		r.Conflicts = []models.SyncConflict{{Field: "deleted", Client: json.RawMessage("true"), Server: json.RawMessage("false"), Winner: "server"}}
+		return nil
+	}
+	err = s.db.DeleteWorkout(id, s.userID, w.Version)
+	if errors.Is(err, database.ErrWorkoutNotFound) {
+		return nil
+	}
+	return err
+}
+
+// upsertExercise creates or changes one of the user's custom exercises.
+func (s *syncBatch) upsertExercise(m *models.SyncMutation, r *models.SyncResult) error {
+	id, err := s.target("exercise", m.ID, m.ClientID)
+	if err != nil {
+		return err
+	}
+	current := customExerciseFields{}
+	var updatedAt time.Time
+	if id != 0 {
+		e, err := s.db.GetExerciseByID(id)
+		if err != nil {
+			return err
+		}
+		if e == nil || e.UserID == nil || *e.UserID != s.userID {
+			return database.ErrExerciseNotFound
+		}
+		current = customExerciseFields{Name: e.Name, Category: e.Category, MuscleGroup: e.MuscleGroup}
+		if e.UpdatedAt != nil {
+			updatedAt = *e.UpdatedAt
+		}
+	}
+	var fields customExerciseFields
+	conflicts, err := s.merge(m, current, id == 0, updatedAt, &fields)
+	if err != nil {
+		return err
+	}
+	e := &models.Exercise{Name: fields.Name, Category: fields.Category, MuscleGroup: fields.MuscleGroup}
+	if err := validation.Error(validation.CustomExercise(e)); err != nil {
+		return underChanges(err)
+	}
+
+	if id == 0 {
+		e, err = s.db.CreateCustomExercise(s.userID, e.Name, e.Category, e.MuscleGroup, m.ClientID)
+	} else {
+		e, err = s.db.UpdateCustomExercise(id, s.userID, e.Name, e.Category, e.MuscleGroup)
+	}
+	if err != nil {
+		return err
+	}
+	s.lib = nil
+	r.ID, r.Conflicts = e.ID, conflicts
+	return nil
+}
+
+// customExerciseFields are the fields of a custom exercise a client can
+// change.
+type customExerciseFields struct {
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	MuscleGroup string `json:"muscle_group"`
+}
+
+// addSet records a set logged offline. Sets are never changed afterwards,
+// so one the server already has is left alone.
+func (s *syncBatch) addSet(m *models.SyncMutation, r *models.SyncResult) error {
+	if m.ClientID != "" {
+		id, err := s.db.FindClientID(s.userID, "set", m.ClientID)
+		if err != nil || id != 0 {
+			r.ID = id
+			return err
+		}
+	}
+	workoutID, err := s.target("workout", m.WorkoutID, m.WorkoutClientID)
+	if err != nil {
+		return err
+	}
+	if workoutID == 0 {
+		if m.WorkoutClientID != "" {
+			return database.ErrWorkoutNotFound
+		}
+		return errNoWorkout
+	}
+
+	var req models.SyncSet
+	b, _ := json.Marshal(m.Changes)
+	if err := decodeFields(b, &req, "invalid_mutation", "mutation sets a field that cannot be changed"); err != nil {
+		return underChanges(err)
+	}
+	if err := validation.SetError(validation.Set(req.Reps, req.WeightKg, req.DurationSec)); err != nil {
+		return underChanges(err)
+	}
+	if req.ExerciseIndex != nil {
+		w, err := s.db.GetWorkoutByID(workoutID, s.userID)
+		if err != nil {
+			return err
+		}
+		if w == nil {
+			return database.ErrWorkoutNotFound
+		}
+		if i := *req.ExerciseIndex; i < 0 || i >= len(w.Exercises) {
+			return database.Invalid("invalid_mutation", "no such exercise entry", models.FieldError{
+				Pointer: "/changes/exercise_index", Code: "range", Message: fmt.Sprintf("must be between 0 and %d", len(w.Exercises)-1),
+			})
+		}
+		req.WorkoutExerciseID = w.Exercises[*req.ExerciseIndex].ID
+	}
+
+	set, err := s.db.AddSyncedSet(workoutID, s.userID, m.ClientID, req)
+	if err != nil {
+		return underChanges(err)
+	}
+	r.ID = set.ID
+	return nil
+}
+
+// underChanges moves the field pointers of a validation error from the
+// record to the mutation's changes.
+func underChanges(err error) error {
+	var de *database.Error
+	if !errors.As(err, &de) || len(de.Fields) == 0 {
+		return err
+	}
+	fields := make([]models.FieldError, len(de.Fields))
+	for i, f := range de.Fields {
+		if f.Pointer != "" {
+			f.Pointer = "/changes" + f.Pointer
+		}
+		fields[i] = f
+	}
+	return &database.Error{Kind: de.Kind, Code: de.Code, Message: de.Message, Fields: fields}
+}
@@ -0,0 +1,157 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func pull(t *testing.T, r *gin.Engine, token, since string) models.SyncChanges {
+	t.Helper()
+	w := doJSON(r, "GET", "/sync?since="+since, token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /sync: %d %s", w.Code, w.Body.String())
+	}
+	var ch models.SyncChanges
+	json.Unmarshal(w.Body.Bytes(), &ch)
+	return ch
+}
+
+func push(t *testing.T, r *gin.Engine, token string, body interface{}) []models.SyncResult {
+	t.Helper()
+	w := doJSON(r, "POST", "/sync", token, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /sync: %d %s", w.Code, w.Body.String())
+	}
+	var resp models.SyncResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Results
+}
+
+func TestSyncRoundTrip(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "sync@test.com")
+
+	first := pull(t, r, token, "")
+	if first.Cursor == "" || len(first.Exercises) == 0 || len(first.Workouts) != 0 {
+		t.Fatalf("first pull: cursor %q, %d exercises, %d workouts", first.Cursor, len(first.Exercises), len(first.Workouts))
+	}
+
+	batch := map[string]interface{}{"mutations": []map[string]interface{}{
+		{"idempotency_key": "k1", "type": "exercise", "op": "upsert", "client_id": "ex-1",
+			"changes": map[string]interface{}{"name": "Sled Push", "category": "strength"}},
+		{"idempotency_key": "k2", "type": "workout", "op": "upsert", "client_id": "w-1",
+			"changes": map[string]interface{}{"title": "Basement", "status": "active", "comment": "Cold",
+				"exercises": []map[string]interface{}{{"exercise_id": 1, "sets": 3, "reps": 5}}}},
+		{"idempotency_key": "k3", "type": "set", "op": "upsert", "client_id": "s-1", "workout_client_id": "w-1",
+			"changes": map[string]interface{}{"exercise_index": 0, "reps": 5, "weight_kg": 100, "completed_at": "2024-03-01T18:00:00Z"}},
+	}}
+	results := push(t, r, token, batch)
+	if len(results) != 3 {
+		t.Fatalf("results: %+v", results)
+	}
+	for _, res := range results {
+		if res.Status != "applied" || res.ID == 0 || res.Replayed {
+			t.Errorf("%s %s: %+v", res.Type, res.ClientID, res)
+		}
+	}
+
+	// A resent batch replays the stored results.
+	again := push(t, r, token, batch)
+	for i, res := range again {
+		if !res.Replayed || res.ID != results[i].ID {
+			t.Errorf("replay %d: %+v", i, res)
+		}
+	}
+	var list []models.Workout
+	json.Unmarshal(doJSON(r, "GET", "/workouts", token, nil).Body.Bytes(), &list)
+	// The workout is created with its status and comment in one write.
+	if len(list) != 1 || list[0].ClientID != "w-1" || list[0].Status != "active" || list[0].Comment != "Cold" || list[0].Version != 1 {
+		t.Fatalf("workouts after replay: %+v", list)
+	}
+
+	rejected := push(t, r, token, map[string]interface{}{"mutations": []map[string]interface{}{
+		{"type": "set", "op": "upsert", "client_id": "s-2", "workout_client_id": "w-1",
+			"changes": map[string]interface{}{"exercise_index": 0, "reps": -1, "weight_kg": 5000}},
+	}})
+	if res := rejected[0]; res.Status != "rejected" || res.Error == nil || res.Error.Code != "invalid_set" ||
+		len(res.Error.Errors) != 2 || res.Error.Errors[0].Pointer != "/mutations/0/changes/reps" {
+		t.Errorf("out-of-range set: %+v", res.Error)
+	}
+
+	changes := pull(t, r, token, first.Cursor)
+	if len(changes.Workouts) != 1 || len(changes.Exercises) != 1 || changes.Exercises[0].ClientID != "ex-1" ||
+		len(changes.Sets) != 1 || changes.Sets[0].ClientID != "s-1" || changes.Sets[0].WorkoutID != results[1].ID {
+		t.Errorf("changes since first pull: %+v", changes)
+	}
+
+	push(t, r, token, map[string]interface{}{"mutations": []map[string]interface{}{
+		{"type": "workout", "op": "delete", "client_id": "w-1"},
+	}})
+	changes = pull(t, r, token, first.Cursor)
+	if len(changes.Workouts) != 0 || len(changes.Deleted) != 1 || changes.Deleted[0].ClientID != "w-1" {
+		t.Errorf("changes after delete: %+v", changes)
+	}
+
+	if w := doJSON(r, "GET", "/sync?since=nope", token, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid cursor: %d", w.Code)
+	}
+}
+
+func TestSyncConflicts(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "conflicts@test.com")
+
+	var created models.Workout
+	json.Unmarshal(doJSON(r, "POST", "/workouts", token, map[string]interface{}{"title": "Legs"}).Body.Bytes(), &created)
+	path := "/workouts/" + itoa(int(created.ID))
+	doJSON(r, "PUT", path, token, map[string]interface{}{"comment": "from the web"})
+
+	offline := func(strategy string, changedAt time.Time) models.SyncResult {
+		results := push(t, r, token, map[string]interface{}{"strategy": strategy, "mutations": []map[string]interface{}{{
+			"type": "workout", "op": "upsert", "id": created.ID, "base_version": created.Version,
+			"base":       map[string]interface{}{"title": "Legs", "comment": ""},
+			"changes":    map[string]interface{}{"title": "Leg day", "comment": "from the phone"},
+			"changed_at": changedAt,
+		}}})
+		return results[0]
+	}
+
+	res := offline("server_wins", time.Now().Add(time.Hour))
+	if res.Status != "applied" || len(res.Conflicts) != 1 || res.Conflicts[0].Field != "comment" || res.Conflicts[0].Winner != "server" {
+		t.Fatalf("server_wins: %+v", res)
+	}
+	var w models.Workout
+	json.Unmarshal(doJSON(r, "GET", path, token, nil).Body.Bytes(), &w)
+	if w.Title != "Leg day" || w.Comment != "from the web" {
+		t.Errorf("after server_wins: %q %q", w.Title, w.Comment)
+	}
+
+	if res := offline("last_writer_wins", time.Now().Add(-time.Hour)); len(res.Conflicts) != 1 || res.Conflicts[0].Winner != "server" {
+		t.Errorf("last_writer_wins with an older change: %+v", res)
+	}
+	if res := offline("last_writer_wins", time.Now().Add(time.Hour)); len(res.Conflicts) != 1 || res.Conflicts[0].Winner != "client" {
+		t.Errorf("last_writer_wins with a newer change: %+v", res)
+	}
+	json.Unmarshal(doJSON(r, "GET", path, token, nil).Body.Bytes(), &w)
+	if w.Comment != "from the phone" {
+		t.Errorf("after last_writer_wins: %q", w.Comment)
+	}
+
+	results := push(t, r, token, map[string]interface{}{"mutations": []map[string]interface{}{
+		{"type": "workout", "op": "upsert", "client_id": "bad",
+			"changes": map[string]interface{}{"title": "Bad", "exercises": []map[string]interface{}{{"exercise_id": 1, "sets": 500, "reps": 5}}}},
+		{"type": "plan", "op": "upsert"},
+	}})
+	if results[0].Status != "rejected" || results[0].Error == nil || len(results[0].Error.Errors) != 1 ||
+		results[0].Error.Errors[0].Pointer != "/mutations/0/changes/exercises/0/sets" {
+		t.Errorf("invalid workout: %+v", results[0])
+	}
+	if results[1].Status != "rejected" || results[1].Error.Errors[0].Pointer != "/mutations/1/type" {
+		t.Errorf("unknown type: %+v", results[1])
+	}
+}
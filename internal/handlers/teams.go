@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TeamHandler serves teams, their leaderboards and their challenges. Teams
+// are visible only to their members; to anyone else they do not exist.
+type TeamHandler struct {
+	db database.Store
+}
+
+func NewTeamHandler(db database.Store) *TeamHandler {
+	return &TeamHandler{db: db}
+}
+
+// POST /teams
+//
+// Body: {"name": "..."}. Creates a team owned by the caller.
+func (h *TeamHandler) Create(c *gin.Context) {
+	var req models.CreateTeamRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	team, err := audited(h.db, c).CreateTeam(c.GetInt64("userID"), req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondTeam(c, http.StatusCreated, team)
+}
+
+// GET /teams
+func (h *TeamHandler) List(c *gin.Context) {
+	list, err := h.db.ListTeams(c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range list {
+		list[i].In(loc)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// POST /teams/join
+//
+// Body: {"code": "..."}. Joins the team with that join code.
+func (h *TeamHandler) Join(c *gin.Context) {
+	var req models.JoinTeamRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	team, err := audited(h.db, c).JoinTeam(c.GetInt64("userID"), req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondTeam(c, http.StatusOK, team)
+}
+
+// GET /teams/:id
+//
+// The team with its members.
+func (h *TeamHandler) Get(c *gin.Context) {
+	id, ok := teamID(c)
+	if !ok {
+		return
+	}
+	team, err := h.db.GetTeam(id, c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if team == nil {
+		c.Error(database.ErrTeamNotFound)
+		return
+	}
+	h.respondTeam(c, http.StatusOK, team)
+}
+
+// DELETE /teams/:id
+//
+// Only the owner may delete a team.
+func (h *TeamHandler) Delete(c *gin.Context) {
+	id, ok := teamID(c)
+	if !ok {
+		return
+	}
+	if err := audited(h.db, c).DeleteTeam(id, c.GetInt64("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// DELETE /teams/:id/members/:user_id
+//
+// Members remove themselves to leave; the owner may remove anyone else.
+func (h *TeamHandler) RemoveMember(c *gin.Context) {
+	id, ok := teamID(c)
+	if !ok {
+		return
+	}
+	memberID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.Error(invalidParam("user_id", "must be a number"))
+		return
+	}
+	if err := audited(h.db, c).RemoveTeamMember(id, c.GetInt64("userID"), memberID); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// GET /teams/:id/leaderboard?metric=volume&period=week
+//
+// Ranks the members by metric: volume (kg lifted, the default), reps,
+// distance (m), sessions (workouts completed), streak (each member's
+// current streak, counted in their own time zone with their rest days, as
+// on /achievements) or prs (personal records set). Period is this week (the default), this month or
+// all, in the caller's time zone; the streak is always the current one.
+func (h *TeamHandler) Leaderboard(c *gin.Context) {
+	id, ok := teamID(c)
+	if !ok {
+		return
+	}
+	board := models.Leaderboard{Metric: c.DefaultQuery("metric", "volume"), Period: c.DefaultQuery("period", "week")}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	now := time.Now().In(loc)
+	var from time.Time
+	switch board.Period {
+	case "week":
+		from = models.StartOfWeek(now, loc)
+	case "month":
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	case "all":
+	default:
+		c.Error(invalidParam("period", "must be one of week, month, all"))
+		return
+	}
+	if !from.IsZero() && board.Metric != "streak" {
+		board.From = &from
+	}
+	board.Entries, err = h.db.GetLeaderboard(id, c.GetInt64("userID"), board.Metric, board.From, nil)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, board)
+}
+
+// POST /teams/:id/challenges
+//
+// Body: {"title": "100 pull-ups", "metric": "reps", "exercise_id": 12,
+// "target": 100, "starts_at": "...", "ends_at": "..."}. Any member may set
+// the team a challenge. Metric is reps, volume, distance (m) or sessions;
+// exercise_id, a built-in exercise, narrows it to that exercise.
+func (h *TeamHandler) CreateChallenge(c *gin.Context) {
+	id, ok := teamID(c)
+	if !ok {
+		return
+	}
+	var req models.CreateChallengeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	ch, err := audited(h.db, c).CreateChallenge(id, c.GetInt64("userID"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondChallenge(c, http.StatusCreated, ch)
+}
+
+// GET /teams/:id/challenges
+//
+// The team's challenges, latest ending first, without standings.
+func (h *TeamHandler) ListChallenges(c *gin.Context) {
+	id, ok := teamID(c)
+	if !ok {
+		return
+	}
+	list, err := h.db.ListChallenges(id, c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range list {
+		list[i].In(loc)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GET /teams/:id/challenges/:challenge_id
+//
+// The challenge with every member's progress, counted from the workouts
+// they completed between its start and end.
+func (h *TeamHandler) GetChallenge(c *gin.Context) {
+	id, challengeID, ok := challengeIDs(c)
+	if !ok {
+		return
+	}
+	ch, err := h.db.GetChallenge(challengeID, id, c.GetInt64("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if ch == nil {
+		c.Error(database.ErrChallengeNotFound)
+		return
+	}
+	h.respondChallenge(c, http.StatusOK, ch)
+}
+
+// DELETE /teams/:id/challenges/:challenge_id
+//
+// The challenge's creator or the team's owner may delete it.
+func (h *TeamHandler) DeleteChallenge(c *gin.Context) {
+	id, challengeID, ok := challengeIDs(c)
+	if !ok {
+		return
+	}
+	if err := audited(h.db, c).DeleteChallenge(challengeID, id, c.GetInt64("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+func teamID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return 0, false
+	}
+	return id, true
+}
+
+func challengeIDs(c *gin.Context) (int64, int64, bool) {
+	id, ok := teamID(c)
+	if !ok {
+		return 0, 0, false
+	}
+	challengeID, err := strconv.ParseInt(c.Param("challenge_id"), 10, 64)
+	if err != nil {
+		c.Error(invalidParam("challenge_id", "must be a number"))
+		return 0, 0, false
+	}
+	return id, challengeID, true
+}
+
+func (h *TeamHandler) respondTeam(c *gin.Context, status int, team *models.Team) {
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	team.In(loc)
+	c.JSON(status, team)
+}
+
+func (h *TeamHandler) respondChallenge(c *gin.Context, status int, ch *models.Challenge) {
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	ch.In(loc)
+	c.JSON(status, ch)
+}
@@ -0,0 +1,90 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+func TestTeams(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	owner := registerAndGetToken(t, r, "owner@test.com")
+	member := registerAndGetToken(t, r, "member@test.com")
+	outsider := registerAndGetToken(t, r, "outsider@test.com")
+
+	var team models.Team
+	w := doJSON(r, "POST", "/teams", owner, map[string]interface{}{"name": "Early birds"})
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &team) != nil || team.JoinCode == "" {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "POST", "/teams/join", member, map[string]interface{}{"code": team.JoinCode}); w.Code != http.StatusOK {
+		t.Fatalf("join: %d %s", w.Code, w.Body.String())
+	}
+	path := fmt.Sprintf("/teams/%d", team.ID)
+	w = doJSON(r, "GET", path, outsider, nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusNotFound || p.Code != "team_not_found" {
+		t.Errorf("outsider: %d %+v", w.Code, p)
+	}
+
+	// Only the member has trained.
+	doJSON(r, "POST", "/workouts", member, map[string]interface{}{
+		"title":     "Bench day",
+		"exercises": []map[string]interface{}{{"exercise_id": 1, "sets": 3, "reps": 10, "weight_kg": 50}},
+	})
+	if w := doJSON(r, "PUT", "/workouts/1", member, map[string]interface{}{"status": "completed"}); w.Code != http.StatusOK {
+		t.Fatalf("complete: %d %s", w.Code, w.Body.String())
+	}
+
+	w = doJSON(r, "GET", path+"/leaderboard?metric=calories", owner, nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusBadRequest || p.Code != "invalid_metric" {
+		t.Errorf("unknown metric: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", path+"/leaderboard?metric=reps&period=all", owner, nil); w.Code != http.StatusOK {
+		t.Errorf("reps leaderboard: %d %s", w.Code, w.Body.String())
+	}
+	var board models.Leaderboard
+	w = doJSON(r, "GET", path+"/leaderboard?metric=volume&period=all", owner, nil)
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &board) != nil || len(board.Entries) != 2 ||
+		board.Entries[0].UserID != 2 || board.Entries[0].Value != 1500 || board.Entries[1].Rank != 2 || board.From != nil {
+		t.Errorf("leaderboard: %d %s", w.Code, w.Body.String())
+	}
+
+	now := time.Now().UTC()
+	challenge := map[string]interface{}{
+		"title": "30 bench reps", "metric": "reps", "exercise_id": 1, "target": 30,
+		"starts_at": now.Add(-time.Hour), "ends_at": now.Add(-2 * time.Hour),
+	}
+	if w := doJSON(r, "POST", path+"/challenges", member, challenge); w.Code != http.StatusBadRequest {
+		t.Errorf("ending before it starts: %d %s", w.Code, w.Body.String())
+	}
+	challenge["ends_at"] = now.Add(7 * 24 * time.Hour)
+	var ch models.Challenge
+	w = doJSON(r, "POST", path+"/challenges", member, challenge)
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &ch) != nil {
+		t.Fatalf("challenge: %d %s", w.Code, w.Body.String())
+	}
+	if len(ch.Standings) != 2 || !ch.Standings[0].Completed || ch.Standings[0].Progress != 30 || ch.Standings[1].Completed {
+		t.Errorf("standings = %+v", ch.Standings)
+	}
+	if w := doJSON(r, "GET", fmt.Sprintf("%s/challenges/%d", path, ch.ID), outsider, nil); w.Code != http.StatusNotFound {
+		t.Errorf("outsider's challenge: %d", w.Code)
+	}
+
+	w = doJSON(r, "DELETE", path+"/members/1", owner, nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusConflict || p.Code != "team_owner_cannot_leave" {
+		t.Errorf("owner leaving: %d %+v", w.Code, p)
+	}
+	w = doJSON(r, "DELETE", path, member, nil)
+	if p := decodeProblem(t, w); w.Code != http.StatusForbidden || p.Code != "team_owner_required" {
+		t.Errorf("member deleting: %d %+v", w.Code, p)
+	}
+	if w := doJSON(r, "DELETE", path+"/members/2", owner, nil); w.Code != http.StatusOK {
+		t.Errorf("remove member: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "DELETE", path, owner, nil); w.Code != http.StatusOK {
+		t.Errorf("delete: %d %s", w.Code, w.Body.String())
+	}
+}
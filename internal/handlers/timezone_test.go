@@ -0,0 +1,102 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUserTimeZone(t *testing.T) {
+	r, _ := setupTestRouter(t)
+
+	w := doJSON(r, "POST", "/auth/register", "", map[string]string{
+		"name": "TZ", "email": "tz@test.com", "password": "pass123", "timezone": "Mars/Olympus",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown zone: expected 400, got %d", w.Code)
+	}
+
+	w = doJSON(r, "POST", "/auth/register", "", map[string]string{
+		"name": "TZ", "email": "tz@test.com", "password": "pass123", "timezone": "Asia/Tokyo",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: %d %s", w.Code, w.Body.String())
+	}
+	var auth struct {
+		Token string `json:"token"`
+		User  struct {
+			TimeZone string `json:"timezone"`
+		} `json:"user"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &auth)
+	if auth.User.TimeZone != "Asia/Tokyo" {
+		t.Errorf("time zone = %q", auth.User.TimeZone)
+	}
+
+	// 20:00 UTC on 1 March is 05:00 on 2 March in Tokyo.
+	w = doJSON(r, "POST", "/workouts", auth.Token, map[string]interface{}{
+		"title": "Early", "scheduled_at": "2024-03-01T20:00:00Z",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ScheduledAt time.Time `json:"scheduled_at"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if _, offset := created.ScheduledAt.Zone(); offset != 9*3600 {
+		t.Errorf("scheduled_at returned with offset %ds, want +09:00", offset)
+	}
+
+	w = doJSON(r, "GET", "/workouts?from=2024-03-02&to=2024-03-02", auth.Token, nil)
+	var list []map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list) != 1 {
+		t.Errorf("workouts on 2 March in Tokyo = %d, want 1", len(list))
+	}
+
+	w = doJSON(r, "PATCH", "/auth/me", auth.Token, map[string]string{"timezone": "UTC"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH /auth/me: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, "GET", "/workouts?from=2024-03-02&to=2024-03-02", auth.Token, nil)
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list) != 0 {
+		t.Errorf("workouts on 2 March in UTC = %d, want 0", len(list))
+	}
+
+	w = doJSON(r, "PATCH", "/auth/me", auth.Token, map[string]string{"timezone": "Local"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Local zone: expected 400, got %d", w.Code)
+	}
+}
+
+func TestReportCountsTodayInUserZone(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	w := doJSON(r, "POST", "/auth/register", "", map[string]string{
+		"name": "TZ", "email": "today@test.com", "password": "pass123", "timezone": "Pacific/Kiritimati",
+	})
+	var auth struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &auth)
+
+	w = doJSON(r, "POST", "/workouts", auth.Token, map[string]interface{}{"title": "Now"})
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	doJSON(r, "PUT", "/workouts/"+itoa(int(created.ID)), auth.Token, map[string]string{"status": "completed"})
+
+	w = doJSON(r, "GET", "/workouts/report", auth.Token, nil)
+	var report struct {
+		TimeZone          string `json:"timezone"`
+		CompletedToday    int    `json:"completed_today"`
+		CompletedThisWeek int    `json:"completed_this_week"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.TimeZone != "Pacific/Kiritimati" || report.CompletedToday != 1 || report.CompletedThisWeek != 1 {
+		t.Errorf("report = %+v", report)
+	}
+}
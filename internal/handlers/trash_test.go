@@ -0,0 +1,44 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func TestTrashAndRestore(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "trash@test.com")
+
+	var created models.Workout
+	json.Unmarshal(doJSON(r, "POST", "/workouts", token, map[string]interface{}{"title": "Oops"}).Body.Bytes(), &created)
+	path := "/workouts/" + itoa(int(created.ID))
+	if w := doJSON(r, "DELETE", path, token, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, "GET", path, token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET of a trashed workout: %d", w.Code)
+	}
+
+	var trash []models.Workout
+	json.Unmarshal(doJSON(r, "GET", "/workouts/trash", token, nil).Body.Bytes(), &trash)
+	if len(trash) != 1 || trash[0].ID != created.ID || trash[0].DeletedAt == nil {
+		t.Fatalf("trash: %+v", trash)
+	}
+
+	w := doJSON(r, "POST", path+"/restore", token, nil)
+	var restored models.Workout
+	json.Unmarshal(w.Body.Bytes(), &restored)
+	if w.Code != http.StatusOK || restored.DeletedAt != nil || w.Header().Get("ETag") != `"3"` {
+		t.Fatalf("restore: %d %s", w.Code, w.Body.String())
+	}
+	var list []models.Workout
+	json.Unmarshal(doJSON(r, "GET", "/workouts", token, nil).Body.Bytes(), &list)
+	if len(list) != 1 {
+		t.Errorf("workouts after restore: %+v", list)
+	}
+	if w := doJSON(r, "POST", path+"/restore", token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("restore outside the trash: %d", w.Code)
+	}
+}
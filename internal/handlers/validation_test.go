@@ -0,0 +1,62 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func TestWorkoutValidationReportsAllViolations(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "rules@test.com")
+
+	w := doJSON(r, "POST", "/workouts", token, map[string]interface{}{
+		"title": "",
+		"exercises": []map[string]interface{}{
+			{"exercise_id": 1, "sets": -3, "reps": 0, "weight_kg": 10000},
+			{"exercise_id": 999999, "sets": 1, "reps": 1},
+		},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	p := decodeProblem(t, w)
+	got := map[string]bool{}
+	for _, e := range p.Errors {
+		got[e.Pointer] = true
+	}
+	for _, ptr := range []string{"/title", "/exercises/0/sets", "/exercises/0/reps", "/exercises/0/weight_kg", "/exercises/1/exercise_id"} {
+		if !got[ptr] {
+			t.Errorf("no violation reported at %s: %+v", ptr, p.Errors)
+		}
+	}
+
+	w = doJSON(r, "POST", "/workouts", token, map[string]interface{}{"title": "Fine"})
+	var created models.Workout
+	json.Unmarshal(w.Body.Bytes(), &created)
+	w = doJSON(r, "PUT", "/workouts/"+itoa(int(created.ID)), token, map[string]interface{}{
+		"status":    "skipped",
+		"exercises": []map[string]interface{}{{"exercise_id": 1, "sets": 3}},
+	})
+	if p := decodeProblem(t, w); w.Code != http.StatusBadRequest || len(p.Errors) != 2 {
+		t.Errorf("invalid update: %d %+v", w.Code, p)
+	}
+}
+
+func TestImportSkipsInvalidWorkouts(t *testing.T) {
+	r, _ := setupTestRouter(t)
+	token := registerAndGetToken(t, r, "rules-import@test.com")
+
+	csv := strongExport + `2024-03-03 18:00:00,Legs,1h,Squat (Barbell),1,2500,5,0,0,,,
+`
+	for _, path := range []string{"/import?dry_run=true", "/import"} {
+		w := uploadCSV(r, path, token, csv)
+		var report models.ImportReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		if report.WorkoutsCreated != 1 || report.WorkoutsInvalid != 1 || len(report.Errors) != 1 ||
+			report.Errors[0].Pointer != "/workouts/1/exercises/0/weight_kg" {
+			t.Errorf("%s: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+}
@@ -1,19 +1,22 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 	"workout-tracker/internal/database"
 	"workout-tracker/internal/models"
+	"workout-tracker/internal/validation"
 
 	"github.com/gin-gonic/gin"
 )
 
 type WorkoutHandler struct {
-	db *database.DB
+	db database.Store
 }
 
-func NewWorkoutHandler(db *database.DB) *WorkoutHandler {
+func NewWorkoutHandler(db database.Store) *WorkoutHandler {
 	return &WorkoutHandler{db: db}
 }
 
@@ -21,96 +24,343 @@ func NewWorkoutHandler(db *database.DB) *WorkoutHandler {
 func (h *WorkoutHandler) Create(c *gin.Context) {
 	userID := c.GetInt64("userID")
 	var req models.CreateWorkoutRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
-	workout, err := h.db.CreateWorkout(userID, req)
+	lib, err := exerciseLibrary(h.db, userID, len(req.Exercises) > 0)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
+	if err := validation.Error(validation.CreateWorkout(&req, lib)); err != nil {
+		c.Error(err)
+		return
+	}
+	workout, err := audited(h.db, c).CreateWorkout(userID, req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !h.localize(c, workout) {
+		return
+	}
+	c.Header("ETag", etag(workout.Version))
 	c.JSON(http.StatusCreated, workout)
 }
 
-// GET /workouts
+// exerciseLibrary loads the exercises the user may log, which validation
+// needs only when the payload lists exercises.
+func exerciseLibrary(db database.Store, userID int64, needed bool) (validation.Library, error) {
+	if !needed {
+		return nil, nil
+	}
+	exercises, err := db.GetExercises(userID)
+	if err != nil {
+		return nil, err
+	}
+	return validation.NewLibrary(exercises), nil
+}
+
+// localize converts workouts to the user's time zone, writing an error
+// response and returning false if the zone cannot be loaded.
+func (h *WorkoutHandler) localize(c *gin.Context, workouts ...*models.Workout) bool {
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	for _, w := range workouts {
+		w.In(loc)
+	}
+	return true
+}
+
+// GET /workouts?status=&from=&to=&exercise_id=&q=&sort=&limit=&cursor=
+//
+// from and to are dates in the user's time zone. Without limit or cursor
+// the response is the plain array of every match. Paginated requests get a
+// page object with the total and next_cursor. The total and next cursor
+// are also sent as X-Total-Count and X-Next-Cursor.
 func (h *WorkoutHandler) List(c *gin.Context) {
 	userID := c.GetInt64("userID")
-	status := c.Query("status") // pending, active, completed, or empty for all
-	workouts, err := h.db.ListWorkouts(userID, status)
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	filter, err := parseWorkoutFilter(c, loc)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	page, err := h.db.ListWorkouts(userID, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	if workouts == nil {
-		workouts = []models.Workout{}
+	for i := range page.Workouts {
+		page.Workouts[i].In(loc)
+	}
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	if c.Query("limit") == "" && c.Query("cursor") == "" {
+		c.JSON(http.StatusOK, page.Workouts)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// parseWorkoutFilter reads the listing parameters shared by GET /workouts
+// and the report, with dates taken in loc.
+func parseWorkoutFilter(c *gin.Context, loc *time.Location) (models.WorkoutFilter, error) {
+	f := models.WorkoutFilter{
+		Status: c.Query("status"), // pending, active, completed, or empty for all
+		Query:  c.Query("q"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+	var err error
+	if f.From, err = parseDateParam(c.Query("from"), loc); err != nil {
+		return f, invalidParam("from", dateFormats)
+	}
+	if f.To, err = parseDateParam(c.Query("to"), loc); err != nil {
+		return f, invalidParam("to", dateFormats)
+	}
+	if v := c.Query("exercise_id"); v != "" {
+		if f.ExerciseID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, invalidParam("exercise_id", "must be a number")
+		}
 	}
-	c.JSON(http.StatusOK, workouts)
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			return f, invalidParam("limit", "must be a positive number")
+		}
+		if f.Limit > database.MaxPageSize {
+			f.Limit = database.MaxPageSize
+		}
+	}
+	if !database.ValidSort(f.Sort) {
+		return f, invalidParam("sort", "must be one of date, created, title, optionally prefixed with -")
+	}
+	return f, nil
 }
 
 // GET /workouts/:id
+//
+// The ETag is the workout's version; If-None-Match with it gives 304.
 func (h *WorkoutHandler) Get(c *gin.Context) {
 	userID := c.GetInt64("userID")
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.Error(errInvalidID)
 		return
 	}
 	workout, err := h.db.GetWorkoutByID(id, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 	if workout == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "workout not found"})
+		c.Error(database.ErrWorkoutNotFound)
+		return
+	}
+	if notModified(c, workout.Version) {
+		return
+	}
+	if !h.localize(c, workout) {
 		return
 	}
+	c.Header("ETag", etag(workout.Version))
 	c.JSON(http.StatusOK, workout)
 }
 
 // PUT /workouts/:id
+//
+// Sets the fields present in the body; exercises, when present, replace
+// the whole list. With If-Match the update only happens if the workout is
+// still at that version, else 412.
 func (h *WorkoutHandler) Update(c *gin.Context) {
 	userID := c.GetInt64("userID")
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.Error(errInvalidID)
 		return
 	}
 	var req models.UpdateWorkoutRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
+		return
+	}
+	lib, err := exerciseLibrary(h.db, userID, len(req.Exercises) > 0)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := validation.Error(validation.UpdateWorkout(&req, lib)); err != nil {
+		c.Error(err)
+		return
+	}
+	workout, err := audited(h.db, c).UpdateWorkout(id, userID, req, ifMatch(c))
+	if err != nil {
+		c.Error(conditionalError(c, err))
+		return
+	}
+	if !h.localize(c, workout) {
+		return
+	}
+	c.Header("ETag", etag(workout.Version))
+	c.JSON(http.StatusOK, workout)
+}
+
+// maxPatchBody caps PATCH documents, which carry a single workout.
+const maxPatchBody = 1 << 20
+
+// PATCH /workouts/:id
+//
+// With Content-Type application/merge-patch+json (or application/json) the
+// body is a JSON Merge Patch of the workout: present fields are set, null
+// clears them, and an exercises array replaces the list, with entries that
+// keep their id updated in place. With application/json-patch+json it is a
+// JSON Patch, which can add, change or remove single exercises, e.g.
+// {"op": "remove", "path": "/exercises/2"}. Only exercise entries that
+// change are written. If-Match works as for PUT.
+func (h *WorkoutHandler) Patch(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchBody))
+	if err != nil {
+		c.Error(database.Invalid("invalid_request", "request body is too large or unreadable"))
+		return
+	}
+
+	workout, err := h.db.GetWorkoutByID(id, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if workout == nil {
+		c.Error(database.ErrWorkoutNotFound)
+		return
+	}
+	if v := ifMatch(c); v != 0 && v != workout.Version {
+		c.Error(errPreconditionFailed)
 		return
 	}
-	workout, err := h.db.UpdateWorkout(id, userID, req)
+
+	old := workout.State()
+	patched, err := applyWorkoutPatch(c.ContentType(), body, old)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	lib, err := exerciseLibrary(h.db, userID, len(patched.Exercises) > 0)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := validation.Error(validation.PatchWorkout(&old, &patched, lib)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	workout, err = audited(h.db, c).SaveWorkout(id, userID, workout.Version, patched)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(conditionalError(c, err))
 		return
 	}
+	if !h.localize(c, workout) {
+		return
+	}
+	c.Header("ETag", etag(workout.Version))
 	c.JSON(http.StatusOK, workout)
 }
 
 // DELETE /workouts/:id
+//
+// If-Match works as for PUT.
 func (h *WorkoutHandler) Delete(c *gin.Context) {
 	userID := c.GetInt64("userID")
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		c.Error(errInvalidID)
 		return
 	}
-	if err := h.db.DeleteWorkout(id, userID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	if err := audited(h.db, c).DeleteWorkout(id, userID, ifMatch(c)); err != nil {
+		c.Error(conditionalError(c, err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
 }
 
+// GET /workouts/trash
+//
+// Lists deleted workouts, most recent first, until they are purged.
+func (h *WorkoutHandler) Trash(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	loc, err := userLocation(h.db, c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	list, err := h.db.ListTrash(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range list {
+		list[i].In(loc)
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// POST /workouts/:id/restore
+func (h *WorkoutHandler) Restore(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	workout, err := audited(h.db, c).RestoreWorkout(id, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !h.localize(c, workout) {
+		return
+	}
+	c.Header("ETag", etag(workout.Version))
+	c.JSON(http.StatusOK, workout)
+}
+
 // GET /workouts/report
+//
+// Accepts the same filter parameters as GET /workouts. Today and this week
+// are counted in the user's time zone.
 func (h *WorkoutHandler) Report(c *gin.Context) {
 	userID := c.GetInt64("userID")
-	report, err := h.db.GetReport(userID)
+	loc, err := userLocation(h.db, c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
+	filter, err := parseWorkoutFilter(c, loc)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	report, err := h.db.GetReport(userID, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range report.Workouts {
+		report.Workouts[i].In(loc)
+	}
 	c.JSON(http.StatusOK, report)
 }
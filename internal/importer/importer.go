@@ -0,0 +1,369 @@
+// Package importer reads CSV exports of other workout apps (Strong, Hevy
+// and FitNotes) into a neutral list of workouts and sets.
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatStrong   Format = "strong"
+	FormatHevy     Format = "hevy"
+	FormatFitNotes Format = "fitnotes"
+)
+
+const lbToKg = 0.45359237
+
+var ErrUnknownFormat = errors.New("unrecognised CSV export, expected Strong, Hevy or FitNotes")
+
+// Set is one logged set. Exercise is the name used by the source app.
+type Set struct {
+	Exercise    string
+	Category    string // only FitNotes exports carry a category
+	WeightKg    float64
+	Reps        int
+	DistanceM   float64
+	DurationSec int
+	Notes       string
+}
+
+// Workout groups the sets done in one session.
+type Workout struct {
+	ExternalID  string // stable across re-exports of the same session
+	Title       string
+	Notes       string
+	StartedAt   time.Time
+	DurationSec int
+	Sets        []Set
+}
+
+// Result is a parsed export.
+type Result struct {
+	Format   Format
+	Workouts []Workout
+	Warnings []string // rows that were skipped, with the reason
+}
+
+// Parse detects the export format from the header row and parses every
+// row. Rows that cannot be read are skipped and reported as warnings.
+// Times without a zone, which is what all three apps write, are taken to be
+// in loc.
+func Parse(r io.Reader, loc *time.Location) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+
+	cr := csv.NewReader(strings.NewReader(text))
+	cr.FieldsPerRecord = -1
+	if first, _, _ := strings.Cut(text, "\n"); strings.Count(first, ";") > strings.Count(first, ",") {
+		cr.Comma = ';' // older Strong exports
+	}
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrUnknownFormat
+	}
+
+	cols := columnIndex(records[0])
+	format := Detect(records[0])
+	var parse func(row func(string) string) (key string, w Workout, s *Set, err error)
+	switch format {
+	case FormatStrong:
+		parse = parseStrongRow(loc)
+	case FormatHevy:
+		parse = parseHevyRow(cols, loc)
+	case FormatFitNotes:
+		parse = parseFitNotesRow(cols, loc)
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	res := &Result{Format: format}
+	byKey := map[string]int{}
+	for i, rec := range records[1:] {
+		row := func(name string) string {
+			if idx, ok := cols[name]; ok && idx < len(rec) {
+				return strings.TrimSpace(rec[idx])
+			}
+			return ""
+		}
+		key, w, set, err := parse(row)
+		if err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("row %d: %v", i+2, err))
+			continue
+		}
+		idx, ok := byKey[key]
+		if !ok {
+			w.ExternalID = externalID(format, key)
+			res.Workouts = append(res.Workouts, w)
+			idx = len(res.Workouts) - 1
+			byKey[key] = idx
+		}
+		if set != nil {
+			res.Workouts[idx].Sets = append(res.Workouts[idx].Sets, *set)
+		}
+	}
+
+	sort.SliceStable(res.Workouts, func(i, j int) bool {
+		return res.Workouts[i].StartedAt.Before(res.Workouts[j].StartedAt)
+	})
+	return res, nil
+}
+
+// Detect identifies the exporting app from the CSV header.
+func Detect(header []string) Format {
+	cols := columnIndex(header)
+	has := func(names ...string) bool {
+		for _, n := range names {
+			if _, ok := cols[n]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case has("workout name", "exercise name", "set order"):
+		return FormatStrong
+	case has("title", "start_time", "exercise_title", "set_index"):
+		return FormatHevy
+	case has("date", "exercise", "category", "reps"):
+		return FormatFitNotes
+	}
+	return ""
+}
+
+func columnIndex(header []string) map[string]int {
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	return cols
+}
+
+// Block is a run of identical consecutive sets of one exercise, stored as a
+// single workout exercise with Sets set to the run length.
+type Block struct {
+	Sets int
+	Set
+}
+
+// Collapse groups consecutive identical sets so that five sets of 5x100kg
+// become one block instead of five rows.
+func Collapse(sets []Set) []Block {
+	var blocks []Block
+	for _, s := range sets {
+		if n := len(blocks); n > 0 {
+			last := &blocks[n-1]
+			if last.Set == s {
+				last.Sets++
+				continue
+			}
+		}
+		blocks = append(blocks, Block{Sets: 1, Set: s})
+	}
+	return blocks
+}
+
+func externalID(format Format, key string) string {
+	sum := sha256.Sum256([]byte(string(format) + "|" + key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ---- Strong ----
+
+// Strong: Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,
+// Distance,Seconds,Notes,Workout Notes,RPE. Weight and distance are in the
+// user's units, which the export does not state; kilograms and kilometres
+// are assumed.
+func parseStrongRow(loc *time.Location) func(row func(string) string) (string, Workout, *Set, error) {
+	return func(row func(string) string) (string, Workout, *Set, error) {
+		started, err := parseTime(row("date"), loc, "2006-01-02 15:04:05", "2006-01-02 15:04")
+		if err != nil {
+			return "", Workout{}, nil, err
+		}
+		w := Workout{
+			Title:       row("workout name"),
+			Notes:       row("workout notes"),
+			StartedAt:   started,
+			DurationSec: parseStrongDuration(row("duration")),
+		}
+		key := started.Format(keyLayout) + "|" + w.Title
+
+		// Rest timer rows have no exercise data.
+		if row("set order") == "Rest Timer" || row("exercise name") == "" {
+			return key, w, nil, nil
+		}
+		set := &Set{
+			Exercise:    row("exercise name"),
+			WeightKg:    parseFloat(row("weight")),
+			Reps:        int(parseFloat(row("reps"))),
+			DistanceM:   parseFloat(row("distance")) * 1000,
+			DurationSec: int(parseFloat(row("seconds"))),
+			Notes:       row("notes"),
+		}
+		return key, w, set, nil
+	}
+}
+
+// parseStrongDuration reads values like "1h 5m", "45m" or "30s".
+func parseStrongDuration(s string) int {
+	total := 0
+	for _, part := range strings.Fields(s) {
+		if len(part) < 2 {
+			continue
+		}
+		n, err := strconv.Atoi(part[:len(part)-1])
+		if err != nil {
+			continue
+		}
+		switch part[len(part)-1] {
+		case 'h':
+			total += n * 3600
+		case 'm':
+			total += n * 60
+		case 's':
+			total += n
+		}
+	}
+	return total
+}
+
+// ---- Hevy ----
+
+// Hevy: title,start_time,end_time,description,exercise_title,superset_id,
+// exercise_notes,set_index,set_type,weight_kg|weight_lbs,reps,
+// distance_km|distance_miles,duration_seconds,rpe
+func parseHevyRow(cols map[string]int, loc *time.Location) func(row func(string) string) (string, Workout, *Set, error) {
+	_, lbs := cols["weight_lbs"]
+	_, miles := cols["distance_miles"]
+	layouts := []string{"2 Jan 2006, 15:04", "2006-01-02 15:04:05", time.RFC3339}
+	return func(row func(string) string) (string, Workout, *Set, error) {
+		started, err := parseTime(row("start_time"), loc, layouts...)
+		if err != nil {
+			return "", Workout{}, nil, err
+		}
+		w := Workout{Title: row("title"), Notes: row("description"), StartedAt: started}
+		if ended, err := parseTime(row("end_time"), loc, layouts...); err == nil && ended.After(started) {
+			w.DurationSec = int(ended.Sub(started).Seconds())
+		}
+		key := started.Format(keyLayout) + "|" + w.Title
+
+		if row("set_type") == "warmup" {
+			return key, w, nil, nil
+		}
+		set := &Set{
+			Exercise:    row("exercise_title"),
+			Reps:        int(parseFloat(row("reps"))),
+			DurationSec: int(parseFloat(row("duration_seconds"))),
+			Notes:       row("exercise_notes"),
+		}
+		if lbs {
+			set.WeightKg = parseFloat(row("weight_lbs")) * lbToKg
+		} else {
+			set.WeightKg = parseFloat(row("weight_kg"))
+		}
+		if miles {
+			set.DistanceM = parseFloat(row("distance_miles")) * 1609.344
+		} else {
+			set.DistanceM = parseFloat(row("distance_km")) * 1000
+		}
+		if set.Exercise == "" {
+			return "", Workout{}, nil, errors.New("missing exercise_title")
+		}
+		return key, w, set, nil
+	}
+}
+
+// ---- FitNotes ----
+
+// FitNotes: Date,Exercise,Category,Weight (kgs)|Weight (lbs),Reps,Distance,
+// Distance Unit,Time,Comment. FitNotes has no sessions, so each training
+// day becomes one workout.
+func parseFitNotesRow(cols map[string]int, loc *time.Location) func(row func(string) string) (string, Workout, *Set, error) {
+	weightCol, factor := "weight (kgs)", 1.0
+	if _, ok := cols["weight (lbs)"]; ok {
+		weightCol, factor = "weight (lbs)", lbToKg
+	}
+	return func(row func(string) string) (string, Workout, *Set, error) {
+		day, err := parseTime(row("date"), loc, "2006-01-02")
+		if err != nil {
+			return "", Workout{}, nil, err
+		}
+		w := Workout{Title: "FitNotes " + day.Format("2006-01-02"), StartedAt: day}
+		key := day.Format("2006-01-02")
+
+		set := &Set{
+			Exercise:    row("exercise"),
+			Category:    row("category"),
+			WeightKg:    parseFloat(row(weightCol)) * factor,
+			Reps:        int(parseFloat(row("reps"))),
+			DurationSec: parseClock(row("time")),
+			Notes:       row("comment"),
+		}
+		dist := parseFloat(row("distance"))
+		switch strings.ToLower(row("distance unit")) {
+		case "m":
+			set.DistanceM = dist
+		case "mi":
+			set.DistanceM = dist * 1609.344
+		default:
+			set.DistanceM = dist * 1000
+		}
+		if set.Exercise == "" {
+			return "", Workout{}, nil, errors.New("missing exercise")
+		}
+		return key, w, set, nil
+	}
+}
+
+// parseClock reads "h:mm:ss" or "mm:ss".
+func parseClock(s string) int {
+	if s == "" {
+		return 0
+	}
+	total := 0
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return total
+}
+
+// keyLayout writes the wall-clock time an app recorded, whatever loc is,
+// so a workout keeps its external ID when the user's time zone changes.
+const keyLayout = "2006-01-02T15:04:05Z"
+
+// parseTime reads a time in loc, unless it carries its own offset, and
+// keeps it in the zone it was read in so its wall clock is available for
+// keys.
+func parseTime(s string, loc *time.Location, layouts ...string) (time.Time, error) {
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", s)
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+	return f
+}
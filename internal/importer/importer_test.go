@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+const strongCSV = `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE
+2024-03-01 18:00:00,Push,1h 5m,Bench Press (Barbell),1,80,5,0,0,,,
+2024-03-01 18:00:00,Push,1h 5m,Bench Press (Barbell),2,80,5,0,0,,,
+2024-03-01 18:00:00,Push,1h 5m,Bench Press (Barbell),Rest Timer,0,0,0,90,,,
+2024-03-01 18:00:00,Push,1h 5m,Cable Crossover,1,20,12,0,0,,,
+2024-03-03 09:00:00,Legs,45m,Squat (Barbell),1,100,5,0,0,,,
+`
+
+const hevyCSV = `"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_lbs","reps","distance_miles","duration_seconds","rpe"
+"Pull","5 Mar 2024, 07:30","5 Mar 2024, 08:15","","Pull Up","","","0","warmup","","5","","",""
+"Pull","5 Mar 2024, 07:30","5 Mar 2024, 08:15","","Pull Up","","","1","normal","","8","","",""
+"Pull","5 Mar 2024, 07:30","5 Mar 2024, 08:15","","Treadmill","","","0","normal","","","1","600",""
+`
+
+const fitNotesCSV = `Date,Exercise,Category,Weight (kgs),Reps,Distance,Distance Unit,Time,Comment
+2024-03-07,Deadlift,Back,140.0,3,,,,
+2024-03-07,Rowing Machine,Cardio,,,2000,m,0:08:00,
+bad-date,Deadlift,Back,140.0,3,,,,
+`
+
+func TestParseStrong(t *testing.T) {
+	res, err := Parse(strings.NewReader(strongCSV), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Format != FormatStrong || len(res.Workouts) != 2 {
+		t.Fatalf("format %q, %d workouts", res.Format, len(res.Workouts))
+	}
+	push := res.Workouts[0]
+	if push.Title != "Push" || push.DurationSec != 3900 || len(push.Sets) != 3 {
+		t.Fatalf("unexpected push workout %+v", push)
+	}
+	blocks := Collapse(push.Sets)
+	if len(blocks) != 2 || blocks[0].Sets != 2 || blocks[0].WeightKg != 80 {
+		t.Fatalf("unexpected blocks %+v", blocks)
+	}
+
+	again, _ := Parse(strings.NewReader(strongCSV), time.UTC)
+	if again.Workouts[0].ExternalID != push.ExternalID {
+		t.Fatal("external IDs must be stable across parses")
+	}
+}
+
+func TestParseHevy(t *testing.T) {
+	res, err := Parse(strings.NewReader(hevyCSV), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Format != FormatHevy || len(res.Workouts) != 1 {
+		t.Fatalf("format %q, %d workouts", res.Format, len(res.Workouts))
+	}
+	w := res.Workouts[0]
+	if w.DurationSec != 2700 || len(w.Sets) != 2 {
+		t.Fatalf("unexpected workout %+v", w)
+	}
+	if run := w.Sets[1]; run.DistanceM < 1609 || run.DistanceM > 1610 || run.DurationSec != 600 {
+		t.Fatalf("unexpected cardio set %+v", run)
+	}
+}
+
+func TestParseFitNotes(t *testing.T) {
+	res, err := Parse(strings.NewReader(fitNotesCSV), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Format != FormatFitNotes || len(res.Workouts) != 1 || len(res.Warnings) != 1 {
+		t.Fatalf("format %q, %d workouts, warnings %v", res.Format, len(res.Workouts), res.Warnings)
+	}
+	row := res.Workouts[0].Sets[1]
+	if row.DistanceM != 2000 || row.DurationSec != 480 {
+		t.Fatalf("unexpected cardio set %+v", row)
+	}
+	if GuessCategory([]Set{row}) != "cardio" || GuessMuscleGroup(res.Workouts[0].Sets[:1]) != "back" {
+		t.Fatal("unexpected category guesses")
+	}
+}
+
+func TestMatchExercise(t *testing.T) {
+	library := []models.Exercise{{ID: 1, Name: "Bench Press"}, {ID: 5, Name: "Pull-Up"}, {ID: 22, Name: "Running"}}
+	cases := map[string]int64{
+		"Bench Press (Barbell)": 1,
+		"Pull Up":               5,
+		"treadmill":             22,
+		"Cable Crossover":       0,
+	}
+	for name, want := range cases {
+		got := MatchExercise(name, library)
+		if (got == nil && want != 0) || (got != nil && got.ID != want) {
+			t.Errorf("MatchExercise(%q) = %v, want %d", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"strings"
+	"workout-tracker/internal/models"
+)
+
+// aliases maps names used by other apps, after normalisation, onto names in
+// the seeded exercise library.
+var aliases = map[string]string{
+	"barbell bench press":          "bench press",
+	"flat bench press":             "bench press",
+	"back squat":                   "squat",
+	"barbell squat":                "squat",
+	"pull up":                      "pull-up",
+	"pullup":                       "pull-up",
+	"chin up":                      "pull-up",
+	"push up":                      "push-up",
+	"pushup":                       "push-up",
+	"barbell row":                  "bent over row",
+	"bent over barbell row":        "bent over row",
+	"military press":               "overhead press",
+	"shoulder press":               "overhead press",
+	"rdl":                          "romanian deadlift",
+	"lat pull down":                "lat pulldown",
+	"run":                          "running",
+	"treadmill":                    "running",
+	"bike":                         "cycling",
+	"stationary bike":              "cycling",
+	"rowing":                       "rowing machine",
+	"rower":                        "rowing machine",
+	"dips":                         "tricep dips",
+	"standing calf raise":          "calf raise",
+	"incline dumbbell bench press": "incline dumbbell press",
+}
+
+// Normalize lower-cases a name and drops the equipment suffix Strong and
+// Hevy append, so "Bench Press (Barbell)" becomes "bench press".
+func Normalize(name string) string {
+	n := strings.ToLower(strings.TrimSpace(name))
+	if i := strings.Index(n, "("); i > 0 {
+		n = strings.TrimSpace(n[:i])
+	}
+	return strings.Join(strings.Fields(n), " ")
+}
+
+// MatchExercise finds the library exercise for a name used by another app,
+// or nil when there is no match and a custom exercise is needed.
+func MatchExercise(name string, library []models.Exercise) *models.Exercise {
+	exact := strings.ToLower(strings.TrimSpace(name))
+	norm := Normalize(name)
+	alias := aliases[norm]
+
+	var byNorm, byAlias *models.Exercise
+	for i := range library {
+		e := &library[i]
+		lower := strings.ToLower(e.Name)
+		switch {
+		case lower == exact:
+			return e
+		case byNorm == nil && lower == norm:
+			byNorm = e
+		case byAlias == nil && alias != "" && lower == alias:
+			byAlias = e
+		}
+	}
+	if byNorm != nil {
+		return byNorm
+	}
+	return byAlias
+}
+
+// GuessCategory picks a category for a custom exercise from how its sets
+// were logged.
+func GuessCategory(sets []Set) string {
+	for _, s := range sets {
+		if c := strings.ToLower(s.Category); c == "cardio" || c == "flexibility" {
+			return c
+		}
+		if s.WeightKg > 0 || s.Reps > 0 {
+			return "strength"
+		}
+		if s.DistanceM > 0 || s.DurationSec > 0 {
+			return "cardio"
+		}
+	}
+	return "strength"
+}
+
+// GuessMuscleGroup uses the FitNotes category when it names a body part.
+func GuessMuscleGroup(sets []Set) string {
+	for _, s := range sets {
+		if c := strings.ToLower(s.Category); c != "" && c != "cardio" && c != "flexibility" {
+			return c
+		}
+	}
+	return ""
+}
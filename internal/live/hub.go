@@ -0,0 +1,154 @@
+// Package live fans live workout session events out to every device a user
+// has open and keeps the server-side rest timers.
+package live
+
+import (
+	"sync"
+	"time"
+	"workout-tracker/internal/models"
+)
+
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[int64]map[chan models.SessionEvent]struct{}
+	timers map[int64]*time.Timer // keyed by session ID
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs:   make(map[int64]map[chan models.SessionEvent]struct{}),
+		timers: make(map[int64]*time.Timer),
+	}
+}
+
+// Subscribe registers a stream for a user. The returned func must be called
+// when the stream closes. The channel is closed when the hub stops.
+func (h *Hub) Subscribe(userID int64) (<-chan models.SessionEvent, func()) {
+	ch := make(chan models.SessionEvent, 16)
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[chan models.SessionEvent]struct{})
+	}
+	h.subs[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[userID], ch)
+		if len(h.subs[userID]) == 0 {
+			delete(h.subs, userID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Publish sends an event to every open stream of a user. Slow streams drop
+// events rather than block the publisher.
+func (h *Hub) Publish(userID int64, ev models.SessionEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[userID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// StartRest publishes a timer start and schedules the matching timer end.
+// Restarting the rest of a session replaces its pending timer.
+func (h *Hub) StartRest(s models.WorkoutSession) {
+	if s.RestUntil == nil {
+		return
+	}
+	h.Publish(s.UserID, models.SessionEvent{
+		Type:      "timer",
+		WorkoutID: s.WorkoutID,
+		Data:      timerData("started", s),
+	})
+	h.scheduleRestEnd(s)
+}
+
+// ResumeRest reschedules the timer end of a session loaded from the
+// database, e.g. after a restart. Rests that already ended are ignored.
+func (h *Hub) ResumeRest(s models.WorkoutSession) {
+	if s.RestUntil == nil || !s.RestUntil.After(time.Now()) {
+		return
+	}
+	h.scheduleRestEnd(s)
+}
+
+// StopRest cancels the pending timer of a session.
+func (h *Hub) StopRest(sessionID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t, ok := h.timers[sessionID]; ok {
+		t.Stop()
+		delete(h.timers, sessionID)
+	}
+}
+
+// Stop cancels every pending timer and ends every stream, for a server
+// that shuts down.
+func (h *Hub) Stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, t := range h.timers {
+		t.Stop()
+		delete(h.timers, id)
+	}
+	for userID, chans := range h.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(h.subs, userID)
+	}
+	h.closed = true
+}
+
+func (h *Hub) scheduleRestEnd(s models.WorkoutSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t, ok := h.timers[s.ID]; ok {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(time.Until(*s.RestUntil), func() {
+		h.mu.Lock()
+		if h.timers[s.ID] != t {
+			h.mu.Unlock()
+			return
+		}
+		delete(h.timers, s.ID)
+		h.mu.Unlock()
+
+		h.Publish(s.UserID, models.SessionEvent{
+			Type:      "timer",
+			WorkoutID: s.WorkoutID,
+			Data:      timerData("finished", s),
+		})
+		if s.NextSet != nil {
+			h.Publish(s.UserID, models.SessionEvent{Type: "next_set", WorkoutID: s.WorkoutID, Data: s.NextSet})
+		}
+	})
+	h.timers[s.ID] = t
+}
+
+func timerData(state string, s models.WorkoutSession) map[string]interface{} {
+	remaining := 0
+	if state == "started" {
+		remaining = int(time.Until(*s.RestUntil).Round(time.Second).Seconds())
+	}
+	return map[string]interface{}{
+		"state":         state,
+		"session_id":    s.ID,
+		"ends_at":       s.RestUntil,
+		"remaining_sec": remaining,
+	}
+}
@@ -0,0 +1,43 @@
+// Package logging sets up the server's logs: JSON lines through log/slog,
+// tagged with the request ID and user of the request they were written
+// for when logged with a request's context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// New returns a logger writing JSON lines at level and above to w.
+// Records logged with a gin context, e.g. slog.ErrorContext(c, ...), get
+// its request_id and user_id.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// contextHandler adds the request's attributes to records. A gin context
+// answers Value with what handlers and middleware stored in it.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id, ok := ctx.Value("requestID").(string); ok && id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if userID, ok := ctx.Value("userID").(int64); ok && userID != 0 {
+			r.AddAttrs(slog.Int64("user_id", userID))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
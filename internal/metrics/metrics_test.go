@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	c := NewCounter("test_events_total", "Events, by kind.", "kind")
+	c.Inc(`say "hi"`)
+	c.Add(2, "plain")
+	h := NewHistogram("test_latency_seconds", "Latency.", []float64{.1, 1}, "op")
+	h.Observe(.05, "read")
+	h.Observe(.5, "read")
+	h.Observe(3, "read")
+	NewCounter("test_unlabelled_total", "Never incremented.")
+
+	var b strings.Builder
+	if _, err := Default.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# HELP test_events_total Events, by kind.\n# TYPE test_events_total counter\n",
+		"test_events_total{kind=\"plain\"} 2\ntest_events_total{kind=\"say \\\"hi\\\"\"} 1\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{op="read",le="0.1"} 1` + "\n",
+		`test_latency_seconds_bucket{op="read",le="1"} 2` + "\n",
+		`test_latency_seconds_bucket{op="read",le="+Inf"} 3` + "\n",
+		`test_latency_seconds_sum{op="read"} 3.55` + "\n",
+		`test_latency_seconds_count{op="read"} 3` + "\n",
+		"test_unlabelled_total 0\n",
+		"# TYPE go_goroutines gauge\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q", want)
+		}
+	}
+	if c.Value("plain") != 2 || h.Count("read") != 3 {
+		t.Errorf("Value = %v, Count = %d", c.Value("plain"), h.Count("read"))
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	c := NewCounter("test_checked_total", "Checked.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc with too few label values did not panic")
+		}
+	}()
+	c.Inc("x")
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"runtime"
+	"time"
+)
+
+// The server's metrics.
+var (
+	HTTPRequests = NewCounter("http_requests_total",
+		"HTTP requests served, by method, route and status.", "method", "route", "status")
+	HTTPDuration = NewHistogram("http_request_duration_seconds",
+		"Time to serve HTTP requests, by method and route.", DefaultBuckets, "method", "route")
+
+	DBDuration = NewHistogram("db_query_duration_seconds",
+		"Time spent in database calls, by store method.", DefaultBuckets, "op")
+	DBErrors = NewCounter("db_errors_total",
+		"Database calls that failed other than with a not found, forbidden, conflict or validation error, by store method.", "op")
+
+	WorkoutsCreated = NewCounter("workouts_created_total",
+		"Workouts created, by source: app, activity, coach or import:<format>.", "source")
+	WorkoutsCompleted = NewCounter("workouts_completed_total",
+		"Workouts marked completed, by finishing a session, setting their status or importing them.")
+	AIGenerations = NewCounter("ai_generations_total",
+		"AI workout plans generated in the app, by result: ok or error.", "result")
+)
+
+var startTime = time.Now()
+
+func init() {
+	NewGaugeFunc("go_goroutines", "Number of goroutines.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	NewGaugeFunc("process_start_time_seconds", "Start time of the server since the Unix epoch in seconds.", func() float64 {
+		return float64(startTime.UnixNano()) / 1e9
+	})
+}
+
+// ObserveQuery records a database call that took d, and whether it failed.
+func ObserveQuery(op string, d time.Duration, failed bool) {
+	DBDuration.Observe(d.Seconds(), op)
+	if failed {
+		DBErrors.Inc(op)
+	}
+}
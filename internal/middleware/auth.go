@@ -8,21 +8,59 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRequired() gin.HandlerFunc {
+// AuthRequired lets through requests with a valid bearer token from
+// tokens, setting the user's ID and email in the context.
+func AuthRequired(tokens *auth.Tokens) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.GetHeader("Authorization")
-		if header == "" || !strings.HasPrefix(header, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+		if !strings.HasPrefix(header, "Bearer ") {
+			abort(c, NewError(http.StatusUnauthorized, "missing_token", "missing token"))
 			return
 		}
-		token := strings.TrimPrefix(header, "Bearer ")
-		claims, err := auth.ValidateToken(token)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		authenticate(c, tokens, strings.TrimPrefix(header, "Bearer "))
+	}
+}
+
+// StreamAuthRequired is AuthRequired for event streams. EventSource cannot
+// set headers, so a request without one may pass the token in the
+// access_token query parameter instead. Tokens in URLs end up in access
+// logs and browser history, so no other route accepts them.
+func StreamAuthRequired(tokens *auth.Tokens) gin.HandlerFunc {
+	withHeader := AuthRequired(tokens)
+	return func(c *gin.Context) {
+		if token := c.Query("access_token"); token != "" && c.GetHeader("Authorization") == "" {
+			authenticate(c, tokens, token)
+			return
+		}
+		withHeader(c)
+	}
+}
+
+func authenticate(c *gin.Context, tokens *auth.Tokens, token string) {
+	claims, err := tokens.Validate(token)
+	if err != nil {
+		abort(c, NewError(http.StatusUnauthorized, "invalid_token", "invalid token"))
+		return
+	}
+	c.Set("userID", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Next()
+}
+
+// AdminRequired lets through only users whose email is one of admins. It
+// must run after AuthRequired.
+func AdminRequired(admins []string) gin.HandlerFunc {
+	allowed := map[string]bool{}
+	for _, email := range admins {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			allowed[email] = true
+		}
+	}
+	return func(c *gin.Context) {
+		if !allowed[strings.ToLower(c.GetString("email"))] {
+			abort(c, NewError(http.StatusForbidden, "admin_required", "only admins can do this"))
 			return
 		}
-		c.Set("userID", claims.UserID)
-		c.Set("email", claims.Email)
 		c.Next()
 	}
 }
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Methods and headers browsers may use in cross-origin requests, and the
+// response headers scripts may read.
+const (
+	corsMethods       = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsHeaders       = "Content-Type, Authorization, If-Match, If-None-Match, Idempotency-Key, X-Request-ID"
+	corsExposeHeaders = "ETag, X-Request-ID, X-Total-Count, X-Next-Cursor, Idempotent-Replayed, Retry-After"
+)
+
+// CORSPolicy says which browser origins may call the API.
+type CORSPolicy struct {
+	// Origins are the allowed origins, such as https://app.example.com;
+	// "*" allows any. None allows only same-origin requests.
+	Origins []string
+	// AllowCredentials lets browsers send cookies and HTTP auth. Browsers
+	// ignore it for origins allowed through "*".
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// Explicit returns the policy without its wildcard, allowing only the
+// origins it lists by name. Routes that return secrets use it.
+func (p CORSPolicy) Explicit() CORSPolicy {
+	var origins []string
+	for _, o := range p.Origins {
+		if o != "*" {
+			origins = append(origins, o)
+		}
+	}
+	p.Origins = origins
+	return p
+}
+
+// allows returns the Access-Control-Allow-Origin for origin, or "" if it
+// is not allowed.
+func (p CORSPolicy) allows(origin string) string {
+	for _, o := range p.Origins {
+		if o == origin {
+			return origin
+		}
+		if o == "*" {
+			return "*"
+		}
+	}
+	return ""
+}
+
+// CORSRoute overrides the policy for a path, or for the paths under it
+// when Path ends in "/*".
+type CORSRoute struct {
+	Path   string
+	Policy CORSPolicy
+}
+
+func (r CORSRoute) matches(path string) bool {
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == r.Path
+}
+
+// CORS answers preflight requests and marks responses readable by the
+// origins policy allows, or by those of the first route in routes whose
+// path matches. Routes are matched on the request path, since preflights
+// reach no route. Preflights from an origin not allowed get 403
+// origin_not_allowed; other requests go on without CORS headers, so the
+// browser keeps their responses from the page.
+func CORS(policy CORSPolicy, routes ...CORSRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := policy
+		for _, r := range routes {
+			if r.matches(c.Request.URL.Path) {
+				p = r.Policy
+				break
+			}
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if origin == "" {
+			c.Next()
+			return
+		}
+		allowed := p.allows(origin)
+		if allowed == "" {
+			if preflight {
+				abort(c, NewError(http.StatusForbidden, "origin_not_allowed", "origin not allowed"))
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", allowed)
+		if p.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			c.Header("Access-Control-Expose-Headers", corsExposeHeaders)
+			c.Next()
+			return
+		}
+		c.Header("Access-Control-Allow-Methods", corsMethods)
+		c.Header("Access-Control-Allow-Headers", corsHeaders)
+		if p.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusError is an error with its own HTTP status, for failures outside
+// the store's error kinds such as bad credentials or an unreadable upload.
+type StatusError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *StatusError) Error() string { return e.Message }
+
+func NewError(status int, code, message string) error {
+	return &StatusError{Status: status, Code: code, Message: message}
+}
+
+// Errors renders the last error a handler recorded with c.Error as an RFC
+// 7807 problem. Domain errors from the database package map onto 400, 403,
+// 404 and 409; anything else is logged and reported as a bare 500, so driver
+// messages never reach clients. Handlers that already started writing,
+// such as streaming exports, only get the error logged.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		writeProblem(c)
+	}
+}
+
+// writeProblem renders the last recorded error, once. Middleware that needs
+// to see the final response, such as Idempotency, calls it early.
+func writeProblem(c *gin.Context) {
+	if len(c.Errors) == 0 || c.GetBool("problemWritten") {
+		return
+	}
+	err := c.Errors.Last().Err
+	if c.Writer.Written() {
+		slog.ErrorContext(c, "request failed after responding", "error", err)
+		return
+	}
+	p := ProblemFor(err)
+	if p.Status == http.StatusInternalServerError {
+		slog.ErrorContext(c, "request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+	}
+	p.Instance = c.Request.URL.Path
+	p.RequestID = c.GetString("requestID")
+	c.Set("problemWritten", true)
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(p.Status, p)
+}
+
+// ProblemFor describes err as a problem, for responses that report several
+// errors, such as one per mutation of a sync batch.
+func ProblemFor(err error) models.Problem {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return problem(se.Status, se.Code, se.Message, nil)
+	}
+	var de *database.Error
+	if errors.As(err, &de) {
+		status := http.StatusInternalServerError
+		switch de.Kind {
+		case database.ErrNotFound:
+			status = http.StatusNotFound
+		case database.ErrForbidden:
+			status = http.StatusForbidden
+		case database.ErrConflict:
+			status = http.StatusConflict
+		case database.ErrValidation:
+			status = http.StatusBadRequest
+		}
+		return problem(status, de.Code, de.Message, de.Fields)
+	}
+	return problem(http.StatusInternalServerError, "internal_error", "internal server error", nil)
+}
+
+func problem(status int, code, detail string, fields []models.FieldError) models.Problem {
+	return models.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Errors: fields,
+	}
+}
+
+// abort records err for Errors to render and stops the handler chain.
+func abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
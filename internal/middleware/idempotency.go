@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// DefaultIdempotencyTTL is how long responses are kept for retries.
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	maxIdempotencyKey = 255
+	// maxIdempotentBody matches the largest upload a handler accepts.
+	maxIdempotentBody = 50 << 20
+)
+
+// replayedHeaders are the response headers stored with the body.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Idempotency makes POST, PUT, PATCH and DELETE requests that carry an
+// Idempotency-Key header safe to retry. The first response under a key is
+// stored for ttl and replayed, with an Idempotent-Replayed header, to
+// retries from the same user. A retry while the first attempt is still
+// running gets 409, however long it runs, and reusing the key for a
+// different request gets 422.
+// Server errors are not stored, so the request can be retried for real.
+// It must run after AuthRequired, since keys belong to users.
+func Idempotency(db database.Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		userID := c.GetInt64("userID")
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			key = ""
+		}
+		if key == "" || userID == 0 {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			abort(c, NewError(http.StatusBadRequest, "invalid_idempotency_key", "Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentBody+1))
+		if err != nil {
+			abort(c, NewError(http.StatusBadRequest, "invalid_body", "could not read the request body"))
+			return
+		}
+		if len(body) > maxIdempotentBody {
+			abort(c, NewError(http.StatusRequestEntityTooLarge, "body_too_large", "request body too large"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c.Request, body)
+
+		stored, err := db.ClaimIdempotencyKey(userID, key, fingerprint, time.Now().Add(-ttl))
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		if stored != nil {
+			switch {
+			case stored.Fingerprint != fingerprint:
+				abort(c, NewError(http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key was already used for a different request"))
+			case stored.Status == 0:
+				c.Header("Retry-After", "1")
+				abort(c, NewError(http.StatusConflict, "idempotency_key_in_use", "a request with this Idempotency-Key is still in progress"))
+			default:
+				for k, v := range stored.Header {
+					c.Header(k, v)
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(stored.Status, stored.Header["Content-Type"], stored.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		saved := false
+		defer func() {
+			// A panic or server error leaves the key free for a retry.
+			if !saved {
+				if err := db.ReleaseIdempotencyKey(userID, key); err != nil {
+					slog.ErrorContext(c, "releasing idempotency key", "error", err)
+				}
+			}
+		}()
+		done := make(chan struct{})
+		defer close(done)
+		go renewClaim(c.Request.Context(), db, userID, key, done)
+		c.Next()
+		writeProblem(c)
+
+		status := rec.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		resp := models.StoredResponse{Fingerprint: fingerprint, Status: status, Header: map[string]string{}, Body: rec.body.Bytes()}
+		for _, h := range replayedHeaders {
+			if v := rec.Header().Get(h); v != "" {
+				resp.Header[h] = v
+			}
+		}
+		if err := db.SaveIdempotentResponse(userID, key, resp); err != nil {
+			slog.ErrorContext(c, "storing idempotent response", "error", err)
+			return
+		}
+		saved = true
+	}
+}
+
+// renewClaim keeps the claim on key alive until done is closed, so that a
+// request outlasting database.IdempotencyClaimTimeout, such as a large
+// import or sync, is not taken for dead and run a second time.
+func renewClaim(ctx context.Context, db database.Store, userID int64, key string, done <-chan struct{}) {
+	ticker := time.NewTicker(database.IdempotencyClaimTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := db.RenewIdempotencyKey(userID, key); err != nil {
+				slog.ErrorContext(ctx, "renewing idempotency key", "error", err)
+			}
+		}
+	}
+}
+
+// requestFingerprint identifies a request by its method, URL and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+	"workout-tracker/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logger logs every request once it is served, with its route, status and
+// latency, and counts it in the HTTP metrics. It must run after RequestID
+// so the line carries the request ID; the user ID is added once
+// AuthRequired has set it.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		// Routes, not paths, keep the metrics' label values bounded.
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		metrics.HTTPRequests.Inc(c.Request.Method, route, strconv.Itoa(status))
+		metrics.HTTPDuration.Observe(latency.Seconds(), c.Request.Method, route)
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case (route == "/healthz" || route == "/readyz") && status < 400:
+			// Probes come every few seconds; only failures matter.
+			level = slog.LevelDebug
+		}
+		slog.Log(c, level, "request",
+			"method", c.Request.Method,
+			"route", route,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(latency.Microseconds())/1000,
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// Recovery turns a panic in a handler into a logged 500 problem.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c, "panic", "error", err, "path", c.Request.URL.Path, "stack", string(debug.Stack()))
+		abort(c, NewError(http.StatusInternalServerError, "internal_error", "internal server error"))
+		writeProblem(c)
+	})
+}
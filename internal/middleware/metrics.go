@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsToken guards the metrics endpoint with a bearer token, separate
+// from user tokens so a scraper needs no account. An empty token leaves
+// the endpoint open.
+func MetricsToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			abort(c, NewError(http.StatusUnauthorized, "invalid_token", "invalid token"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,21 +1,45 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
 
 type User struct {
 	ID           int64     `json:"id"`
 	Name         string    `json:"name"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
+	TimeZone     string    `json:"timezone"`
+	RestDays     int       `json:"rest_days"` // days in a row without a workout that keep a streak going
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// Location is the user's time zone, used for day and week boundaries. An
+// unknown or empty zone falls back to UTC.
+func (u *User) Location() *time.Location {
+	if u == nil || u.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 type Exercise struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Category    string `json:"category"`
 	MuscleGroup string `json:"muscle_group"`
+	UserID      *int64 `json:"user_id,omitempty"` // set on custom exercises
+
+	// Set on custom exercises created through sync.
+	ClientID  string     `json:"client_id,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 }
 
 type Workout struct {
@@ -25,13 +49,77 @@ type Workout struct {
 	Description string            `json:"description"`
 	Comment     string            `json:"comment"`
 	Status      string            `json:"status"`
+	Visibility  string            `json:"visibility"` // private, followers or public
 	ScheduledAt *time.Time        `json:"scheduled_at"`
 	CompletedAt *time.Time        `json:"completed_at"`
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
+	Version     int               `json:"version"` // bumped by every change; the ETag
+	ClientID    string            `json:"client_id,omitempty"`
+	AssignedBy  *int64            `json:"assigned_by,omitempty"` // the coach who scheduled it for the user
+	DeletedAt   *time.Time        `json:"deleted_at,omitempty"`  // set while the workout is in the trash
 	Exercises   []WorkoutExercise `json:"exercises,omitempty"`
 }
 
+// State returns the editable part of the workout.
+func (w *Workout) State() WorkoutState {
+	s := WorkoutState{
+		Title:       w.Title,
+		Description: w.Description,
+		Comment:     w.Comment,
+		Status:      w.Status,
+		Visibility:  w.Visibility,
+		ScheduledAt: w.ScheduledAt,
+		Exercises:   make([]WorkoutStateExercise, len(w.Exercises)),
+	}
+	for i, e := range w.Exercises {
+		s.Exercises[i] = WorkoutStateExercise{ID: e.ID, WorkoutExerciseRequest: e.Request()}
+	}
+	return s
+}
+
+// Date is when the workout happened, or is planned to happen.
+func (w *Workout) Date() time.Time {
+	switch {
+	case w.CompletedAt != nil:
+		return *w.CompletedAt
+	case w.ScheduledAt != nil:
+		return *w.ScheduledAt
+	}
+	return w.CreatedAt
+}
+
+// In converts the workout's times to loc, so responses show the user's
+// local time.
+func (w *Workout) In(loc *time.Location) {
+	w.CreatedAt = w.CreatedAt.In(loc)
+	w.UpdatedAt = w.UpdatedAt.In(loc)
+	if w.ScheduledAt != nil {
+		t := w.ScheduledAt.In(loc)
+		w.ScheduledAt = &t
+	}
+	if w.CompletedAt != nil {
+		t := w.CompletedAt.In(loc)
+		w.CompletedAt = &t
+	}
+	if w.DeletedAt != nil {
+		t := w.DeletedAt.In(loc)
+		w.DeletedAt = &t
+	}
+}
+
+// StartOfDay is midnight of t's day in loc.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// StartOfWeek is midnight on the Monday of t's ISO week in loc.
+func StartOfWeek(t time.Time, loc *time.Location) time.Time {
+	day := StartOfDay(t, loc)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
 type WorkoutExercise struct {
 	ID          int64     `json:"id"`
 	WorkoutID   int64     `json:"workout_id"`
@@ -40,14 +128,66 @@ type WorkoutExercise struct {
 	Reps        int       `json:"reps"`
 	WeightKg    float64   `json:"weight_kg"`
 	DurationSec int       `json:"duration_sec"`
+	RestSec     int       `json:"rest_sec"`
 	Notes       string    `json:"notes"`
 	Exercise    *Exercise `json:"exercise,omitempty"`
+
+	// Cardio fields. Pace and speed are derived from distance and duration.
+	DistanceM      float64 `json:"distance_m"`
+	ElevationGainM float64 `json:"elevation_gain_m"`
+	AvgHeartRate   int     `json:"avg_heart_rate"`
+	MaxHeartRate   int     `json:"max_heart_rate"`
+	Calories       int     `json:"calories"`
+	PaceSecPerKm   float64 `json:"pace_sec_per_km,omitempty"`
+	SpeedKmh       float64 `json:"speed_kmh,omitempty"`
+
+	// The sets logged against the entry in live sessions or by sync,
+	// filled in by exports.
+	LoggedSets []SessionSet `json:"logged_sets,omitempty"`
+}
+
+// Request returns the values of the entry as they are written.
+func (e *WorkoutExercise) Request() WorkoutExerciseRequest {
+	return WorkoutExerciseRequest{
+		ExerciseID:     e.ExerciseID,
+		Sets:           e.Sets,
+		Reps:           e.Reps,
+		WeightKg:       e.WeightKg,
+		DurationSec:    e.DurationSec,
+		RestSec:        e.RestSec,
+		Notes:          e.Notes,
+		DistanceM:      e.DistanceM,
+		ElevationGainM: e.ElevationGainM,
+		AvgHeartRate:   e.AvgHeartRate,
+		MaxHeartRate:   e.MaxHeartRate,
+		Calories:       e.Calories,
+	}
+}
+
+// DeriveCardio fills in pace and speed. Both stay zero unless distance and
+// duration are recorded.
+func (we *WorkoutExercise) DeriveCardio() {
+	we.PaceSecPerKm, we.SpeedKmh = 0, 0
+	if we.DistanceM <= 0 || we.DurationSec <= 0 {
+		return
+	}
+	km := we.DistanceM / 1000
+	hours := float64(we.DurationSec) / 3600
+	we.PaceSecPerKm = math.Round(float64(we.DurationSec)/km*10) / 10
+	we.SpeedKmh = math.Round(km/hours*100) / 100
 }
 
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
+	TimeZone string `json:"timezone"` // IANA name, e.g. "Europe/Berlin"; defaults to UTC
+}
+
+// UpdateUserRequest changes the user's settings; at least one must be set.
+type UpdateUserRequest struct {
+	TimeZone string `json:"timezone"`
+	RestDays *int   `json:"rest_days" binding:"omitempty,min=0,max=6"`
 }
 
 type LoginRequest struct {
@@ -61,19 +201,40 @@ type AuthResponse struct {
 }
 
 type CreateWorkoutRequest struct {
-	Title       string                   `json:"title" binding:"required"`
+	Title       string                   `json:"title"`
 	Description string                   `json:"description"`
+	Visibility  string                   `json:"visibility"` // defaults to private
 	ScheduledAt *time.Time               `json:"scheduled_at"`
 	Exercises   []WorkoutExerciseRequest `json:"exercises"`
+
+	// ClientID is an ID the client generated, so that retrying a create
+	// never makes a second workout.
+	ClientID string `json:"client_id,omitempty"`
+
+	// Set by importers so that re-importing the same file is a no-op.
+	Source     string `json:"-"`
+	ExternalID string `json:"-"`
+
+	// Set by sync, which creates a workout in its final state. Status
+	// defaults to pending.
+	Status  string `json:"-"`
+	Comment string `json:"-"`
 }
 
 type WorkoutExerciseRequest struct {
-	ExerciseID  int64   `json:"exercise_id" binding:"required"`
+	ExerciseID  int64   `json:"exercise_id"`
 	Sets        int     `json:"sets"`
 	Reps        int     `json:"reps"`
 	WeightKg    float64 `json:"weight_kg"`
 	DurationSec int     `json:"duration_sec"`
+	RestSec     int     `json:"rest_sec"`
 	Notes       string  `json:"notes"`
+
+	DistanceM      float64 `json:"distance_m"`
+	ElevationGainM float64 `json:"elevation_gain_m"`
+	AvgHeartRate   int     `json:"avg_heart_rate"`
+	MaxHeartRate   int     `json:"max_heart_rate"`
+	Calories       int     `json:"calories"`
 }
 
 type UpdateWorkoutRequest struct {
@@ -81,15 +242,584 @@ type UpdateWorkoutRequest struct {
 	Description *string                  `json:"description"`
 	Comment     *string                  `json:"comment"`
 	Status      *string                  `json:"status"`
+	Visibility  *string                  `json:"visibility"`
 	ScheduledAt *time.Time               `json:"scheduled_at"`
 	Exercises   []WorkoutExerciseRequest `json:"exercises"`
 }
 
+// WorkoutState is the editable part of a workout, the document PATCH
+// requests apply to. Exercises that already exist keep their id; new ones
+// have none.
+type WorkoutState struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Comment     string                 `json:"comment"`
+	Status      string                 `json:"status"`
+	Visibility  string                 `json:"visibility"`
+	ScheduledAt *time.Time             `json:"scheduled_at"`
+	Exercises   []WorkoutStateExercise `json:"exercises"`
+}
+
+type WorkoutStateExercise struct {
+	ID int64 `json:"id,omitempty"`
+	WorkoutExerciseRequest
+}
+
 type WorkoutReport struct {
 	TotalWorkouts      int       `json:"total_workouts"`
 	CompletedWorkouts  int       `json:"completed_workouts"`
 	TotalVolumeKg      float64   `json:"total_volume_kg"`
 	AvgWorkoutsPerWeek float64   `json:"avg_workouts_per_week"`
+	CompletedToday     int       `json:"completed_today"`
+	CompletedThisWeek  int       `json:"completed_this_week"`
+	TimeZone           string    `json:"timezone"` // zone used for days and weeks
 	MostUsedExercise   string    `json:"most_used_exercise"`
+	CardioDistanceKm   float64   `json:"cardio_distance_km"`
+	CardioDurationSec  int       `json:"cardio_duration_sec"`
+	RunningDistanceKm  float64   `json:"running_distance_km"`
+	RunningSessions    int       `json:"running_sessions"`
 	Workouts           []Workout `json:"workouts"`
+	NextCursor         string    `json:"next_cursor,omitempty"`
+}
+
+// WorkoutFilter narrows workout listings and reports. Zero values mean no
+// restriction; a zero Limit returns every match.
+type WorkoutFilter struct {
+	Status     string
+	From       *time.Time // inclusive, by scheduled date (or creation date)
+	To         *time.Time // inclusive
+	ExerciseID int64
+	Query      string // matched against title and description
+	Sort       string // date, created or title; prefix "-" for descending
+	Limit      int
+	Cursor     string
+}
+
+type WorkoutPage struct {
+	Workouts   []Workout `json:"workouts"`
+	Total      int       `json:"total"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// Follow is a user someone follows, or one of their followers.
+type Follow struct {
+	UserID int64     `json:"user_id"`
+	Name   string    `json:"name"`
+	Since  time.Time `json:"since"`
+}
+
+// FeedItem is a completed workout shown to the author's followers, with the
+// personal records set in it and what others made of it.
+type FeedItem struct {
+	Workout
+	Author       string           `json:"author"`
+	PRs          []PersonalRecord `json:"prs"`
+	Reactions    map[string]int   `json:"reactions"`    // count by kind
+	MyReactions  []string         `json:"my_reactions"` // kinds the viewer reacted with
+	CommentCount int              `json:"comment_count"`
+}
+
+// PersonalRecord is the heaviest set of an exercise logged in a workout
+// that beat the athlete's previous best.
+type PersonalRecord struct {
+	ExerciseID   int64     `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	WeightKg     float64   `json:"weight_kg"`
+	Reps         int       `json:"reps"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
+type FeedPage struct {
+	Items      []FeedItem `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// Comment is a remark left on a feed item.
+type Comment struct {
+	ID        int64     `json:"id"`
+	WorkoutID int64     `json:"workout_id"`
+	UserID    int64     `json:"user_id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ReactionRequest struct {
+	Kind string `json:"kind" binding:"required,oneof=like fire strong clap"`
+}
+
+type CommentRequest struct {
+	Body string `json:"body" binding:"required,max=1000"`
+}
+
+// WorkoutSummary is the read-only view of a workout behind a share link,
+// which anyone with the link may open.
+type WorkoutSummary struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Author      string            `json:"author"`
+	Date        time.Time         `json:"date"`
+	Status      string            `json:"status"`
+	Exercises   []WorkoutExercise `json:"exercises"`
+	PRs         []PersonalRecord  `json:"prs"`
+}
+
+// CoachLink gives a coach access to an athlete's account, within the
+// scopes the athlete grants: view, assign and edit. It is pending until
+// the athlete accepts the coach's invite, and ends when either revokes it.
+type CoachLink struct {
+	CoachID     int64      `json:"coach_id"`
+	CoachName   string     `json:"coach_name"`
+	AthleteID   int64      `json:"athlete_id"`
+	AthleteName string     `json:"athlete_name"`
+	Scopes      []string   `json:"scopes"`
+	Status      string     `json:"status"` // pending or active
+	CreatedAt   time.Time  `json:"created_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+}
+
+// In converts the link's times to loc.
+func (l *CoachLink) In(loc *time.Location) {
+	l.CreatedAt = l.CreatedAt.In(loc)
+	if l.AcceptedAt != nil {
+		t := l.AcceptedAt.In(loc)
+		l.AcceptedAt = &t
+	}
+}
+
+type CoachInviteRequest struct {
+	Email  string   `json:"email" binding:"required,email"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=view assign edit"`
+}
+
+type CoachScopesRequest struct {
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=view assign edit"`
+}
+
+// Team is a group of users who share a leaderboard and challenges. Members
+// bring others in with the join code.
+type Team struct {
+	ID          int64        `json:"id"`
+	Name        string       `json:"name"`
+	OwnerID     int64        `json:"owner_id"`
+	JoinCode    string       `json:"join_code"`
+	MemberCount int          `json:"member_count"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Members     []TeamMember `json:"members,omitempty"`
+}
+
+// In converts the team's times to loc.
+func (t *Team) In(loc *time.Location) {
+	t.CreatedAt = t.CreatedAt.In(loc)
+	for i := range t.Members {
+		t.Members[i].JoinedAt = t.Members[i].JoinedAt.In(loc)
+	}
+}
+
+type TeamMember struct {
+	UserID   int64     `json:"user_id"`
+	Name     string    `json:"name"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type CreateTeamRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type JoinTeamRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// Leaderboard ranks every member of a team on a metric over a period.
+// Members with equal values share a rank.
+type Leaderboard struct {
+	Metric  string             `json:"metric"` // volume, sessions, streak or prs
+	Period  string             `json:"period"` // week, month or all
+	From    *time.Time         `json:"from,omitempty"`
+	To      *time.Time         `json:"to,omitempty"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+type LeaderboardEntry struct {
+	Rank   int     `json:"rank"`
+	UserID int64   `json:"user_id"`
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+}
+
+// Challenge is a team goal over a fixed time, such as 100 pull-ups this
+// week. Progress counts the exercises of workouts completed in the window.
+type Challenge struct {
+	ID         int64               `json:"id"`
+	TeamID     int64               `json:"team_id"`
+	CreatedBy  *int64              `json:"created_by"`
+	Title      string              `json:"title"`
+	Metric     string              `json:"metric"`                // reps, volume, distance or sessions
+	ExerciseID *int64              `json:"exercise_id,omitempty"` // counts only this exercise
+	Target     float64             `json:"target"`
+	StartsAt   time.Time           `json:"starts_at"`
+	EndsAt     time.Time           `json:"ends_at"`
+	CreatedAt  time.Time           `json:"created_at"`
+	Standings  []ChallengeStanding `json:"standings,omitempty"`
+}
+
+// In converts the challenge's times to loc.
+func (ch *Challenge) In(loc *time.Location) {
+	ch.StartsAt = ch.StartsAt.In(loc)
+	ch.EndsAt = ch.EndsAt.In(loc)
+	ch.CreatedAt = ch.CreatedAt.In(loc)
+}
+
+type ChallengeStanding struct {
+	Rank      int     `json:"rank"`
+	UserID    int64   `json:"user_id"`
+	Name      string  `json:"name"`
+	Progress  float64 `json:"progress"`
+	Completed bool    `json:"completed"` // progress reached the target
+}
+
+type CreateChallengeRequest struct {
+	Title      string    `json:"title" binding:"required,max=200"`
+	Metric     string    `json:"metric" binding:"required,oneof=reps volume distance sessions"`
+	ExerciseID *int64    `json:"exercise_id"`
+	Target     float64   `json:"target" binding:"required,gt=0"`
+	StartsAt   time.Time `json:"starts_at" binding:"required"`
+	EndsAt     time.Time `json:"ends_at" binding:"required,gtfield=StartsAt"`
+}
+
+// Goal is a target users set themselves: sessions or cardio distance (km)
+// per week or month, a lift (kg) on an exercise, or a body weight (kg).
+// Progress is computed when the goal is read.
+type Goal struct {
+	ID         int64        `json:"id"`
+	Kind       string       `json:"kind"` // sessions, cardio_distance, lift or bodyweight
+	Target     float64      `json:"target"`
+	Period     string       `json:"period,omitempty"`      // week or month, for sessions and cardio_distance
+	ExerciseID *int64       `json:"exercise_id,omitempty"` // the exercise of a lift
+	StartValue *float64     `json:"start_value,omitempty"` // body weight when the goal was set
+	CreatedAt  time.Time    `json:"created_at"`
+	Progress   GoalProgress `json:"progress"`
+}
+
+type GoalProgress struct {
+	Current  float64    `json:"current"`
+	Percent  float64    `json:"percent"` // of the way to the target, 0 to 100
+	Achieved bool       `json:"achieved"`
+	From     *time.Time `json:"from,omitempty"` // the period counted
+	To       *time.Time `json:"to,omitempty"`
+}
+
+// In converts the goal's times to loc.
+func (g *Goal) In(loc *time.Location) {
+	g.CreatedAt = g.CreatedAt.In(loc)
+	if g.Progress.From != nil {
+		from, to := g.Progress.From.In(loc), g.Progress.To.In(loc)
+		g.Progress.From, g.Progress.To = &from, &to
+	}
+}
+
+type CreateGoalRequest struct {
+	Kind       string  `json:"kind" binding:"required,oneof=sessions cardio_distance lift bodyweight"`
+	Target     float64 `json:"target" binding:"required,gt=0"`
+	Period     string  `json:"period" binding:"omitempty,oneof=week month"` // defaults to week
+	ExerciseID *int64  `json:"exercise_id" binding:"required_if=Kind lift"`
+}
+
+type BodyWeight struct {
+	ID         int64     `json:"id"`
+	WeightKg   float64   `json:"weight_kg"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+type LogBodyWeightRequest struct {
+	WeightKg   float64    `json:"weight_kg" binding:"required,gt=0,lt=1000"`
+	MeasuredAt *time.Time `json:"measured_at"` // defaults to now
+}
+
+// Streak is the user's run of training days in their time zone. Up to
+// RestDays days in a row without a workout keep it going, and count
+// toward its length.
+type Streak struct {
+	Current       int    `json:"current"` // days; 0 once the run has lapsed
+	Longest       int    `json:"longest"`
+	RestDays      int    `json:"rest_days"`
+	LastWorkoutOn string `json:"last_workout_on,omitempty"` // YYYY-MM-DD
+}
+
+// Achievement is a badge, earned or still to earn.
+type Achievement struct {
+	Code        string     `json:"code"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Earned      bool       `json:"earned"`
+	EarnedAt    *time.Time `json:"earned_at,omitempty"`
+	WorkoutID   *int64     `json:"workout_id,omitempty"` // the workout that earned it
+}
+
+type Achievements struct {
+	Streak Streak        `json:"streak"`
+	Badges []Achievement `json:"badges"`
+}
+
+// In converts the times badges were earned to loc.
+func (a *Achievements) In(loc *time.Location) {
+	for i := range a.Badges {
+		if t := a.Badges[i].EarnedAt; t != nil {
+			earned := t.In(loc)
+			a.Badges[i].EarnedAt = &earned
+		}
+	}
+}
+
+// AssignWorkoutRequest schedules a copy of one of the coach's own workouts,
+// the template, for an athlete. Title and description default to the
+// template's.
+type AssignWorkoutRequest struct {
+	TemplateID  int64      `json:"template_id" binding:"required"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+// WorkoutSession is a live run-through of a workout, with sets logged one
+// at a time as they are done.
+type WorkoutSession struct {
+	ID         int64        `json:"id"`
+	WorkoutID  int64        `json:"workout_id"`
+	UserID     int64        `json:"user_id"`
+	Status     string       `json:"status"` // active, finished
+	RestUntil  *time.Time   `json:"rest_until"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at"`
+	Sets       []SessionSet `json:"sets"`
+	NextSet    *NextSet     `json:"next_set"`
+}
+
+type SessionSet struct {
+	ID                int64     `json:"id"`
+	SessionID         int64     `json:"session_id"`
+	WorkoutExerciseID int64     `json:"workout_exercise_id"`
+	ExerciseID        int64     `json:"exercise_id"`
+	SetNumber         int       `json:"set_number"`
+	Reps              int       `json:"reps"`
+	WeightKg          float64   `json:"weight_kg"`
+	DurationSec       int       `json:"duration_sec"`
+	RestSec           int       `json:"rest_sec"`
+	IsPR              bool      `json:"is_pr"`
+	CompletedAt       time.Time `json:"completed_at"`
+
+	// Filled in by the sync change feed.
+	WorkoutID int64  `json:"workout_id,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+}
+
+// NextSet is the set the athlete should do after the current rest.
+type NextSet struct {
+	WorkoutExerciseID int64   `json:"workout_exercise_id"`
+	ExerciseID        int64   `json:"exercise_id"`
+	ExerciseName      string  `json:"exercise_name"`
+	SetNumber         int     `json:"set_number"`
+	TargetReps        int     `json:"target_reps"`
+	TargetWeightKg    float64 `json:"target_weight_kg"`
+	TargetDurationSec int     `json:"target_duration_sec"`
+	RestSec           int     `json:"rest_sec"`
+}
+
+type LogSetRequest struct {
+	WorkoutExerciseID int64   `json:"workout_exercise_id" binding:"required"`
+	Reps              int     `json:"reps"`
+	WeightKg          float64 `json:"weight_kg"`
+	DurationSec       int     `json:"duration_sec"`
+}
+
+// SessionEvent is pushed to every open event stream of the session owner.
+type SessionEvent struct {
+	Type      string      `json:"type"` // session, timer, next_set, pr, finished
+	WorkoutID int64       `json:"workout_id"`
+	Data      interface{} `json:"data"`
+}
+
+// ActivityImport is the result of importing a GPX, TCX or FIT file.
+type ActivityImport struct {
+	Format  string   `json:"format"`
+	Workout *Workout `json:"workout"`
+}
+
+// ImportReport describes what a CSV import did, or would do on a dry run.
+type ImportReport struct {
+	Format          string            `json:"format"`
+	DryRun          bool              `json:"dry_run"`
+	WorkoutsFound   int               `json:"workouts_found"`
+	WorkoutsCreated int               `json:"workouts_created"`
+	WorkoutsSkipped int               `json:"workouts_skipped"` // already imported
+	WorkoutsInvalid int               `json:"workouts_invalid"`
+	SetsFound       int               `json:"sets_found"`
+	Exercises       []ExerciseMapping `json:"exercises"`
+	Warnings        []string          `json:"warnings"`
+	// Errors are the rule violations of the invalid workouts, which are
+	// not imported. Pointers start at the workout's position in the file,
+	// as in "/workouts/3/exercises/0/reps".
+	Errors []FieldError `json:"errors"`
+}
+
+// ExerciseMapping records which exercise a name from the import maps to.
+type ExerciseMapping struct {
+	SourceName   string `json:"source_name"`
+	ExerciseID   int64  `json:"exercise_id,omitempty"` // zero for new exercises on a dry run or that no imported workout used
+	ExerciseName string `json:"exercise_name"`
+	Created      bool   `json:"created"` // or would be, on a dry run
+}
+
+// Problem is an RFC 7807 error response, sent as application/problem+json.
+// Code is stable and meant for programs; Detail is for people.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is one invalid value of a request: a body value identified
+// by its JSON pointer (RFC 6901), such as "/exercises/0/reps", or a query,
+// path or form parameter identified by name.
+type FieldError struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// AuditEvent is one change to a user's data. UserID owns the data; ActorID
+// made the change and is 0 for background jobs. Changes holds the fields
+// that changed, with their values before and after.
+type AuditEvent struct {
+	ID        int64                  `json:"id"`
+	UserID    int64                  `json:"user_id"`
+	ActorID   int64                  `json:"actor_id,omitempty"`
+	Action    string                 `json:"action"` // create, update, delete, restore or purge
+	Entity    string                 `json:"entity"` // user, exercise, workout, session, set, follow, reaction, comment, share, coach_link, team, team_member, challenge, goal or body_weight
+	EntityID  int64                  `json:"entity_id"`
+	Changes   map[string]AuditChange `json:"changes"`
+	RequestID string                 `json:"request_id,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditChange is a field's value before and after a change; null on the
+// side where the record did not exist.
+type AuditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// AuditFilter narrows the audit log. UserID 0 means every user, for admins.
+type AuditFilter struct {
+	UserID   int64
+	Entity   string
+	EntityID int64
+	Action   string
+	From     *time.Time // inclusive
+	To       *time.Time // inclusive
+	Limit    int
+	Cursor   string
+}
+
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// StoredResponse is the response to a request sent with an Idempotency-Key,
+// kept to be replayed when the request is retried. Fingerprint identifies
+// the request; Status is 0 while the first attempt is still running.
+type StoredResponse struct {
+	Fingerprint string
+	Status      int
+	Header      map[string]string
+	Body        []byte
+}
+
+// SyncChanges is everything that changed for a user since a sync cursor.
+// Cursor is sent back as since on the next pull.
+type SyncChanges struct {
+	Cursor    string       `json:"cursor"`
+	Workouts  []Workout    `json:"workouts"`
+	Exercises []Exercise   `json:"exercises"`
+	Sets      []SessionSet `json:"sets"`
+	Deleted   []Tombstone  `json:"deleted"`
+}
+
+// Tombstone records a deleted workout or set so that clients can drop
+// their copy. A deleted workout takes its sets with it.
+type Tombstone struct {
+	Type      string    `json:"type"` // workout or set
+	ID        int64     `json:"id"`
+	ClientID  string    `json:"client_id,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncRequest is a batch of changes a client made offline, in the order it
+// made them.
+type SyncRequest struct {
+	Strategy  string         `json:"strategy"` // server_wins (default) or last_writer_wins
+	Mutations []SyncMutation `json:"mutations" binding:"required"`
+}
+
+// SyncMutation creates, changes or deletes one workout, custom exercise or
+// logged set, named by ID or, when it was created offline, by ClientID.
+// Changes holds the new values of the fields the client changed and Base
+// their values when it last synced; a field whose server value no longer
+// matches Base is a conflict.
+type SyncMutation struct {
+	IdempotencyKey  string                     `json:"idempotency_key"`
+	Type            string                     `json:"type"` // workout, exercise or set
+	Op              string                     `json:"op"`   // upsert or delete
+	ID              int64                      `json:"id,omitempty"`
+	ClientID        string                     `json:"client_id,omitempty"`
+	WorkoutID       int64                      `json:"workout_id,omitempty"` // the workout of a set
+	WorkoutClientID string                     `json:"workout_client_id,omitempty"`
+	BaseVersion     int                        `json:"base_version,omitempty"`
+	Base            map[string]json.RawMessage `json:"base,omitempty"`
+	Changes         map[string]json.RawMessage `json:"changes,omitempty"`
+	ChangedAt       time.Time                  `json:"changed_at"`
+}
+
+// SyncSet is a set logged offline. Its exercise is an entry of the workout,
+// by ID or, for entries that were created offline too, by position.
+type SyncSet struct {
+	WorkoutExerciseID int64      `json:"workout_exercise_id"`
+	ExerciseIndex     *int       `json:"exercise_index"`
+	Reps              int        `json:"reps"`
+	WeightKg          float64    `json:"weight_kg"`
+	DurationSec       int        `json:"duration_sec"`
+	CompletedAt       *time.Time `json:"completed_at"`
+}
+
+// SyncResult is the outcome of one mutation.
+type SyncResult struct {
+	IdempotencyKey string         `json:"idempotency_key,omitempty"`
+	Type           string         `json:"type"`
+	ID             int64          `json:"id,omitempty"`
+	ClientID       string         `json:"client_id,omitempty"`
+	Status         string         `json:"status"` // applied or rejected
+	Replayed       bool           `json:"replayed,omitempty"`
+	Version        int            `json:"version,omitempty"`
+	Conflicts      []SyncConflict `json:"conflicts,omitempty"`
+	Error          *Problem       `json:"error,omitempty"`
+}
+
+// SyncConflict is a field changed both by the client and on the server
+// since the client's base, and which side's value was kept.
+type SyncConflict struct {
+	Field  string          `json:"field"`
+	Client json.RawMessage `json:"client"`
+	Server json.RawMessage `json:"server"`
+	Winner string          `json:"winner"` // client or server
+}
+
+type SyncResponse struct {
+	Results []SyncResult `json:"results"`
 }
@@ -0,0 +1,272 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC
+// 6902) documents to decoded JSON values, as produced by json.Unmarshal
+// into an interface{}.
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of the two patch formats.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// Error is a patch that cannot be applied. Pointer locates the offending
+// operation or value.
+type Error struct {
+	Pointer string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Pointer + ": " + e.Message }
+
+// Merge applies a JSON Merge Patch: objects merge member by member, null
+// removes a member and anything else, arrays included, replaces the
+// target.
+func Merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = Merge(t[k], v)
+		}
+	}
+	return t
+}
+
+// Operation is one step of a JSON Patch.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply runs the operations of a JSON Patch in order. The patch is atomic
+// for the caller: on error the partly patched document must be discarded.
+func Apply(doc interface{}, ops []Operation) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = apply(doc, op)
+		if err != nil {
+			return nil, &Error{Pointer: "/" + strconv.Itoa(i), Message: err.Error()}
+		}
+	}
+	return doc, nil
+}
+
+func apply(doc interface{}, op Operation) (interface{}, error) {
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%s needs a value", op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("invalid value: %v", err)
+		}
+	case "move", "copy":
+		v, err := get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, fmt.Errorf("cannot move a value into itself")
+			}
+			if doc, err = remove(doc, op.From); err != nil {
+				return nil, err
+			}
+		}
+		value = clone(v)
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	switch op.Op {
+	case "add", "move", "copy":
+		return add(doc, op.Path, value)
+	case "remove":
+		return remove(doc, op.Path)
+	case "replace":
+		if op.Path == "" {
+			return value, nil
+		}
+		doc, err := remove(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, value)
+	default: // test
+		current, err := get(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("test failed at %s", op.Path)
+		}
+		return doc, nil
+	}
+}
+
+// split turns a JSON pointer into its unescaped reference tokens.
+func split(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func get(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := split(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[t]
+			if !ok {
+				return nil, fmt.Errorf("no value at %s", pointer)
+			}
+			doc = v
+		case []interface{}:
+			i, err := index(t, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("no value at %s", pointer)
+		}
+	}
+	return doc, nil
+}
+
+// add sets the value at pointer, inserting into arrays ("-" appends).
+func add(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := split(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return update(doc, tokens, func(parent interface{}, last string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[last] = value
+			return node, nil
+		case []interface{}:
+			i := len(node)
+			if last != "-" {
+				if i, err = index(last, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("cannot add to %s", pointer)
+	})
+}
+
+func remove(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := split(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	return update(doc, tokens, func(parent interface{}, last string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[last]; !ok {
+				return nil, fmt.Errorf("no value at %s", pointer)
+			}
+			delete(node, last)
+			return node, nil
+		case []interface{}:
+			i, err := index(last, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("no value at %s", pointer)
+	})
+}
+
+// update walks to the parent of the last token and replaces it with what
+// fn returns, since changing an array's length gives a new slice.
+func update(doc interface{}, tokens []string, fn func(parent interface{}, last string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("no value at /%s", tokens[0])
+		}
+		v, err := update(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = v
+		return node, nil
+	case []interface{}:
+		i, err := index(tokens[0], len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := update(node[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = v
+		return node, nil
+	}
+	return nil, fmt.Errorf("no value at /%s", tokens[0])
+}
+
+// index parses an array index no greater than max.
+func index(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+func clone(v interface{}) interface{} {
+	b, _ := json.Marshal(v)
+	var out interface{}
+	json.Unmarshal(b, &out)
+	return out
+}
@@ -0,0 +1,86 @@
+package patch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// Cases from RFC 7396, appendix A.
+func TestMerge(t *testing.T) {
+	cases := []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+	}
+	for _, c := range cases {
+		got := Merge(decode(t, c.target), decode(t, c.patch))
+		if want := decode(t, c.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("Merge(%s, %s) = %v, want %s", c.target, c.patch, got, c.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct{ doc, ops, want string }{
+		{`{"a":[1,2]}`, `[{"op":"add","path":"/a/1","value":9}]`, `{"a":[1,9,2]}`},
+		{`{"a":[1,2]}`, `[{"op":"add","path":"/a/-","value":3}]`, `{"a":[1,2,3]}`},
+		{`{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/0"}]`, `{"a":[2,3]}`},
+		{`{"a":[{"n":1},{"n":2}]}`, `[{"op":"replace","path":"/a/1/n","value":5}]`, `{"a":[{"n":1},{"n":5}]}`},
+		{`{"a":[1,2]}`, `[{"op":"replace","path":"/a/0","value":7}]`, `{"a":[7,2]}`},
+		{`{"a":1}`, `[{"op":"test","path":"/a","value":1},{"op":"remove","path":"/a"}]`, `{}`},
+		{`{"a":[1,2,3]}`, `[{"op":"move","from":"/a/0","path":"/a/-"}]`, `{"a":[2,3,1]}`},
+		{`{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`},
+		{`{"a/b":1}`, `[{"op":"replace","path":"/a~1b","value":2}]`, `{"a/b":2}`},
+	}
+	for _, c := range cases {
+		var ops []Operation
+		json.Unmarshal([]byte(c.ops), &ops)
+		got, err := Apply(decode(t, c.doc), ops)
+		if err != nil {
+			t.Errorf("Apply(%s, %s): %v", c.doc, c.ops, err)
+			continue
+		}
+		if want := decode(t, c.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("Apply(%s, %s) = %v, want %s", c.doc, c.ops, got, c.want)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	cases := []struct{ doc, ops, pointer string }{
+		{`{"a":1}`, `[{"op":"test","path":"/a","value":2}]`, "/0"},
+		{`{"a":[1]}`, `[{"op":"add","path":"/a/0","value":0},{"op":"remove","path":"/a/5"}]`, "/1"},
+		{`{"a":1}`, `[{"op":"replace","path":"/b","value":2}]`, "/0"},
+		{`{"a":[1]}`, `[{"op":"add","path":"/a/01","value":2}]`, "/0"},
+		{`{}`, `[{"op":"frobnicate","path":"/a"}]`, "/0"},
+		{`{}`, `[{"op":"add","path":"/a"}]`, "/0"},
+	}
+	for _, c := range cases {
+		var ops []Operation
+		json.Unmarshal([]byte(c.ops), &ops)
+		_, err := Apply(decode(t, c.doc), ops)
+		perr, ok := err.(*Error)
+		if !ok || perr.Pointer != c.pointer {
+			t.Errorf("Apply(%s, %s) error = %v, want one at %s", c.doc, c.ops, err, c.pointer)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Package static serves the web app's files: from memory, with ETags and
+// Last-Modified for revalidation, gzip or brotli when the browser accepts
+// it, and index.html for paths the app routes itself.
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"workout-tracker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Site is a set of files served under a path prefix.
+type Site struct {
+	fsys    fs.FS
+	modTime time.Time
+	reload  bool
+
+	mu     sync.Mutex
+	assets map[string]*asset
+}
+
+// asset is a file ready to serve, with its compressed variants.
+type asset struct {
+	name        string
+	contentType string
+	modTime     time.Time
+	size        int64
+	etag        string
+	body        []byte
+	gzip        []byte // nil when compressing does not pay
+	brotli      []byte // from a precompressed .br sibling, if any
+}
+
+// New loads the files of fsys, leaving out Go sources and precompressed
+// siblings, which are served as variants of their file. Files without a
+// modification time, as in an embed.FS, get modTime. With reload, files
+// changed since they were loaded are read again on request, for working on
+// the app while the server runs.
+func New(fsys fs.FS, modTime time.Time, reload bool) (*Site, error) {
+	s := &Site{fsys: fsys, modTime: modTime.UTC().Truncate(time.Second), reload: reload, assets: map[string]*asset{}}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !servable(name) {
+			return err
+		}
+		a, err := s.load(name)
+		if err != nil {
+			return err
+		}
+		s.assets[name] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.assets["index.html"]; !ok {
+		return nil, fmt.Errorf("static: no index.html: %w", fs.ErrNotExist)
+	}
+	return s, nil
+}
+
+func servable(name string) bool {
+	switch path.Ext(name) {
+	case ".go", ".br", ".gz":
+		return false
+	}
+	return !strings.HasPrefix(path.Base(name), ".")
+}
+
+// load reads a file and prepares its variants.
+func (s *Site) load(name string) (*asset, error) {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	body, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	a := &asset{name: name, size: info.Size(), body: body, modTime: info.ModTime().UTC().Truncate(time.Second)}
+	if a.modTime.IsZero() || a.modTime.Unix() <= 0 {
+		a.modTime = s.modTime
+	}
+	a.contentType = mime.TypeByExtension(path.Ext(name))
+	if a.contentType == "" {
+		a.contentType = http.DetectContentType(body)
+	}
+	sum := sha256.Sum256(body)
+	a.etag = hex.EncodeToString(sum[:8])
+
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(body)
+	zw.Close()
+	if buf.Len() < len(body) {
+		a.gzip = buf.Bytes()
+	}
+	if br, err := fs.ReadFile(s.fsys, name+".br"); err == nil {
+		a.brotli = br
+	}
+	return a, nil
+}
+
+// asset returns the file name, reading it again first if it changed and
+// the site reloads.
+func (s *Site) asset(name string) *asset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.assets[name]
+	if !s.reload {
+		return a
+	}
+	info, err := fs.Stat(s.fsys, name)
+	switch {
+	case err != nil || info.IsDir() || !servable(name):
+		delete(s.assets, name)
+		return nil
+	case a != nil && info.ModTime().UTC().Truncate(time.Second).Equal(a.modTime) && info.Size() == a.size:
+		return a
+	}
+	if a, err = s.load(name); err != nil {
+		return nil
+	}
+	s.assets[name] = a
+	return a
+}
+
+var errNotFound = middleware.NewError(http.StatusNotFound, "not_found", "file not found")
+
+// Serve serves the file named by the path parameter "path". Paths with no
+// file and no extension, such as /app/workouts/12, are the app's own
+// routes and get index.html; other missing files get 404.
+func (s *Site) Serve(c *gin.Context) {
+	name := strings.TrimPrefix(path.Clean("/"+c.Param("path")), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	a := s.asset(name)
+	if a == nil && path.Ext(name) == "" {
+		a = s.asset("index.html")
+	}
+	if a == nil {
+		c.Error(errNotFound)
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", a.contentType)
+	h.Add("Vary", "Accept-Encoding")
+	// The files are not fingerprinted, so browsers revalidate them every
+	// time; a 304 costs only the round trip.
+	h.Set("Cache-Control", "no-cache")
+	body, encoding := a.body, ""
+	switch accepts := c.GetHeader("Accept-Encoding"); {
+	case a.brotli != nil && acceptsEncoding(accepts, "br"):
+		body, encoding = a.brotli, "br"
+	case a.gzip != nil && acceptsEncoding(accepts, "gzip"):
+		body, encoding = a.gzip, "gzip"
+	}
+	// Each encoding is a representation of its own, with its own ETag.
+	etag := a.etag
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+		etag += "-" + encoding
+	}
+	h.Set("ETag", `"`+etag+`"`)
+	http.ServeContent(c.Writer, c.Request, a.name, a.modTime, bytes.NewReader(body))
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header accepts coding
+// with a nonzero quality.
+func acceptsEncoding(header, coding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
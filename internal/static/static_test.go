@@ -0,0 +1,124 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+	"workout-tracker/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+var index = "<!DOCTYPE html><title>app</title>" + strings.Repeat("<p>workout</p>", 100)
+
+func router(s *Site) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Errors())
+	r.GET("/app/*path", s.Serve)
+	r.HEAD("/app/*path", s.Serve)
+	return r
+}
+
+func get(r *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestServe(t *testing.T) {
+	built := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte(index)},
+		"index.html.br": {Data: []byte("brotli bytes")},
+		"app.css":       {Data: []byte("body{}")},
+		"frontend.go":   {Data: []byte("package frontend")},
+	}
+	s, err := New(fsys, built, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router(s)
+
+	w := get(r, "/app/", nil)
+	if w.Code != http.StatusOK || w.Body.String() != index {
+		t.Fatalf("index: %d %q", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") != built.Format(http.TimeFormat) || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("headers: %v", w.Header())
+	}
+
+	if w := get(r, "/app/", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: %d", w.Code)
+	}
+	if w := get(r, "/app/", map[string]string{"If-Modified-Since": built.Add(time.Hour).Format(http.TimeFormat)}); w.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since: %d", w.Code)
+	}
+
+	w = get(r, "/app/index.html", map[string]string{"Accept-Encoding": "gzip, deflate"})
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") == etag || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("gzip headers: %v", w.Header())
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != index {
+		t.Errorf("gzip body: %q", body)
+	}
+
+	w = get(r, "/app/", map[string]string{"Accept-Encoding": "gzip, br"})
+	if w.Header().Get("Content-Encoding") != "br" || w.Body.String() != "brotli bytes" {
+		t.Errorf("brotli: %v %q", w.Header(), w.Body.String())
+	}
+	if w := get(r, "/app/", map[string]string{"Accept-Encoding": "br;q=0, gzip"}); w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("br refused: %v", w.Header())
+	}
+	if w := get(r, "/app/app.css", map[string]string{"Accept-Encoding": "gzip"}); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "body{}" {
+		t.Errorf("a file too small to compress: %v", w.Header())
+	}
+
+	if w := get(r, "/app/workouts/12", nil); w.Code != http.StatusOK || w.Body.String() != index {
+		t.Errorf("app route fallback: %d", w.Code)
+	}
+	for _, path := range []string{"/app/missing.js", "/app/frontend.go", "/app/index.html.br"} {
+		if w := get(r, path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s: %d", path, w.Code)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte("v1"), ModTime: time.Unix(1000, 0)}}
+	s, err := New(fsys, time.Now(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router(s)
+	if w := get(r, "/app/", nil); w.Body.String() != "v1" {
+		t.Fatalf("before the edit: %q", w.Body.String())
+	}
+	fsys["index.html"] = &fstest.MapFile{Data: []byte("v2"), ModTime: time.Unix(2000, 0)}
+	fsys["new.js"] = &fstest.MapFile{Data: []byte("x"), ModTime: time.Unix(2000, 0)}
+	if w := get(r, "/app/", nil); w.Body.String() != "v2" {
+		t.Errorf("after the edit: %q", w.Body.String())
+	}
+	if w := get(r, "/app/new.js", nil); w.Code != http.StatusOK {
+		t.Errorf("new file: %d", w.Code)
+	}
+
+	if _, err := New(fstest.MapFS{"app.js": {}}, time.Now(), false); err == nil {
+		t.Error("a site without index.html loaded")
+	}
+}
@@ -0,0 +1,19 @@
+package validation
+
+import (
+	"strings"
+	"workout-tracker/internal/models"
+)
+
+// CustomExercise checks an exercise a user adds to their own library.
+func CustomExercise(e *models.Exercise) []models.FieldError {
+	var errs []models.FieldError
+	if strings.TrimSpace(e.Name) == "" {
+		errs = append(errs, required("/name"))
+	}
+	errs = append(errs, text("/name", "name", e.Name)...)
+	if _, ok := requiredByCategory[e.Category]; !ok {
+		errs = append(errs, models.FieldError{Pointer: "/category", Code: "oneof", Message: "must be one of strength, cardio, flexibility"})
+	}
+	return append(errs, text("/muscle_group", "muscle_group", e.MuscleGroup)...)
+}
@@ -0,0 +1,24 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+func TestCustomExercise(t *testing.T) {
+	if errs := CustomExercise(&models.Exercise{Name: "Zercher Squat", Category: "strength", MuscleGroup: "legs"}); len(errs) != 0 {
+		t.Errorf("valid exercise rejected: %+v", errs)
+	}
+	errs := CustomExercise(&models.Exercise{Name: " ", Category: "yoga", MuscleGroup: strings.Repeat("x", 101)})
+	got := map[string]string{}
+	for _, e := range errs {
+		got[e.Pointer] = e.Code
+	}
+	want := map[string]string{"/name": "required", "/category": "oneof", "/muscle_group": "max"}
+	for ptr, code := range want {
+		if got[ptr] != code {
+			t.Errorf("%s: got %q, want %q (%+v)", ptr, got[ptr], code, errs)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package validation
+
+import (
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+)
+
+// setFields are the values of a logged set, bounded like the same values
+// of a workout exercise.
+var setFields = map[string]bool{"reps": true, "weight_kg": true, "duration_sec": true}
+
+// Set checks the values of one set logged live or synced from a device.
+func Set(reps int, weightKg float64, durationSec int) []models.FieldError {
+	e := models.WorkoutExerciseRequest{Reps: reps, WeightKg: weightKg, DurationSec: durationSec}
+	var errs []models.FieldError
+	for _, r := range exerciseRules {
+		if !setFields[r.field] {
+			continue
+		}
+		if err := r.check("", &e); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	return errs
+}
+
+// SetError turns violations of a set into the validation error handlers
+// report, or nil if there are none.
+func SetError(errs []models.FieldError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return database.Invalid("invalid_set", "set is invalid", errs...)
+}
@@ -0,0 +1,13 @@
+package validation
+
+import "testing"
+
+func TestSet(t *testing.T) {
+	if errs := Set(5, 100, 0); len(errs) != 0 {
+		t.Errorf("valid set rejected: %+v", errs)
+	}
+	got := pointers(Set(-1, 10000, 0))
+	if len(got) != 2 || got["/reps"] != "range" || got["/weight_kg"] != "range" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,266 @@
+// Package validation checks workout payloads against the rules shared by
+// creating, updating and importing workouts. Every violation is reported,
+// each with the JSON pointer of the offending value, so a client can fix
+// them all in one round trip.
+package validation
+
+import (
+	"fmt"
+	"unicode/utf8"
+	"workout-tracker/internal/database"
+	"workout-tracker/internal/models"
+)
+
+// MaxExercisesPerWorkout caps the exercises of one workout.
+const MaxExercisesPerWorkout = 50
+
+// Statuses a workout can be set to.
+var statuses = map[string]bool{"pending": true, "active": true, "completed": true}
+
+// Who can see a workout besides its owner: nobody, the owner's followers,
+// or anyone signed in.
+var visibilities = map[string]bool{"private": true, "followers": true, "public": true}
+
+// textLimits are the maximum lengths, in characters, of free-text values.
+var textLimits = map[string]int{
+	"title":        200,
+	"description":  2000,
+	"comment":      2000,
+	"notes":        1000,
+	"name":         200,
+	"muscle_group": 100,
+}
+
+// numberRule bounds one numeric value of a workout exercise.
+type numberRule struct {
+	field    string
+	value    func(e *models.WorkoutExerciseRequest) float64
+	min, max float64
+}
+
+// check reports the value out of range, or nil if it is within it.
+func (r numberRule) check(at string, e *models.WorkoutExerciseRequest) *models.FieldError {
+	if v := r.value(e); v >= r.min && v <= r.max {
+		return nil
+	}
+	return &models.FieldError{
+		Pointer: at + "/" + r.field,
+		Code:    "range",
+		Message: fmt.Sprintf("must be between %g and %g", r.min, r.max),
+	}
+}
+
+var exerciseRules = []numberRule{
+	{"sets", func(e *models.WorkoutExerciseRequest) float64 { return float64(e.Sets) }, 0, 100},
+	{"reps", func(e *models.WorkoutExerciseRequest) float64 { return float64(e.Reps) }, 0, 1000},
+	{"weight_kg", func(e *models.WorkoutExerciseRequest) float64 { return e.WeightKg }, 0, 1000},
+	{"duration_sec", func(e *models.WorkoutExerciseRequest) float64 { return float64(e.DurationSec) }, 0, 24 * 3600},
+	{"rest_sec", func(e *models.WorkoutExerciseRequest) float64 { return float64(e.RestSec) }, 0, 3600},
+	{"distance_m", func(e *models.WorkoutExerciseRequest) float64 { return e.DistanceM }, 0, 1000000},
+	{"elevation_gain_m", func(e *models.WorkoutExerciseRequest) float64 { return e.ElevationGainM }, 0, 20000},
+	{"avg_heart_rate", func(e *models.WorkoutExerciseRequest) float64 { return float64(e.AvgHeartRate) }, 0, 250},
+	{"max_heart_rate", func(e *models.WorkoutExerciseRequest) float64 { return float64(e.MaxHeartRate) }, 0, 250},
+	{"calories", func(e *models.WorkoutExerciseRequest) float64 { return float64(e.Calories) }, 0, 20000},
+}
+
+// requiredByCategory lists, for each exercise category, the values an
+// entry must record. Each group is satisfied by any one of its fields
+// being positive: cardio needs a duration or a distance.
+var requiredByCategory = map[string][][]string{
+	"strength":    {{"sets"}, {"reps"}},
+	"cardio":      {{"duration_sec", "distance_m"}},
+	"flexibility": {{"duration_sec", "reps"}},
+}
+
+// Library is the set of exercises a user may log: the shared library and
+// their own custom exercises, keyed by ID.
+type Library map[int64]models.Exercise
+
+func NewLibrary(exercises []models.Exercise) Library {
+	lib := make(Library, len(exercises))
+	for _, e := range exercises {
+		lib[e.ID] = e
+	}
+	return lib
+}
+
+// CreateWorkout checks a new workout.
+func CreateWorkout(req *models.CreateWorkoutRequest, lib Library) []models.FieldError {
+	var errs []models.FieldError
+	if req.Title == "" {
+		errs = append(errs, required("/title"))
+	}
+	errs = append(errs, text("/title", "title", req.Title)...)
+	errs = append(errs, text("/description", "description", req.Description)...)
+	if req.Visibility != "" && !visibilities[req.Visibility] {
+		errs = append(errs, visibilityError)
+	}
+	return append(errs, Exercises("/exercises", req.Exercises, lib)...)
+}
+
+// UpdateWorkout checks the values an update sets; absent fields are left
+// alone and not checked.
+func UpdateWorkout(req *models.UpdateWorkoutRequest, lib Library) []models.FieldError {
+	var errs []models.FieldError
+	if req.Title != nil {
+		if *req.Title == "" {
+			errs = append(errs, required("/title"))
+		}
+		errs = append(errs, text("/title", "title", *req.Title)...)
+	}
+	if req.Description != nil {
+		errs = append(errs, text("/description", "description", *req.Description)...)
+	}
+	if req.Comment != nil {
+		errs = append(errs, text("/comment", "comment", *req.Comment)...)
+	}
+	if req.Status != nil && !statuses[*req.Status] {
+		errs = append(errs, models.FieldError{Pointer: "/status", Code: "oneof", Message: "must be one of pending, active, completed"})
+	}
+	if req.Visibility != nil && !visibilities[*req.Visibility] {
+		errs = append(errs, visibilityError)
+	}
+	if req.Exercises != nil {
+		errs = append(errs, Exercises("/exercises", req.Exercises, lib)...)
+	}
+	return errs
+}
+
+// Exercises checks the exercises of a workout found at path.
+func Exercises(path string, list []models.WorkoutExerciseRequest, lib Library) []models.FieldError {
+	var errs []models.FieldError
+	if len(list) > MaxExercisesPerWorkout {
+		errs = append(errs, models.FieldError{
+			Pointer: path,
+			Code:    "max",
+			Message: fmt.Sprintf("must have at most %d items", MaxExercisesPerWorkout),
+		})
+	}
+	for i := range list {
+		errs = append(errs, Exercise(fmt.Sprintf("%s/%d", path, i), &list[i], lib)...)
+	}
+	return errs
+}
+
+// PatchWorkout checks the result of patching a workout, looking only at
+// what the patch changed so that older entries recorded before a rule
+// existed do not block unrelated edits.
+func PatchWorkout(old, patched *models.WorkoutState, lib Library) []models.FieldError {
+	req := models.UpdateWorkoutRequest{}
+	if patched.Title != old.Title {
+		req.Title = &patched.Title
+	}
+	if patched.Description != old.Description {
+		req.Description = &patched.Description
+	}
+	if patched.Comment != old.Comment {
+		req.Comment = &patched.Comment
+	}
+	if patched.Status != old.Status {
+		req.Status = &patched.Status
+	}
+	if patched.Visibility != old.Visibility {
+		req.Visibility = &patched.Visibility
+	}
+	errs := UpdateWorkout(&req, lib)
+
+	if len(patched.Exercises) > MaxExercisesPerWorkout && len(patched.Exercises) > len(old.Exercises) {
+		errs = append(errs, models.FieldError{
+			Pointer: "/exercises",
+			Code:    "max",
+			Message: fmt.Sprintf("must have at most %d items", MaxExercisesPerWorkout),
+		})
+	}
+	before := map[int64]models.WorkoutExerciseRequest{}
+	for _, e := range old.Exercises {
+		before[e.ID] = e.WorkoutExerciseRequest
+	}
+	for i := range patched.Exercises {
+		e := &patched.Exercises[i]
+		if prev, ok := before[e.ID]; ok && e.ID != 0 && prev == e.WorkoutExerciseRequest {
+			continue
+		}
+		errs = append(errs, Exercise(fmt.Sprintf("/exercises/%d", i), &e.WorkoutExerciseRequest, lib)...)
+	}
+	return errs
+}
+
+// Exercise checks one exercise entry found at the pointer at.
+func Exercise(at string, e *models.WorkoutExerciseRequest, lib Library) []models.FieldError {
+	var errs []models.FieldError
+	exercise, known := lib[e.ExerciseID]
+	switch {
+	case e.ExerciseID == 0:
+		errs = append(errs, required(at+"/exercise_id"))
+	case !known:
+		errs = append(errs, models.FieldError{
+			Pointer: at + "/exercise_id",
+			Code:    "unknown_exercise",
+			Message: fmt.Sprintf("no exercise with id %d", e.ExerciseID),
+		})
+	}
+
+	values := map[string]float64{}
+	outOfRange := map[string]bool{}
+	for _, r := range exerciseRules {
+		values[r.field] = r.value(e)
+		if err := r.check(at, e); err != nil {
+			outOfRange[r.field] = true
+			errs = append(errs, *err)
+		}
+	}
+	errs = append(errs, text(at+"/notes", "notes", e.Notes)...)
+
+	if !known {
+		return errs
+	}
+	for _, group := range requiredByCategory[exercise.Category] {
+		// An out-of-range value has already been reported.
+		if !anyPositive(values, group) && !outOfRange[group[0]] {
+			errs = append(errs, models.FieldError{
+				Pointer: at + "/" + group[0],
+				Code:    "required",
+				Message: requiredMessage(exercise.Category, group),
+			})
+		}
+	}
+	return errs
+}
+
+// Error turns violations into the validation error handlers report, or
+// nil if there are none.
+func Error(errs []models.FieldError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return database.Invalid("invalid_workout", "workout is invalid", errs...)
+}
+
+var visibilityError = models.FieldError{Pointer: "/visibility", Code: "oneof", Message: "must be one of private, followers, public"}
+
+func required(pointer string) models.FieldError {
+	return models.FieldError{Pointer: pointer, Code: "required", Message: "is required"}
+}
+
+func text(pointer, field, s string) []models.FieldError {
+	if max := textLimits[field]; utf8.RuneCountInString(s) > max {
+		return []models.FieldError{{Pointer: pointer, Code: "max", Message: fmt.Sprintf("must be at most %d characters", max)}}
+	}
+	return nil
+}
+
+func anyPositive(values map[string]float64, fields []string) bool {
+	for _, f := range fields {
+		if values[f] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func requiredMessage(category string, group []string) string {
+	if len(group) == 1 {
+		return fmt.Sprintf("must be greater than 0 for %s exercises", category)
+	}
+	return fmt.Sprintf("%s or %s must be greater than 0 for %s exercises", group[0], group[1], category)
+}
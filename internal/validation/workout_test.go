@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+	"workout-tracker/internal/models"
+)
+
+var lib = NewLibrary([]models.Exercise{
+	{ID: 1, Name: "Bench Press", Category: "strength"},
+	{ID: 2, Name: "Running", Category: "cardio"},
+	{ID: 3, Name: "Hamstring Stretch", Category: "flexibility"},
+})
+
+func pointers(errs []models.FieldError) map[string]string {
+	m := map[string]string{}
+	for _, e := range errs {
+		m[e.Pointer] = e.Code
+	}
+	return m
+}
+
+func TestCreateWorkoutValid(t *testing.T) {
+	req := models.CreateWorkoutRequest{
+		Title: "Mixed",
+		Exercises: []models.WorkoutExerciseRequest{
+			{ExerciseID: 1, Sets: 3, Reps: 5, WeightKg: 100},
+			{ExerciseID: 2, DistanceM: 5000},
+			{ExerciseID: 3, DurationSec: 60},
+		},
+	}
+	if errs := CreateWorkout(&req, lib); len(errs) != 0 {
+		t.Errorf("valid workout rejected: %+v", errs)
+	}
+}
+
+func TestCreateWorkoutReportsEveryViolation(t *testing.T) {
+	req := models.CreateWorkoutRequest{
+		Exercises: []models.WorkoutExerciseRequest{
+			{ExerciseID: 1, Sets: -1, Reps: 0, WeightKg: 10000},
+			{ExerciseID: 2},
+			{ExerciseID: 99, Sets: 1},
+			{Sets: 1},
+		},
+	}
+	got := pointers(CreateWorkout(&req, lib))
+	want := map[string]string{
+		"/title":                    "required",
+		"/exercises/0/sets":         "range",
+		"/exercises/0/reps":         "required",
+		"/exercises/0/weight_kg":    "range",
+		"/exercises/1/duration_sec": "required",
+		"/exercises/2/exercise_id":  "unknown_exercise",
+		"/exercises/3/exercise_id":  "required",
+	}
+	for p, code := range want {
+		if got[p] != code {
+			t.Errorf("%s: code %q, want %q", p, got[p], code)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExerciseLimit(t *testing.T) {
+	list := make([]models.WorkoutExerciseRequest, MaxExercisesPerWorkout+1)
+	for i := range list {
+		list[i] = models.WorkoutExerciseRequest{ExerciseID: 1, Sets: 1, Reps: 1}
+	}
+	if got := pointers(Exercises("/exercises", list, lib)); got["/exercises"] != "max" || len(got) != 1 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestUpdateWorkoutChecksOnlyPresentFields(t *testing.T) {
+	if errs := UpdateWorkout(&models.UpdateWorkoutRequest{}, nil); len(errs) != 0 {
+		t.Errorf("empty update rejected: %+v", errs)
+	}
+	empty, status, long := "", "skipped", strings.Repeat("x", 2001)
+	req := models.UpdateWorkoutRequest{Title: &empty, Status: &status, Comment: &long}
+	got := pointers(UpdateWorkout(&req, nil))
+	if got["/title"] != "required" || got["/status"] != "oneof" || got["/comment"] != "max" || len(got) != 3 {
+		t.Errorf("got %v", got)
+	}
+}
@@ -1,3 +1,6 @@
 @echo off
 echo Running tests...
 go test ./tests/... -v
+echo.
+echo Running tests with the pure-Go SQLite driver...
+go test -tags purego ./tests/... ./internal/...
@@ -8,7 +8,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"workout-tracker/internal/auth"
 	"workout-tracker/internal/database"
 	"workout-tracker/internal/handlers"
 	"workout-tracker/internal/middleware"
@@ -26,18 +28,20 @@ func setupTestRouter() *gin.Engine {
 	seeder.SeedExercises(db)
 
 	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Errors())
 
-	authH := handlers.NewAuthHandler(db)
+	tokens := auth.NewTokens(os.Getenv("JWT_SECRET"), time.Hour)
+	authH := handlers.NewAuthHandler(db, tokens)
 	exerciseH := handlers.NewExerciseHandler(db)
 	workoutH := handlers.NewWorkoutHandler(db)
 
 	r.POST("/auth/register", authH.Register)
 	r.POST("/auth/login", authH.Login)
-	r.GET("/auth/me", middleware.AuthRequired(), authH.Me)
+	r.GET("/auth/me", middleware.AuthRequired(tokens), authH.Me)
 
-	r.GET("/exercises", middleware.AuthRequired(), exerciseH.List)
+	r.GET("/exercises", middleware.AuthRequired(tokens), exerciseH.List)
 
-	wg := r.Group("/workouts", middleware.AuthRequired())
+	wg := r.Group("/workouts", middleware.AuthRequired(tokens))
 	{
 		wg.POST("", workoutH.Create)
 		wg.GET("", workoutH.List)